@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/proxy"
+	"go.uber.org/zap"
+)
+
+func NewCmdCert(logger *zap.Logger) *Cert {
+	return &Cert{
+		logger: logger,
+	}
+}
+
+type Cert struct {
+	logger *zap.Logger
+}
+
+func (c *Cert) GetCmd() *cobra.Command {
+	// manage the CA signing the certificates the proxy serves to the application over TLS
+	var certCmd = &cobra.Command{
+		Use:     "cert",
+		Short:   "export, rotate or replace the CA used by keploy to intercept the TLS connections",
+		Example: "keploy cert export -o ./keploy-ca.crt",
+	}
+
+	var exportCmd = &cobra.Command{
+		Use:     "export",
+		Short:   "write the certificate of the CA to a file",
+		Example: "keploy cert export -o ./keploy-ca.crt",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			output, err := cmd.Flags().GetString("output")
+			if err != nil {
+				c.logger.Error("failed to read the output path")
+				return err
+			}
+			output, err = filepath.Abs(output)
+			if err != nil {
+				c.logger.Error("failed to get the absolute path from relative path", zap.Error(err))
+				return err
+			}
+			return proxy.ExportCA(c.logger, output)
+		},
+	}
+	exportCmd.Flags().StringP("output", "o", "keploy-ca.crt", "Path of the file to write the CA certificate into")
+
+	var rotateCmd = &cobra.Command{
+		Use:     "rotate",
+		Short:   "generate a new CA to replace the current one",
+		Example: "sudo keploy cert rotate",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return proxy.RotateCA(c.logger)
+		},
+	}
+
+	var setCmd = &cobra.Command{
+		Use:     "set",
+		Short:   "sign the certificates served by the proxy with a custom CA",
+		Example: "sudo keploy cert set --cert ./ca.crt --key ./ca.key",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			certPath, err := cmd.Flags().GetString("cert")
+			if err != nil {
+				c.logger.Error("failed to read the CA certificate path")
+				return err
+			}
+			keyPath, err := cmd.Flags().GetString("key")
+			if err != nil {
+				c.logger.Error("failed to read the CA private key path")
+				return err
+			}
+			return proxy.SetCustomCA(c.logger, certPath, keyPath)
+		},
+	}
+	setCmd.Flags().String("cert", "", "PEM encoded certificate of the custom CA")
+	setCmd.Flags().String("key", "", "PEM encoded private key of the custom CA")
+	_ = setCmd.MarkFlagRequired("cert")
+	_ = setCmd.MarkFlagRequired("key")
+
+	var resetCmd = &cobra.Command{
+		Use:     "reset",
+		Short:   "go back to the CA bundled with keploy",
+		Example: "sudo keploy cert reset",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return proxy.ResetCA(c.logger)
+		},
+	}
+
+	for _, sub := range []*cobra.Command{exportCmd, rotateCmd, setCmd, resetCmd} {
+		sub.SilenceUsage = true
+		sub.SilenceErrors = true
+		certCmd.AddCommand(sub)
+	}
+
+	return certCmd
+}
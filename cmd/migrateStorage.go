@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/service/migrateStorage"
+	"go.uber.org/zap"
+)
+
+func NewCmdMigrateStorage(logger *zap.Logger) *MigrateStorage {
+	migrator := migrateStorage.NewMigrator(logger)
+	return &MigrateStorage{
+		migrator: migrator,
+		logger:   logger,
+	}
+}
+
+type MigrateStorage struct {
+	migrator migrateStorage.Migrator
+	logger   *zap.Logger
+}
+
+func (m *MigrateStorage) GetCmd() *cobra.Command {
+	// convert the recorded test-sets and test reports between the storage backends
+	var migrateCmd = &cobra.Command{
+		Use:     "migrate-storage",
+		Short:   "convert the recorded testcases, mocks and test reports between the yaml and sqlite storage",
+		Example: "keploy migrate-storage --from yaml --to sqlite -p /path/to/localdir",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cmd.Flags().GetString("path")
+			if err != nil {
+				m.logger.Error("failed to read the testcase path input")
+				return err
+			}
+
+			from, err := cmd.Flags().GetString("from")
+			if err != nil {
+				m.logger.Error("failed to read the source storage")
+				return err
+			}
+
+			to, err := cmd.Flags().GetString("to")
+			if err != nil {
+				m.logger.Error("failed to read the destination storage")
+				return err
+			}
+
+			if err := validateStorage(from); err != nil {
+				m.logger.Error("", zap.Error(err))
+				return err
+			}
+			if err := validateStorage(to); err != nil {
+				m.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			//if user provides relative path
+			if len(path) > 0 && path[0] != '/' {
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					m.logger.Error("failed to get the absolute path from relative path", zap.Error(err))
+				}
+				path = absPath
+			} else if len(path) == 0 { // if user doesn't provide any path
+				cdirPath, err := os.Getwd()
+				if err != nil {
+					m.logger.Error("failed to get the path of current directory", zap.Error(err))
+				}
+				path = cdirPath
+			}
+
+			path += "/keploy"
+
+			m.logger.Info("", zap.Any("keploy test and mock path", path))
+			return m.migrator.Migrate(path, from, to)
+		},
+	}
+
+	migrateCmd.Flags().StringP("path", "p", "", "Path to the local directory where generated testcases/mocks are stored")
+
+	migrateCmd.Flags().String("from", models.YamlStorage, "Storage backend to read the test-sets from: yaml or sqlite")
+
+	migrateCmd.Flags().String("to", models.SqliteStorage, "Storage backend to write the test-sets into: yaml or sqlite")
+
+	migrateCmd.SilenceUsage = true
+	migrateCmd.SilenceErrors = true
+
+	return migrateCmd
+}
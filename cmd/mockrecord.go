@@ -77,12 +77,22 @@ func (mr *MockRecord) GetCmd() *cobra.Command {
 				return
 			}
 
-			mr.mockRecorder.MockRecord(path, proxyPort, pid, dir, enableTele)
+			server, err := readMockServer(cmd)
+			if err != nil {
+				mr.logger.Error(Emoji+"failed to read the mock server config", zap.Error(err))
+				return
+			}
+			if server == nil && pid == 0 {
+				mr.logger.Error(Emoji + "missing required --pid flag, or --no-ebpf to run without eBPF")
+				return
+			}
+
+			mr.mockRecorder.MockRecord(path, proxyPort, pid, dir, server, enableTele)
 		},
 	}
 
-	serveCmd.Flags().Uint32("pid", 0, "Process id of your application.")
-	serveCmd.MarkFlagRequired("pid")
+	serveCmd.Flags().Uint32("pid", 0, "Process id of your application, not needed with --no-ebpf.")
+	addMockServerFlags(serveCmd)
 
 	serveCmd.Flags().Uint32("proxyport", 0, "Choose a port to run Keploy Proxy.")
 	serveCmd.Flags().Bool("enableTele", true, "Switch for telemetry")
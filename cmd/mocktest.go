@@ -77,12 +77,22 @@ func (s *MockTest) GetCmd() *cobra.Command {
 				return
 			}
 
-			s.mockTester.MockTest(path, proxyPort, pid, dir, enableTele)
+			server, err := readMockServer(cmd)
+			if err != nil {
+				s.logger.Error(Emoji+"failed to read the mock server config", zap.Error(err))
+				return
+			}
+			if server == nil && pid == 0 {
+				s.logger.Error(Emoji + "missing required --pid flag, or --no-ebpf to run without eBPF")
+				return
+			}
+
+			s.mockTester.MockTest(path, proxyPort, pid, dir, server, enableTele)
 		},
 	}
 
-	serveCmd.Flags().Uint32("pid", 0, "Process id of your application.")
-	serveCmd.MarkFlagRequired("pid")
+	serveCmd.Flags().Uint32("pid", 0, "Process id of your application, not needed with --no-ebpf.")
+	addMockServerFlags(serveCmd)
 
 	serveCmd.Flags().StringP("path", "p", "", "Path to local directory where generated testcases/mocks are stored")
 	serveCmd.MarkFlagRequired("path")
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/service/normalize"
+	"go.uber.org/zap"
+)
+
+func NewCmdNormalize(logger *zap.Logger) *Normalize {
+	normalizer := normalize.NewNormalizer(logger)
+	return &Normalize{
+		normalizer: normalizer,
+		logger:     logger,
+	}
+}
+
+type Normalize struct {
+	normalizer normalize.Normalizer
+	logger     *zap.Logger
+}
+
+func (n *Normalize) GetCmd() *cobra.Command {
+	// infer the assertion rules of the recorded testcases from their response bodies
+	var normalizeCmd = &cobra.Command{
+		Use:     "normalize",
+		Short:   "infer the assertion rules for the dynamic fields of the recorded response bodies",
+		Example: "keploy normalize -p /path/to/localdir --testsets test-set-0 --write",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cmd.Flags().GetString("path")
+			if err != nil {
+				n.logger.Error("failed to read the testcase path input")
+				return err
+			}
+
+			testSets, err := cmd.Flags().GetStringSlice("testsets")
+			if err != nil {
+				n.logger.Error("failed to read the testsets")
+				return err
+			}
+
+			write, err := cmd.Flags().GetBool("write")
+			if err != nil {
+				n.logger.Error("failed to read the write flag")
+				return err
+			}
+
+			//if user provides relative path
+			if len(path) > 0 && path[0] != '/' {
+				absPath, err := filepath.Abs(path)
+				if err != nil {
+					n.logger.Error("failed to get the absolute path from relative path", zap.Error(err))
+				}
+				path = absPath
+			} else if len(path) == 0 { // if user doesn't provide any path
+				cdirPath, err := os.Getwd()
+				if err != nil {
+					n.logger.Error("failed to get the path of current directory", zap.Error(err))
+				}
+				path = cdirPath
+			}
+
+			path += "/keploy"
+
+			n.logger.Info("", zap.Any("keploy test and mock path", path))
+			return n.normalizer.Normalize(path, testSets, write)
+		},
+	}
+
+	normalizeCmd.Flags().StringP("path", "p", "", "Path to the local directory where generated testcases/mocks are stored")
+
+	normalizeCmd.Flags().StringSliceP("testsets", "t", []string{}, "Testsets to normalize e.g. --testsets \"test-set-1, test-set-2\"")
+
+	normalizeCmd.Flags().Bool("write", false, "Write the inferred rules into the assertions of the testcase files")
+
+	normalizeCmd.SilenceUsage = true
+	normalizeCmd.SilenceErrors = true
+
+	return normalizeCmd
+}
@@ -41,7 +41,7 @@ func readRecordConfig(configPath string) (*models.Record, error) {
 
 var filters = models.Filters{}
 
-func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, configPath string) error {
+func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, passThrough *[]models.PassThroughRule, protocolPorts *models.ProtocolPorts, storage *string, configPath string) error {
 	configFilePath := filepath.Join(configPath, "keploy-config.yaml")
 	if isExist := utils.CheckFileExists(configFilePath); !isExist {
 		return errFileNotFound
@@ -75,6 +75,11 @@ func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string
 	if len(*passThroughPorts) == 0 {
 		*passThroughPorts = confRecord.PassThroughPorts
 	}
+	*passThrough = confRecord.PassThrough
+	*protocolPorts = confRecord.ProtocolPorts
+	if *storage == "" {
+		*storage = confRecord.Storage
+	}
 	return nil
 }
 
@@ -128,6 +133,18 @@ func (r *Record) GetCmd() *cobra.Command {
 				return err
 			}
 
+			dnsTimeout, err := cmd.Flags().GetDuration("dns-timeout")
+			if err != nil {
+				r.logger.Error("failed to read the dns timeout", zap.Error(err))
+				return err
+			}
+
+			grpcSchema, err := readGrpcSchema(cmd)
+			if err != nil {
+				r.logger.Error("failed to read the grpc schema flags", zap.Error(err))
+				return err
+			}
+
 			ports, err := cmd.Flags().GetUintSlice("passThroughPorts")
 			if err != nil {
 				r.logger.Error("failed to read the ports of outgoing calls to be ignored")
@@ -146,13 +163,21 @@ func (r *Record) GetCmd() *cobra.Command {
 				return err
 			}
 
+			storage, err := cmd.Flags().GetString("storage")
+			if err != nil {
+				r.logger.Error("failed to read the storage backend")
+				return err
+			}
+
 			enableTele, err := cmd.Flags().GetBool("enableTele")
 			if err != nil {
 				r.logger.Error("failed to read the disable telemetry flag")
 				return err
 			}
 
-			err = r.GetRecordConfig(&path, &proxyPort, &appCmd, &appContainer, &networkName, &delay, &buildDelay, &ports, configPath)
+			var passThrough []models.PassThroughRule
+			var protocolPorts models.ProtocolPorts
+			err = r.GetRecordConfig(&path, &proxyPort, &appCmd, &appContainer, &networkName, &delay, &buildDelay, &ports, &passThrough, &protocolPorts, &storage, configPath)
 			if err != nil {
 				if err == errFileNotFound {
 					r.logger.Info("continuing without configuration file because file not found")
@@ -161,6 +186,21 @@ func (r *Record) GetCmd() *cobra.Command {
 				}
 			}
 
+			if err := validateStorage(storage); err != nil {
+				r.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validatePassThrough(passThrough); err != nil {
+				r.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validateProtocolPorts(protocolPorts); err != nil {
+				r.logger.Error("", zap.Error(err))
+				return err
+			}
+
 			if appCmd == "" {
 				r.logger.Error("missing required -c flag or appCmd in config file")
 				if isDockerCmd {
@@ -210,7 +250,7 @@ func (r *Record) GetCmd() *cobra.Command {
 			}
 
 			r.logger.Debug("the ports are", zap.Any("ports", ports))
-			r.recorder.CaptureTraffic(path, proxyPort, appCmd, appContainer, networkName, delay, buildDelay, ports, &filters, enableTele)
+			r.recorder.CaptureTraffic(path, proxyPort, appCmd, appContainer, networkName, delay, buildDelay, dnsTimeout, ports, passThrough, protocolPorts, &filters, storage, grpcSchema, enableTele)
 			return nil
 		},
 	}
@@ -229,10 +269,18 @@ func (r *Record) GetCmd() *cobra.Command {
 
 	recordCmd.Flags().DurationP("buildDelay", "", 30*time.Second, "User provided time to wait docker container build")
 
+	recordCmd.Flags().Duration("dns-timeout", time.Second, "Time given to the upstream nameservers to resolve a dns query of the application")
+
+	addGrpcSchemaFlags(recordCmd)
+
+	recordCmd.Flags().Bool("grpc-reflection", false, "Ask the gRPC servers for the descriptors of their services with server reflection")
+
 	recordCmd.Flags().UintSlice("passThroughPorts", []uint{}, "Ports of Outgoing dependency calls to be ignored as mocks")
 
 	recordCmd.Flags().String("config-path", ".", "Path to the local directory where keploy configuration file is stored")
 
+	recordCmd.Flags().String("storage", "", "Storage backend for the generated testcases/mocks: yaml or sqlite (default yaml)")
+
 	recordCmd.Flags().Bool("enableTele", true, "Switch for telemetry")
 	recordCmd.Flags().MarkHidden("enableTele")
 
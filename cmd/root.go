@@ -3,28 +3,164 @@ package cmd
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/TheZeroSlave/zapsentry"
 	sentry "github.com/getsentry/sentry-go"
 	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/models"
 	"go.keploy.io/server/pkg/platform/fs"
 	"go.keploy.io/server/utils"
 	"go.uber.org/zap"
 	"go.uber.org/zap/buffer"
 	"go.uber.org/zap/zapcore"
+	yamlLib "gopkg.in/yaml.v3"
 )
 
 var Emoji = "\U0001F430" + " Keploy:"
 
 var errFileNotFound = errors.New("fileNotFound")
 
+// validateStorage checks the storage backend chosen with the --storage flag or the config file.
+func validateStorage(storage string) error {
+	switch storage {
+	case "", models.YamlStorage, models.SqliteStorage:
+		return nil
+	}
+	return fmt.Errorf("invalid storage backend %q, should be one of %s or %s", storage, models.YamlStorage, models.SqliteStorage)
+}
+
+// validatePassThrough checks the pass-through rules read from the config file.
+func validatePassThrough(rules []models.PassThroughRule) error {
+	for i, rule := range rules {
+		if rule.IP == "" && rule.Host == "" && rule.Path == "" && rule.Protocol == "" {
+			return fmt.Errorf("pass-through rule %d should match on at least one of ip, host, path or protocol", i)
+		}
+		if rule.IP != "" && net.ParseIP(rule.IP) == nil {
+			if _, _, err := net.ParseCIDR(rule.IP); err != nil {
+				return fmt.Errorf("invalid ip %q of pass-through rule %d, should be an address or a CIDR block", rule.IP, i)
+			}
+		}
+		if rule.Path != "" && !strings.HasPrefix(rule.Path, "/") {
+			return fmt.Errorf("path %q of pass-through rule %d should start with /", rule.Path, i)
+		}
+		switch rule.Protocol {
+		case "", "http", "grpc", "mongo", "postgres", "mysql", "redis", "kafka", "generic":
+		default:
+			return fmt.Errorf("unknown protocol %q of pass-through rule %d", rule.Protocol, i)
+		}
+	}
+	return nil
+}
+
+// validateProtocolPorts checks the protocols of the ports read from the config file.
+func validateProtocolPorts(protocolPorts models.ProtocolPorts) error {
+	for port, protocol := range protocolPorts {
+		if port == 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d in protocolPorts", port)
+		}
+		switch protocol {
+		case "http", "grpc", "mongo", "postgres", "mysql", "redis", "kafka":
+		default:
+			return fmt.Errorf("unknown protocol %q of port %d in protocolPorts", protocol, port)
+		}
+	}
+	return nil
+}
+
+// addMockServerFlags adds the flags running the proxy without eBPF.
+func addMockServerFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("no-ebpf", false, "Run without eBPF, the application reaches the proxy through HTTP_PROXY/HTTPS_PROXY and the dependency listeners")
+	cmd.Flags().StringSlice("listen", []string{}, "Listener of a dependency as <local address>=<dependency address>, e.g. localhost:15432=postgres:5432")
+	cmd.Flags().String("config-path", ".", "Path to the local directory where keploy configuration file is stored")
+}
+
+// readMockServer returns the config of the proxy running without eBPF, or nil when the eBPF hooks are used. The
+// listeners of the --listen flag are added to the ones of the config file.
+func readMockServer(cmd *cobra.Command) (*models.MockServer, error) {
+	noEbpf, err := cmd.Flags().GetBool("no-ebpf")
+	if err != nil || !noEbpf {
+		return nil, err
+	}
+	configPath, err := cmd.Flags().GetString("config-path")
+	if err != nil {
+		return nil, err
+	}
+	listens, err := cmd.Flags().GetStringSlice("listen")
+	if err != nil {
+		return nil, err
+	}
+
+	server := &models.MockServer{}
+	if configFilePath := filepath.Join(configPath, "keploy-config.yaml"); utils.CheckFileExists(configFilePath) {
+		file, err := os.OpenFile(configFilePath, os.O_RDONLY, os.ModePerm)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		var doc models.Config
+		if err := yamlLib.NewDecoder(file).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to get the mock server config from config file due to error: %s", err)
+		}
+		server.Listeners = doc.MockServer.Listeners
+	}
+	for _, listen := range listens {
+		local, target, ok := strings.Cut(listen, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid listener %q, should be <local address>=<dependency address>", listen)
+		}
+		server.Listeners = append(server.Listeners, models.DependencyListener{Listen: local, Target: target})
+	}
+	for _, listener := range server.Listeners {
+		if _, _, err := net.SplitHostPort(listener.Listen); err != nil {
+			return nil, fmt.Errorf("invalid listen address %q: %v", listener.Listen, err)
+		}
+		if _, _, err := net.SplitHostPort(listener.Target); err != nil {
+			return nil, fmt.Errorf("invalid target address %q of %s: %v", listener.Target, listener.Listen, err)
+		}
+	}
+	return server, nil
+}
+
+// addGrpcSchemaFlags adds the flags pointing at the descriptors of the gRPC services.
+func addGrpcSchemaFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("proto-file", []string{}, "Proto files of the gRPC services, compiled with protoc to store their messages as protojson")
+	cmd.Flags().StringSlice("proto-include", []string{}, "Directories where protoc looks up the imports of the proto files")
+	cmd.Flags().StringSlice("proto-descriptor-set", []string{}, "Descriptor sets of the gRPC services, as written by protoc --descriptor_set_out")
+}
+
+func readGrpcSchema(cmd *cobra.Command) (models.GrpcSchema, error) {
+	var (
+		schema models.GrpcSchema
+		err    error
+	)
+	if schema.ProtoFiles, err = cmd.Flags().GetStringSlice("proto-file"); err != nil {
+		return schema, err
+	}
+	if schema.ProtoIncludes, err = cmd.Flags().GetStringSlice("proto-include"); err != nil {
+		return schema, err
+	}
+	if schema.DescriptorSets, err = cmd.Flags().GetStringSlice("proto-descriptor-set"); err != nil {
+		return schema, err
+	}
+	if cmd.Flags().Lookup("grpc-reflection") != nil {
+		if schema.Reflection, err = cmd.Flags().GetBool("grpc-reflection"); err != nil {
+			return schema, err
+		}
+	}
+	return schema, nil
+}
+
 type Root struct {
 	logger *zap.Logger
 	// subCommands holds a list of registered plugins.
@@ -252,7 +388,7 @@ func (r *Root) execute() {
 	r.logger = setupLogger()
 	r.logger = modifyToSentryLogger(r.logger, sentry.CurrentHub().Client())
 	defer deleteLogs(r.logger)
-	r.subCommands = append(r.subCommands, NewCmdRecord(r.logger), NewCmdTest(r.logger), NewCmdServe(r.logger), NewCmdExample(r.logger), NewCmdMockRecord(r.logger), NewCmdMockTest(r.logger), NewCmdGenerateConfig(r.logger))
+	r.subCommands = append(r.subCommands, NewCmdRecord(r.logger), NewCmdTest(r.logger), NewCmdServe(r.logger), NewCmdExample(r.logger), NewCmdMockRecord(r.logger), NewCmdMockTest(r.logger), NewCmdGenerateConfig(r.logger), NewCmdMigrateStorage(r.logger), NewCmdNormalize(r.logger), NewCmdUpdate(r.logger), NewCmdCert(r.logger))
 
 	// add the registered keploy plugins as subcommands to the rootCmd
 	for _, sc := range r.subCommands {
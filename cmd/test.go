@@ -5,11 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform/export"
 	"go.keploy.io/server/pkg/service/test"
 	"go.keploy.io/server/utils"
 	"go.uber.org/zap"
@@ -39,7 +41,7 @@ func readTestConfig(configPath string) (*models.Test, error) {
 	return &doc.Test, nil
 }
 
-func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, tests *map[string][]string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThorughPorts *[]uint, apiTimeout *uint64, globalNoise *models.GlobalNoise, testSetNoise *models.TestsetNoise, coverageReportPath *string, withCoverage *bool, configPath string) error {
+func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, tests *map[string][]string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThorughPorts *[]uint, passThrough *[]models.PassThroughRule, apiTimeout *uint64, globalNoise *models.GlobalNoise, testSetNoise *models.TestsetNoise, coverageReportPath *string, withCoverage *bool, storage *string, latency *models.LatencyConfig, assertions *models.AssertionConfig, mockMatching *models.MockMatching, protocolPorts *models.ProtocolPorts, configPath string) error {
 	configFilePath := filepath.Join(configPath, "keploy-config.yaml")
 	if isExist := utils.CheckFileExists(configFilePath); !isExist {
 		return errFileNotFound
@@ -77,6 +79,7 @@ func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, te
 	if len(*passThorughPorts) == 0 {
 		*passThorughPorts = confTest.PassThroughPorts
 	}
+	*passThrough = confTest.PassThrough
 	if len(*coverageReportPath) == 0 {
 		*coverageReportPath = confTest.CoverageReportPath
 	}
@@ -84,11 +87,74 @@ func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, te
 	if *apiTimeout == 5 {
 		*apiTimeout = confTest.ApiTimeout
 	}
+	if *storage == "" {
+		*storage = confTest.Storage
+	}
+	*latency = confTest.Latency
+	*assertions = confTest.Assertions
+	*mockMatching = confTest.MockMatching
+	*protocolPorts = confTest.ProtocolPorts
 	*globalNoise = confTest.GlobalNoise.Global
 	*testSetNoise = confTest.GlobalNoise.Testsets
 	return nil
 }
 
+// validateMockMatching checks the scoring of the HTTP mocks and the matching of the postgres mocks read from the
+// config file.
+func validateMockMatching(mockMatching models.MockMatching) error {
+	if mockMatching.Threshold < 0 || mockMatching.Threshold > 1 {
+		return fmt.Errorf("the threshold of mockMatching must be between 0 and 1, got %v", mockMatching.Threshold)
+	}
+	for i, param := range mockMatching.Postgres.IgnoreParams {
+		if param.Position < 0 {
+			return fmt.Errorf("the position of the ignored postgres param %d must not be negative, got %d", i, param.Position)
+		}
+		if _, err := regexp.Compile(param.Query); err != nil {
+			return fmt.Errorf("invalid query of the ignored postgres param %d: %v", i, err)
+		}
+		if _, err := regexp.Compile(param.Value); err != nil {
+			return fmt.Errorf("invalid value of the ignored postgres param %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// validateAssertions checks the assertion rules read from the config file.
+func validateAssertions(assertions models.AssertionConfig) error {
+	ruleSets := map[string]models.AssertionRules{"global": assertions.Global}
+	for testSet, rules := range assertions.Testsets {
+		ruleSets[testSet] = rules
+	}
+	for name, rules := range ruleSets {
+		if err := rules.Validate(); err != nil {
+			return fmt.Errorf("invalid assertion rules of %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// validateLatency checks the latency budgets read from the config file.
+func validateLatency(latency models.LatencyConfig) error {
+	budgets := map[string]models.LatencyBudget{"global": latency.Global}
+	for testSet, budget := range latency.Testsets {
+		budgets[testSet] = budget
+	}
+	for name, budget := range budgets {
+		if budget.Ratio < 0 || budget.Absolute < 0 {
+			return fmt.Errorf("latency budget of %s should not be negative", name)
+		}
+		if budget.Ratio != 0 && budget.Ratio < 1 {
+			return fmt.Errorf("latency ratio of %s should be at least 1, got %v", name, budget.Ratio)
+		}
+		switch budget.Mode {
+		case "", models.LatencyModeFail, models.LatencyModeWarn:
+		default:
+			return fmt.Errorf("invalid latency mode %q for %s, should be one of %s or %s", budget.Mode, name, models.LatencyModeFail, models.LatencyModeWarn)
+		}
+	}
+	return nil
+}
+
 type Test struct {
 	tester test.Tester
 	logger *zap.Logger
@@ -172,12 +238,92 @@ func (t *Test) GetCmd() *cobra.Command {
 				return err
 			}
 
+			storage, err := cmd.Flags().GetString("storage")
+			if err != nil {
+				t.logger.Error("failed to read the storage backend")
+				return err
+			}
+
 			enableTele, err := cmd.Flags().GetBool("enableTele")
 			if err != nil {
 				t.logger.Error("failed to read the disable telemetry flag")
 				return err
 			}
 
+			reportFormats, err := cmd.Flags().GetStringSlice("report-format")
+			if err != nil {
+				t.logger.Error("failed to read the report formats")
+				return err
+			}
+			for _, format := range reportFormats {
+				if _, err := export.NewExporter(format); err != nil {
+					t.logger.Error("", zap.Error(err))
+					return err
+				}
+			}
+
+			reportDir, err := cmd.Flags().GetString("report-dir")
+			if err != nil {
+				t.logger.Error("failed to read the report directory")
+				return err
+			}
+
+			parallel, err := cmd.Flags().GetInt("parallel")
+			if err != nil {
+				t.logger.Error("failed to read the number of parallel test-sets")
+				return err
+			}
+			if parallel < 0 {
+				t.logger.Error("the number of parallel test-sets should not be negative", zap.Int("parallel", parallel))
+				return errors.New("invalid value for parallel")
+			}
+
+			// keploy update runs this command with the flags to select the testcases to re-baseline
+			var update *test.UpdateOptions
+			if cmd.Name() == "update" {
+				update, err = readUpdateOptions(cmd)
+				if err != nil {
+					t.logger.Error("failed to read the update options", zap.Error(err))
+					return err
+				}
+			}
+
+			detectNoise, err := cmd.Flags().GetBool("detect-noise")
+			if err != nil {
+				t.logger.Error("failed to read the detect-noise flag")
+				return err
+			}
+
+			noiseRuns, err := cmd.Flags().GetInt("noise-runs")
+			if err != nil {
+				t.logger.Error("failed to read the number of noise detection runs")
+				return err
+			}
+			if !detectNoise {
+				noiseRuns = 0
+			} else if noiseRuns < 2 {
+				t.logger.Error("noise detection needs at least 2 runs of every testcase", zap.Int("noise-runs", noiseRuns))
+				return errors.New("noise-runs should be at least 2")
+			}
+
+			dnsTimeout, err := cmd.Flags().GetDuration("dns-timeout")
+			if err != nil {
+				t.logger.Error("failed to read the dns timeout")
+				return err
+			}
+
+			grpcSchema, err := readGrpcSchema(cmd)
+			if err != nil {
+				t.logger.Error("failed to read the grpc schema flags", zap.Error(err))
+				return err
+			}
+
+			pruneUnusedMocks, err := cmd.Flags().GetBool("prune-unused-mocks")
+			if err != nil {
+				t.logger.Error("failed to read the prune-unused-mocks flag")
+				return err
+			}
+
 			tests := map[string][]string{}
 
 			testsets, err := cmd.Flags().GetStringSlice("testsets")
@@ -192,8 +338,13 @@ func (t *Test) GetCmd() *cobra.Command {
 
 			globalNoise := make(models.GlobalNoise)
 			testsetNoise := make(models.TestsetNoise)
+			latency := models.LatencyConfig{}
+			assertions := models.AssertionConfig{}
+			mockMatching := models.MockMatching{}
+			var passThrough []models.PassThroughRule
+			var protocolPorts models.ProtocolPorts
 
-			err = t.getTestConfig(&path, &proxyPort, &appCmd, &tests, &appContainer, &networkName, &delay, &buildDelay, &ports, &apiTimeout, &globalNoise, &testsetNoise, &coverageReportPath, &withCoverage, configPath)
+			err = t.getTestConfig(&path, &proxyPort, &appCmd, &tests, &appContainer, &networkName, &delay, &buildDelay, &ports, &passThrough, &apiTimeout, &globalNoise, &testsetNoise, &coverageReportPath, &withCoverage, &storage, &latency, &assertions, &mockMatching, &protocolPorts, configPath)
 			if err != nil {
 				if err == errFileNotFound {
 					t.logger.Info("continuing without configuration file because file not found")
@@ -202,6 +353,36 @@ func (t *Test) GetCmd() *cobra.Command {
 				}
 			}
 
+			if err := validateStorage(storage); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validateLatency(latency); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validateAssertions(assertions); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validatePassThrough(passThrough); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validateMockMatching(mockMatching); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
+			if err := validateProtocolPorts(protocolPorts); err != nil {
+				t.logger.Error("", zap.Error(err))
+				return err
+			}
+
 			if appCmd == "" {
 				t.logger.Error("Couldn't find appCmd")
 				if isDockerCmd {
@@ -247,6 +428,14 @@ func (t *Test) GetCmd() *cobra.Command {
 
 			testReportPath := path + "/testReports"
 
+			if len(reportDir) > 0 && reportDir[0] != '/' {
+				absPath, err := filepath.Abs(reportDir)
+				if err != nil {
+					t.logger.Error("failed to get the absolute path of the report directory", zap.Error(err))
+				}
+				reportDir = absPath
+			}
+
 			t.logger.Info("", zap.Any("keploy test and mock path", path), zap.Any("keploy testReport path", testReportPath))
 
 			var hasContainerName bool
@@ -278,12 +467,26 @@ func (t *Test) GetCmd() *cobra.Command {
 				Delay:              delay,
 				BuildDelay:         buildDelay,
 				PassThroughPorts:   ports,
+				PassThrough:        passThrough,
 				ApiTimeout:         apiTimeout,
 				ProxyPort:          proxyPort,
 				GlobalNoise:        globalNoise,
 				TestsetNoise:       testsetNoise,
 				WithCoverage:       withCoverage,
 				CoverageReportPath: coverageReportPath,
+				Storage:            storage,
+				ReportFormats:      reportFormats,
+				ReportDir:          reportDir,
+				Latency:            latency,
+				Assertions:         assertions,
+				MockMatching:       mockMatching,
+				ProtocolPorts:      protocolPorts,
+				NoiseRuns:          noiseRuns,
+				Update:             update,
+				Parallel:           parallel,
+				PruneUnusedMocks:   pruneUnusedMocks,
+				DnsTimeout:         dnsTimeout,
+				GrpcSchema:         grpcSchema,
 			}, enableTele)
 
 			return nil
@@ -307,10 +510,28 @@ func (t *Test) GetCmd() *cobra.Command {
 
 	testCmd.Flags().Uint64("apiTimeout", 5, "User provided timeout for calling its application")
 
+	testCmd.Flags().Duration("dns-timeout", time.Second, "Time given to the upstream nameservers to resolve a dns query of the application")
+
+	addGrpcSchemaFlags(testCmd)
+
 	testCmd.Flags().UintSlice("passThroughPorts", []uint{}, "Ports of Outgoing dependency calls to be ignored as mocks")
 
 	testCmd.Flags().String("config-path", ".", "Path to the local directory where keploy configuration file is stored")
 
+	testCmd.Flags().String("storage", "", "Storage backend of the recorded testcases/mocks: yaml or sqlite (default yaml)")
+
+	testCmd.Flags().StringSlice("report-format", []string{}, "Export the test reports for CI in the given formats e.g. --report-format junit,json")
+
+	testCmd.Flags().String("report-dir", "", "Path to the local directory where the exported test reports are stored (default <path>/keploy/testReports)")
+
+	testCmd.Flags().Int("parallel", 0, "Number of test-sets replayed at the same time, each on its own instance of the application listening on the recorded port + instance index (passed as {port} in the command and KEPLOY_APP_PORT)")
+
+	testCmd.Flags().Bool("detect-noise", false, "Replay every testcase several times against the same mocks and add the fields that change between the runs to its noise")
+
+	testCmd.Flags().Int("noise-runs", 2, "Number of times every testcase is replayed with --detect-noise")
+
+	testCmd.Flags().Bool("prune-unused-mocks", false, "Remove the mocks which were never consumed from the mocks of the test-sets whose testcases all passed")
+
 	testCmd.Flags().String("mongoPassword", "default123", "Authentication password for mocking MongoDB connection")
 
 	testCmd.Flags().String("coverageReportPath", "", "Write a go coverage profile to the file in the given directory.")
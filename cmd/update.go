@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"regexp"
+
+	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/service/test"
+	"go.uber.org/zap"
+)
+
+func NewCmdUpdate(logger *zap.Logger) *Update {
+	return &Update{
+		test:   NewCmdTest(logger),
+		logger: logger,
+	}
+}
+
+type Update struct {
+	test   *Test
+	logger *zap.Logger
+}
+
+func (u *Update) GetCmd() *cobra.Command {
+	// update replays the test-sets like keploy test and rewrites the expected response of the failing testcases
+	updateCmd := u.test.GetCmd()
+	updateCmd.Use = "update"
+	updateCmd.Short = "replay the recorded testcases and re-baseline the expected response of the failing ones"
+	updateCmd.Example = `sudo -E env PATH=$PATH keploy update -c "/path/to/user/app" --testsets test-set-0 --filter "/users" --yes`
+
+	updateCmd.Flags().BoolP("yes", "y", false, "Update every failing testcase that matches the filter without asking")
+
+	updateCmd.Flags().String("filter", "", "Regex over the testcase name or request URL to select the testcases to update")
+
+	return updateCmd
+}
+
+func readUpdateOptions(cmd *cobra.Command) (*test.UpdateOptions, error) {
+	yes, err := cmd.Flags().GetBool("yes")
+	if err != nil {
+		return nil, err
+	}
+	filter, err := cmd.Flags().GetString("filter")
+	if err != nil {
+		return nil, err
+	}
+	update := &test.UpdateOptions{Yes: yes}
+	if filter != "" {
+		update.Filter, err = regexp.Compile(filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return update, nil
+}
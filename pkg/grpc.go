@@ -0,0 +1,341 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/protocolbuffers/protoscope"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Http2Preface is sent by the client at the start of every HTTP/2 connection.
+const Http2Preface = http2.ClientPreface
+
+// initialHeaderTableSize is the size of the hpack dynamic table until the peers agree on another one.
+const initialHeaderTableSize = 4096
+
+func CreateLengthPrefixedMessageFromPayload(data []byte) models.GrpcLengthPrefixedMessage {
+	msg := models.GrpcLengthPrefixedMessage{}
+
+	// If the body is not length prefixed, we return the default value.
+	if len(data) < 5 {
+		return msg
+	}
+
+	// The first byte is the compression flag.
+	msg.CompressionFlag = uint(data[0])
+
+	// The next 4 bytes are message length.
+	msg.MessageLength = binary.BigEndian.Uint32(data[1:5])
+
+	// The payload could be empty. We only parse it if it is present.
+	if len(data) >= 5 {
+		// Use protoscope to decode the message.
+		msg.DecodedData = protoscope.Write(data[5:], protoscope.WriterOptions{})
+	}
+
+	return msg
+}
+
+func CreatePayloadFromLengthPrefixedMessage(msg models.GrpcLengthPrefixedMessage) ([]byte, error) {
+	var encodedData []byte
+	var err error
+	if msg.Schema != "" {
+		encodedData, err = encodeGrpcMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		scanner := protoscope.NewScanner(msg.DecodedData)
+		encodedData, err = scanner.Exec()
+		if err != nil {
+			return nil, fmt.Errorf("could not encode grpc msg using protoscope: %v", err)
+		}
+	}
+
+	// Note that the encoded length is present in the msg, but it is also equal to the len of encodedData.
+	// We should give the preference to the length of encodedData, since the mocks might have been altered.
+
+	// Reserve 1 byte for compression flag, 4 bytes for length capture.
+	payload := make([]byte, 1+4)
+	payload[0] = uint8(msg.CompressionFlag)
+	binary.BigEndian.PutUint32(payload[1:5], uint32(len(encodedData)))
+	payload = append(payload, encodedData...)
+
+	return payload, nil
+}
+
+func ExtractHeaders(frame *http2.HeadersFrame, decoder *hpack.Decoder) (pseudoHeaders, ordinaryHeaders map[string]string, err error) {
+	hf, err := decoder.DecodeFull(frame.HeaderBlockFragment())
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode headers: %v", err)
+	}
+
+	pseudoHeaders = make(map[string]string)
+	ordinaryHeaders = make(map[string]string)
+
+	for _, header := range hf {
+		if header.IsPseudo() {
+			pseudoHeaders[header.Name] = header.Value
+		} else {
+			ordinaryHeaders[header.Name] = header.Value
+		}
+	}
+
+	return pseudoHeaders, ordinaryHeaders, nil
+}
+
+// IsHttp2Connection reports whether the bytes read by the application start an HTTP/2 connection.
+func IsHttp2Connection(requestBuf []byte) bool {
+	return bytes.HasPrefix(requestBuf, []byte(Http2Preface))
+}
+
+// grpcCallStream holds a call of the connection until the application ends its response.
+type grpcCallStream struct {
+	stream      models.GrpcStream
+	reqBody     []byte
+	respBody    []byte
+	respHeaders bool
+}
+
+// http2Reader decodes the frames sent by one peer of an HTTP/2 connection. The bytes may be fed
+// in chunks of any size, the frames are only read once they are complete.
+type http2Reader struct {
+	buf     bytes.Buffer
+	framer  *http2.Framer
+	decoder *hpack.Decoder
+}
+
+func newHttp2Reader() *http2Reader {
+	r := &http2Reader{decoder: hpack.NewDecoder(initialHeaderTableSize, nil)}
+	r.framer = http2.NewFramer(nil, &r.buf)
+	r.framer.SetMaxReadFrameSize(1<<24 - 1)
+	return r
+}
+
+// feed reads the frames completed by data and hands them to handle. The frames are only valid
+// until handle returns, the framer reuses its buffer for the next one. A frame which can't be
+// read is skipped and the first error is returned once the complete frames are read.
+func (r *http2Reader) feed(data []byte, handle func(frame http2.Frame)) error {
+	var firstErr error
+	r.buf.Write(data)
+	for r.buf.Len() >= 9 {
+		header := r.buf.Bytes()[:3]
+		length := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+		if r.buf.Len() < 9+length {
+			break
+		}
+		frame, err := r.framer.ReadFrame()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		handle(frame)
+	}
+	return firstErr
+}
+
+// GrpcCapture assembles the gRPC calls of an HTTP/2 ingress connection from the bytes which the
+// application reads and writes on it. The hpack tables of both peers live as long as the connection.
+type GrpcCapture struct {
+	requests  *http2Reader
+	responses *http2Reader
+	streams   map[uint32]*grpcCallStream
+	preface   bool
+	logger    *zap.Logger
+}
+
+// NewGrpcCapture starts capturing the gRPC calls of an HTTP/2 connection.
+func NewGrpcCapture(logger *zap.Logger) *GrpcCapture {
+	return &GrpcCapture{
+		requests:  newHttp2Reader(),
+		responses: newHttp2Reader(),
+		streams:   map[uint32]*grpcCallStream{},
+		logger:    logger,
+	}
+}
+
+// Capture feeds the bytes exchanged on the connection since the previous call and returns the
+// calls whose response ended with them.
+func (c *GrpcCapture) Capture(requestBuf, responseBuf []byte, reqTimestamp, resTimestamp time.Time) []models.GrpcStream {
+	if !c.preface {
+		if !IsHttp2Connection(requestBuf) {
+			c.logger.Debug("the connection does not start with the http2 preface")
+			return nil
+		}
+		requestBuf = requestBuf[len(Http2Preface):]
+		c.preface = true
+	}
+
+	// the responses of the streams can't be sent before their requests, so the bytes read by the
+	// application are handled first
+	err := c.requests.feed(requestBuf, func(frame http2.Frame) {
+		c.handleRequestFrame(frame, reqTimestamp)
+	})
+	if err != nil {
+		c.logger.Error("failed to read the http2 frames of the grpc requests", zap.Error(err))
+	}
+
+	calls := []models.GrpcStream{}
+	err = c.responses.feed(responseBuf, func(frame http2.Frame) {
+		if call, ended := c.handleResponseFrame(frame, resTimestamp); ended {
+			calls = append(calls, call)
+		}
+	})
+	if err != nil {
+		c.logger.Error("failed to read the http2 frames of the grpc responses", zap.Error(err))
+	}
+	return calls
+}
+
+func (c *GrpcCapture) handleRequestFrame(frame http2.Frame, timestamp time.Time) {
+	streamID := frame.Header().StreamID
+	switch f := frame.(type) {
+	case *http2.HeadersFrame:
+		// headers frames longer than a frame are continued by continuation frames, which are
+		// not decoded, as for the outgoing grpc calls
+		pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(f, c.requests.decoder)
+		if err != nil {
+			c.logger.Error("failed to decode the headers of the grpc request", zap.Error(err), zap.Any("stream", streamID))
+			return
+		}
+		stream := models.NewGrpcStream(streamID)
+		stream.GrpcReq.Headers.PseudoHeaders = pseudoHeaders
+		stream.GrpcReq.Headers.OrdinaryHeaders = ordinaryHeaders
+		stream.GrpcReq.Timestamp = timestamp
+		c.streams[streamID] = &grpcCallStream{stream: stream}
+	case *http2.DataFrame:
+		if s, ok := c.streams[streamID]; ok {
+			s.reqBody = append(s.reqBody, f.Data()...)
+		}
+	case *http2.RSTStreamFrame:
+		delete(c.streams, streamID)
+	}
+}
+
+// handleResponseFrame returns the call of the stream and true once the response of the stream ends.
+func (c *GrpcCapture) handleResponseFrame(frame http2.Frame, timestamp time.Time) (models.GrpcStream, bool) {
+	streamID := frame.Header().StreamID
+	switch f := frame.(type) {
+	case *http2.HeadersFrame:
+		pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(f, c.responses.decoder)
+		if err != nil {
+			c.logger.Error("failed to decode the headers of the grpc response", zap.Error(err), zap.Any("stream", streamID))
+			return models.GrpcStream{}, false
+		}
+		s, ok := c.streams[streamID]
+		if !ok {
+			return models.GrpcStream{}, false
+		}
+		switch {
+		case !s.respHeaders:
+			s.respHeaders = true
+			s.stream.GrpcResp.Headers.PseudoHeaders = pseudoHeaders
+			if f.StreamEnded() {
+				// a trailers-only response carries the status of the call in its headers
+				s.stream.GrpcResp.Trailers.OrdinaryHeaders = ordinaryHeaders
+			} else {
+				s.stream.GrpcResp.Headers.OrdinaryHeaders = ordinaryHeaders
+			}
+		default:
+			s.stream.GrpcResp.Trailers.PseudoHeaders = pseudoHeaders
+			s.stream.GrpcResp.Trailers.OrdinaryHeaders = ordinaryHeaders
+		}
+		if !f.StreamEnded() {
+			return models.GrpcStream{}, false
+		}
+		delete(c.streams, streamID)
+		s.stream.GrpcReq.Body = DecodeGrpcMessage(s.stream.GrpcReq.Headers, s.reqBody, true)
+		s.stream.GrpcResp.Body = DecodeGrpcMessage(s.stream.GrpcReq.Headers, s.respBody, false)
+		s.stream.GrpcResp.Timestamp = timestamp
+		return s.stream, true
+	case *http2.DataFrame:
+		if s, ok := c.streams[streamID]; ok {
+			s.respBody = append(s.respBody, f.Data()...)
+		}
+	case *http2.RSTStreamFrame:
+		delete(c.streams, streamID)
+	}
+	return models.GrpcStream{}, false
+}
+
+// SimulateGrpc replays the gRPC call of the testcase over h2c on the authority and returns the
+// response of the application, with the status of the call in its trailers.
+func SimulateGrpc(tc models.TestCase, testSet string, logger *zap.Logger, apiTimeout uint64) (*models.GrpcResp, error) {
+	headers := tc.GrpcReq.Headers
+	logger.Info("starting test for of", zap.Any("test case", models.HighlightString(tc.Name)), zap.Any("test set", models.HighlightString(testSet)))
+	payload, err := CreatePayloadFromLengthPrefixedMessage(tc.GrpcReq.Body)
+	if err != nil {
+		logger.Error("failed to encode the body of the grpc request", zap.Error(err))
+		return nil, err
+	}
+
+	url := fmt.Sprintf("http://%s%s", headers.PseudoHeaders[":authority"], headers.PseudoHeaders[":path"])
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		logger.Error("failed to create a grpc request", zap.Error(err))
+		return nil, err
+	}
+	for key, value := range headers.OrdinaryHeaders {
+		req.Header.Set(key, value)
+	}
+	req.ContentLength = int64(len(payload))
+
+	httpResp, err := newH2cClient(time.Second * time.Duration(apiTimeout)).Do(req)
+	if err != nil {
+		logger.Error("failed sending testcase grpc request", zap.Error(err))
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	body, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		logger.Error("failed reading the response body of the grpc call", zap.Error(err))
+		return nil, err
+	}
+
+	resp := models.NewGrpcStream(0).GrpcResp
+	resp.Headers.PseudoHeaders[":status"] = strconv.Itoa(httpResp.StatusCode)
+	resp.Body = DecodeGrpcMessage(headers, body, false)
+	respHeaders := resp.Headers.OrdinaryHeaders
+	if len(httpResp.Trailer) == 0 {
+		// a trailers-only response carries the status of the call in its headers
+		respHeaders = resp.Trailers.OrdinaryHeaders
+	}
+	for key, values := range httpResp.Header {
+		respHeaders[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+	for key, values := range httpResp.Trailer {
+		resp.Trailers.OrdinaryHeaders[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+	return &resp, nil
+}
+
+// newH2cClient returns a client which sends the requests over HTTP/2 without TLS, as the grpc
+// servers of the applications are reached.
+func newH2cClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, addr)
+			},
+		},
+	}
+}
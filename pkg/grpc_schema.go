@@ -0,0 +1,642 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GrpcSchemaFile stores the descriptors reflected from the servers during record, next to the
+// test-sets, so that the messages recorded with them can be encoded again in test mode.
+const GrpcSchemaFile = "grpc.protoset"
+
+// grpcReflectionPaths are the methods of the reflection service, the newest version first.
+var grpcReflectionPaths = []string{
+	"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo",
+	"/grpc.reflection.v1alpha.ServerReflection/ServerReflectionInfo",
+}
+
+// grpcSchemaRegistry holds the descriptors of the gRPC services known to Keploy.
+type grpcSchemaRegistry struct {
+	mutex      sync.RWMutex
+	files      *protoregistry.Files
+	reflection bool
+	// reflected holds the services whose descriptors were already asked to their servers
+	reflected map[string]bool
+	// store receives the reflected descriptors, along with the ones read from it
+	store    string
+	storeSet []*descriptorpb.FileDescriptorProto
+	logger   *zap.Logger
+}
+
+var grpcSchema = &grpcSchemaRegistry{
+	files:     new(protoregistry.Files),
+	reflected: map[string]bool{},
+	logger:    zap.NewNop(),
+}
+
+// LoadGrpcSchema registers the descriptors of the gRPC services given in config, along with the
+// ones reflected by the earlier records of path. The servers are asked for the descriptors of
+// the services which are still unknown when config enables the reflection.
+func LoadGrpcSchema(path string, config models.GrpcSchema, logger *zap.Logger) error {
+	grpcSchema.mutex.Lock()
+	defer grpcSchema.mutex.Unlock()
+	grpcSchema.logger = logger
+	grpcSchema.reflection = config.Reflection
+	grpcSchema.store = filepath.Join(path, GrpcSchemaFile)
+
+	sets := append([]string{}, config.DescriptorSets...)
+	if len(config.ProtoFiles) > 0 {
+		compiled, err := compileProtoFiles(config.ProtoFiles, config.ProtoIncludes)
+		if err != nil {
+			logger.Error("failed to compile the proto files", zap.Error(err), zap.Any("proto files", config.ProtoFiles))
+			return err
+		}
+		defer os.Remove(compiled)
+		sets = append(sets, compiled)
+	}
+	for _, set := range sets {
+		fds, err := readDescriptorSet(set)
+		if err != nil {
+			logger.Error("failed to read the descriptor set", zap.Error(err), zap.String("path", set))
+			return err
+		}
+		grpcSchema.register(fds)
+	}
+
+	if _, err := os.Stat(grpcSchema.store); err == nil {
+		fds, err := readDescriptorSet(grpcSchema.store)
+		if err != nil {
+			logger.Error("failed to read the reflected grpc descriptors", zap.Error(err), zap.String("path", grpcSchema.store))
+			return err
+		}
+		grpcSchema.storeSet = fds
+		grpcSchema.register(fds)
+	}
+	return nil
+}
+
+// compileProtoFiles compiles the proto files into a descriptor set with protoc and returns its path.
+func compileProtoFiles(protoFiles, includes []string) (string, error) {
+	if _, err := exec.LookPath("protoc"); err != nil {
+		return "", errors.New("protoc is needed to compile the proto files, pass a descriptor set instead")
+	}
+	out, err := os.CreateTemp("", "keploy-*.protoset")
+	if err != nil {
+		return "", err
+	}
+	out.Close()
+
+	args := []string{"--include_imports", "--descriptor_set_out=" + out.Name()}
+	seen := map[string]bool{}
+	for _, include := range append(append([]string{}, includes...), protoDirs(protoFiles)...) {
+		if !seen[include] {
+			seen[include] = true
+			args = append(args, "-I", include)
+		}
+	}
+	args = append(args, protoFiles...)
+	if output, err := exec.Command("protoc", args...).CombinedOutput(); err != nil {
+		os.Remove(out.Name())
+		return "", fmt.Errorf("%v: %s", err, output)
+	}
+	return out.Name(), nil
+}
+
+// protoDirs returns the directories of the proto files, which are looked up for their imports
+// after the include paths.
+func protoDirs(protoFiles []string) []string {
+	dirs := []string{}
+	for _, file := range protoFiles {
+		dirs = append(dirs, filepath.Dir(file))
+	}
+	return dirs
+}
+
+func readDescriptorSet(path string) ([]*descriptorpb.FileDescriptorProto, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, err
+	}
+	return set.File, nil
+}
+
+// register adds the files to the registry once their dependencies are registered. The files
+// which can't be resolved are logged and left out.
+func (r *grpcSchemaRegistry) register(fds []*descriptorpb.FileDescriptorProto) {
+	pending := fds
+	for len(pending) > 0 {
+		var unresolved []*descriptorpb.FileDescriptorProto
+		var lastErr error
+		for _, fd := range pending {
+			if _, err := r.files.FindFileByPath(fd.GetName()); err == nil {
+				continue
+			}
+			file, err := protodesc.NewFile(fd, schemaResolver{r.files})
+			if err != nil {
+				unresolved, lastErr = append(unresolved, fd), err
+				continue
+			}
+			if err := r.files.RegisterFile(file); err != nil {
+				r.logger.Warn("failed to register the grpc descriptor", zap.Error(err), zap.String("file", fd.GetName()))
+			}
+		}
+		if len(unresolved) == len(pending) {
+			for _, fd := range unresolved {
+				r.logger.Warn("failed to resolve the grpc descriptor", zap.Error(lastErr), zap.String("file", fd.GetName()))
+			}
+			return
+		}
+		pending = unresolved
+	}
+}
+
+// schemaResolver resolves the dependencies of the descriptors from the registered files and
+// from the descriptors linked into Keploy, like the well-known types.
+type schemaResolver struct {
+	files *protoregistry.Files
+}
+
+func (s schemaResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if file, err := s.files.FindFileByPath(path); err == nil {
+		return file, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (s schemaResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if desc, err := s.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
+
+// method returns the descriptor of the method called on path, e.g. "/helloworld.Greeter/SayHello".
+// When the reflection is enabled during record, the server on authority is asked for the unknown
+// services in the background: the callers hold the locks of the proxy and of the connections, so
+// the calls made until the descriptors arrive are kept undecoded rather than waiting for them.
+func (r *grpcSchemaRegistry) method(path, authority string) protoreflect.MethodDescriptor {
+	service, method, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok {
+		return nil
+	}
+	if md := r.lookupMethod(service, method); md != nil {
+		return md
+	}
+	if authority != "" && r.shouldReflect(service) {
+		go r.reflect(service, authority)
+	}
+	return nil
+}
+
+// reflect registers and stores the descriptors of the service given by the server on authority.
+func (r *grpcSchemaRegistry) reflect(service, authority string) {
+	fds, err := reflectGrpcService(authority, service)
+	if err != nil {
+		r.logger.Debug("failed to reflect the descriptors of the grpc service", zap.Error(err), zap.String("service", service), zap.String("authority", authority))
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.register(fds)
+	r.save(fds)
+}
+
+func (r *grpcSchemaRegistry) lookupMethod(service, method string) protoreflect.MethodDescriptor {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	desc, err := r.files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil
+	}
+	return sd.Methods().ByName(protoreflect.Name(method))
+}
+
+// shouldReflect reports whether the server of the service has to be asked for its descriptors,
+// every service is only asked once, whether its server answers or not.
+func (r *grpcSchemaRegistry) shouldReflect(service string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.reflection || models.GetMode() != models.MODE_RECORD || r.reflected[service] {
+		return false
+	}
+	r.reflected[service] = true
+	return true
+}
+
+// save writes the reflected descriptors to the store, so that test mode can load them.
+func (r *grpcSchemaRegistry) save(fds []*descriptorpb.FileDescriptorProto) {
+	if r.store == "" {
+		return
+	}
+	saved := map[string]bool{}
+	for _, fd := range r.storeSet {
+		saved[fd.GetName()] = true
+	}
+	for _, fd := range fds {
+		if !saved[fd.GetName()] {
+			saved[fd.GetName()] = true
+			r.storeSet = append(r.storeSet, fd)
+		}
+	}
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: r.storeSet})
+	if err != nil {
+		r.logger.Error("failed to encode the reflected grpc descriptors", zap.Error(err))
+		return
+	}
+	if err := os.WriteFile(r.store, data, 0644); err != nil {
+		r.logger.Error("failed to store the reflected grpc descriptors", zap.Error(err), zap.String("path", r.store))
+	}
+}
+
+// reflectGrpcService asks the reflection service of the server on authority for the descriptors
+// of the service and of its dependencies. The server is reached over h2c.
+func reflectGrpcService(authority, service string) ([]*descriptorpb.FileDescriptorProto, error) {
+	var lastErr error
+	for _, path := range grpcReflectionPaths {
+		// file_containing_symbol
+		fds, err := reflectionRequest(authority, path, protowire.AppendString(protowire.AppendTag(nil, 4, protowire.BytesType), service))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		// the servers usually send the dependencies along, the missing ones are asked by name
+		known := map[string]bool{}
+		for _, fd := range fds {
+			known[fd.GetName()] = true
+		}
+		for _, fd := range append([]*descriptorpb.FileDescriptorProto{}, fds...) {
+			for _, dep := range fd.GetDependency() {
+				if known[dep] {
+					continue
+				}
+				known[dep] = true
+				if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+					continue
+				}
+				// file_by_filename
+				depFds, err := reflectionRequest(authority, path, protowire.AppendString(protowire.AppendTag(nil, 3, protowire.BytesType), dep))
+				if err != nil {
+					return nil, err
+				}
+				fds = append(fds, depFds...)
+			}
+		}
+		return fds, nil
+	}
+	return nil, lastErr
+}
+
+// reflectionRequest sends a ServerReflectionRequest and decodes the file descriptors of its response.
+func reflectionRequest(authority, path string, request []byte) ([]*descriptorpb.FileDescriptorProto, error) {
+	payload := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(payload[1:5], uint32(len(request)))
+	payload = append(payload, request...)
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://%s%s", authority, path), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+	resp, err := newH2cClient(5 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	status := resp.Trailer.Get("grpc-status")
+	if status == "" {
+		status = resp.Header.Get("grpc-status")
+	}
+	if status != "0" {
+		return nil, fmt.Errorf("the reflection call failed with grpc-status %q", status)
+	}
+
+	fds := []*descriptorpb.FileDescriptorProto{}
+	for len(body) >= 5 {
+		length := int(binary.BigEndian.Uint32(body[1:5]))
+		if len(body) < 5+length {
+			return nil, errors.New("the reflection response is truncated")
+		}
+		message := body[5 : 5+length]
+		body = body[5+length:]
+		// ServerReflectionResponse: file_descriptor_response = 4, error_response = 7
+		for len(message) > 0 {
+			num, typ, n := protowire.ConsumeTag(message)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			message = message[n:]
+			value, n := consumeFieldValue(num, typ, message)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			message = message[n:]
+			switch num {
+			case 4:
+				files, err := fileDescriptorResponse(value)
+				if err != nil {
+					return nil, err
+				}
+				fds = append(fds, files...)
+			case 7:
+				return nil, fmt.Errorf("the reflection service replied with an error: %q", value)
+			}
+		}
+	}
+	return fds, nil
+}
+
+// fileDescriptorResponse decodes the serialized files of a FileDescriptorResponse.
+func fileDescriptorResponse(message []byte) ([]*descriptorpb.FileDescriptorProto, error) {
+	fds := []*descriptorpb.FileDescriptorProto{}
+	for len(message) > 0 {
+		num, typ, n := protowire.ConsumeTag(message)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		message = message[n:]
+		value, n := consumeFieldValue(num, typ, message)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		message = message[n:]
+		if num != 1 || typ != protowire.BytesType {
+			continue
+		}
+		fd := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(value, fd); err != nil {
+			return nil, err
+		}
+		fds = append(fds, fd)
+	}
+	return fds, nil
+}
+
+// consumeFieldValue returns the bytes of a length-delimited field along with the length of the
+// field value, or a negative length when it can't be parsed.
+func consumeFieldValue(num protowire.Number, typ protowire.Type, b []byte) ([]byte, int) {
+	if typ == protowire.BytesType {
+		return protowire.ConsumeBytes(b)
+	}
+	n := protowire.ConsumeFieldValue(num, typ, b)
+	if n < 0 {
+		return nil, n
+	}
+	return b[:n], n
+}
+
+// DecodeGrpcMessage decodes the length-prefixed message of a call with the request headers. The
+// message is decoded as protojson when the schema of the method is known, and by field number
+// with protoscope otherwise.
+func DecodeGrpcMessage(reqHeaders models.GrpcHeaders, data []byte, isRequest bool) models.GrpcLengthPrefixedMessage {
+	msg := CreateLengthPrefixedMessageFromPayload(data)
+	// the compressed messages are kept as they are
+	if len(data) < 5 || msg.CompressionFlag != 0 {
+		return msg
+	}
+	md := grpcSchema.method(reqHeaders.PseudoHeaders[":path"], reqHeaders.PseudoHeaders[":authority"])
+	if md == nil {
+		return msg
+	}
+	desc := md.Output()
+	if isRequest {
+		desc = md.Input()
+	}
+	message := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(data[5:], message); err != nil {
+		grpcSchema.logger.Debug("failed to decode the grpc message with its schema", zap.Error(err), zap.String("type", string(desc.FullName())))
+		return msg
+	}
+	// the fields unknown to the schema would be lost by protojson
+	if len(message.GetUnknown()) > 0 {
+		grpcSchema.logger.Debug("the grpc message has fields unknown to its schema", zap.String("type", string(desc.FullName())))
+		return msg
+	}
+	encoded, err := protojson.Marshal(message)
+	if err != nil {
+		grpcSchema.logger.Debug("failed to encode the grpc message as protojson", zap.Error(err), zap.String("type", string(desc.FullName())))
+		return msg
+	}
+	// protojson varies its whitespace from one build to another
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, encoded, "", "  "); err != nil {
+		return msg
+	}
+	msg.Schema = string(desc.FullName())
+	msg.DecodedData = indented.String()
+	return msg
+}
+
+// encodeGrpcMessage encodes the protojson of a message with its schema.
+func encodeGrpcMessage(msg models.GrpcLengthPrefixedMessage) ([]byte, error) {
+	grpcSchema.mutex.RLock()
+	desc, err := schemaResolver{grpcSchema.files}.FindDescriptorByName(protoreflect.FullName(msg.Schema))
+	grpcSchema.mutex.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("the schema of the grpc message %s is not loaded: %v", msg.Schema, err)
+	}
+	md, ok := desc.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a protobuf message", msg.Schema)
+	}
+	message := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal([]byte(msg.DecodedData), message); err != nil {
+		return nil, fmt.Errorf("could not decode the protojson of the grpc message %s: %v", msg.Schema, err)
+	}
+	return proto.MarshalOptions{Deterministic: true}.Marshal(message)
+}
+
+// MatchGrpcMessages compares the messages field by field, leaving out the noisy fields. The
+// messages are compared by field name when both have the same schema and by field number
+// otherwise, in which case the noise is given as field number paths like "2.1".
+func MatchGrpcMessages(expected, actual models.GrpcLengthPrefixedMessage, noise map[string][]string) bool {
+	if expected.CompressionFlag != actual.CompressionFlag {
+		return false
+	}
+	if expected.DecodedData == actual.DecodedData && expected.Schema == actual.Schema {
+		return true
+	}
+	var expFields, actFields interface{}
+	if expected.Schema != "" && expected.Schema == actual.Schema {
+		if json.Unmarshal([]byte(expected.DecodedData), &expFields) != nil || json.Unmarshal([]byte(actual.DecodedData), &actFields) != nil {
+			return false
+		}
+	} else {
+		var ok bool
+		if expFields, ok = grpcWireFields(expected); !ok {
+			return false
+		}
+		if actFields, ok = grpcWireFields(actual); !ok {
+			return false
+		}
+	}
+	return matchGrpcFields(expFields, actFields, "", noise)
+}
+
+// grpcWireFields decodes the message by field number.
+func grpcWireFields(msg models.GrpcLengthPrefixedMessage) (interface{}, bool) {
+	payload, err := CreatePayloadFromLengthPrefixedMessage(msg)
+	if err != nil {
+		return nil, false
+	}
+	return wireFields(payload[5:], 0)
+}
+
+// maxWireDepth bounds the nesting of the messages decoded without a schema.
+const maxWireDepth = 32
+
+// wireFields decodes a protobuf message without its schema into a map of its field numbers. A
+// length-delimited value is decoded as a nested message when it is one, as a string otherwise.
+func wireFields(b []byte, depth int) (map[string]interface{}, bool) {
+	fields := map[string]interface{}{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+		var value interface{}
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, false
+			}
+			value, b = v, b[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(b)
+			if n < 0 {
+				return nil, false
+			}
+			value, b = uint64(v), b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, false
+			}
+			value, b = v, b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, false
+			}
+			value, b = string(v), b[n:]
+			if len(v) > 0 && depth < maxWireDepth {
+				if nested, ok := wireFields(v, depth+1); ok {
+					value = nested
+				}
+			}
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return nil, false
+			}
+			value, b = string(b[:n]), b[n:]
+		}
+		key := strconv.Itoa(int(num))
+		switch existing := fields[key].(type) {
+		case nil:
+			fields[key] = value
+		case []interface{}:
+			fields[key] = append(existing, value)
+		default:
+			fields[key] = []interface{}{existing, value}
+		}
+	}
+	return fields, true
+}
+
+// matchGrpcFields compares the decoded fields under path. A noisy field matches any value, or
+// the values matching one of its regexes.
+func matchGrpcFields(expected, actual interface{}, path string, noise map[string][]string) bool {
+	if regexes, noisy := noise[path]; noisy && path != "" {
+		if len(regexes) == 0 {
+			return true
+		}
+		value := fmt.Sprint(actual)
+		for _, pattern := range regexes {
+			if matched, err := regexp.MatchString(pattern, value); err == nil && matched {
+				return true
+			}
+		}
+	}
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for key := range act {
+			if _, ok := exp[key]; !ok && !isNoisyField(joinFieldPath(path, key), noise) {
+				return false
+			}
+		}
+		for key, value := range exp {
+			if !matchGrpcFields(value, act[key], joinFieldPath(path, key), noise) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(exp) {
+			return false
+		}
+		for i := range exp {
+			if !matchGrpcFields(exp[i], act[i], path, noise) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+func isNoisyField(path string, noise map[string][]string) bool {
+	_, noisy := noise[path]
+	return noisy
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
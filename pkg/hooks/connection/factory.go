@@ -21,6 +21,8 @@ var Emoji = "\U0001F430" + " Keploy:"
 // Factory is a routine-safe container that holds a trackers with unique ID, and able to create new tracker.
 type Factory struct {
 	connections         map[structs.ConnID]*Tracker
+	grpcCaptures        map[structs.ConnID]*pkg.GrpcCapture
+	wsCaptures          map[structs.ConnID]*pkg.WebSocketCapture
 	inactivityThreshold time.Duration
 	mutex               *sync.RWMutex
 	logger              *zap.Logger
@@ -30,6 +32,8 @@ type Factory struct {
 func NewFactory(inactivityThreshold time.Duration, logger *zap.Logger) *Factory {
 	return &Factory{
 		connections:         make(map[structs.ConnID]*Tracker),
+		grpcCaptures:        make(map[structs.ConnID]*pkg.GrpcCapture),
+		wsCaptures:          make(map[structs.ConnID]*pkg.WebSocketCapture),
 		mutex:               &sync.RWMutex{},
 		inactivityThreshold: inactivityThreshold,
 		logger:              logger,
@@ -49,6 +53,31 @@ func (factory *Factory) HandleReadyConnections(db platform.TestCaseDB, ctx conte
 				continue
 			}
 
+			// the connections which start with the http2 preface carry grpc calls, whose frames
+			// are decoded with the state of the connection
+			grpcCapture, isGrpc := factory.grpcCaptures[connID]
+			if !isGrpc && pkg.IsHttp2Connection(requestBuf) {
+				grpcCapture, isGrpc = pkg.NewGrpcCapture(factory.logger), true
+				factory.grpcCaptures[connID] = grpcCapture
+			}
+			if isGrpc {
+				calls := grpcCapture.Capture(requestBuf, responseBuf, reqTimestampTest, resTimestampTest)
+				if models.GetMode() == models.MODE_RECORD {
+					for _, call := range calls {
+						captureGrpc(db, call, factory.logger, ctx, filters)
+					}
+				}
+				continue
+			}
+
+			// the connections upgraded to websocket carry the frames of the session
+			if wsCapture, ok := factory.wsCaptures[connID]; ok {
+				if wsCapture.Capture(requestBuf, responseBuf, reqTimestampTest, resTimestampTest) {
+					factory.captureWebSocket(db, connID, ctx, filters)
+				}
+				continue
+			}
+
 			parsedHttpReq, err := pkg.ParseHTTPRequest(requestBuf)
 			if err != nil {
 				factory.logger.Error("failed to parse the http request from byte array", zap.Error(err))
@@ -60,6 +89,15 @@ func (factory *Factory) HandleReadyConnections(db platform.TestCaseDB, ctx conte
 				continue
 			}
 
+			if parsedHttpRes.StatusCode == http.StatusSwitchingProtocols && pkg.IsWebSocketUpgrade(parsedHttpReq.Header) {
+				wsCapture := pkg.NewWebSocketCapture(parsedHttpReq, parsedHttpRes, reqTimestampTest, resTimestampTest)
+				factory.wsCaptures[connID] = wsCapture
+				if wsCapture.Capture(pkg.SkipHttpHeaders(requestBuf), pkg.SkipHttpHeaders(responseBuf), reqTimestampTest, resTimestampTest) {
+					factory.captureWebSocket(db, connID, ctx, filters)
+				}
+				continue
+			}
+
 			switch models.GetMode() {
 			case models.MODE_RECORD:
 				// capture the ingress call for record cmd
@@ -79,7 +117,24 @@ func (factory *Factory) HandleReadyConnections(db platform.TestCaseDB, ctx conte
 
 	// Delete all the processed trackers.
 	for _, key := range trackersToDelete {
+		// the sessions that were not closed end with their connection
+		if wsCapture, ok := factory.wsCaptures[key]; ok && !wsCapture.Closed() {
+			factory.captureWebSocket(db, key, ctx, filters)
+		}
 		delete(factory.connections, key)
+		delete(factory.grpcCaptures, key)
+		delete(factory.wsCaptures, key)
+	}
+}
+
+// captureWebSocket stores the session of the connection as a testcase in record mode.
+func (factory *Factory) captureWebSocket(db platform.TestCaseDB, connID structs.ConnID, ctx context.Context, filters *models.Filters) {
+	if models.GetMode() != models.MODE_RECORD {
+		return
+	}
+	err := db.WriteTestcase(factory.wsCaptures[connID].TestCase(), ctx, filters)
+	if err != nil {
+		factory.logger.Error("failed to record the ingress websocket session", zap.Error(err))
 	}
 }
 
@@ -141,3 +196,19 @@ func capture(db platform.TestCaseDB, req *http.Request, resp *http.Response, log
 		return
 	}
 }
+
+func captureGrpc(db platform.TestCaseDB, call models.GrpcStream, logger *zap.Logger, ctx context.Context, filters *models.Filters) {
+	err := db.WriteTestcase(&models.TestCase{
+		Version:  models.GetVersion(),
+		Name:     call.GrpcReq.Headers.OrdinaryHeaders["keploy-test-name"],
+		Kind:     models.GRPC_EXPORT,
+		Created:  time.Now().Unix(),
+		GrpcReq:  call.GrpcReq,
+		GrpcResp: call.GrpcResp,
+		Noise:    map[string][]string{},
+	}, ctx, filters)
+	if err != nil {
+		logger.Error("failed to record the ingress grpc call", zap.Error(err))
+		return
+	}
+}
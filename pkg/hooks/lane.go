@@ -0,0 +1,111 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// NewLane returns a hook for one more instance of the user application. The lane shares the eBPF
+// programs and maps loaded by h, but it has its own mock stores and application process, so that
+// test-sets can be replayed in parallel.
+//
+// The kernel keeps a single app pid, so the calls of the lanes can't be told apart there: they are
+// all redirected to the proxy, which routes them by the pid of the caller (see OwnsPid). A call
+// which no lane owns is not served, it is reported as unmatched by the lanes. Lanes are refused when
+// the kernel only redirects the calls of the process set with SendAppPid.
+//
+// A lane must not be stopped with Stop, the eBPF resources are released by the hook it came from.
+// It is released with ReleaseLane once its test-set is done.
+func (h *Hook) NewLane() (*Hook, error) {
+	if h.appPid != 0 {
+		return nil, fmt.Errorf("%s the calls of the lanes are not redirected, only the ones of the process %d are", Emoji, h.appPid)
+	}
+	ldb, err := NewLocalDb(mockSchema())
+	if err != nil {
+		return nil, fmt.Errorf("error while creating new LocalDb: %v", err)
+	}
+	lane := &Hook{
+		proxyInfoMap:     h.proxyInfoMap,
+		inodeMap:         h.inodeMap,
+		redirectProxyMap: h.redirectProxyMap,
+		keployModeMap:    h.keployModeMap,
+		keployPid:        h.keployPid,
+		keployServerPort: h.keployServerPort,
+		passthroughPorts: h.passthroughPorts,
+		TestCaseDB:       h.TestCaseDB,
+		logger:           h.logger,
+		proxyPort:        h.proxyPort,
+		localDb:          ldb,
+		mu:               &sync.Mutex{},
+		userIpAddress:    make(chan string),
+		idc:              h.idc,
+		mainRoutineId:    h.mainRoutineId,
+	}
+	// the application of the lane is stopped along with the one of h
+	h.lanesMu.Lock()
+	h.lanes = append(h.lanes, lane)
+	h.lanesMu.Unlock()
+	return lane, nil
+}
+
+// ReleaseLane forgets a lane returned by NewLane, Stop no longer stops its application.
+func (h *Hook) ReleaseLane(lane *Hook) {
+	h.lanesMu.Lock()
+	defer h.lanesMu.Unlock()
+	for i, l := range h.lanes {
+		if l == lane {
+			h.lanes = append(h.lanes[:i], h.lanes[i+1:]...)
+			return
+		}
+	}
+}
+
+// SetAppEnv sets the variables added to the environment of the user application at its next launch.
+func (h *Hook) SetAppEnv(env ...string) {
+	h.appEnv = env
+}
+
+// OwnsPid reports whether the process belongs to the user application launched by the hook, that
+// is it runs in the process group of the application or descends from it.
+func (h *Hook) OwnsPid(pid uint32) bool {
+	if h.userAppCmd == nil || h.userAppCmd.Process == nil || pid == 0 {
+		return false
+	}
+	appPid := h.userAppCmd.Process.Pid
+	if pgid, err := syscall.Getpgid(int(pid)); err == nil && pgid == appPid {
+		return true
+	}
+	for current := int(pid); current > 1; {
+		if current == appPid {
+			return true
+		}
+		parent, err := parentPid(current)
+		if err != nil {
+			return false
+		}
+		current = parent
+	}
+	return false
+}
+
+// parentPid reads the parent of the process from /proc/<pid>/stat.
+func parentPid(pid int) (int, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// the command name is wrapped in parentheses and may contain spaces
+	end := strings.LastIndexByte(string(stat), ')')
+	if end < 0 {
+		return 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat)[end+1:])
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
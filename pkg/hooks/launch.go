@@ -480,6 +480,9 @@ func (h *Hook) runApp(appCmd string, isUnitTestIntegration bool) error {
 	// Set the output of the command
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
+	if len(h.appEnv) > 0 {
+		cmd.Env = append(os.Environ(), h.appEnv...)
+	}
 	h.userAppCmd = cmd
 
 	// Run the app as the user who invoked sudo
@@ -44,6 +44,8 @@ type Hook struct {
 	keployModeMap    *ebpf.Map
 	keployPid        *ebpf.Map
 	appPidMap        *ebpf.Map
+	// appPid is the process the kernel redirects the calls of, 0 when it redirects the calls of every process
+	appPid           uint32
 	keployServerPort *ebpf.Map
 	passthroughPorts *ebpf.Map
 
@@ -94,6 +96,15 @@ type Hook struct {
 	writevRet     link.Link
 
 	idc clients.InternalDockerClient
+
+	// appEnv is added to the environment of the user application
+	appEnv []string
+	// lanes are the hooks of the other instances of the user application, see NewLane
+	lanes   []*Hook
+	lanesMu sync.Mutex
+	// usage tracks the consumption of the mocks during a testrun, see MockUsage
+	usage   mockUsage
+	usageMu sync.Mutex
 }
 
 func NewHook(db platform.TestCaseDB, mainRoutineId int, logger *zap.Logger) (*Hook, error) {
@@ -102,16 +113,7 @@ func NewHook(db platform.TestCaseDB, mainRoutineId int, logger *zap.Logger) (*Ho
 		logger.Fatal("failed to create internal docker client", zap.Error(err))
 	}
 
-	schemaMap := map[string]map[string]string{
-		mockTable: {
-			mockTableIndex: mockTableIndexField,
-		},
-		configMockTable: {
-			configMockTableIndex: configMockTableIndexField,
-		},
-	}
-
-	ldb, err := NewLocalDb(schemaMap)
+	ldb, err := NewLocalDb(mockSchema())
 	if err != nil {
 		return nil, fmt.Errorf("error while creating new LocalDb: %v", err)
 	}
@@ -211,6 +213,9 @@ func (h *Hook) DeleteTcsMock(mock *models.Mock) (bool, error) {
 	if err != nil {
 		return isDeleted, fmt.Errorf("error while deleting tcs mocks %v from localDb %v", mock, err)
 	}
+	if isDeleted {
+		h.MarkMockUsed(mock)
+	}
 	return isDeleted, nil
 }
 
@@ -329,11 +334,15 @@ func (h *Hook) GetDestinationInfo(srcPort uint16) (*structs.DestInfo, error) {
 // This function is used when running Keploy tests along with unit tests of the application.
 func (h *Hook) SendAppPid(pid uint32) error {
 	h.logger.Debug("Sending app pid to kernel", zap.Any("app Pid", pid))
+	if h.appPidMap == nil {
+		return fmt.Errorf("%s the app pid can only be sent by the hook which loaded the eBPF programs", Emoji)
+	}
 	err := h.appPidMap.Update(uint32(0), &pid, ebpf.UpdateAny)
 	if err != nil {
 		h.logger.Error("failed to send the app pid to the ebpf program", zap.Any("app Pid", pid), zap.Any("error thrown by ebpf map", err.Error()))
 		return err
 	}
+	h.appPid = pid
 	return nil
 }
 
@@ -423,6 +432,12 @@ func (h *Hook) Recover(id int) {
 
 	if r := recover(); r != nil {
 		h.logger.Debug("Recover from panic in go routine", zap.Any("current routine id", id), zap.Any("main routine id", h.mainRoutineId))
+		// without the eBPF hooks, i.e. the mock server of --no-ebpf and of the sdk, the panic only ends the call
+		// being served, there is nothing to release
+		if !h.ebpfLoaded() {
+			h.logger.Error("recovered from a panic while serving a call", zap.Any("panic", r), zap.Stack("stack"))
+			return
+		}
 		h.Stop(true)
 		// stop the user application cmd
 		h.StopUserApplication()
@@ -453,6 +468,11 @@ func (h *Hook) Stop(forceStop bool) {
 	if !forceStop {
 		h.logger.Info("Received signal to exit keploy program..")
 		h.StopUserApplication()
+		h.lanesMu.Lock()
+		for _, lane := range h.lanes {
+			lane.StopUserApplication()
+		}
+		h.lanesMu.Unlock()
 	} else {
 		h.logger.Info("Exiting keploy program gracefully.")
 	}
@@ -472,41 +492,37 @@ func (h *Hook) Stop(forceStop bool) {
 		}
 	}
 
-	// closing all events
-	//other
-	h.socket.Close()
-	//egress
-	h.bind.Close()
-	h.udpp4.Close()
-	//ipv4
-	h.connect4.Close()
-	h.gp4.Close()
-	h.tcppv4.Close()
-	h.tcpv4.Close()
-	h.tcpv4Ret.Close()
-	//ipv6
-	h.connect6.Close()
-	h.gp6.Close()
-	h.tcppv6.Close()
-	h.tcpv6.Close()
-	h.tcpv6Ret.Close()
-	//ingress
-	h.accept.Close()
-	h.acceptRet.Close()
-	h.accept4.Close()
-	h.accept4Ret.Close()
-	h.close.Close()
-	h.closeRet.Close()
-	h.read.Close()
-	h.readRet.Close()
-	h.write.Close()
-	h.writeRet.Close()
+	if !h.ebpfLoaded() {
+		return
+	}
+	// closing all events, the hooks which failed to attach are nil
+	links := []link.Link{
+		//other
+		h.socket,
+		//egress
+		h.bind, h.udpp4,
+		//ipv4
+		h.connect4, h.gp4, h.tcppv4, h.tcpv4, h.tcpv4Ret,
+		//ipv6
+		h.connect6, h.gp6, h.tcppv6, h.tcpv6, h.tcpv6Ret,
+		//ingress
+		h.accept, h.acceptRet, h.accept4, h.accept4Ret, h.close, h.closeRet, h.read, h.readRet, h.write, h.writeRet,
+		h.writev, h.writevRet,
+	}
+	for _, l := range links {
+		if l != nil {
+			l.Close()
+		}
+	}
 	h.objects.Close()
-	h.writev.Close()
-	h.writevRet.Close()
 	h.logger.Info("eBPF resources released successfully...")
 }
 
+// ebpfLoaded reports whether the eBPF programs and maps were loaded into the kernel by LoadHooks.
+func (h *Hook) ebpfLoaded() bool {
+	return h.proxyInfoMap != nil
+}
+
 // LoadHooks is used to attach the eBPF hooks into the linux kernel. Hooks are attached for outgoing and incoming network requests.
 //
 // proxyPorts is used for redirecting outgoing network calls to the unoccupied proxy server.
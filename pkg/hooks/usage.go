@@ -0,0 +1,68 @@
+package hooks
+
+import (
+	"go.keploy.io/server/pkg/models"
+)
+
+// mockUsage counts the mocks consumed by the parsers, the outgoing calls which were not served
+// by any mock and the ones passed through to their destination by the pass-through rules. The
+// mocks are tracked by pointer, as their ids change when they are set again.
+type mockUsage struct {
+	consumed      map[*models.Mock]int
+	unmatched     []models.UnmatchedCall
+	passedThrough map[string]int
+}
+
+// MarkMockUsed records that the mock served an outgoing call. DeleteTcsMock marks the testcase
+// mocks it removes, the parsers mark the config mocks they reply with.
+func (h *Hook) MarkMockUsed(mock *models.Mock) {
+	if mock == nil {
+		return
+	}
+	h.usageMu.Lock()
+	defer h.usageMu.Unlock()
+	if h.usage.consumed == nil {
+		h.usage.consumed = map[*models.Mock]int{}
+	}
+	h.usage.consumed[mock]++
+}
+
+// RecordUnmatchedCall records an outgoing call of the application for which no mock was found.
+func (h *Hook) RecordUnmatchedCall(kind models.Kind, request string) {
+	h.usageMu.Lock()
+	defer h.usageMu.Unlock()
+	h.usage.unmatched = append(h.usage.unmatched, models.UnmatchedCall{
+		Kind:    kind,
+		Request: request,
+	})
+}
+
+// MockUsage returns the number of times each mock was consumed and the unmatched outgoing calls
+// since the previous call, and starts counting again.
+func (h *Hook) MockUsage() (map[*models.Mock]int, []models.UnmatchedCall) {
+	h.usageMu.Lock()
+	defer h.usageMu.Unlock()
+	consumed, unmatched := h.usage.consumed, h.usage.unmatched
+	h.usage.consumed, h.usage.unmatched = nil, nil
+	return consumed, unmatched
+}
+
+// RecordPassThrough records an outgoing call which the pass-through rule sent to its destination.
+func (h *Hook) RecordPassThrough(rule string) {
+	h.usageMu.Lock()
+	defer h.usageMu.Unlock()
+	if h.usage.passedThrough == nil {
+		h.usage.passedThrough = map[string]int{}
+	}
+	h.usage.passedThrough[rule]++
+}
+
+// PassThroughUsage returns the number of outgoing calls passed through by each rule since the
+// previous call, and starts counting again.
+func (h *Hook) PassThroughUsage() map[string]int {
+	h.usageMu.Lock()
+	defer h.usageMu.Unlock()
+	passedThrough := h.usage.passedThrough
+	h.usage.passedThrough = nil
+	return passedThrough
+}
@@ -13,6 +13,18 @@ const configMockTableIndex string = "id"
 const mockTableIndexField string = "Id"
 const configMockTableIndexField string = "Id"
 
+// mockSchema returns the tables of the local db that stores the mocks of a test-set.
+func mockSchema() map[string]map[string]string {
+	return map[string]map[string]string{
+		mockTable: {
+			mockTableIndex: mockTableIndexField,
+		},
+		configMockTable: {
+			configMockTableIndex: configMockTableIndexField,
+		},
+	}
+}
+
 // ConvertIPToUint32 converts a string representation of an IPv4 address to a 32-bit integer.
 func ConvertIPToUint32(ipStr string) (uint32, error) {
 	ipAddr := net.ParseIP(ipStr)
@@ -0,0 +1,291 @@
+// Package keploy records and replays the mocks of the dependencies of the code under a go test. The proxy and the
+// mock store run in the process of the test: the code under test reaches them through the HTTP client of the
+// session and the listeners of its other dependencies, as the proxy started by `keploy mockTest --no-ebpf`. The
+// eBPF hooks are not loaded, they would redirect the calls of the proxy along with the ones of the test. Without
+// them, a panic while serving a call only ends that call: the test process goes on.
+//
+//	func TestCheckout(t *testing.T) {
+//		session := keploy.Start(t, keploy.Options{MockSet: "checkout"})
+//		api := payments.NewClient(session.Client())
+//		...
+//	}
+//
+// The mode and the global state of the parsers are shared by the process, a single session runs at a time: New
+// waits for the running session to be stopped, so the parallel tests using keploy run one after the other.
+package keploy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform/telemetry"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.keploy.io/server/pkg/proxy"
+	"go.uber.org/zap"
+)
+
+// Options configures a session.
+type Options struct {
+	// Path is the directory of the mock sets, "./keploy" by default
+	Path string
+	// MockSet is the name of the file of the mocks in Path, without its .yaml extension
+	MockSet string
+	// Record stores the calls made to the dependencies in the mock set instead of replaying it
+	Record bool
+	// ProxyPort is the port of the forward proxy, a free one is picked when it is zero
+	ProxyPort uint32
+	// Listeners are the local addresses standing for the dependencies the code under test connects to directly
+	Listeners []models.DependencyListener
+	// AllowUnusedMocks lets a replay end without consuming all the mocks of the set
+	AllowUnusedMocks bool
+	// ProtocolPorts are the protocols of the dependencies listening on ports other than their default one, e.g. a
+	// mysql on 6033, which the replay can't tell from the calls
+	ProtocolPorts models.ProtocolPorts
+	// MockMatching is how the HTTP mocks are scored against the calls during a replay
+	MockMatching models.MockMatching
+	// MongoPassword is the password the mongo connections authenticate with during the replay
+	MongoPassword string
+	// Logger receives the logs of the proxy and the parsers, they are dropped when it is nil
+	Logger *zap.Logger
+}
+
+// sessionMu is held by the running session, from New until Stop.
+var sessionMu sync.Mutex
+
+// Session is a running proxy serving the mocks of a mock set.
+type Session struct {
+	opts    Options
+	hook    *hooks.Hook
+	ps      *proxy.ProxySet
+	client  *http.Client
+	mocks   []*models.Mock // the mocks of the set loaded for a replay
+	stopped bool
+	mu      sync.Mutex
+}
+
+// New starts a session once the running one is stopped. It has to be stopped by Stop.
+func New(opts Options) (*Session, error) {
+	if opts.MockSet == "" {
+		return nil, errors.New("the name of the mock set is missing")
+	}
+	sessionMu.Lock()
+	s, err := newSession(opts)
+	if err != nil {
+		sessionMu.Unlock()
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSession starts the proxy of a session, sessionMu is held by the caller.
+func newSession(opts Options) (s *Session, err error) {
+	if opts.Path == "" {
+		opts.Path = "keploy"
+	}
+	path, err := filepath.Abs(opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the absolute path of the mock sets: %v", err)
+	}
+	opts.Path = path
+	if opts.Logger == nil {
+		opts.Logger = zap.NewNop()
+	}
+	// the proxy is handed the listener of the free port, another process can't take the port in between
+	var listener net.Listener
+	if opts.ProxyPort == 0 {
+		if listener, err = freePort(); err != nil {
+			return nil, err
+		}
+		opts.ProxyPort = uint32(listener.Addr().(*net.TCPAddr).Port)
+		defer func() {
+			if s == nil {
+				listener.Close()
+			}
+		}()
+	}
+
+	mode := models.MODE_TEST
+	if opts.Record {
+		mode = models.MODE_RECORD
+		if err := os.MkdirAll(opts.Path, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create the directory of the mock sets: %v", err)
+		}
+		// the store sets the permissions of the files it creates with sudo, which tests do not run with
+		mockFile := filepath.Join(opts.Path, opts.MockSet+".yaml")
+		if _, err := os.Stat(mockFile); os.IsNotExist(err) {
+			if err := os.WriteFile(mockFile, nil, 0o644); err != nil {
+				return nil, fmt.Errorf("failed to create the mock set: %v", err)
+			}
+		}
+	}
+	models.SetMode(mode)
+
+	tele := telemetry.NewTelemetry(false, false, nil, opts.Logger, "", nil)
+	ys := yaml.NewYamlStore(opts.Path, opts.Path, "", opts.MockSet, opts.Logger, tele)
+	hook, err := hooks.NewHook(ys, 0, opts.Logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the mock store: %v", err)
+	}
+
+	session := &Session{opts: opts, hook: hook}
+	if !opts.Record {
+		if err := session.loadMocks(ys); err != nil {
+			return nil, err
+		}
+	}
+
+	mocksTotal := map[string]int{}
+	ctx := context.WithValue(context.Background(), "mocksTotal", &mocksTotal)
+	session.ps, err = proxy.StartMockServer(opts.Logger, proxy.Option{Port: opts.ProxyPort, Listener: listener, MongoPassword: opts.MongoPassword, MockMatching: opts.MockMatching, ProtocolPorts: opts.ProtocolPorts}, opts.Listeners, hook, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the proxy on port %v: %v", opts.ProxyPort, err)
+	}
+
+	session.client, err = newClient(opts.ProxyPort)
+	if err != nil {
+		session.ps.StopProxyServer()
+		return nil, err
+	}
+	return session, nil
+}
+
+// Start starts a session for the test and stops it when the test ends. The test fails when the session can't be
+// started, or when the replay did not consume all the mocks of the set.
+func Start(t testing.TB, opts Options) *Session {
+	t.Helper()
+	s, err := New(opts)
+	if err != nil {
+		t.Fatalf("failed to start keploy: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := s.Stop(); err != nil {
+			t.Error(err)
+		}
+	})
+	return s
+}
+
+// Client returns an HTTP client sending its calls through the proxy, trusting the certificates it serves.
+func (s *Session) Client() *http.Client {
+	return s.client
+}
+
+// ProxyURL returns the URL of the forward proxy, for the HTTP clients that are not built from Client.
+func (s *Session) ProxyURL() string {
+	return fmt.Sprintf("http://localhost:%v", s.opts.ProxyPort)
+}
+
+// loadMocks hands the mocks of the set to the mock store.
+func (s *Session) loadMocks(ys *yaml.Yaml) error {
+	tcsMocks, err := ys.ReadTcsMocks(&models.TestCase{}, "")
+	if err != nil {
+		return fmt.Errorf("failed to read the mocks of %v: %v", s.opts.MockSet, err)
+	}
+	configMocks, err := ys.ReadConfigMocks("")
+	if err != nil {
+		return fmt.Errorf("failed to read the config mocks of %v: %v", s.opts.MockSet, err)
+	}
+	var readTcsMocks, readConfigMocks []*models.Mock
+	for _, mock := range tcsMocks {
+		if tcsMock, ok := mock.(*models.Mock); ok {
+			readTcsMocks = append(readTcsMocks, tcsMock)
+		}
+	}
+	for _, mock := range configMocks {
+		if configMock, ok := mock.(*models.Mock); ok {
+			readConfigMocks = append(readConfigMocks, configMock)
+		}
+	}
+	if len(readTcsMocks)+len(readConfigMocks) == 0 {
+		return fmt.Errorf("no mocks found for %v in %v", s.opts.MockSet, s.opts.Path)
+	}
+	if err := s.hook.SetConfigMocks(readConfigMocks); err != nil {
+		return err
+	}
+	if err := s.hook.SetTcsMocks(readTcsMocks); err != nil {
+		return err
+	}
+	s.mocks = append(readConfigMocks, readTcsMocks...)
+	return nil
+}
+
+// Stop stops the proxy. After a replay, it returns an error listing the mocks which were not consumed and the
+// calls which no mock matched.
+func (s *Session) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopped {
+		return nil
+	}
+	s.stopped = true
+	s.client.CloseIdleConnections()
+	s.ps.StopProxyServer()
+	sessionMu.Unlock()
+	if s.opts.Record {
+		return nil
+	}
+
+	consumed, unmatched := s.hook.MockUsage()
+	var problems []string
+	for _, call := range unmatched {
+		problems = append(problems, fmt.Sprintf("no %v mock matched the call %v", call.Kind, call.Request))
+	}
+	if !s.opts.AllowUnusedMocks {
+		for _, mock := range s.mocks {
+			if consumed[mock] == 0 {
+				problems = append(problems, fmt.Sprintf("the %v mock recorded at %v was not consumed", mock.Kind, mock.Spec.ReqTimestampMock))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("the replay of %v did not match its mocks:\n\t%v", s.opts.MockSet, strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// newClient returns an HTTP client using the proxy and trusting the CA it signs the certificates with.
+func newClient(proxyPort uint32) (*http.Client, error) {
+	caCert, _, err := proxy.LoadCA()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the CA of the proxy: %v", err)
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	if !roots.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("failed to parse the CA of the proxy")
+	}
+	proxyURL, err := url.Parse(fmt.Sprintf("http://localhost:%v", proxyPort))
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		},
+	}, nil
+}
+
+// freePort returns a listener on a local port which is not in use.
+func freePort() (net.Listener, error) {
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to find a free port for the proxy: %v", err)
+	}
+	return listener, nil
+}
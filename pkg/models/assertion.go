@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AssertionRule checks the shape of a dynamic JSON field instead of its recorded value.
+// Every rule that is set has to pass. For a path that runs through an array, the rule is
+// applied to each of its elements, in the same way as noise.
+type AssertionRule struct {
+	// Type is one of string, number, boolean, object, array or null
+	Type  string   `json:"type,omitempty" yaml:"type,omitempty"`
+	Regex string   `json:"regex,omitempty" yaml:"regex,omitempty"`
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"`
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"`
+	// MinLength and MaxLength bound the number of elements of an array
+	MinLength *int          `json:"minLength,omitempty" yaml:"minLength,omitempty"`
+	MaxLength *int          `json:"maxLength,omitempty" yaml:"maxLength,omitempty"`
+	OneOf     []interface{} `json:"oneOf,omitempty" yaml:"oneOf,omitempty"`
+	// Timestamp requires an ISO 8601 timestamp string
+	Timestamp bool `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+}
+
+// AssertionRules maps a body JSON path, written like a body noise key e.g. "body.data.id", to its rule.
+type AssertionRules map[string]AssertionRule
+
+// Validate checks that every rule sits under the body and uses a known type and a valid regex.
+func (rules AssertionRules) Validate() error {
+	for path, rule := range rules {
+		if path != "body" && !strings.HasPrefix(path, "body.") {
+			return fmt.Errorf("rule path %q should start with body", path)
+		}
+		switch rule.Type {
+		case "", "string", "number", "boolean", "object", "array", "null":
+		default:
+			return fmt.Errorf("unknown type %q for %s", rule.Type, path)
+		}
+		if _, err := regexp.Compile(rule.Regex); err != nil {
+			return fmt.Errorf("invalid regex for %s: %v", path, err)
+		}
+		if rule.Min != nil && rule.Max != nil && *rule.Min > *rule.Max {
+			return fmt.Errorf("min is greater than max for %s", path)
+		}
+		if rule.MinLength != nil && rule.MaxLength != nil && *rule.MinLength > *rule.MaxLength {
+			return fmt.Errorf("minLength is greater than maxLength for %s", path)
+		}
+	}
+	return nil
+}
+
+// AssertionConfig holds the assertion rules of keploy-config.yaml, the rules of a test-set override
+// the global ones for the same path and the rules of a testcase override both.
+type AssertionConfig struct {
+	Global   AssertionRules            `json:"global" yaml:"global"`
+	Testsets map[string]AssertionRules `json:"test-sets" yaml:"test-sets"`
+}
+
+type AssertionResult struct {
+	Normal  bool   `json:"normal" bson:"normal" yaml:"normal"`
+	Path    string `json:"path" bson:"path" yaml:"path"`
+	Message string `json:"message,omitempty" bson:"message,omitempty" yaml:"message,omitempty"`
+}
@@ -3,20 +3,40 @@ package models
 import "time"
 
 type Config struct {
-	Record Record `json:"record" yaml:"record"`
-	Test   Test   `json:"test" yaml:"test"`
+	Record     Record     `json:"record" yaml:"record"`
+	Test       Test       `json:"test" yaml:"test"`
+	MockServer MockServer `json:"mockServer" yaml:"mockServer"`
+}
+
+// MockServer configures the proxy of mockRecord and mockTest when they run without eBPF. The application sends
+// its HTTP calls to the proxy through HTTP_PROXY and HTTPS_PROXY, and connects to the listeners of its other
+// dependencies instead of connecting to them.
+type MockServer struct {
+	Listeners []DependencyListener `json:"listeners" yaml:"listeners"`
+}
+
+// DependencyListener is a local address of the proxy standing for a dependency of the application.
+type DependencyListener struct {
+	// Listen is the address the application connects to, e.g. localhost:15432
+	Listen string `json:"listen" yaml:"listen"`
+	// Target is the address of the dependency the calls are recorded from, e.g. postgres:5432. Its port tells
+	// apart the protocols where the server speaks first, like mysql on 3306.
+	Target string `json:"target" yaml:"target"`
 }
 
 type Record struct {
-	Path             string        `json:"path" yaml:"path"`
-	Command          string        `json:"command" yaml:"command"`
-	ProxyPort        uint32        `json:"proxyport" yaml:"proxyport"`
-	ContainerName    string        `json:"containerName" yaml:"containerName"`
-	NetworkName      string        `json:"networkName" yaml:"networkName"`
-	Delay            uint64        `json:"delay" yaml:"delay"`
-	BuildDelay       time.Duration `json:"buildDelay" yaml:"buildDelay"`
-	PassThroughPorts []uint        `json:"passThroughPorts" yaml:"passThroughPorts"`
-	Filters          Filters       `json:"filters" yaml:"filters"`
+	Path             string            `json:"path" yaml:"path"`
+	Command          string            `json:"command" yaml:"command"`
+	ProxyPort        uint32            `json:"proxyport" yaml:"proxyport"`
+	ContainerName    string            `json:"containerName" yaml:"containerName"`
+	NetworkName      string            `json:"networkName" yaml:"networkName"`
+	Delay            uint64            `json:"delay" yaml:"delay"`
+	BuildDelay       time.Duration     `json:"buildDelay" yaml:"buildDelay"`
+	PassThroughPorts []uint            `json:"passThroughPorts" yaml:"passThroughPorts"`
+	PassThrough      []PassThroughRule `json:"passThrough" yaml:"passThrough"` // outgoing calls sent to their destination without being recorded
+	Filters          Filters           `json:"filters" yaml:"filters"`
+	ProtocolPorts    ProtocolPorts     `json:"protocolPorts" yaml:"protocolPorts"` // protocols of the dependencies listening on ports other than their default one
+	Storage          string            `json:"storage" yaml:"storage"`
 }
 
 type Filters struct {
@@ -40,10 +60,95 @@ type Test struct {
 	BuildDelay         time.Duration       `json:"buildDelay" yaml:"buildDelay"`
 	ApiTimeout         uint64              `json:"apiTimeout" yaml:"apiTimeout"`
 	PassThroughPorts   []uint              `json:"passThroughPorts" yaml:"passThroughPorts"`
+	PassThrough        []PassThroughRule   `json:"passThrough" yaml:"passThrough"`               // outgoing calls sent to their destination instead of being mocked
 	WithCoverage       bool                `json:"withCoverage" yaml:"withCoverage"`             // boolean to capture the coverage in test
 	CoverageReportPath string              `json:"coverageReportPath" yaml:"coverageReportPath"` // directory path to store the coverage files
+	Storage            string              `json:"storage" yaml:"storage"`                       // backend of the recorded testcases and mocks: yaml or sqlite
+	Latency            LatencyConfig       `json:"latency" yaml:"latency"`                       // response time budgets relative to the recorded latency
+	Assertions         AssertionConfig     `json:"assertions" yaml:"assertions"`                 // per JSON path rules checked instead of the recorded body values
+	MockMatching       MockMatching        `json:"mockMatching" yaml:"mockMatching"`             // how the HTTP mocks are scored against the outgoing calls
+	ProtocolPorts      ProtocolPorts       `json:"protocolPorts" yaml:"protocolPorts"`           // protocols of the dependencies listening on ports other than their default one
+}
+
+// ProtocolPorts maps the destination ports of the outgoing calls to the parser handling them, e.g. 6033: mysql.
+// The parsers of the protocols where the server speaks first can't tell their calls apart by the first message
+// of the application, the mocks of a test are served on these ports by the parser of the map.
+type ProtocolPorts map[uint32]string
+
+// MockMatching configures how the HTTP mocks with the same path, method and header and query keys as an
+// outgoing call are scored against it. The mock with the best score is served, the earliest recorded one
+// when several of them have it. Postgres configures the matching of the postgres mocks.
+//
+// It is read from the test section of the config by `keploy test`, and from Options.MockMatching by the go sdk.
+// `keploy mockTest` reads no config, its mocks are matched with the defaults.
+type MockMatching struct {
+	// Threshold is the lowest score between 0 and 1 a mock is served with, the best mock is always served when it is 0
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+	// Headers are the headers whose values are compared along with the query parameters and the body
+	Headers []string `json:"headers" yaml:"headers"`
+	// Noise are the fields of the JSON bodies left out of the comparison, e.g. "body.requestId"
+	Noise []string `json:"noise" yaml:"noise"`
+	// Postgres is how the queries of the application are matched with the postgres mocks
+	Postgres PostgresMatching `json:"postgres" yaml:"postgres"`
 }
 
+// PostgresMatching configures the matching of the postgres mocks. The queries of the application and of the mocks
+// are compared by their normalized sql and the values of their parameters, the bound ones of the extended protocol
+// and the literals of the simple queries.
+type PostgresMatching struct {
+	// IgnoreParams are the parameters whose values are left out of the comparison, e.g. generated uuids or now()
+	IgnoreParams []IgnoredParam `json:"ignoreParams" yaml:"ignoreParams"`
+}
+
+// IgnoredParam selects the parameters whose values are not compared. A parameter is ignored when all the fields
+// set in the rule match it.
+type IgnoredParam struct {
+	// Query is a regular expression matched with the normalized sql of the query, e.g. "^insert into orders"
+	Query string `json:"query" yaml:"query"`
+	// Position is the position of the parameter starting at 1, the one of $1 for the bound parameters
+	Position int `json:"position" yaml:"position"`
+	// Value is a regular expression matched with the recorded value of the parameter
+	Value string `json:"value" yaml:"value"`
+}
+
+// PassThroughRule selects the outgoing calls which the proxy sends to their destination untouched, they are
+// neither recorded nor mocked. A call is passed through when all the fields set in the rule match it.
+type PassThroughRule struct {
+	// Name identifies the rule in the counters of the passed through calls, the matched fields are used when it is empty
+	Name string `json:"name" yaml:"name"`
+	// IP is the address of the destination or a CIDR block containing it
+	IP string `json:"ip" yaml:"ip"`
+	// Host is matched with the SNI of a TLS connection and with the Host header of an HTTP request, a leading "*."
+	// matches any subdomain
+	Host string `json:"host" yaml:"host"`
+	// Path is a prefix of the path of an HTTP request
+	Path string `json:"path" yaml:"path"`
+	// Protocol is the parser claiming the traffic: http, grpc, mongo, postgres, mysql, redis, kafka or generic
+	Protocol string `json:"protocol" yaml:"protocol"`
+}
+
+// LatencyConfig holds the response time budget of every test-set, a test-set budget overrides
+// the fields that it sets in the global one.
+type LatencyConfig struct {
+	Global   LatencyBudget            `json:"global" yaml:"global"`
+	Testsets map[string]LatencyBudget `json:"test-sets" yaml:"test-sets"`
+}
+
+// LatencyBudget bounds how much slower a replayed call may be than the recorded one. Ratio allows
+// up to recorded*ratio and Absolute allows up to recorded+absolute. When both are set, a call has
+// to exceed both of them, so that very fast baselines do not fail on scheduling jitter.
+type LatencyBudget struct {
+	Ratio    float64       `json:"ratio" yaml:"ratio"`
+	Absolute time.Duration `json:"absolute" yaml:"absolute"`
+	// Mode is either "fail" (default) to fail the testcase or "warn" to only report the regression
+	Mode string `json:"mode" yaml:"mode"`
+}
+
+const (
+	LatencyModeFail = "fail"
+	LatencyModeWarn = "warn"
+)
+
 type Globalnoise struct {
 	Global   GlobalNoise  `json:"global" yaml:"global"`
 	Testsets TestsetNoise `json:"test-sets" yaml:"test-sets"`
@@ -14,6 +14,13 @@ const (
 	String         string = "string"
 )
 
+// storage backends for the recorded testcases, mocks and test reports
+const (
+	YamlStorage   string = "yaml"
+	SqliteStorage string = "sqlite"
+	SqliteDbName  string = "keploy.db"
+)
+
 var (
 	PassThroughHosts = []string{"dc.services.visualstudio.com"}
 )
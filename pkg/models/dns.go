@@ -0,0 +1,14 @@
+package models
+
+// DNSReq stores a question asked by the application to resolve a domain.
+type DNSReq struct {
+	Name  string `json:"name" yaml:"name"`
+	Qtype string `json:"qtype" yaml:"qtype"`
+}
+
+// DNSResp stores the answer of the upstream servers. Every answer is a resource record in the
+// zone file format, like "example.com. 300 IN A 93.184.216.34".
+type DNSResp struct {
+	Rcode   string   `json:"rcode" yaml:"rcode"`
+	Answers []string `json:"answers,omitempty" yaml:"answers,omitempty"`
+}
@@ -1,25 +1,35 @@
 package models
 
+import "time"
+
 type GrpcHeaders struct {
 	PseudoHeaders   map[string]string `json:"pseudo_headers" yaml:"pseudo_headers"`
 	OrdinaryHeaders map[string]string `json:"ordinary_headers" yaml:"ordinary_headers"`
 }
 
+// GrpcLengthPrefixedMessage is a message of a gRPC call. The message is decoded as protojson when
+// Schema names its protobuf message type, and as protoscope text by field number otherwise.
 type GrpcLengthPrefixedMessage struct {
 	CompressionFlag uint   `json:"compression_flag" yaml:"compression_flag"`
 	MessageLength   uint32 `json:"message_length" yaml:"message_length"`
+	Schema          string `json:"schema,omitempty" yaml:"schema,omitempty"`
 	DecodedData     string `json:"decoded_data" yaml:"decoded_data"`
 }
 
 type GrpcReq struct {
-	Headers GrpcHeaders               `json:"headers" yaml:"headers"`
-	Body    GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
+	Headers   GrpcHeaders               `json:"headers" yaml:"headers"`
+	Body      GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
+	Timestamp time.Time                 `json:"timestamp" yaml:"timestamp,omitempty"`
+	// Noise lists the fields of the message which are left out when a call is matched with the
+	// mock, by their json path or field number path, with the regexes of their noisy values.
+	Noise map[string][]string `json:"noise,omitempty" yaml:"noise,omitempty"`
 }
 
 type GrpcResp struct {
-	Headers  GrpcHeaders               `json:"headers" yaml:"headers"`
-	Body     GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
-	Trailers GrpcHeaders               `json:"trailers" yaml:"trailers"`
+	Headers   GrpcHeaders               `json:"headers" yaml:"headers"`
+	Body      GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
+	Trailers  GrpcHeaders               `json:"trailers" yaml:"trailers"`
+	Timestamp time.Time                 `json:"timestamp" yaml:"timestamp,omitempty"`
 }
 
 // GrpcStream is a helper function to combine the request-response model in a single struct.
@@ -51,3 +61,15 @@ func NewGrpcStream(streamID uint32) GrpcStream {
 		},
 	}
 }
+
+// GrpcSchema points Keploy at the descriptors of the gRPC services of the application and its
+// dependencies, so that their messages are stored as protojson.
+type GrpcSchema struct {
+	// ProtoFiles are compiled with protoc, looking up their imports in ProtoIncludes.
+	ProtoFiles    []string
+	ProtoIncludes []string
+	// DescriptorSets are serialized FileDescriptorSets, as written by protoc --descriptor_set_out.
+	DescriptorSets []string
+	// Reflection asks the servers for the descriptors of the services they serve during record.
+	Reflection bool
+}
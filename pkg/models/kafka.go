@@ -0,0 +1,24 @@
+package models
+
+// KafkaRequestHeader is the decoded request header shared by every kafka api.
+type KafkaRequestHeader struct {
+	ApiKey        int16  `json:"api_key" yaml:"api_key"`
+	ApiName       string `json:"api_name" yaml:"api_name"`
+	ApiVersion    int16  `json:"api_version" yaml:"api_version"`
+	CorrelationId int32  `json:"correlation_id" yaml:"correlation_id"`
+	ClientId      string `json:"client_id,omitempty" yaml:"client_id,omitempty"`
+}
+
+// KafkaRequest stores a kafka request. Body holds the base64 encoded bytes that follow the
+// request header, including the tagged fields of flexible versions.
+type KafkaRequest struct {
+	Header KafkaRequestHeader `json:"header" yaml:"header"`
+	Body   string             `json:"body" yaml:"body"`
+}
+
+// KafkaResponse stores a kafka response. Body holds the base64 encoded bytes that follow the
+// correlation id, so that the response can be replayed with the correlation id of a new request.
+type KafkaResponse struct {
+	CorrelationId int32  `json:"correlation_id" yaml:"correlation_id"`
+	Body          string `json:"body" yaml:"body"`
+}
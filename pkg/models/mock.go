@@ -40,6 +40,17 @@ type MockSpec struct {
 	//for MySql
 	MySqlRequests  []MySQLRequest  `json:"MySqlRequests,omitempty"`
 	MySqlResponses []MySQLResponse `json:"MySqlResponses,omitempty"`
+	//for Redis
+	RedisRequests  []RedisRequest  `json:"RedisRequests,omitempty"`
+	RedisResponses []RedisResponse `json:"RedisResponses,omitempty"`
+	//for Kafka
+	KafkaRequest  *KafkaRequest  `json:"KafkaRequest,omitempty"`
+	KafkaResponse *KafkaResponse `json:"KafkaResponse,omitempty"`
+	//for DNS
+	DNSReq  *DNSReq  `json:"DNSRequest,omitempty"`
+	DNSResp *DNSResp `json:"DNSResponse,omitempty"`
+	//for WebSocket, the session follows the handshake of HttpReq and HttpResp
+	WebSocketFrames []WebSocketFrame `json:"WebSocketFrames,omitempty"`
 
 	ReqTimestampMock time.Time `json:"ReqTimestampMock,omitempty"`
 	ResTimestampMock time.Time `json:"ResTimestampMock,omitempty"`
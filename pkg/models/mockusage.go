@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// MockUsage is the consumption of the recorded mocks during the replay of a testcase.
+type MockUsage struct {
+	// Unused are the mocks recorded for the testcase which were not consumed by its replay
+	Unused []MockRef `json:"unused,omitempty" yaml:"unused,omitempty"`
+	// Unmatched are the outgoing calls of the application for which no mock was found
+	Unmatched []UnmatchedCall `json:"unmatched,omitempty" yaml:"unmatched,omitempty"`
+}
+
+// MockCoverage is the consumption of the recorded mocks over a test-set run.
+type MockCoverage struct {
+	Total int `json:"total" yaml:"total"`
+	Used  int `json:"used" yaml:"used"`
+	// Unused are the mocks which were never consumed, they are removed by --prune-unused-mocks
+	Unused []MockRef `json:"unused,omitempty" yaml:"unused,omitempty"`
+	// OverConsumed are the testcase mocks consumed more times than they were recorded
+	OverConsumed []MockRef `json:"overConsumed,omitempty" yaml:"over_consumed,omitempty"`
+	Unmatched    int       `json:"unmatched" yaml:"unmatched"`
+	// PassedThrough counts the outgoing calls sent to their destination by each pass-through rule
+	PassedThrough map[string]int `json:"passedThrough,omitempty" yaml:"passed_through,omitempty"`
+}
+
+// MockRef points to a recorded mock by its kind and the time of its request.
+type MockRef struct {
+	Kind      Kind      `json:"kind" yaml:"kind"`
+	Type      string    `json:"type,omitempty" yaml:"type,omitempty"`
+	Timestamp time.Time `json:"timestamp,omitempty" yaml:"timestamp,omitempty"`
+	Recorded  int       `json:"recorded,omitempty" yaml:"recorded,omitempty"`
+	Consumed  int       `json:"consumed,omitempty" yaml:"consumed,omitempty"`
+}
+
+// UnmatchedCall is an outgoing call of the application which was not served by any mock.
+type UnmatchedCall struct {
+	Kind    Kind   `json:"kind" yaml:"kind"`
+	Request string `json:"request" yaml:"request"`
+}
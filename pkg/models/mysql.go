@@ -59,6 +59,7 @@ type MySQLQueryPacket struct {
 
 type MySQLComStmtExecute struct {
 	StatementID    uint32           `yaml:"statement_id"`
+	Query          string           `yaml:"query"` // query of the statement, its id only identifies it on the recorded connection
 	Flags          byte             `yaml:"flags"`
 	IterationCount uint32           `yaml:"iteration_count"`
 	NullBitmap     []byte           `yaml:"null_bitmap"`
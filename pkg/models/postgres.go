@@ -18,6 +18,7 @@ type Backend struct {
 	Close               pgproto3.Close               `json:"close,omitempty" yaml:"close,omitempty"`
 	CopyFail            pgproto3.CopyFail            `json:"copy_fail,omitempty" yaml:"copy_fail,omitempty"`
 	CopyData            pgproto3.CopyData            `json:"copy_data,omitempty" yaml:"copy_data,omitempty"`
+	CopyDatas           []pgproto3.CopyData          `json:"copy_datas,omitempty" yaml:"copy_datas,omitempty"`
 	CopyDone            pgproto3.CopyDone            `json:"copy_done,omitempty" yaml:"copy_done,omitempty"`
 	Describe            pgproto3.Describe            `json:"describe,omitempty" yaml:"describe,omitempty"`
 	Execute             pgproto3.Execute             `yaml:"-"`
@@ -63,6 +64,7 @@ type Frontend struct {
 	CommandCompletes                []pgproto3.CommandComplete               `json:"command_complete,omitempty" yaml:"command_complete,omitempty"`
 	CopyBothResponse                pgproto3.CopyBothResponse                `json:"copy_both_response,omitempty" yaml:"copy_both_response,omitempty"`
 	CopyData                        pgproto3.CopyData                        `json:"copy_data,omitempty" yaml:"copy_data,omitempty"`
+	CopyDatas                       []pgproto3.CopyData                      `json:"copy_datas,omitempty" yaml:"copy_datas,omitempty"`
 	CopyInResponse                  pgproto3.CopyInResponse                  `json:"copy_in_response,omitempty" yaml:"copy_in_response,omitempty"`
 	CopyOutResponse                 pgproto3.CopyOutResponse                 `json:"copy_out_response,omitempty" yaml:"copy_out_response,omitempty"`
 	CopyDone                        pgproto3.CopyDone                        `json:"copy_done,omitempty" yaml:"copy_done,omitempty"`
@@ -74,6 +76,7 @@ type Frontend struct {
 	NoData                          pgproto3.NoData                          `json:"no_data,omitempty" yaml:"no_data,omitempty"`
 	NoticeResponse                  pgproto3.NoticeResponse                  `json:"notice_response,omitempty" yaml:"notice_response,omitempty"`
 	NotificationResponse            pgproto3.NotificationResponse            `json:"notification_response,omitempty" yaml:"notification_response,omitempty"`
+	NotificationResponses           []pgproto3.NotificationResponse          `json:"notification_responses,omitempty" yaml:"notification_responses,omitempty"`
 	ParameterDescription            pgproto3.ParameterDescription            `json:"parameter_description,omitempty" yaml:"parameter_description,omitempty"`
 	ParameterStatus                 pgproto3.ParameterStatus                 `yaml:"-"`
 	ParameterStatusCombined         []pgproto3.ParameterStatus               `json:"parameter_status,omitempty" yaml:"parameter_status,omitempty"`
@@ -0,0 +1,45 @@
+package models
+
+// RedisDataType is the RESP2/RESP3 type of a redis value, named after its wire prefix.
+type RedisDataType string
+
+const (
+	RedisSimpleString RedisDataType = "simple_string"   // +
+	RedisError        RedisDataType = "error"           // -
+	RedisInteger      RedisDataType = "integer"         // :
+	RedisBulkString   RedisDataType = "bulk_string"     // $
+	RedisArray        RedisDataType = "array"           // *
+	RedisNull         RedisDataType = "null"            // _
+	RedisDouble       RedisDataType = "double"          // ,
+	RedisBoolean      RedisDataType = "boolean"         // #
+	RedisBulkError    RedisDataType = "bulk_error"      // !
+	RedisVerbatim     RedisDataType = "verbatim_string" // =
+	RedisBigNumber    RedisDataType = "big_number"      // (
+	RedisMap          RedisDataType = "map"             // %
+	RedisSet          RedisDataType = "set"             // ~
+	RedisPush         RedisDataType = "push"            // >
+)
+
+// RedisValue is a decoded RESP value. Aggregate types (array, map, set, push) keep their
+// children in Elements, maps are stored as a flat list of alternating keys and values.
+type RedisValue struct {
+	Type       RedisDataType `json:"type" yaml:"type"`
+	Data       string        `json:"data,omitempty" yaml:"data,omitempty"`
+	Encoding   string        `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+	Null       bool          `json:"null,omitempty" yaml:"null,omitempty"`
+	Elements   []RedisValue  `json:"elements,omitempty" yaml:"elements,omitempty"`
+	Attributes []RedisValue  `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+}
+
+// RedisRequest stores a single command sent by the client, e.g. SET user:1 alice.
+// Args holds the arguments following the command name (and its subcommand, if any).
+type RedisRequest struct {
+	Command  string   `json:"command" yaml:"command"`
+	Key      string   `json:"key,omitempty" yaml:"key,omitempty"`
+	Args     []string `json:"args,omitempty" yaml:"args,omitempty,flow"`
+	Encoding string   `json:"encoding,omitempty" yaml:"encoding,omitempty"`
+}
+
+type RedisResponse struct {
+	Message RedisValue `json:"message" yaml:"message"`
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type Kind string
 type BodyType string
 type Version string
@@ -25,6 +27,10 @@ const (
 	Postgres       Kind     = "Postgres"
 	GRPC_EXPORT    Kind     = "gRPC"
 	Mongo          Kind     = "Mongo"
+	Redis          Kind     = "Redis"
+	Kafka          Kind     = "Kafka"
+	DNS            Kind     = "DNS"
+	WebSocket      Kind     = "WebSocket"
 	BodyTypeUtf8   BodyType = "utf-8"
 	BodyTypeBinary BodyType = "binary"
 	BodyTypePlain  BodyType = "PLAIN"
@@ -46,10 +52,31 @@ type TestCase struct {
 	GrpcReq  GrpcReq             `json:"grpcReq"`
 	Anchors  map[string][]string `json:"anchors"`
 	Noise    map[string][]string `json:"noise"`
+	Rules    AssertionRules      `json:"rules"`
 	Mocks    []*Mock             `json:"mocks"`
 	Type     string              `json:"type"`
+	// WebSocketFrames are the frames of the session opened by the handshake of HttpReq and HttpResp
+	WebSocketFrames []WebSocketFrame `json:"webSocketFrames"`
 }
 
 func (tc *TestCase) GetKind() string {
 	return string(tc.Kind)
 }
+
+// Timestamps returns the times at which the application received the request of the testcase
+// and sent its response. A websocket session lasts until its last frame.
+func (tc *TestCase) Timestamps() (time.Time, time.Time) {
+	switch tc.Kind {
+	case GRPC_EXPORT:
+		return tc.GrpcReq.Timestamp, tc.GrpcResp.Timestamp
+	case WebSocket:
+		end := tc.HttpResp.Timestamp
+		for _, frame := range tc.WebSocketFrames {
+			if frame.Timestamp.After(end) {
+				end = frame.Timestamp
+			}
+		}
+		return tc.HttpReq.Timestamp, end
+	}
+	return tc.HttpReq.Timestamp, tc.HttpResp.Timestamp
+}
@@ -1,14 +1,15 @@
 package models
 
 type TestReport struct {
-	Version Version      `json:"version" yaml:"version"`
-	Name    string       `json:"name" yaml:"name"`
-	Status  string       `json:"status" yaml:"status"`
-	Success int          `json:"success" yaml:"success"`
-	Failure int          `json:"failure" yaml:"failure"`
-	Total   int          `json:"total" yaml:"total"`
-	Tests   []TestResult `json:"tests" yaml:"tests,omitempty"`
-	TestSet string       `json:"testSet" yaml:"test_set"`
+	Version Version       `json:"version" yaml:"version"`
+	Name    string        `json:"name" yaml:"name"`
+	Status  string        `json:"status" yaml:"status"`
+	Success int           `json:"success" yaml:"success"`
+	Failure int           `json:"failure" yaml:"failure"`
+	Total   int           `json:"total" yaml:"total"`
+	Tests   []TestResult  `json:"tests" yaml:"tests,omitempty"`
+	TestSet string        `json:"testSet" yaml:"test_set"`
+	Mocks   *MockCoverage `json:"mocks,omitempty" yaml:"mocks,omitempty"`
 }
 
 func (tr *TestReport) GetKind() string {
@@ -26,8 +27,13 @@ type TestResult struct {
 	TestCaseID   string     `json:"testCaseID" yaml:"test_case_id"`
 	Req          HttpReq    `json:"req" yaml:"req,omitempty"`
 	Res          HttpResp   `json:"resp" yaml:"resp,omitempty"`
+	GrpcReq      GrpcReq    `json:"grpcReq" yaml:"grpcReq,omitempty"`
+	GrpcRes      GrpcResp   `json:"grpcResp" yaml:"grpcResp,omitempty"`
 	Noise        Noise      `json:"noise" yaml:"noise,omitempty"`
 	Result       Result     `json:"result" yaml:"result"`
+	Mocks        MockUsage  `json:"mocks" yaml:"mocks,omitempty"`
+	// WebSocketFrames are the frames the application sent during the replayed session
+	WebSocketFrames []WebSocketFrame `json:"webSocketFrames,omitempty" yaml:"webSocketFrames,omitempty"`
 }
 
 func (tr *TestResult) GetKind() string {
@@ -46,10 +52,21 @@ const (
 )
 
 type Result struct {
-	StatusCode    IntResult      `json:"status_code" bson:"status_code" yaml:"status_code"`
-	HeadersResult []HeaderResult `json:"headers_result" bson:"headers_result" yaml:"headers_result"`
-	BodyResult    []BodyResult   `json:"body_result" bson:"body_result" yaml:"body_result"`
-	DepResult     []DepResult    `json:"dep_result" bson:"dep_result" yaml:"dep_result"`
+	StatusCode    IntResult         `json:"status_code" bson:"status_code" yaml:"status_code"`
+	HeadersResult []HeaderResult    `json:"headers_result" bson:"headers_result" yaml:"headers_result"`
+	BodyResult    []BodyResult      `json:"body_result" bson:"body_result" yaml:"body_result"`
+	DepResult     []DepResult       `json:"dep_result" bson:"dep_result" yaml:"dep_result"`
+	Latency       *LatencyResult    `json:"latency,omitempty" bson:"latency,omitempty" yaml:"latency,omitempty"`
+	RuleResult    []AssertionResult `json:"rule_result,omitempty" bson:"rule_result,omitempty" yaml:"rule_result,omitempty"`
+}
+
+// LatencyResult compares the response time of the replayed call with the recorded one, in milliseconds.
+type LatencyResult struct {
+	Normal   bool   `json:"normal" bson:"normal" yaml:"normal"`
+	Expected int64  `json:"expected" bson:"expected" yaml:"expected"`
+	Actual   int64  `json:"actual" bson:"actual" yaml:"actual"`
+	Budget   int64  `json:"budget" bson:"budget" yaml:"budget"`
+	Mode     string `json:"mode" bson:"mode" yaml:"mode"`
 }
 
 type DepResult struct {
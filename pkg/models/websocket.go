@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// types of the WebSocket frames
+const (
+	WebSocketText   string = "text"
+	WebSocketBinary string = "binary"
+	WebSocketPing   string = "ping"
+	WebSocketPong   string = "pong"
+	WebSocketClose  string = "close"
+)
+
+// WebSocketFrame stores a message of a WebSocket session, the fragments of a message are joined. The frames
+// of a session are stored in the order they were sent, Origin tells which side sent them. The data of the
+// text frames and the reason of the close frames are kept as is, the other data is base64 encoded.
+type WebSocketFrame struct {
+	Origin    OriginType `json:"origin" yaml:"origin"`
+	Type      string     `json:"type" yaml:"type"`
+	Data      string     `json:"data,omitempty" yaml:"data,omitempty"`
+	CloseCode int        `json:"closeCode,omitempty" yaml:"closeCode,omitempty"`
+	Timestamp time.Time  `json:"timestamp" yaml:"timestamp,omitempty"`
+}
@@ -0,0 +1,90 @@
+package export
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+const (
+	FormatJUnit = "junit"
+	FormatJSON  = "json"
+)
+
+// Exporter writes the test reports of a test run into a format that CI systems understand.
+type Exporter interface {
+	// Export writes the reports, one per test-set, into a file under dir and returns its path.
+	Export(reports []*models.TestReport, dir string) (string, error)
+}
+
+// NewExporter returns the exporter for the given report format.
+func NewExporter(format string) (Exporter, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case FormatJUnit:
+		return &junitExporter{}, nil
+	case FormatJSON:
+		return &jsonExporter{}, nil
+	}
+	return nil, fmt.Errorf("invalid report format %q, should be one of %s or %s", format, FormatJUnit, FormatJSON)
+}
+
+// ExportAll writes the reports in every requested format into dir.
+func ExportAll(reports []*models.TestReport, formats []string, dir string, logger *zap.Logger) error {
+	if len(formats) == 0 {
+		return nil
+	}
+	err := os.MkdirAll(dir, os.ModePerm)
+	if err != nil {
+		logger.Error("failed to create the directory for the exported test reports", zap.Error(err), zap.String("path", dir))
+		return err
+	}
+	for _, format := range formats {
+		exporter, err := NewExporter(format)
+		if err != nil {
+			return err
+		}
+		path, err := exporter.Export(reports, dir)
+		if err != nil {
+			logger.Error("failed to export the test reports", zap.Error(err), zap.String("format", format))
+			return err
+		}
+		logger.Info("exported the test reports", zap.String("format", format), zap.String("path", path))
+	}
+	return nil
+}
+
+// failures lists a readable line for every status code, header, latency and body mismatch of a test result.
+func failures(result models.Result) []string {
+	lines := []string{}
+	if !result.StatusCode.Normal {
+		lines = append(lines, fmt.Sprintf("status code: expected %d, actual %d", result.StatusCode.Expected, result.StatusCode.Actual))
+	}
+	for _, header := range result.HeadersResult {
+		if header.Normal {
+			continue
+		}
+		key := header.Expected.Key
+		if key == "" {
+			key = header.Actual.Key
+		}
+		lines = append(lines, fmt.Sprintf("header %s: expected %q, actual %q", key, strings.Join(header.Expected.Value, ", "), strings.Join(header.Actual.Value, ", ")))
+	}
+	if result.Latency != nil && !result.Latency.Normal {
+		lines = append(lines, fmt.Sprintf("response time: recorded %dms, budget %dms, actual %dms", result.Latency.Expected, result.Latency.Budget, result.Latency.Actual))
+	}
+	for _, rule := range result.RuleResult {
+		if !rule.Normal {
+			lines = append(lines, fmt.Sprintf("assertion %s: %s", rule.Path, rule.Message))
+		}
+	}
+	for _, body := range result.BodyResult {
+		if body.Normal {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("body (%s): expected %s, actual %s", body.Type, body.Expected, body.Actual))
+	}
+	return lines
+}
@@ -0,0 +1,87 @@
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// jsonReportVersion is bumped whenever a field of the json report is changed or removed,
+// so that consumers can rely on its shape.
+const jsonReportVersion = 1
+
+type jsonReport struct {
+	Version  int           `json:"version"`
+	Total    int           `json:"total"`
+	Passed   int           `json:"passed"`
+	Failed   int           `json:"failed"`
+	TestSets []jsonTestSet `json:"testSets"`
+}
+
+type jsonTestSet struct {
+	Name   string     `json:"name"`
+	Report string     `json:"report"`
+	Status string     `json:"status"`
+	Total  int        `json:"total"`
+	Passed int        `json:"passed"`
+	Failed int        `json:"failed"`
+	Tests  []jsonTest `json:"tests"`
+}
+
+type jsonTest struct {
+	Name      string            `json:"name"`
+	Status    models.TestStatus `json:"status"`
+	Started   int64             `json:"started"`
+	Completed int64             `json:"completed"`
+	Failures  []string          `json:"failures"`
+	Result    models.Result     `json:"result"`
+}
+
+type jsonExporter struct{}
+
+// Export writes the reports of every test-set into keploy-report.json.
+func (e *jsonExporter) Export(reports []*models.TestReport, dir string) (string, error) {
+	out := jsonReport{Version: jsonReportVersion, TestSets: []jsonTestSet{}}
+	for _, report := range reports {
+		testSet := jsonTestSet{
+			Name:   report.TestSet,
+			Report: report.Name,
+			Status: report.Status,
+			Total:  report.Total,
+			Passed: report.Success,
+			Failed: report.Failure,
+			Tests:  []jsonTest{},
+		}
+		for _, test := range report.Tests {
+			jsonTest := jsonTest{
+				Name:      test.TestCaseID,
+				Status:    test.Status,
+				Started:   test.Started,
+				Completed: test.Completed,
+				Failures:  []string{},
+				Result:    test.Result,
+			}
+			if test.Status != models.TestStatusPassed {
+				jsonTest.Failures = failures(test.Result)
+			}
+			testSet.Tests = append(testSet.Tests, jsonTest)
+		}
+		out.Total += testSet.Total
+		out.Passed += testSet.Passed
+		out.Failed += testSet.Failed
+		out.TestSets = append(out.TestSets, testSet)
+	}
+
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "keploy-report.json")
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,108 @@
+package export
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitExporter struct{}
+
+// Export writes a <testsuite> per test-set and a <testcase> per test into junit.xml.
+func (e *junitExporter) Export(reports []*models.TestReport, dir string) (string, error) {
+	suites := junitTestSuites{Name: "keploy"}
+	for _, report := range reports {
+		suite := junitTestSuite{
+			Name:     report.TestSet,
+			Tests:    len(report.Tests),
+			Failures: report.Failure,
+		}
+		var started, completed int64
+		for _, test := range report.Tests {
+			if started == 0 || test.Started < started {
+				started = test.Started
+			}
+			if test.Completed > completed {
+				completed = test.Completed
+			}
+			testCase := junitTestCase{
+				Name:      test.TestCaseID,
+				ClassName: report.TestSet,
+				Time:      seconds(test.Started, test.Completed),
+			}
+			if test.Status != models.TestStatusPassed {
+				lines := failures(test.Result)
+				message := "testcase " + test.TestCaseID + " failed"
+				if len(lines) > 0 {
+					message = lines[0]
+				}
+				testCase.Failure = &junitFailure{
+					Message: message,
+					Type:    string(test.Status),
+					Text:    strings.Join(lines, "\n"),
+				}
+			}
+			suite.Cases = append(suite.Cases, testCase)
+		}
+		suite.Time = seconds(started, completed)
+		if started != 0 {
+			suite.Timestamp = time.Unix(started, 0).UTC().Format("2006-01-02T15:04:05")
+		}
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "junit.xml")
+	err = os.WriteFile(path, append([]byte(xml.Header), data...), 0644)
+	if err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func seconds(started, completed int64) string {
+	if completed < started {
+		return "0"
+	}
+	return strconv.FormatInt(completed-started, 10)
+}
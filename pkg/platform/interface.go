@@ -13,6 +13,19 @@ type TestCaseDB interface {
 	ReadConfigMocks(path string) ([]KindSpecifier, error)
 }
 
+// TestCaseUpdater is implemented by the stores that can rewrite or remove a recorded testcase in place.
+type TestCaseUpdater interface {
+	UpdateTest(path string, tc KindSpecifier, ctx context.Context) error
+	DeleteTest(path, name string, ctx context.Context) error
+}
+
+// MockPruner is implemented by the stores that can remove recorded mocks in place.
+type MockPruner interface {
+	// DeleteMocks removes the mocks of the test-set in the path for which remove returns true and
+	// returns how many were removed.
+	DeleteMocks(path string, remove func(mock KindSpecifier) bool, ctx context.Context) (int, error)
+}
+
 type TestReportDB interface {
 	Lock()
 	Unlock()
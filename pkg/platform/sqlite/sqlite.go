@@ -0,0 +1,511 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.keploy.io/server/pkg/platform/telemetry"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite"
+)
+
+var Emoji = "\U0001F430" + " Keploy:"
+
+// schema keeps every document as the same yaml that the yaml store writes, next to the columns
+// needed to query it. Mocks are indexed by kind and timestamp so that the mocks of a testcase can
+// be selected by its request/response window without decoding the whole test-set.
+const schema = `
+CREATE TABLE IF NOT EXISTS testcases (
+	test_set TEXT NOT NULL,
+	name     TEXT NOT NULL,
+	kind     TEXT NOT NULL,
+	created  INTEGER NOT NULL,
+	doc      TEXT NOT NULL,
+	PRIMARY KEY (test_set, name)
+);
+CREATE TABLE IF NOT EXISTS mocks (
+	id            INTEGER PRIMARY KEY AUTOINCREMENT,
+	test_set      TEXT NOT NULL,
+	name          TEXT NOT NULL,
+	kind          TEXT NOT NULL,
+	type          TEXT NOT NULL,
+	req_timestamp INTEGER NOT NULL,
+	res_timestamp INTEGER NOT NULL,
+	doc           TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS mocks_kind_timestamp ON mocks (test_set, kind, req_timestamp);
+CREATE INDEX IF NOT EXISTS mocks_timestamp ON mocks (test_set, type, req_timestamp, res_timestamp);
+CREATE TABLE IF NOT EXISTS test_reports (
+	name    TEXT PRIMARY KEY,
+	created INTEGER NOT NULL,
+	doc     TEXT NOT NULL
+);
+`
+
+type Sqlite struct {
+	TcsPath  string
+	MockPath string
+	DbPath   string
+	Logger   *zap.Logger
+	db       *sql.DB
+	tele     *telemetry.Telemetry
+	mutex    sync.Mutex
+}
+
+// Open opens the sqlite file at dbPath, creating it along with its tables if it does not exist.
+func Open(dbPath string, logger *zap.Logger) (*sql.DB, error) {
+	err := os.MkdirAll(filepath.Dir(dbPath), os.ModePerm)
+	if err != nil {
+		logger.Error("failed to create the directory for the sqlite file", zap.Error(err), zap.Any("path", dbPath))
+		return nil, err
+	}
+	// the testcases and the test reports of a run are stored in the same file through two handles, a writer of
+	// one waits for the lock of the other instead of failing with SQLITE_BUSY, and WAL lets it read meanwhile
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		logger.Error("failed to open the sqlite file", zap.Error(err), zap.Any("path", dbPath))
+		return nil, err
+	}
+	// sqlite allows a single writer, so the connections are serialised instead of failing with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(schema)
+	if err != nil {
+		logger.Error("failed to create the sqlite tables", zap.Error(err), zap.Any("path", dbPath))
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSqliteStore returns a TestCaseDB that stores the testcases and mocks in a single sqlite file.
+// tcsPath and mockPath are used in the same way as in the yaml store, their test-set directory
+// names identify the rows of a test-set.
+func NewSqliteStore(dbPath, tcsPath, mockPath string, Logger *zap.Logger, tele *telemetry.Telemetry) (*Sqlite, error) {
+	db, err := Open(dbPath, Logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Sqlite{
+		TcsPath:  tcsPath,
+		MockPath: mockPath,
+		DbPath:   dbPath,
+		Logger:   Logger,
+		db:       db,
+		tele:     tele,
+		mutex:    sync.Mutex{},
+	}, nil
+}
+
+func (s *Sqlite) Close() error {
+	return s.db.Close()
+}
+
+// testSetOf returns the test-set name for the testcase path (<test-set>/tests) or the mock path (<test-set>).
+func testSetOf(path string) string {
+	path = filepath.Clean(path)
+	if filepath.Base(path) == "tests" {
+		path = filepath.Dir(path)
+	}
+	return filepath.Base(path)
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// nextIndex returns the index for a new document named <prefix>-<index>.
+func nextIndex(names []string, prefix string) int {
+	lastIndex := 0
+	for _, name := range names {
+		indxStr, ok := strings.CutPrefix(name, prefix+"-")
+		if !ok {
+			continue
+		}
+		indx, err := strconv.Atoi(indxStr)
+		if err != nil {
+			continue
+		}
+		if indx > lastIndex {
+			lastIndex = indx
+		}
+	}
+	return lastIndex + 1
+}
+
+func (s *Sqlite) queryStrings(query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	values := []string{}
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+func (s *Sqlite) WriteTestcase(tcRead platform.KindSpecifier, ctx context.Context, filtersRead platform.KindSpecifier) error {
+	tc, ok := tcRead.(*models.TestCase)
+	if !ok {
+		return fmt.Errorf("%s failed to read testcase in WriteTestcase", Emoji)
+	}
+	if yaml.IsBypassed(tc, filtersRead) {
+		return nil
+	}
+
+	s.tele.RecordedTestAndMocks()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	testsTotal, ok := ctx.Value("testsTotal").(*int)
+	if !ok {
+		s.Logger.Debug("failed to get testsTotal from context")
+	} else {
+		*testsTotal++
+	}
+
+	testSet := testSetOf(s.TcsPath)
+	tcsName := tc.Name
+	if tcsName == "" {
+		names, err := s.queryStrings("SELECT name FROM testcases WHERE test_set = ?", testSet)
+		if err != nil {
+			s.Logger.Error("failed to read the testcase names from sqlite", zap.Error(err))
+			return err
+		}
+		tcsName = fmt.Sprintf("test-%v", nextIndex(names, "test"))
+	}
+
+	doc, err := yaml.EncodeTestcase(*tc, s.Logger)
+	if err != nil {
+		return err
+	}
+	doc.Name = tcsName
+	data, err := yamlLib.Marshal(doc)
+	if err != nil {
+		s.Logger.Error("failed to marshal the testcase", zap.Error(err), zap.Any("testcase name", tcsName))
+		return err
+	}
+	_, err = s.db.Exec("INSERT OR REPLACE INTO testcases (test_set, name, kind, created, doc) VALUES (?, ?, ?, ?, ?)",
+		testSet, tcsName, string(tc.Kind), tc.Created, string(data))
+	if err != nil {
+		s.Logger.Error("failed to write the testcase into sqlite", zap.Error(err), zap.Any("testcase name", tcsName))
+		return err
+	}
+	s.Logger.Info("🟠 Keploy has captured test cases for the user's application.", zap.String("path", s.DbPath), zap.String("test-set", testSet), zap.String("testcase name", tcsName))
+	return nil
+}
+
+func (s *Sqlite) ReadTestcase(path string, lastSeenId platform.KindSpecifier, options platform.KindSpecifier) ([]platform.KindSpecifier, error) {
+	if path == "" {
+		path = s.TcsPath
+	}
+	docs, err := s.queryStrings("SELECT doc FROM testcases WHERE test_set = ? ORDER BY created, rowid", testSetOf(path))
+	if err != nil {
+		s.Logger.Error("failed to read the testcases from sqlite", zap.Error(err), zap.Any("test-set", testSetOf(path)))
+		return nil, err
+	}
+	tcsRead := make([]platform.KindSpecifier, 0, len(docs))
+	for _, data := range docs {
+		var doc yaml.NetworkTrafficDoc
+		err := yamlLib.Unmarshal([]byte(data), &doc)
+		if err != nil {
+			s.Logger.Error("failed to unmarshal the testcase stored in sqlite", zap.Error(err))
+			return nil, err
+		}
+		tc, err := yaml.Decode(&doc, s.Logger)
+		if err != nil {
+			return nil, err
+		}
+		tcsRead = append(tcsRead, tc)
+	}
+	return tcsRead, nil
+}
+
+// SetNoise replaces the noise of the testcase stored for the test-set of the path, TcsPath is used
+// when the path is empty.
+func (s *Sqlite) SetNoise(path, name string, noise map[string][]string) error {
+	return s.updateTestcase(path, name, func(tc *models.TestCase) {
+		tc.Noise = noise
+	})
+}
+
+// UpdateTest rewrites the request and response of the testcase stored for the test-set of the path,
+// its noise and assertion rules are kept.
+func (s *Sqlite) UpdateTest(path string, tcRead platform.KindSpecifier, ctx context.Context) error {
+	updated, ok := tcRead.(*models.TestCase)
+	if !ok {
+		return fmt.Errorf("%s failed to read testcase in UpdateTest", Emoji)
+	}
+	return s.updateTestcase(path, updated.Name, func(tc *models.TestCase) {
+		tc.HttpReq = updated.HttpReq
+		tc.HttpResp = updated.HttpResp
+	})
+}
+
+// DeleteTest removes the testcase stored for the test-set of the path.
+func (s *Sqlite) DeleteTest(path, name string, ctx context.Context) error {
+	if path == "" {
+		path = s.TcsPath
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.db.Exec("DELETE FROM testcases WHERE test_set = ? AND name = ?", testSetOf(path), name)
+	if err != nil {
+		s.Logger.Error("failed to delete the testcase from sqlite", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	return nil
+}
+
+// DeleteMocks removes the mocks of the test-set of the path for which remove returns true.
+func (s *Sqlite) DeleteMocks(path string, remove func(mock platform.KindSpecifier) bool, ctx context.Context) (int, error) {
+	if path == "" {
+		path = s.MockPath
+	}
+	testSet := testSetOf(path)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	rows, err := s.db.Query("SELECT id, doc FROM mocks WHERE test_set = ? ORDER BY id", testSet)
+	if err != nil {
+		s.Logger.Error("failed to read the mocks to prune from sqlite", zap.Error(err), zap.Any("test-set", testSet))
+		return 0, err
+	}
+	ids := []int64{}
+	for rows.Next() {
+		var (
+			id   int64
+			data string
+			doc  yaml.NetworkTrafficDoc
+		)
+		if err := rows.Scan(&id, &data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if err := yamlLib.Unmarshal([]byte(data), &doc); err != nil {
+			continue
+		}
+		mocks, err := yaml.DecodeMocks([]*yaml.NetworkTrafficDoc{&doc}, s.Logger)
+		if err == nil && len(mocks) == 1 && remove(mocks[0]) {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		_, err := s.db.Exec("DELETE FROM mocks WHERE id = ?", id)
+		if err != nil {
+			s.Logger.Error("failed to delete the mock from sqlite", zap.Error(err), zap.Any("test-set", testSet))
+			return 0, err
+		}
+	}
+	return len(ids), nil
+}
+
+// updateTestcase decodes a stored testcase, applies update to it and stores it back.
+func (s *Sqlite) updateTestcase(path, name string, update func(tc *models.TestCase)) error {
+	if path == "" {
+		path = s.TcsPath
+	}
+	testSet := testSetOf(path)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	docs, err := s.queryStrings("SELECT doc FROM testcases WHERE test_set = ? AND name = ?", testSet, name)
+	if err != nil {
+		s.Logger.Error("failed to read the testcase from sqlite", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	if len(docs) == 0 {
+		return fmt.Errorf("%s testcase %s not found in test-set %s", Emoji, name, testSet)
+	}
+	var doc yaml.NetworkTrafficDoc
+	if err := yamlLib.Unmarshal([]byte(docs[0]), &doc); err != nil {
+		s.Logger.Error("failed to unmarshal the testcase stored in sqlite", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	tc, err := yaml.Decode(&doc, s.Logger)
+	if err != nil {
+		return err
+	}
+	update(tc)
+
+	updated, err := yaml.EncodeTestcase(*tc, s.Logger)
+	if err != nil {
+		return err
+	}
+	updated.Name = name
+	data, err := yamlLib.Marshal(updated)
+	if err != nil {
+		s.Logger.Error("failed to marshal the testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	_, err = s.db.Exec("UPDATE testcases SET kind = ?, created = ?, doc = ? WHERE test_set = ? AND name = ?", string(tc.Kind), tc.Created, string(data), testSet, name)
+	if err != nil {
+		s.Logger.Error("failed to update the testcase in sqlite", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	return nil
+}
+
+func (s *Sqlite) WriteMock(mockRead platform.KindSpecifier, ctx context.Context) error {
+	mock, ok := mockRead.(*models.Mock)
+	if !ok {
+		return fmt.Errorf("%s failed to read mock in WriteMock", Emoji)
+	}
+	mocksTotal, ok := ctx.Value("mocksTotal").(*map[string]int)
+	if !ok {
+		s.Logger.Debug("failed to get mocksTotal from context")
+	} else {
+		s.mutex.Lock()
+		(*mocksTotal)[string(mock.Kind)]++
+		s.mutex.Unlock()
+	}
+	if mock.Name == "" {
+		mock.Name = "mocks"
+	}
+
+	doc, err := yaml.EncodeMock(mock, s.Logger)
+	if err != nil {
+		return err
+	}
+	data, err := yamlLib.Marshal(doc)
+	if err != nil {
+		s.Logger.Error("failed to marshal the mock", zap.Error(err))
+		return err
+	}
+	_, err = s.db.Exec("INSERT INTO mocks (test_set, name, kind, type, req_timestamp, res_timestamp, doc) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		testSetOf(s.MockPath), mock.Name, string(mock.Kind), mock.Spec.Metadata["type"],
+		unixNano(mock.Spec.ReqTimestampMock), unixNano(mock.Spec.ResTimestampMock), string(data))
+	if err != nil {
+		s.Logger.Error("failed to write the mock into sqlite", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+func (s *Sqlite) readMocks(query string, args ...interface{}) ([]*models.Mock, error) {
+	docs, err := s.queryStrings(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	yamlDocs := make([]*yaml.NetworkTrafficDoc, 0, len(docs))
+	for _, data := range docs {
+		var doc yaml.NetworkTrafficDoc
+		err := yamlLib.Unmarshal([]byte(data), &doc)
+		if err != nil {
+			return nil, err
+		}
+		yamlDocs = append(yamlDocs, &doc)
+	}
+	return yaml.DecodeMocks(yamlDocs, s.Logger)
+}
+
+// ReadTcsMocks returns the non-config mocks of the test-set. When a testcase is passed, only the
+// mocks recorded between its request and response are selected, the filter runs in sqlite.
+func (s *Sqlite) ReadTcsMocks(tcRead platform.KindSpecifier, path string) ([]platform.KindSpecifier, error) {
+	if path == "" {
+		path = s.MockPath
+	}
+	testSet := testSetOf(path)
+	tc, readTcs := tcRead.(*models.TestCase)
+
+	query := "SELECT doc FROM mocks WHERE test_set = ? AND type != 'config' ORDER BY id"
+	args := []interface{}{testSet}
+	if readTcs {
+		reqTimestamp, resTimestamp := tc.Timestamps()
+		switch {
+		case reqTimestamp.IsZero():
+			s.Logger.Warn("request timestamp is missing for " + tc.Name)
+		case resTimestamp.IsZero():
+			s.Logger.Warn("response timestamp is missing for " + tc.Name)
+		default:
+			// mocks without timestamps are kept to support the ones recorded by older versions
+			query = `SELECT doc FROM mocks WHERE test_set = ? AND type != 'config' AND (
+				((req_timestamp = 0 OR res_timestamp = 0) AND kind != 'SQL') OR
+				(req_timestamp > ? AND res_timestamp < ?)) ORDER BY id`
+			args = append(args, reqTimestamp.UnixNano(), resTimestamp.UnixNano())
+		}
+	}
+
+	mocks, err := s.readMocks(query, args...)
+	if err != nil {
+		s.Logger.Error("failed to read the mocks from sqlite", zap.Error(err), zap.Any("test-set", testSet))
+		return nil, err
+	}
+	tcsMocks := make([]platform.KindSpecifier, 0, len(mocks))
+	for _, mock := range mocks {
+		tcsMocks = append(tcsMocks, mock)
+	}
+	return tcsMocks, nil
+}
+
+func (s *Sqlite) ReadConfigMocks(path string) ([]platform.KindSpecifier, error) {
+	if path == "" {
+		path = s.MockPath
+	}
+	testSet := testSetOf(path)
+	mocks, err := s.readMocks("SELECT doc FROM mocks WHERE test_set = ? AND type = 'config' ORDER BY id", testSet)
+	if err != nil {
+		s.Logger.Error("failed to read the config mocks from sqlite", zap.Error(err), zap.Any("test-set", testSet))
+		return nil, err
+	}
+	configMocks := make([]platform.KindSpecifier, 0, len(mocks))
+	for _, mock := range mocks {
+		configMocks = append(configMocks, mock)
+	}
+	return configMocks, nil
+}
+
+// ReadSessionIndices returns the names of the test-sets stored in the sqlite file.
+func (s *Sqlite) ReadSessionIndices() ([]string, error) {
+	names, err := s.queryStrings("SELECT test_set FROM testcases UNION SELECT test_set FROM mocks ORDER BY 1")
+	if err != nil {
+		return nil, err
+	}
+	indices := []string{}
+	for _, name := range names {
+		indxStr, ok := strings.CutPrefix(name, models.TestSetPattern)
+		if _, err := strconv.Atoi(indxStr); ok && err == nil {
+			indices = append(indices, name)
+		}
+	}
+	return indices, nil
+}
+
+// NewSessionIndex returns the name for the next test-set stored in the sqlite file.
+func (s *Sqlite) NewSessionIndex() (string, error) {
+	indices, err := s.ReadSessionIndices()
+	if err != nil {
+		return "", err
+	}
+	indx := 0
+	for _, name := range indices {
+		fileIndx, _ := strconv.Atoi(strings.TrimPrefix(name, models.TestSetPattern))
+		if indx < fileIndx+1 {
+			indx = fileIndx + 1
+		}
+	}
+	return fmt.Sprintf("%s%v", models.TestSetPattern, indx), nil
+}
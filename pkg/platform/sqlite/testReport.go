@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+type TestReport struct {
+	tests  map[string][]platform.KindSpecifier
+	m      sync.Mutex
+	db     *sql.DB
+	Logger *zap.Logger
+	// w serialises the writes, so that test-sets running in parallel get distinct report names
+	w sync.Mutex
+}
+
+// NewTestReportDB returns a TestReportDB that stores the test reports in the sqlite file at dbPath.
+// The path arguments of Read and Write are ignored, a report is identified by its name.
+func NewTestReportDB(dbPath string, logger *zap.Logger) (*TestReport, error) {
+	db, err := Open(dbPath, logger)
+	if err != nil {
+		return nil, err
+	}
+	return &TestReport{
+		tests:  make(map[string][]platform.KindSpecifier),
+		m:      sync.Mutex{},
+		db:     db,
+		Logger: logger,
+	}, nil
+}
+
+func (tr *TestReport) Lock() {
+	tr.m.Lock()
+}
+
+func (tr *TestReport) Unlock() {
+	tr.m.Unlock()
+}
+
+func (tr *TestReport) SetResult(runId string, test platform.KindSpecifier) {
+	tr.m.Lock()
+	tr.tests[runId] = append(tr.tests[runId], test)
+	tr.m.Unlock()
+}
+
+func (tr *TestReport) GetResults(runId string) ([]platform.KindSpecifier, error) {
+	tr.m.Lock()
+	defer tr.m.Unlock()
+	testResults, ok := tr.tests[runId]
+	if !ok {
+		return nil, fmt.Errorf("%s found no test results for test report with id: %s", Emoji, runId)
+	}
+	return testResults, nil
+}
+
+// Names returns the names of the stored test reports in the order they were first written.
+func (tr *TestReport) Names() ([]string, error) {
+	rows, err := tr.db.Query("SELECT name FROM test_reports ORDER BY created, rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+func (tr *TestReport) Read(ctx context.Context, path, name string) (platform.KindSpecifier, error) {
+	var data string
+	err := tr.db.QueryRow("SELECT doc FROM test_reports WHERE name = ?", name).Scan(&data)
+	if err != nil {
+		return &models.TestReport{}, err
+	}
+	var doc models.TestReport
+	err = yamlLib.Unmarshal([]byte(data), &doc)
+	if err != nil {
+		return &models.TestReport{}, fmt.Errorf("%s failed to decode the test report stored in sqlite. error: %v", Emoji, err.Error())
+	}
+	return &doc, nil
+}
+
+func (tr *TestReport) Write(ctx context.Context, path string, doc platform.KindSpecifier) error {
+	readDock, ok := doc.(*models.TestReport)
+	if !ok {
+		return fmt.Errorf("%s failed to read test report in sqlite.", Emoji)
+	}
+	tr.w.Lock()
+	defer tr.w.Unlock()
+	if readDock.Name == "" {
+		names, err := tr.Names()
+		if err != nil {
+			return fmt.Errorf("%s failed to read the test report names from sqlite. error: %s", Emoji, err.Error())
+		}
+		readDock.Name = fmt.Sprintf("report-%v", nextIndex(names, "report"))
+	}
+
+	data, err := yamlLib.Marshal(readDock)
+	if err != nil {
+		return fmt.Errorf("%s failed to marshal document to yaml. error: %s", Emoji, err.Error())
+	}
+	// keep the created time of the first write, the report is rewritten once the test-set completes
+	_, err = tr.db.Exec(`INSERT INTO test_reports (name, created, doc) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET doc = excluded.doc`, readDock.Name, time.Now().UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("%s failed to write test report in sqlite. error: %s", Emoji, err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package yaml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// SetRules replaces the assertion rules of the testcase stored in the path, TcsPath is used when
+// the path is empty.
+func (ys *Yaml) SetRules(path, name string, rules models.AssertionRules) error {
+	return ys.setAssertion(path, name, "rules", rules)
+}
+
+// SetNoise replaces the noise of the testcase stored in the path, TcsPath is used when the path
+// is empty.
+func (ys *Yaml) SetNoise(path, name string, noise map[string][]string) error {
+	return ys.setAssertion(path, name, "noise", noise)
+}
+
+// setAssertion replaces one key of the testcase assertions. The file is edited as a yaml node
+// tree, so the rest of the recorded testcase is kept as it was written.
+func (ys *Yaml) setAssertion(path, name, key string, value interface{}) error {
+	if path == "" {
+		path = ys.TcsPath
+	}
+	yamlPath := filepath.Join(path, name+".yaml")
+	data, err := os.ReadFile(yamlPath)
+	if err != nil {
+		ys.Logger.Error("failed to read the yaml testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+
+	var doc yamlLib.Node
+	if err := yamlLib.Unmarshal(data, &doc); err != nil {
+		ys.Logger.Error("failed to unmarshal the yaml testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return fmt.Errorf("testcase %s is empty", name)
+	}
+	spec := mappingValue(doc.Content[0], "spec")
+	if spec == nil {
+		return fmt.Errorf("testcase %s has no spec", name)
+	}
+	assertions := mappingValue(spec, "assertions")
+	if assertions == nil || assertions.Kind != yamlLib.MappingNode {
+		assertions = &yamlLib.Node{Kind: yamlLib.MappingNode, Tag: "!!map"}
+		setMappingValue(spec, "assertions", assertions)
+	}
+
+	var valueNode yamlLib.Node
+	if err := valueNode.Encode(value); err != nil {
+		ys.Logger.Error("failed to encode the testcase assertions", zap.Error(err), zap.Any("testcase name", name), zap.Any("assertion", key))
+		return err
+	}
+	setMappingValue(assertions, key, &valueNode)
+
+	data, err = yamlLib.Marshal(&doc)
+	if err != nil {
+		ys.Logger.Error("failed to marshal the yaml testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	if err := os.WriteFile(yamlPath, data, os.ModePerm); err != nil {
+		ys.Logger.Error("failed to write the yaml testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	return nil
+}
+
+// mappingValue returns the value of key in a mapping node, or nil when the key is missing.
+func mappingValue(node *yamlLib.Node, key string) *yamlLib.Node {
+	if node.Kind != yamlLib.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets the value of key in a mapping node, appending the key when it is missing.
+func setMappingValue(node *yamlLib.Node, key string, value *yamlLib.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!str", Value: key}, value)
+}
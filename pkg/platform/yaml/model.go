@@ -28,51 +28,83 @@ func (nd *NetworkTrafficDoc) GetKind() string {
 }
 
 func EncodeTestcase(tc models.TestCase, logger *zap.Logger) (*NetworkTrafficDoc, error) {
-
-	header := pkg.ToHttpHeader(tc.HttpReq.Header)
-	curl := pkg.MakeCurlCommand(string(tc.HttpReq.Method), tc.HttpReq.URL, pkg.ToYamlHttpHeader(header), tc.HttpReq.Body)
 	doc := &NetworkTrafficDoc{
 		Version: tc.Version,
 		Kind:    tc.Kind,
 		Name:    tc.Name,
-		Curl:    curl,
-	}
-	// find noisy fields
-	m, err := FlattenHttpResponse(pkg.ToHttpHeader(tc.HttpResp.Header), tc.HttpResp.Body)
-	if err != nil {
-		msg := "error in flattening http response"
-		logger.Error(msg, zap.Error(err))
 	}
-	noise := tc.Noise
 
-	noiseFieldsFound := FindNoisyFields(m, func(k string, vals []string) bool {
-		// check if k is date
-		for _, v := range vals {
-			if pkg.IsTime(v) {
-				return true
-			}
+	switch tc.Kind {
+	case models.HTTP:
+		header := pkg.ToHttpHeader(tc.HttpReq.Header)
+		doc.Curl = pkg.MakeCurlCommand(string(tc.HttpReq.Method), tc.HttpReq.URL, pkg.ToYamlHttpHeader(header), tc.HttpReq.Body)
+
+		// find noisy fields
+		m, err := FlattenHttpResponse(pkg.ToHttpHeader(tc.HttpResp.Header), tc.HttpResp.Body)
+		if err != nil {
+			msg := "error in flattening http response"
+			logger.Error(msg, zap.Error(err))
 		}
+		noise := tc.Noise
 
-		// maybe we need to concatenate the values
-		return pkg.IsTime(strings.Join(vals, ", "))
-	})
+		noiseFieldsFound := FindNoisyFields(m, func(k string, vals []string) bool {
+			// check if k is date
+			for _, v := range vals {
+				if pkg.IsTime(v) {
+					return true
+				}
+			}
 
-	for _, v := range noiseFieldsFound {
-		noise[v] = []string{}
-	}
+			// maybe we need to concatenate the values
+			return pkg.IsTime(strings.Join(vals, ", "))
+		})
 
-	switch tc.Kind {
-	case models.HTTP:
-		err := doc.Spec.Encode(spec.HttpSpec{
+		for _, v := range noiseFieldsFound {
+			noise[v] = []string{}
+		}
+
+		assertions := map[string]interface{}{
+			"noise": noise,
+		}
+		if len(tc.Rules) > 0 {
+			assertions["rules"] = tc.Rules
+		}
+
+		err = doc.Spec.Encode(spec.HttpSpec{
+			Request:    tc.HttpReq,
+			Response:   tc.HttpResp,
+			Created:    tc.Created,
+			Assertions: assertions,
+		})
+		if err != nil {
+			logger.Error("failed to encode testcase into a yaml doc", zap.Error(err))
+			return nil, err
+		}
+	case models.GRPC_EXPORT:
+		err := doc.Spec.Encode(spec.GrpcSpec{
+			GrpcReq:  tc.GrpcReq,
+			GrpcResp: tc.GrpcResp,
+			Created:  tc.Created,
+			Assertions: map[string]interface{}{
+				"noise": tc.Noise,
+			},
+		})
+		if err != nil {
+			logger.Error("failed to encode the grpc testcase into a yaml doc", zap.Error(err))
+			return nil, err
+		}
+	case models.WebSocket:
+		err := doc.Spec.Encode(spec.WebSocketSpec{
 			Request:  tc.HttpReq,
 			Response: tc.HttpResp,
+			Frames:   tc.WebSocketFrames,
 			Created:  tc.Created,
 			Assertions: map[string]interface{}{
-				"noise": noise,
+				"noise": tc.Noise,
 			},
 		})
 		if err != nil {
-			logger.Error("failed to encode testcase into a yaml doc", zap.Error(err))
+			logger.Error("failed to encode the websocket testcase into a yaml doc", zap.Error(err))
 			return nil, err
 		}
 	default:
@@ -225,6 +257,63 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			logger.Error(Emoji+"failed to marshal the SQL input-output as yaml", zap.Error(err))
 			return nil, err
 		}
+	case models.Redis:
+		redisSpec := spec.RedisSpec{
+			Metadata:         mock.Spec.Metadata,
+			Requests:         mock.Spec.RedisRequests,
+			Responses:        mock.Spec.RedisResponses,
+			CreatedAt:        mock.Spec.Created,
+			ReqTimestampMock: mock.Spec.ReqTimestampMock,
+			ResTimestampMock: mock.Spec.ResTimestampMock,
+		}
+		err := yamlDoc.Spec.Encode(redisSpec)
+		if err != nil {
+			logger.Error("failed to marshal the redis input-output as yaml", zap.Error(err))
+			return nil, err
+		}
+	case models.Kafka:
+		kafkaSpec := spec.KafkaSpec{
+			Metadata:         mock.Spec.Metadata,
+			Request:          *mock.Spec.KafkaRequest,
+			Response:         *mock.Spec.KafkaResponse,
+			CreatedAt:        mock.Spec.Created,
+			ReqTimestampMock: mock.Spec.ReqTimestampMock,
+			ResTimestampMock: mock.Spec.ResTimestampMock,
+		}
+		err := yamlDoc.Spec.Encode(kafkaSpec)
+		if err != nil {
+			logger.Error("failed to marshal the kafka input-output as yaml", zap.Error(err))
+			return nil, err
+		}
+	case models.DNS:
+		dnsSpec := spec.DNSSpec{
+			Metadata:         mock.Spec.Metadata,
+			Request:          *mock.Spec.DNSReq,
+			Response:         *mock.Spec.DNSResp,
+			CreatedAt:        mock.Spec.Created,
+			ReqTimestampMock: mock.Spec.ReqTimestampMock,
+			ResTimestampMock: mock.Spec.ResTimestampMock,
+		}
+		err := yamlDoc.Spec.Encode(dnsSpec)
+		if err != nil {
+			logger.Error("failed to marshal the dns question-answers as yaml", zap.Error(err))
+			return nil, err
+		}
+	case models.WebSocket:
+		webSocketSpec := spec.WebSocketSpec{
+			Metadata:         mock.Spec.Metadata,
+			Request:          *mock.Spec.HttpReq,
+			Response:         *mock.Spec.HttpResp,
+			Frames:           mock.Spec.WebSocketFrames,
+			Created:          mock.Spec.Created,
+			ReqTimestampMock: mock.Spec.ReqTimestampMock,
+			ResTimestampMock: mock.Spec.ResTimestampMock,
+		}
+		err := yamlDoc.Spec.Encode(webSocketSpec)
+		if err != nil {
+			logger.Error("failed to marshal the websocket session as yaml", zap.Error(err))
+			return nil, err
+		}
 	default:
 		logger.Error("failed to marshal the recorded mock into yaml due to invalid kind of mock")
 		return nil, errors.New("type of mock is invalid")
@@ -250,18 +339,12 @@ func Decode(yamlTestcase *NetworkTrafficDoc, logger *zap.Logger) (*models.TestCa
 		tc.Created = httpSpec.Created
 		tc.HttpReq = httpSpec.Request
 		tc.HttpResp = httpSpec.Response
-		tc.Noise = map[string][]string{}
-		switch reflect.ValueOf(httpSpec.Assertions["noise"]).Kind() {
-		case reflect.Map:
-			for k, v := range httpSpec.Assertions["noise"].(map[string]interface{}) {
-				tc.Noise[k] = []string{}
-				for _, val := range v.([]interface{}) {
-					tc.Noise[k] = append(tc.Noise[k], val.(string))
-				}
-			}
-		case reflect.Slice:
-			for _, v := range httpSpec.Assertions["noise"].([]interface{}) {
-				tc.Noise[v.(string)] = []string{}
+		tc.Noise = decodeNoise(httpSpec.Assertions["noise"])
+		if rules, ok := httpSpec.Assertions["rules"]; ok {
+			tc.Rules, err = decodeRules(rules)
+			if err != nil {
+				logger.Error("failed to unmarshal the assertion rules of the http testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+				return nil, err
 			}
 		}
 	// unmarshal its mocks from yaml docs to go struct
@@ -272,8 +355,22 @@ func Decode(yamlTestcase *NetworkTrafficDoc, logger *zap.Logger) (*models.TestCa
 			logger.Error(Emoji+"failed to unmarshal a yaml doc into the gRPC testcase", zap.Error(err))
 			return nil, err
 		}
+		tc.Created = grpcSpec.Created
 		tc.GrpcReq = grpcSpec.GrpcReq
 		tc.GrpcResp = grpcSpec.GrpcResp
+		tc.Noise = decodeNoise(grpcSpec.Assertions["noise"])
+	case models.WebSocket:
+		webSocketSpec := spec.WebSocketSpec{}
+		err := yamlTestcase.Spec.Decode(&webSocketSpec)
+		if err != nil {
+			logger.Error("failed to unmarshal a yaml doc into the websocket testcase", zap.Error(err))
+			return nil, err
+		}
+		tc.Created = webSocketSpec.Created
+		tc.HttpReq = webSocketSpec.Request
+		tc.HttpResp = webSocketSpec.Response
+		tc.WebSocketFrames = webSocketSpec.Frames
+		tc.Noise = decodeNoise(webSocketSpec.Assertions["noise"])
 	default:
 		logger.Error("failed to unmarshal yaml doc of unknown type", zap.Any("type of yaml doc", tc.Kind))
 		return nil, errors.New("yaml doc of unknown type")
@@ -281,7 +378,41 @@ func Decode(yamlTestcase *NetworkTrafficDoc, logger *zap.Logger) (*models.TestCa
 	return &tc, nil
 }
 
-func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.Mock, error) {
+// decodeNoise converts the noise of the assertions, a map of the noisy fields to their noisy
+// values or a list of the noisy fields, into the noise of the testcase.
+func decodeNoise(noise interface{}) map[string][]string {
+	decoded := map[string][]string{}
+	switch reflect.ValueOf(noise).Kind() {
+	case reflect.Map:
+		for k, v := range noise.(map[string]interface{}) {
+			decoded[k] = []string{}
+			for _, val := range v.([]interface{}) {
+				decoded[k] = append(decoded[k], val.(string))
+			}
+		}
+	case reflect.Slice:
+		for _, v := range noise.([]interface{}) {
+			decoded[v.(string)] = []string{}
+		}
+	}
+	return decoded
+}
+
+// decodeRules converts the generic rules node of the assertions into typed assertion rules.
+func decodeRules(rules interface{}) (models.AssertionRules, error) {
+	data, err := yamlLib.Marshal(rules)
+	if err != nil {
+		return nil, err
+	}
+	decoded := models.AssertionRules{}
+	err = yamlLib.Unmarshal(data, &decoded)
+	if err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+func DecodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.Mock, error) {
 	mocks := []*models.Mock{}
 
 	for _, m := range yamlMocks {
@@ -383,6 +514,67 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 				return nil, err
 			}
 			mock.Spec = *mockSpec
+		case models.Redis:
+			redisSpec := spec.RedisSpec{}
+			err := m.Spec.Decode(&redisSpec)
+			if err != nil {
+				logger.Error("failed to unmarshal a yaml doc into redis mock", zap.Error(err), zap.Any("mock name", m.Name))
+				return nil, err
+			}
+			mock.Spec = models.MockSpec{
+				Metadata:         redisSpec.Metadata,
+				RedisRequests:    redisSpec.Requests,
+				RedisResponses:   redisSpec.Responses,
+				Created:          redisSpec.CreatedAt,
+				ReqTimestampMock: redisSpec.ReqTimestampMock,
+				ResTimestampMock: redisSpec.ResTimestampMock,
+			}
+		case models.Kafka:
+			kafkaSpec := spec.KafkaSpec{}
+			err := m.Spec.Decode(&kafkaSpec)
+			if err != nil {
+				logger.Error("failed to unmarshal a yaml doc into kafka mock", zap.Error(err), zap.Any("mock name", m.Name))
+				return nil, err
+			}
+			mock.Spec = models.MockSpec{
+				Metadata:         kafkaSpec.Metadata,
+				KafkaRequest:     &kafkaSpec.Request,
+				KafkaResponse:    &kafkaSpec.Response,
+				Created:          kafkaSpec.CreatedAt,
+				ReqTimestampMock: kafkaSpec.ReqTimestampMock,
+				ResTimestampMock: kafkaSpec.ResTimestampMock,
+			}
+		case models.DNS:
+			dnsSpec := spec.DNSSpec{}
+			err := m.Spec.Decode(&dnsSpec)
+			if err != nil {
+				logger.Error("failed to unmarshal a yaml doc into dns mock", zap.Error(err), zap.Any("mock name", m.Name))
+				return nil, err
+			}
+			mock.Spec = models.MockSpec{
+				Metadata:         dnsSpec.Metadata,
+				DNSReq:           &dnsSpec.Request,
+				DNSResp:          &dnsSpec.Response,
+				Created:          dnsSpec.CreatedAt,
+				ReqTimestampMock: dnsSpec.ReqTimestampMock,
+				ResTimestampMock: dnsSpec.ResTimestampMock,
+			}
+		case models.WebSocket:
+			webSocketSpec := spec.WebSocketSpec{}
+			err := m.Spec.Decode(&webSocketSpec)
+			if err != nil {
+				logger.Error("failed to unmarshal a yaml doc into websocket mock", zap.Error(err), zap.Any("mock name", m.Name))
+				return nil, err
+			}
+			mock.Spec = models.MockSpec{
+				Metadata:         webSocketSpec.Metadata,
+				HttpReq:          &webSocketSpec.Request,
+				HttpResp:         &webSocketSpec.Response,
+				WebSocketFrames:  webSocketSpec.Frames,
+				Created:          webSocketSpec.Created,
+				ReqTimestampMock: webSocketSpec.ReqTimestampMock,
+				ResTimestampMock: webSocketSpec.ResTimestampMock,
+			}
 		default:
 			logger.Error("failed to unmarshal a mock yaml doc of unknown type", zap.Any("type", m.Kind))
 			continue
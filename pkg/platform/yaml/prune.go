@@ -0,0 +1,58 @@
+package yaml
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// DeleteMocks rewrites the mocks file of the test-set in the path, MockPath is used when the path
+// is empty, without the mocks for which remove returns true. The documents which can't be decoded,
+// like the ones recorded by keploy enterprise, are kept.
+func (ys *Yaml) DeleteMocks(path string, remove func(mock platform.KindSpecifier) bool, ctx context.Context) (int, error) {
+	if path == "" {
+		path = ys.MockPath
+	}
+	mockName := "mocks"
+	if ys.MockName != "" {
+		mockName = ys.MockName
+	}
+	docs, err := read(path, mockName)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks to prune", zap.Error(err), zap.Any("session", filepath.Base(path)))
+		return 0, err
+	}
+
+	removed := 0
+	data := []byte{}
+	for _, doc := range docs {
+		mocks, err := DecodeMocks([]*NetworkTrafficDoc{doc}, ys.Logger)
+		if err == nil && len(mocks) == 1 && remove(mocks[0]) {
+			removed++
+			continue
+		}
+		d, err := yamlLib.Marshal(doc)
+		if err != nil {
+			ys.Logger.Error("failed to marshal the mock to keep", zap.Error(err), zap.Any("session", filepath.Base(path)))
+			return 0, err
+		}
+		if len(data) > 0 {
+			data = append(data, []byte("---\n")...)
+		}
+		data = append(data, d...)
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	err = os.WriteFile(filepath.Join(path, mockName+".yaml"), data, os.ModePerm)
+	if err != nil {
+		ys.Logger.Error("failed to write the pruned mocks", zap.Error(err), zap.Any("session", filepath.Base(path)))
+		return 0, err
+	}
+	return removed, nil
+}
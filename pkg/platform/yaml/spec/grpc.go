@@ -7,8 +7,10 @@ import (
 )
 
 type GrpcSpec struct {
-	GrpcReq          models.GrpcReq  `json:"grpcReq" yaml:"grpcReq"`
-	GrpcResp         models.GrpcResp `json:"grpcResp" yaml:"grpcResp"`
-	ReqTimestampMock time.Time       `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
-	ResTimestampMock time.Time       `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+	GrpcReq          models.GrpcReq         `json:"grpcReq" yaml:"grpcReq"`
+	GrpcResp         models.GrpcResp        `json:"grpcResp" yaml:"grpcResp"`
+	Assertions       map[string]interface{} `json:"assertions" yaml:"assertions,omitempty"`
+	Created          int64                  `json:"created" yaml:"created,omitempty"`
+	ReqTimestampMock time.Time              `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
+	ResTimestampMock time.Time              `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
 }
@@ -0,0 +1,16 @@
+package spec
+
+import (
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+type KafkaSpec struct {
+	Metadata         map[string]string    `json:"metadata" yaml:"metadata"`
+	Request          models.KafkaRequest  `json:"request" yaml:"request"`
+	Response         models.KafkaResponse `json:"response" yaml:"response"`
+	CreatedAt        int64                `json:"created" yaml:"created,omitempty"`
+	ReqTimestampMock time.Time            `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
+	ResTimestampMock time.Time            `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+}
@@ -0,0 +1,18 @@
+package spec
+
+import (
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+type WebSocketSpec struct {
+	Metadata         map[string]string       `json:"metadata" yaml:"metadata"`
+	Request          models.HttpReq          `json:"req" yaml:"req"`
+	Response         models.HttpResp         `json:"resp" yaml:"resp"`
+	Frames           []models.WebSocketFrame `json:"frames" yaml:"frames"`
+	Assertions       map[string]interface{}  `json:"assertions" yaml:"assertions,omitempty"`
+	Created          int64                   `json:"created" yaml:"created,omitempty"`
+	ReqTimestampMock time.Time               `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
+	ResTimestampMock time.Time               `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+}
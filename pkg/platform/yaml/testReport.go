@@ -18,6 +18,8 @@ type TestReport struct {
 	tests  map[string][]platform.KindSpecifier
 	m      sync.Mutex
 	Logger *zap.Logger
+	// w serialises the writes, so that test-sets running in parallel get distinct report names
+	w sync.Mutex
 }
 
 func NewTestReportFS(logger *zap.Logger) *TestReport {
@@ -45,6 +47,8 @@ func (fe *TestReport) SetResult(runId string, test platform.KindSpecifier) {
 }
 
 func (fe *TestReport) GetResults(runId string) ([]platform.KindSpecifier, error) {
+	fe.m.Lock()
+	defer fe.m.Unlock()
 	testResults, ok := fe.tests[runId]
 	if !ok {
 		return nil, fmt.Errorf("%s found no test results for test report with id: %s", Emoji, runId)
@@ -74,6 +78,8 @@ func (fe *TestReport) Write(ctx context.Context, path string, doc platform.KindS
 	if !ok {
 		return fmt.Errorf("%s failed to read test report in yaml file.", Emoji)
 	}
+	fe.w.Lock()
+	defer fe.w.Unlock()
 	if readDock.Name == "" {
 		lastIndex, err := findLastIndex(path, fe.Logger)
 		if err != nil {
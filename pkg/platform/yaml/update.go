@@ -0,0 +1,91 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// UpdateTest rewrites the request and response of the testcase stored in the path, TcsPath is used
+// when the path is empty. The other fields of the document, like the noise and the assertion rules,
+// are kept as they were written and in the same order.
+func (ys *Yaml) UpdateTest(path string, tcRead platform.KindSpecifier, ctx context.Context) error {
+	tc, ok := tcRead.(*models.TestCase)
+	if !ok {
+		return fmt.Errorf("%s failed to read testcase in UpdateTest", Emoji)
+	}
+	updated := *tc
+	// the recorded noise is kept, so there is no need to detect it again
+	updated.Noise = map[string][]string{}
+	encoded, err := EncodeTestcase(updated, ys.Logger)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		path = ys.TcsPath
+	}
+	yamlPath := filepath.Join(path, tc.Name+".yaml")
+	data, err := os.ReadFile(yamlPath)
+	if err != nil {
+		ys.Logger.Error("failed to read the yaml testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+		return err
+	}
+
+	var file yamlLib.Node
+	if err := yamlLib.Unmarshal(data, &file); err != nil {
+		ys.Logger.Error("failed to unmarshal the yaml testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+		return err
+	}
+	if len(file.Content) == 0 {
+		return fmt.Errorf("testcase %s is empty", tc.Name)
+	}
+	doc := file.Content[0]
+	spec := mappingValue(doc, "spec")
+	if spec == nil {
+		return fmt.Errorf("testcase %s has no spec", tc.Name)
+	}
+
+	var curl yamlLib.Node
+	if err := curl.Encode(encoded.Curl); err != nil {
+		ys.Logger.Error("failed to encode the curl of the testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+		return err
+	}
+	setMappingValue(doc, "curl", &curl)
+	for _, key := range []string{"req", "resp"} {
+		value := mappingValue(&encoded.Spec, key)
+		if value == nil {
+			return fmt.Errorf("failed to encode the %s of testcase %s", key, tc.Name)
+		}
+		setMappingValue(spec, key, value)
+	}
+
+	data, err = yamlLib.Marshal(&file)
+	if err != nil {
+		ys.Logger.Error("failed to marshal the yaml testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+		return err
+	}
+	if err := os.WriteFile(yamlPath, data, os.ModePerm); err != nil {
+		ys.Logger.Error("failed to write the yaml testcase", zap.Error(err), zap.Any("testcase name", tc.Name))
+		return err
+	}
+	return nil
+}
+
+// DeleteTest removes the testcase stored in the path, TcsPath is used when the path is empty.
+func (ys *Yaml) DeleteTest(path, name string, ctx context.Context) error {
+	if path == "" {
+		path = ys.TcsPath
+	}
+	if err := os.Remove(filepath.Join(path, name+".yaml")); err != nil {
+		ys.Logger.Error("failed to delete the yaml testcase", zap.Error(err), zap.Any("testcase name", name))
+		return err
+	}
+	return nil
+}
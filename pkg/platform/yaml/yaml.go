@@ -160,24 +160,26 @@ func hasBannedHeaders(object map[string]string, bannedHeaders []string) bool {
 	return false
 }
 
+// IsBypassed reports whether the testcase matches the record filters and should not be stored.
+func IsBypassed(tc *models.TestCase, filtersRead platform.KindSpecifier) bool {
+	filters, ok := filtersRead.(*models.Filters)
+	if !ok {
+		return false
+	}
+	if tc.Kind == models.GRPC_EXPORT {
+		headers := tc.GrpcReq.Headers
+		return containsMatchingUrl(filters.URLMethods, headers.PseudoHeaders[":path"], models.Method(headers.PseudoHeaders[":method"])) || hasBannedHeaders(headers.OrdinaryHeaders, filters.ReqHeader)
+	}
+	return containsMatchingUrl(filters.URLMethods, tc.HttpReq.URL, tc.HttpReq.Method) || hasBannedHeaders(tc.HttpReq.Header, filters.ReqHeader)
+}
+
 func (ys *Yaml) WriteTestcase(tcRead platform.KindSpecifier, ctx context.Context, filtersRead platform.KindSpecifier) error {
 	tc, ok := tcRead.(*models.TestCase)
 	if !ok {
 		return fmt.Errorf("%s failed to read testcase in WriteTestcase", Emoji)
 	}
-	filters, ok := filtersRead.(*models.Filters)
-
-	var bypassTestCase = false
 
-	if ok {
-		if containsMatchingUrl(filters.URLMethods, tc.HttpReq.URL, tc.HttpReq.Method) {
-			bypassTestCase = true
-		} else if hasBannedHeaders(tc.HttpReq.Header, filters.ReqHeader) {
-			bypassTestCase = true
-		}
-	}
-
-	if !bypassTestCase {
+	if !IsBypassed(tc, filtersRead) {
 		ys.tele.RecordedTestAndMocks()
 		ys.mutex.Lock()
 		testsTotal, ok := ctx.Value("testsTotal").(*int)
@@ -363,7 +365,7 @@ func (ys *Yaml) ReadTcsMocks(tcRead platform.KindSpecifier, path string) ([]plat
 			ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
 			return nil, err
 		}
-		mocks, err := decodeMocks(yamls, ys.Logger)
+		mocks, err := DecodeMocks(yamls, ys.Logger)
 		if err != nil {
 			ys.Logger.Error("failed to decode the config mocks from yaml docs", zap.Error(err), zap.Any("session", filepath.Base(path)))
 			return nil, err
@@ -379,12 +381,13 @@ func (ys *Yaml) ReadTcsMocks(tcRead platform.KindSpecifier, path string) ([]plat
 	if !readTcs {
 		return tcsMocks, nil
 	}
-	if tc.HttpReq.Timestamp == (time.Time{}) {
+	reqTimestamp, resTimestamp := tc.Timestamps()
+	if reqTimestamp == (time.Time{}) {
 		ys.Logger.Warn("request timestamp is missing for " + tc.Name)
 		return tcsMocks, nil
 	}
 
-	if tc.HttpResp.Timestamp == (time.Time{}) {
+	if resTimestamp == (time.Time{}) {
 		ys.Logger.Warn("response timestamp is missing for " + tc.Name)
 		return tcsMocks, nil
 	}
@@ -404,7 +407,7 @@ func (ys *Yaml) ReadTcsMocks(tcRead platform.KindSpecifier, path string) ([]plat
 		}
 
 		// Checking if the mock's request and response timestamps lie between the test's request and response timestamp
-		if mock.Spec.ReqTimestampMock.After(tc.HttpReq.Timestamp) && mock.Spec.ResTimestampMock.Before(tc.HttpResp.Timestamp) {
+		if mock.Spec.ReqTimestampMock.After(reqTimestamp) && mock.Spec.ResTimestampMock.Before(resTimestamp) {
 			filteredMocks = append(filteredMocks, mock)
 		}
 	}
@@ -445,7 +448,7 @@ func (ys *Yaml) ReadConfigMocks(path string) ([]platform.KindSpecifier, error) {
 			ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
 			return nil, err
 		}
-		mocks, err := decodeMocks(yamls, ys.Logger)
+		mocks, err := DecodeMocks(yamls, ys.Logger)
 		if err != nil {
 			ys.Logger.Error("failed to decode the config mocks from yaml docs", zap.Error(err), zap.Any("session", filepath.Base(path)))
 			return nil, err
@@ -461,10 +464,3 @@ func (ys *Yaml) ReadConfigMocks(path string) ([]platform.KindSpecifier, error) {
 	return configMocks, nil
 
 }
-func (ys *Yaml) UpdateTest(mock *models.Mock, ctx context.Context) error {
-	return nil
-}
-
-func (ys *Yaml) DeleteTest(mock *models.Mock, ctx context.Context) error {
-	return nil
-}
@@ -0,0 +1,191 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/cloudflare/cfssl/initca"
+	cfsslLog "github.com/cloudflare/cfssl/log"
+	"go.uber.org/zap"
+)
+
+const (
+	caCertFile = "ca.crt"
+	caKeyFile  = "ca.key"
+	// caBundleFile is the bundle of the system certificates and the CA handed to python applications
+	caBundleFile = "keploy-ca-bundle.pem"
+)
+
+// systemCaBundles are the usual locations of the bundle of the trusted certificates.
+var systemCaBundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+	"/etc/ssl/cert.pem",
+}
+
+// CaDir is the directory of the CA replacing the one bundled with keploy, managed by the `keploy cert` command. It
+// doesn't depend on the user, so the CA is the same whether keploy runs with sudo or as root.
+const CaDir = "/etc/keploy/ca"
+
+// LoadCA returns the PEM encoded certificate and private key of the CA signing the certificates served by the
+// proxy. The CA of CaDir is used when there is one, the bundled CA otherwise.
+func LoadCA() ([]byte, []byte, error) {
+	cert, err := os.ReadFile(filepath.Join(CaDir, caCertFile))
+	if os.IsNotExist(err) {
+		return caCrt, caPKey, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := os.ReadFile(filepath.Join(CaDir, caKeyFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// ExportCA writes the certificate of the CA to path, so that it can be added to the trust stores the proxy does not
+// know about.
+func ExportCA(logger *zap.Logger, path string) error {
+	cert, _, err := LoadCA()
+	if err != nil {
+		logger.Error("failed to load the CA", zap.Error(err))
+		return err
+	}
+	err = os.WriteFile(path, cert, 0644)
+	if err != nil {
+		logger.Error("failed to export the CA certificate", zap.Error(err), zap.String("path", path))
+		return err
+	}
+	logger.Info("exported the CA certificate", zap.String("path", path))
+	return nil
+}
+
+// RotateCA generates a new CA that replaces the current one. The certificates of the previous CA are no longer
+// trusted once the proxy installs the new one.
+func RotateCA(logger *zap.Logger) error {
+	cfsslLog.Level = cfsslLog.LevelError
+	cert, _, key, err := initca.New(&csr.CertificateRequest{
+		CN:         "Keploy CA",
+		Names:      []csr.Name{{O: "Keploy"}},
+		KeyRequest: csr.NewKeyRequest(),
+		CA:         &csr.CAConfig{Expiry: "87600h"},
+	})
+	if err != nil {
+		logger.Error("failed to generate a new CA", zap.Error(err))
+		return err
+	}
+	if err := saveCA(cert, key); err != nil {
+		logger.Error("failed to store the new CA", zap.Error(err))
+		return err
+	}
+	logger.Info("rotated the CA, it is installed by the next record or test run")
+	return nil
+}
+
+// SetCustomCA makes the proxy sign its certificates with the CA of the given PEM files.
+func SetCustomCA(logger *zap.Logger, certPath, keyPath string) error {
+	cert, err := os.ReadFile(certPath)
+	if err != nil {
+		logger.Error("failed to read the CA certificate", zap.Error(err), zap.String("path", certPath))
+		return err
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		logger.Error("failed to read the CA private key", zap.Error(err), zap.String("path", keyPath))
+		return err
+	}
+	if _, err := tls.X509KeyPair(cert, key); err != nil {
+		logger.Error("the CA certificate does not match its private key", zap.Error(err))
+		return err
+	}
+	parsed, err := helpers.ParseCertificatePEM(cert)
+	if err != nil {
+		logger.Error("failed to parse the CA certificate", zap.Error(err))
+		return err
+	}
+	if !parsed.IsCA {
+		err = fmt.Errorf("the certificate of %s is not a CA certificate", certPath)
+		logger.Error("", zap.Error(err))
+		return err
+	}
+	if err := saveCA(cert, key); err != nil {
+		logger.Error("failed to store the custom CA", zap.Error(err))
+		return err
+	}
+	logger.Info("the proxy now signs its certificates with the custom CA", zap.String("subject", parsed.Subject.CommonName))
+	return nil
+}
+
+// ResetCA goes back to the CA bundled with keploy.
+func ResetCA(logger *zap.Logger) error {
+	if err := os.RemoveAll(CaDir); err != nil {
+		logger.Error("failed to remove the CA", zap.Error(err), zap.String("path", CaDir))
+		return err
+	}
+	logger.Info("the proxy now signs its certificates with the bundled CA")
+	return nil
+}
+
+func saveCA(cert, key []byte) error {
+	// the certificate is readable by everyone for `keploy cert export`, the key only by root
+	if err := os.MkdirAll(CaDir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(CaDir, caKeyFile), key, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(CaDir, caCertFile), cert, 0644)
+}
+
+// InstallNodeCA makes the node applications trust the CA. Node adds the certificates of NODE_EXTRA_CA_CERTS to
+// its own root store, which ignores the store of the system.
+func InstallNodeCA(logger *zap.Logger, caPath string) {
+	if err := os.Setenv("NODE_EXTRA_CA_CERTS", caPath); err != nil {
+		logger.Error("failed to set environment variable NODE_EXTRA_CA_CERTS", zap.Error(err))
+		return
+	}
+	logger.Debug("node applications trust the CA", zap.String("NODE_EXTRA_CA_CERTS", caPath))
+}
+
+// InstallPythonCA makes the python applications using requests trust the CA. Requests trusts nothing but the
+// bundle of REQUESTS_CA_BUNDLE, so the CA is appended to the certificates of the system to keep verifying the
+// calls that do not go through the proxy.
+func InstallPythonCA(logger *zap.Logger, caPath string) {
+	ca, err := os.ReadFile(caPath)
+	if err != nil {
+		logger.Error("failed to read the CA certificate", zap.Error(err), zap.String("path", caPath))
+		return
+	}
+	var bundle bytes.Buffer
+	for _, path := range systemCaBundles {
+		system, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		bundle.Write(system)
+		if !bytes.HasSuffix(system, []byte("\n")) {
+			bundle.WriteByte('\n')
+		}
+		break
+	}
+	bundle.Write(ca)
+
+	bundlePath := filepath.Join(os.TempDir(), caBundleFile)
+	if err := os.WriteFile(bundlePath, bundle.Bytes(), 0644); err != nil {
+		logger.Error("failed to write the CA bundle for python", zap.Error(err), zap.String("path", bundlePath))
+		return
+	}
+	if err := os.Setenv("REQUESTS_CA_BUNDLE", bundlePath); err != nil {
+		logger.Error("failed to set environment variable REQUESTS_CA_BUNDLE", zap.Error(err))
+		return
+	}
+	logger.Debug("python applications trust the CA", zap.String("REQUESTS_CA_BUNDLE", bundlePath))
+}
@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"container/list"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudflare/cfssl/csr"
+	"github.com/cloudflare/cfssl/helpers"
+	cfsslLog "github.com/cloudflare/cfssl/log"
+	"github.com/cloudflare/cfssl/signer"
+	"github.com/cloudflare/cfssl/signer/local"
+	"go.uber.org/zap"
+)
+
+// DefaultCertCacheSize is the number of server names whose certificates are kept by the proxy.
+const DefaultCertCacheSize = 1000
+
+// certRenewBefore is how long before its expiry a cached certificate is signed again.
+const certRenewBefore = time.Hour
+
+// certCache signs the certificates served to the application during the TLS handshakes and keeps the most recently
+// used ones, keyed by the server name of the handshake.
+type certCache struct {
+	logger  *zap.Logger
+	signer  *local.Signer
+	size    int
+	mutex   *sync.Mutex
+	order   *list.List // front is the most recently used certificate
+	entries map[string]*list.Element
+}
+
+type cachedCert struct {
+	serverName string
+	cert       *tls.Certificate
+}
+
+func newCertCache(logger *zap.Logger, caCert, caKey []byte, size int) (*certCache, error) {
+	cfsslLog.Level = cfsslLog.LevelError
+
+	caPrivKey, err := helpers.ParsePrivateKeyPEM(caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA private key: %v", err)
+	}
+	caCertParsed, err := helpers.ParseCertificatePEM(caCert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+	cryptoSigner, ok := caPrivKey.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("the CA private key can not sign certificates")
+	}
+	signerd, err := local.NewSigner(cryptoSigner, caCertParsed, signer.DefaultSigAlgo(cryptoSigner), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create signer: %v", err)
+	}
+	if size <= 0 {
+		size = DefaultCertCacheSize
+	}
+	return &certCache{
+		logger:  logger,
+		signer:  signerd,
+		size:    size,
+		mutex:   &sync.Mutex{},
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}, nil
+}
+
+// getCertificate is the tls.Config GetCertificate callback of the connections of the application.
+func (c *certCache) getCertificate(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.get(clientHello.ServerName)
+}
+
+// get returns the certificate of serverName, signing it when it is not cached or about to expire.
+func (c *certCache) get(serverName string) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	if elem, ok := c.entries[serverName]; ok {
+		entry := elem.Value.(*cachedCert)
+		if time.Until(entry.cert.Leaf.NotAfter) > certRenewBefore {
+			c.order.MoveToFront(elem)
+			c.mutex.Unlock()
+			return entry.cert, nil
+		}
+		c.order.Remove(elem)
+		delete(c.entries, serverName)
+	}
+	c.mutex.Unlock()
+
+	// signing is slow, the handshakes of the other server names go on meanwhile
+	cert, err := c.sign(serverName)
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Debug("signed the certificate of the server", zap.String("server name", serverName))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if elem, ok := c.entries[serverName]; ok {
+		// signed concurrently by another handshake
+		c.order.MoveToFront(elem)
+		return elem.Value.(*cachedCert).cert, nil
+	}
+	c.entries[serverName] = c.order.PushFront(&cachedCert{serverName: serverName, cert: cert})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedCert).serverName)
+	}
+	return cert, nil
+}
+
+// sign generates a new server certificate and private key for the given hostname.
+func (c *certCache) sign(serverName string) (*tls.Certificate, error) {
+	serverReq := &csr.CertificateRequest{
+		//Make the name accordng to the ip of the request
+		CN: serverName,
+		Hosts: []string{
+			serverName,
+		},
+		KeyRequest: csr.NewKeyRequest(),
+	}
+
+	serverCsr, serverKey, err := csr.ParseRequest(serverReq)
+	if err != nil {
+		return nil, fmt.Errorf(Emoji+"failed to create server CSR: %v", err)
+	}
+
+	serverCert, err := c.signer.Sign(signer.SignRequest{
+		Hosts:   serverReq.Hosts,
+		Request: string(serverCsr),
+		Profile: "web",
+	})
+	if err != nil {
+		return nil, fmt.Errorf(Emoji+"failed to sign server certificate: %v", err)
+	}
+
+	// Load the server certificate and private key
+	serverTlsCert, err := tls.X509KeyPair(serverCert, serverKey)
+	if err != nil {
+		return nil, fmt.Errorf(Emoji+"failed to load server certificate and key: %v", err)
+	}
+	serverTlsCert.Leaf, err = x509.ParseCertificate(serverTlsCert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf(Emoji+"failed to parse server certificate: %v", err)
+	}
+
+	return &serverTlsCert, nil
+}
@@ -0,0 +1,373 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// DefaultDnsTimeout is the time given to the upstream servers to resolve a question.
+const DefaultDnsTimeout = 1 * time.Second
+
+func (ps *ProxySet) startDnsServer() {
+
+	dnsServerAddr := fmt.Sprintf(":%v", ps.Port)
+
+	handler := ps
+	server := &dns.Server{
+		Addr:      dnsServerAddr,
+		Net:       "udp",
+		Handler:   handler,
+		UDPSize:   65535,
+		ReusePort: true,
+		// DisableBackground: true,
+	}
+
+	ps.DnsServer = server
+
+	ps.logger.Info(fmt.Sprintf("starting DNS server at addr %v", server.Addr))
+	err := server.ListenAndServe()
+	if err != nil {
+		ps.logger.Error("failed to start dns server", zap.Any("addr", server.Addr), zap.Error(err))
+	}
+}
+
+// For DNS caching
+var cache = struct {
+	sync.RWMutex
+	m map[string][]dns.RR
+}{m: make(map[string][]dns.RR)}
+
+func generateCacheKey(name string, qtype uint16) string {
+	return fmt.Sprintf("%s-%s", name, dns.TypeToString[qtype])
+}
+
+func (ps *ProxySet) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+
+	ps.logger.Debug("", zap.Any("Source socket info", w.RemoteAddr().String()))
+	ps.logger.Debug("Got some Dns queries")
+	// the process asking over udp is unknown, so the mocks of every instance of the application are
+	// looked up
+	msg := ps.answerDNS(r, ps.dnsHooks(), w.RemoteAddr())
+
+	ps.logger.Debug(fmt.Sprintf("dns msg sending back:\n%v\n", msg))
+	ps.logger.Debug(fmt.Sprintf("dns msg RCODE sending back:\n%v\n", msg.Rcode))
+	ps.logger.Debug("Writing dns info back to the client...")
+	err := w.WriteMsg(msg)
+	if err != nil {
+		ps.logger.Error("failed to write dns info back to the client", zap.Error(err))
+	}
+}
+
+// serveDNSOverTCP answers the dns queries sent over a tcp connection, like the ones whose answer
+// doesn't fit in a udp message.
+func (ps *ProxySet) serveDNSOverTCP(conn net.Conn, h *hooks.Hook) {
+	defer conn.Close()
+	dnsConn := &dns.Conn{Conn: conn}
+	for {
+		r, err := dnsConn.ReadMsg()
+		if err != nil {
+			ps.logger.Debug("stopped reading the dns queries over tcp", zap.Error(err))
+			return
+		}
+		err = dnsConn.WriteMsg(ps.answerDNS(r, []*hooks.Hook{h}, conn.RemoteAddr()))
+		if err != nil {
+			ps.logger.Error("failed to write dns info back to the client over tcp", zap.Error(err))
+			return
+		}
+	}
+}
+
+// answerDNS builds the reply to the query. In record mode the questions are resolved by the upstream
+// servers and the answers are stored as config mocks of the test-set. In test mode they are served
+// from the mocks of the hooks, the questions without a mock get the address of the proxy. remote is
+// the address the query came from.
+func (ps *ProxySet) answerDNS(r *dns.Msg, hs []*hooks.Hook, remote net.Addr) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.SetReply(r)
+	msg.Authoritative = true
+	for _, question := range r.Question {
+		ps.logger.Debug("", zap.Any("Record Type", question.Qtype), zap.Any("Received Query", question.Name))
+
+		var (
+			answers []dns.RR
+			rcode   = dns.RcodeSuccess
+		)
+		if models.GetMode() == models.MODE_RECORD {
+			answers, rcode = ps.recordDNS(question)
+		} else {
+			var found bool
+			answers, rcode, found = ps.mockedDNS(question, hs)
+			if !found {
+				answers = ps.defaultDNS(question)
+				if question.Qtype != dns.TypeA && question.Qtype != dns.TypeAAAA {
+					if h := ps.askingHook(hs, remote); h != nil {
+						h.RecordUnmatchedCall(models.DNS, strings.TrimSuffix(question.Name, ".")+" "+dns.TypeToString[question.Qtype])
+					}
+				}
+			}
+		}
+		if rcode != dns.RcodeSuccess {
+			msg.Rcode = rcode
+		}
+		msg.Answer = append(msg.Answer, answers...)
+	}
+	return msg
+}
+
+// recordDNS resolves the question with the upstream servers and stores the answer as a config mock,
+// once for every question of the recording.
+func (ps *ProxySet) recordDNS(question dns.Question) ([]dns.RR, int) {
+	reqTimestampMock := time.Now()
+	resp, err := resolveUpstream(question, ps.DnsServerTimeout)
+	if err != nil {
+		ps.logger.Debug("failed to resolve the dns query with the upstream servers", zap.Any("query", question.Name), zap.Error(err))
+		// the answers of the system resolver may come from local files, so they are not recorded
+		if question.Qtype == dns.TypeA || question.Qtype == dns.TypeAAAA {
+			return filterAnswers(resolveDNSQuery(question.Name, ps.logger, ps.DnsServerTimeout), question.Qtype), dns.RcodeSuccess
+		}
+		return nil, dns.RcodeServerFailure
+	}
+
+	key := generateCacheKey(strings.ToLower(question.Name), question.Qtype)
+	ps.dnsMutex.Lock()
+	recorded := ps.dnsRecorded[key]
+	ps.dnsRecorded[key] = true
+	ps.dnsMutex.Unlock()
+	if recorded {
+		return resp.Answer, resp.Rcode
+	}
+
+	answers := make([]string, 0, len(resp.Answer))
+	for _, rr := range resp.Answer {
+		answers = append(answers, rr.String())
+	}
+	err = ps.hook.AppendMocks(&models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.DNS,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{
+				"name": "DNS",
+				"type": "config",
+			},
+			DNSReq: &models.DNSReq{
+				Name:  question.Name,
+				Qtype: dns.TypeToString[question.Qtype],
+			},
+			DNSResp: &models.DNSResp{
+				Rcode:   dns.RcodeToString[resp.Rcode],
+				Answers: answers,
+			},
+			Created:          time.Now().Unix(),
+			ReqTimestampMock: reqTimestampMock,
+			ResTimestampMock: time.Now(),
+		},
+	}, ps.ctx)
+	if err != nil {
+		ps.logger.Error("failed to store the dns mock", zap.Error(err), zap.Any("query", question.Name))
+	}
+	return resp.Answer, resp.Rcode
+}
+
+// mockedDNS looks up the answer recorded for the question in the config mocks of the hooks.
+func (ps *ProxySet) mockedDNS(question dns.Question, hs []*hooks.Hook) ([]dns.RR, int, bool) {
+	qtype := dns.TypeToString[question.Qtype]
+	for _, h := range hs {
+		configMocks, err := h.GetConfigMocks()
+		if err != nil {
+			ps.logger.Error("failed to get the config mocks to answer the dns query", zap.Error(err))
+			continue
+		}
+		for _, mock := range configMocks {
+			if mock.Kind != models.DNS || mock.Spec.DNSReq == nil || mock.Spec.DNSResp == nil {
+				continue
+			}
+			if !strings.EqualFold(mock.Spec.DNSReq.Name, question.Name) || mock.Spec.DNSReq.Qtype != qtype {
+				continue
+			}
+			answers := make([]dns.RR, 0, len(mock.Spec.DNSResp.Answers))
+			for _, answer := range mock.Spec.DNSResp.Answers {
+				rr, err := dns.NewRR(answer)
+				if err != nil || rr == nil {
+					ps.logger.Error("failed to parse the recorded dns answer", zap.Error(err), zap.Any("answer", answer))
+					continue
+				}
+				answers = append(answers, rr)
+			}
+			rcode, ok := dns.StringToRcode[mock.Spec.DNSResp.Rcode]
+			if !ok {
+				rcode = dns.RcodeSuccess
+			}
+			h.MarkMockUsed(mock)
+			return answers, rcode, true
+		}
+	}
+	return nil, dns.RcodeSuccess, false
+}
+
+// defaultDNS answers the A and AAAA questions which were not recorded with the address of the
+// proxy, the outgoing calls reach the proxy anyway.
+func (ps *ProxySet) defaultDNS(question dns.Question) []dns.RR {
+	key := generateCacheKey(question.Name, question.Qtype)
+
+	// Check if the answer is cached
+	cache.RLock()
+	answers, found := cache.m[key]
+	cache.RUnlock()
+	if found {
+		return answers
+	}
+
+	if question.Qtype == dns.TypeA {
+		answers = []dns.RR{&dns.A{
+			Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
+			A:   net.ParseIP(util.ToIP4AddressStr(ps.IP4)),
+		}}
+		ps.logger.Debug("failed to resolve dns query hence sending proxy ip4", zap.Any("proxy Ip", util.ToIP4AddressStr(ps.IP4)))
+	} else if question.Qtype == dns.TypeAAAA {
+		if ps.dockerAppCmd {
+			ps.logger.Debug("failed to resolve dns query (in docker case) hence sending empty record")
+		} else {
+			answers = []dns.RR{&dns.AAAA{
+				Hdr:  dns.RR_Header{Name: question.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 3600},
+				AAAA: net.ParseIP(util.ToIPv6AddressStr(ps.IP6)),
+			}}
+			ps.logger.Debug("failed to resolve dns query hence sending proxy ip6", zap.Any("proxy Ip", util.ToIPv6AddressStr(ps.IP6)))
+		}
+	}
+	ps.logger.Debug(fmt.Sprintf("Answers[when resolution failed for query:%v]:\n%v\n", question.Qtype, answers))
+
+	// Cache the answer
+	cache.Lock()
+	cache.m[key] = answers
+	cache.Unlock()
+	return answers
+}
+
+// resolveUpstream asks the nameservers of the system for the question, over tcp when the answer
+// is truncated.
+func resolveUpstream(question dns.Question, timeout time.Duration) (*dns.Msg, error) {
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Servers) == 0 {
+		return nil, errors.New("no nameserver is configured")
+	}
+
+	query := new(dns.Msg)
+	query.SetQuestion(question.Name, question.Qtype)
+	query.RecursionDesired = true
+
+	udpClient := &dns.Client{Net: "udp", Timeout: timeout}
+	tcpClient := &dns.Client{Net: "tcp", Timeout: timeout}
+	for _, server := range config.Servers {
+		addr := net.JoinHostPort(server, config.Port)
+		resp, _, err := udpClient.Exchange(query, addr)
+		if err == nil && resp.Truncated {
+			resp, _, err = tcpClient.Exchange(query, addr)
+		}
+		if err == nil {
+			return resp, nil
+		}
+	}
+	return nil, fmt.Errorf("none of the nameservers %v answered the query for %s", config.Servers, question.Name)
+}
+
+func resolveDNSQuery(domain string, logger *zap.Logger, timeout time.Duration) []dns.RR {
+	// Remove the last dot from the domain name if it exists
+	domain = strings.TrimSuffix(domain, ".")
+
+	// Create a context with a timeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Use the default system resolver
+	resolver := net.DefaultResolver
+
+	// Perform the lookup with the context
+	ips, err := resolver.LookupIPAddr(ctx, domain)
+	if err != nil {
+		logger.Debug(fmt.Sprintf("failed to resolve the dns query for:%v", domain), zap.Error(err))
+		return nil
+	}
+
+	// Convert the resolved IPs to dns.RR
+	var answers []dns.RR
+	for _, ip := range ips {
+		if ipv4 := ip.IP.To4(); ipv4 != nil {
+			answers = append(answers, &dns.A{
+				Hdr: dns.RR_Header{Name: dns.Fqdn(domain), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
+				A:   ipv4,
+			})
+		} else {
+			answers = append(answers, &dns.AAAA{
+				Hdr:  dns.RR_Header{Name: dns.Fqdn(domain), Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 3600},
+				AAAA: ip.IP,
+			})
+		}
+	}
+
+	if len(answers) > 0 {
+		logger.Debug("net.LookupIP resolved the ip address...")
+	}
+
+	return answers
+}
+
+// filterAnswers keeps the answers of the type asked, the system resolver returns both the ipv4 and
+// the ipv6 addresses.
+func filterAnswers(answers []dns.RR, qtype uint16) []dns.RR {
+	filtered := []dns.RR{}
+	for _, answer := range answers {
+		if answer.Header().Rrtype == qtype {
+			filtered = append(filtered, answer)
+		}
+	}
+	return filtered
+}
+
+// askingHook returns the hook of the instance of the application which sent a query from remote,
+// nil when it can't be told.
+func (ps *ProxySet) askingHook(hs []*hooks.Hook, remote net.Addr) *hooks.Hook {
+	if len(hs) == 1 {
+		return hs[0]
+	}
+	udpAddr, ok := remote.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	pid, err := udpOwner(udpAddr.Port)
+	if err != nil {
+		ps.logger.Debug("failed to find the process which sent the dns query", zap.Error(err), zap.Any("source", remote.String()))
+		return nil
+	}
+	h, _, ok := ps.route(pid)
+	if !ok {
+		return nil
+	}
+	return h
+}
+
+// dnsHooks returns the hook of the proxy along with the ones of the parallel instances of the
+// application.
+func (ps *ProxySet) dnsHooks() []*hooks.Hook {
+	ps.lanesMutex.Lock()
+	defer ps.lanesMutex.Unlock()
+	hs := []*hooks.Hook{ps.hook}
+	for _, l := range ps.lanes {
+		hs = append(hs, l.hook)
+	}
+	return hs
+}
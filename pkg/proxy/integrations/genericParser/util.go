@@ -116,3 +116,15 @@ func IsAsciiPrintable(s string) bool {
 	}
 	return true
 }
+
+// genericRequestSummary describes the requests of an unmatched call in the test report.
+func genericRequestSummary(requestBuffers [][]byte) string {
+	size := 0
+	for _, buf := range requestBuffers {
+		if IsAsciiPrintable(string(buf)) {
+			return string(buf)
+		}
+		size += len(buf)
+	}
+	return fmt.Sprintf("%d bytes in %d packets", size, len(requestBuffers))
+}
@@ -8,9 +8,15 @@ import (
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/hpack"
 
+	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
 )
 
+// maxDataFrameSize is the largest DATA frame a peer accepts before it raises its SETTINGS_MAX_FRAME_SIZE.
+const maxDataFrameSize = 16384
+
 type transcoder struct {
 	sic     *StreamInfoCollection
 	hook    *hooks.Hook
@@ -69,6 +75,12 @@ func (srv *transcoder) ProcessDataFrame(dataFrame *http2.DataFrame) error {
 			zap.Any("stream_id", id))
 		return http2.ConnectionError(http2.ErrCodeProtocol)
 	}
+	if srv.sic.AddHttpPayload(id, dataFrame.Data(), true) {
+		if dataFrame.StreamEnded() {
+			return srv.respondHttpCall(id)
+		}
+		return nil
+	}
 	srv.sic.AddPayloadForRequest(id, dataFrame.Data())
 
 	if dataFrame.StreamEnded() {
@@ -83,6 +95,7 @@ func (srv *transcoder) ProcessDataFrame(dataFrame *http2.DataFrame) error {
 		return fmt.Errorf("failed match mocks: %v", err)
 	}
 	if mock == nil {
+		srv.hook.RecordUnmatchedCall(models.GRPC_EXPORT, grpcReq.Headers.PseudoHeaders[KLabelForPath])
 		return fmt.Errorf("failed to mock the output for unrecorded outgoing grpc call")
 	}
 
@@ -129,7 +142,7 @@ func (srv *transcoder) ProcessDataFrame(dataFrame *http2.DataFrame) error {
 		return err
 	}
 
-	payload, err := CreatePayloadFromLengthPrefixedMessage(grpcMockResp.Body)
+	payload, err := pkg.CreatePayloadFromLengthPrefixedMessage(grpcMockResp.Body)
 	if err != nil {
 		srv.logger.Error("could not create grpc payload from mocks", zap.Error(err))
 		return err
@@ -231,16 +244,77 @@ func (srv *transcoder) ProcessHeadersFrame(headersFrame *http2.HeadersFrame) err
 		return http2.ConnectionError(http2.ErrCodeProtocol)
 	}
 
-	pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(headersFrame, srv.decoder)
+	pseudoHeaders, ordinaryHeaders, err := pkg.ExtractHeaders(headersFrame, srv.decoder)
 	if err != nil {
 		fmt.Errorf("could not extract headers from frame: %v", err)
 	}
 
+	if srv.sic.AddHttpHeaders(id, pseudoHeaders, ordinaryHeaders, true) {
+		if headersFrame.StreamEnded() {
+			return srv.respondHttpCall(id)
+		}
+		return nil
+	}
+
 	srv.sic.AddHeadersForRequest(id, pseudoHeaders, true)
 	srv.sic.AddHeadersForRequest(id, ordinaryHeaders, false)
 	return nil
 }
 
+// respondHttpCall answers the plain HTTP call of the stream with the HTTP mock recorded for it, once the
+// application has sent the whole request. An unrecorded call resets the stream, the other streams go on.
+func (srv *transcoder) respondHttpCall(id uint32) error {
+	call := srv.sic.TakeHttpCall(id)
+	mock, err := httpparser.MatchHttp2Call(call, srv.hook, srv.logger)
+	if err != nil {
+		srv.logger.Error("error while matching http mocks", zap.Error(err))
+	}
+	if mock == nil {
+		srv.logger.Error("Didn't match any prexisting http mock", zap.Any("method", call.PseudoHeaders[KLabelForMethod]), zap.Any("path", call.PseudoHeaders[KLabelForPath]))
+		srv.hook.RecordUnmatchedCall(models.HTTP, call.PseudoHeaders[KLabelForMethod]+" "+call.PseudoHeaders[KLabelForPath])
+		return srv.framer.WriteRSTStream(id, http2.ErrCodeInternal)
+	}
+
+	fields, body, err := httpparser.Http2Response(mock, srv.logger)
+	if err != nil {
+		return err
+	}
+	buf := new(bytes.Buffer)
+	encoder := hpack.NewEncoder(buf)
+	for _, field := range fields {
+		if err := encoder.WriteField(field); err != nil {
+			srv.logger.Error("could not encode header", zap.Error(err), zap.Any("key", field.Name), zap.Any("value", field.Value))
+			return err
+		}
+	}
+	err = srv.framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      id,
+		BlockFragment: buf.Bytes(),
+		EndStream:     len(body) == 0,
+		EndHeaders:    true,
+	})
+	if err != nil {
+		srv.logger.Error("could not write the headers of the http response onto client", zap.Error(err))
+		return err
+	}
+
+	// the body is split into frames of the default maximum frame size, the flow control window of the
+	// application is not tracked, like for the grpc responses
+	for len(body) > 0 {
+		size := len(body)
+		if size > maxDataFrameSize {
+			size = maxDataFrameSize
+		}
+		err = srv.framer.WriteData(id, size == len(body), body[:size])
+		if err != nil {
+			srv.logger.Error("could not write the body of the http response onto client", zap.Error(err))
+			return err
+		}
+		body = body[size:]
+	}
+	return nil
+}
+
 func (srv *transcoder) ProcessPushPromise(pushPromiseFrame *http2.PushPromiseFrame) error {
 	// A client cannot push. Thus, servers MUST treat the receipt of a PUSH_PROMISE
 	// frame as a connection error (Section 5.4.1) of type PROTOCOL_ERROR.
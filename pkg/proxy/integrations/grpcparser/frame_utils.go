@@ -69,23 +69,3 @@ func PrintFrame(frame http2.Frame) {
 		PrintPingFrame(frame.(*http2.PingFrame))
 	}
 }
-
-func ExtractHeaders(frame *http2.HeadersFrame, decoder *hpack.Decoder) (pseudoHeaders, ordinaryHeaders map[string]string, err error) {
-	hf, err := decoder.DecodeFull(frame.HeaderBlockFragment())
-	if err != nil {
-		return nil, nil, fmt.Errorf("could not decode headers: %v", err)
-	}
-
-	pseudoHeaders = make(map[string]string)
-	ordinaryHeaders = make(map[string]string)
-
-	for _, header := range hf {
-		if header.IsPseudo() {
-			pseudoHeaders[header.Name] = header.Value
-		} else {
-			ordinaryHeaders[header.Name] = header.Value
-		}
-	}
-
-	return pseudoHeaders, ordinaryHeaders, nil
-}
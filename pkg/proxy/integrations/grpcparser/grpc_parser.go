@@ -31,7 +31,8 @@ func NewGrpcParser(logger *zap.Logger, h *hooks.Hook) *GrpcParser {
 	}
 }
 
-// OutgoingType will be a method of GrpcParser.
+// OutgoingType will be a method of GrpcParser. It handles every HTTP/2 connection, the streams that
+// do not carry gRPC are stored as HTTP mocks.
 func (g *GrpcParser) OutgoingType(buffer []byte) bool {
 	return bytes.HasPrefix(buffer[:], []byte("PRI * HTTP/2"))
 }
@@ -76,7 +77,7 @@ func encodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *
 		defer h.Recover(pkg.GenerateRandomID())
 		defer utils.HandlePanic()
 		defer wg.Done()
-		err := TransferFrame(destConn, clientConn, streamInfoCollection, isReqFromClient, serverSideDecoder, ctx)
+		err := TransferFrame(destConn, clientConn, streamInfoCollection, isReqFromClient, serverSideDecoder, logger, ctx)
 		if err != nil {
 			// check for EOF error
 			if err == io.EOF {
@@ -95,7 +96,7 @@ func encodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *
 		defer h.Recover(pkg.GenerateRandomID())
 		defer utils.HandlePanic()
 		defer wg.Done()
-		err := TransferFrame(clientConn, destConn, streamInfoCollection, !isReqFromClient, clientSideDecoder, ctx)
+		err := TransferFrame(clientConn, destConn, streamInfoCollection, !isReqFromClient, clientSideDecoder, logger, ctx)
 		if err != nil {
 			logger.Error("failed to transfer frame from server to client", zap.Error(err))
 		}
@@ -108,7 +109,7 @@ func encodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *
 }
 
 // TransferFrame reads one frame from rhs and writes it to lhs.
-func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqFromClient bool, decoder *hpack.Decoder, ctx context.Context) error {
+func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqFromClient bool, decoder *hpack.Decoder, logger *zap.Logger, ctx context.Context) error {
 	isRespFromServer := !isReqFromClient
 	framer := http2.NewFramer(lhs, rhs)
 	for {
@@ -157,11 +158,19 @@ func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqF
 			if err != nil {
 				return fmt.Errorf("could not write headers frame: %v", err)
 			}
-			pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(headersFrame, decoder)
+			pseudoHeaders, ordinaryHeaders, err := pkg.ExtractHeaders(headersFrame, decoder)
 			if err != nil {
 				return fmt.Errorf("could not extract headers from frame: %v", err)
 			}
 
+			// the streams that do not carry gRPC are stored as HTTP mocks once the response ends
+			if sic.AddHttpHeaders(streamID, pseudoHeaders, ordinaryHeaders, isReqFromClient) {
+				if isRespFromServer && headersFrame.StreamEnded() {
+					sic.PersistHttpCallForStream(streamID, logger, ctx)
+				}
+				continue
+			}
+
 			if isReqFromClient {
 				sic.AddHeadersForRequest(streamID, pseudoHeaders, true)
 				sic.AddHeadersForRequest(streamID, ordinaryHeaders, false)
@@ -188,6 +197,12 @@ func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqF
 			if err != nil {
 				return fmt.Errorf("could not write data frame: %v", err)
 			}
+			if sic.AddHttpPayload(dataFrame.StreamID, dataFrame.Data(), isReqFromClient) {
+				if isRespFromServer && dataFrame.StreamEnded() {
+					sic.PersistHttpCallForStream(dataFrame.StreamID, logger, ctx)
+				}
+				continue
+			}
 			if isReqFromClient {
 				// Capturing the request timestamp
 				sic.ReqTimestampMock = time.Now()
@@ -230,6 +245,8 @@ func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqF
 			if err != nil {
 				return fmt.Errorf("could not write reset stream frame: %v", err)
 			}
+			// a cancelled http call is not stored
+			sic.TakeHttpCall(rstStreamFrame.StreamID)
 		case *http2.GoAwayFrame:
 			goAwayFrame := frame.(*http2.GoAwayFrame)
 			err := framer.WriteGoAway(goAwayFrame.StreamID, goAwayFrame.ErrCode, goAwayFrame.DebugData())
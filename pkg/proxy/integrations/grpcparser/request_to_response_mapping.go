@@ -3,6 +3,7 @@ package grpcparser
 import (
 	"fmt"
 
+	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
 )
@@ -58,13 +59,8 @@ func FilterMocksBasedOnGrpcRequest(grpcReq models.GrpcReq, hook *hooks.Hook) (*m
 				continue
 			}
 
-			// Investigate the compression flag.
-			if have.Body.CompressionFlag != grpcReq.Body.CompressionFlag {
-				continue
-			}
-
-			// Investigate the body.
-			if have.Body.DecodedData != grpcReq.Body.DecodedData {
+			// Investigate the body, field by field, leaving out the noise of the mock.
+			if !pkg.MatchGrpcMessages(have.Body, grpcReq.Body, have.Noise) {
 				continue
 			}
 
@@ -5,8 +5,11 @@ import (
 	"sync"
 	"time"
 
+	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
+	"go.uber.org/zap"
 )
 
 // StreamInfoCollection is a thread-safe data structure to store all communications
@@ -18,12 +21,72 @@ type StreamInfoCollection struct {
 	StreamInfo       map[uint32]models.GrpcStream
 	ReqTimestampMock time.Time
 	ResTimestampMock time.Time
+	// HttpCalls holds the streams that do not carry gRPC, they are stored as HTTP mocks
+	HttpCalls map[uint32]*httpparser.Http2Call
 }
 
 func NewStreamInfoCollection(h *hooks.Hook) *StreamInfoCollection {
 	return &StreamInfoCollection{
 		hook:       h,
 		StreamInfo: make(map[uint32]models.GrpcStream),
+		HttpCalls:  make(map[uint32]*httpparser.Http2Call),
+	}
+}
+
+// AddHttpHeaders adds the headers to the stream when it carries a plain HTTP call, which is known from the
+// content-type of the first headers of the request. It reports whether the stream is an HTTP one.
+func (sic *StreamInfoCollection) AddHttpHeaders(streamID uint32, pseudoHeaders, ordinaryHeaders map[string]string, isRequest bool) bool {
+	sic.mutex.Lock()
+	defer sic.mutex.Unlock()
+
+	call, ok := sic.HttpCalls[streamID]
+	if !ok {
+		if _, isGrpc := sic.StreamInfo[streamID]; isGrpc || !isRequest || !httpparser.IsHttp2Call(ordinaryHeaders) {
+			return false
+		}
+		call = httpparser.NewHttp2Call()
+		sic.HttpCalls[streamID] = call
+	}
+	call.AddHeaders(pseudoHeaders, ordinaryHeaders, isRequest)
+	return true
+}
+
+// AddHttpPayload adds the DATA frame to the stream when it carries a plain HTTP call. It reports whether the
+// stream is an HTTP one.
+func (sic *StreamInfoCollection) AddHttpPayload(streamID uint32, payload []byte, isRequest bool) bool {
+	sic.mutex.Lock()
+	defer sic.mutex.Unlock()
+
+	call, ok := sic.HttpCalls[streamID]
+	if ok {
+		call.AddPayload(payload, isRequest)
+	}
+	return ok
+}
+
+// TakeHttpCall removes the HTTP call of the stream from the collection and returns it.
+func (sic *StreamInfoCollection) TakeHttpCall(streamID uint32) *httpparser.Http2Call {
+	sic.mutex.Lock()
+	defer sic.mutex.Unlock()
+
+	call := sic.HttpCalls[streamID]
+	delete(sic.HttpCalls, streamID)
+	return call
+}
+
+// PersistHttpCallForStream stores the HTTP call of the stream as an HTTP mock, once its response has ended.
+func (sic *StreamInfoCollection) PersistHttpCallForStream(streamID uint32, logger *zap.Logger, ctx context.Context) {
+	call := sic.TakeHttpCall(streamID)
+	if call == nil {
+		return
+	}
+	mock, err := call.Mock(logger)
+	if err != nil {
+		logger.Error("failed to capture the http2 call as a mock", zap.Error(err), zap.Any("stream id", streamID))
+		return
+	}
+	if mock != nil {
+		sic.hook.AppendMocks(mock, ctx)
 	}
 }
 
@@ -85,7 +148,7 @@ func (sic *StreamInfoCollection) AddPayloadForRequest(streamID uint32, payload [
 	// We cannot modify non pointer values in nested entries in map.
 	// Create a copy and overwrite it.
 	info := sic.StreamInfo[streamID]
-	info.GrpcReq.Body = CreateLengthPrefixedMessageFromPayload(payload)
+	info.GrpcReq.Body = pkg.DecodeGrpcMessage(info.GrpcReq.Headers, payload, true)
 	sic.StreamInfo[streamID] = info
 }
 
@@ -99,7 +162,7 @@ func (sic *StreamInfoCollection) AddPayloadForResponse(streamID uint32, payload
 	// We cannot modify non pointer values in nested entries in map.
 	// Create a copy and overwrite it.
 	info := sic.StreamInfo[streamID]
-	info.GrpcResp.Body = CreateLengthPrefixedMessageFromPayload(payload)
+	info.GrpcResp.Body = pkg.DecodeGrpcMessage(info.GrpcReq.Headers, payload, false)
 	sic.StreamInfo[streamID] = info
 }
 
@@ -134,4 +197,5 @@ func (sic *StreamInfoCollection) ResetStream(streamID uint32) {
 	defer sic.mutex.Unlock()
 
 	delete(sic.StreamInfo, streamID)
+	delete(sic.HttpCalls, streamID)
 }
@@ -0,0 +1,273 @@
+package httpparser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"golang.org/x/net/http2/hpack"
+)
+
+// connectionHeaders are the HTTP/1.x headers that are not allowed in an HTTP/2 message.
+var connectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// Http2Call collects a call made over a stream of an HTTP/2 connection that does not carry gRPC. The call is
+// stored as an HTTP mock, like the ones made over HTTP/1.x.
+type Http2Call struct {
+	PseudoHeaders     map[string]string
+	Headers           map[string]string
+	Body              []byte
+	RespPseudoHeaders map[string]string
+	RespHeaders       map[string]string
+	RespBody          []byte
+	ReqTimestamp      time.Time
+	ResTimestamp      time.Time
+}
+
+func NewHttp2Call() *Http2Call {
+	return &Http2Call{
+		PseudoHeaders:     map[string]string{},
+		Headers:           map[string]string{},
+		RespPseudoHeaders: map[string]string{},
+		RespHeaders:       map[string]string{},
+	}
+}
+
+// IsHttp2Call reports whether the request headers of an HTTP/2 stream start a call that is not a gRPC one.
+func IsHttp2Call(ordinaryHeaders map[string]string) bool {
+	return !strings.HasPrefix(ordinaryHeaders["content-type"], "application/grpc")
+}
+
+// AddHeaders adds a header block of the stream. The trailers of the response are left out, the HTTP mocks have
+// no place for them.
+func (c *Http2Call) AddHeaders(pseudoHeaders, ordinaryHeaders map[string]string, isRequest bool) {
+	if isRequest {
+		if c.ReqTimestamp.IsZero() {
+			c.ReqTimestamp = time.Now()
+		}
+		for key, value := range pseudoHeaders {
+			c.PseudoHeaders[key] = value
+		}
+		for key, value := range ordinaryHeaders {
+			c.Headers[key] = value
+		}
+		return
+	}
+	if _, ok := c.RespPseudoHeaders[":status"]; ok {
+		return
+	}
+	c.ResTimestamp = time.Now()
+	for key, value := range pseudoHeaders {
+		c.RespPseudoHeaders[key] = value
+	}
+	for key, value := range ordinaryHeaders {
+		c.RespHeaders[key] = value
+	}
+}
+
+// AddPayload appends the data of a DATA frame of the stream.
+func (c *Http2Call) AddPayload(payload []byte, isRequest bool) {
+	if isRequest {
+		c.Body = append(c.Body, payload...)
+		return
+	}
+	c.RespBody = append(c.RespBody, payload...)
+}
+
+// Request returns the request of the call the way net/http reads an HTTP/1.x one.
+func (c *Http2Call) Request() (*http.Request, error) {
+	reqURL, err := url.ParseRequestURI(c.PseudoHeaders[":path"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the path of the http2 request: %v", err)
+	}
+	header := http.Header{}
+	for key, value := range c.Headers {
+		header.Add(key, value)
+	}
+	return &http.Request{
+		Method:        c.PseudoHeaders[":method"],
+		URL:           reqURL,
+		Proto:         "HTTP/2.0",
+		ProtoMajor:    2,
+		ProtoMinor:    0,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Host:          c.PseudoHeaders[":authority"],
+		RequestURI:    c.PseudoHeaders[":path"],
+	}, nil
+}
+
+// Mock returns the HTTP mock of the call, or nil for the hosts whose calls are not recorded.
+func (c *Http2Call) Mock(logger *zap.Logger) (*models.Mock, error) {
+	req, err := c.Request()
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range models.PassThroughHosts {
+		if req.Host == host {
+			return nil, nil
+		}
+	}
+	statusCode, err := strconv.Atoi(c.RespPseudoHeaders[":status"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the status of the http2 response: %v", err)
+	}
+	respHeader := http.Header{}
+	for key, value := range c.RespHeaders {
+		respHeader.Add(key, value)
+	}
+	respBody := c.RespBody
+	if respHeader.Get("Content-Encoding") == "gzip" {
+		if ok, reader := checkIfGzipped(io.NopCloser(bytes.NewReader(respBody))); ok {
+			gzipReader, err := gzip.NewReader(reader)
+			if err != nil {
+				logger.Error("failed to create a gzip reader", zap.Error(err))
+				return nil, err
+			}
+			respBody, err = io.ReadAll(gzipReader)
+			if err != nil {
+				logger.Error("failed to read the the http2 response body", zap.Error(err))
+				return nil, err
+			}
+		}
+	}
+	respHeader.Set("Content-Length", strconv.Itoa(len(respBody)))
+
+	return &models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.HTTP,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{
+				"name":      "Http",
+				"type":      models.HttpClient,
+				"operation": req.Method,
+			},
+			HttpReq: &models.HttpReq{
+				Method:     models.Method(req.Method),
+				ProtoMajor: req.ProtoMajor,
+				ProtoMinor: req.ProtoMinor,
+				URL:        req.URL.String(),
+				Header:     pkg.ToYamlHttpHeader(req.Header),
+				Body:       string(c.Body),
+				URLParams:  pkg.UrlParams(req),
+				Host:       req.Host,
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode: statusCode,
+				Header:     pkg.ToYamlHttpHeader(respHeader),
+				Body:       string(respBody),
+			},
+			Created:          time.Now().Unix(),
+			ReqTimestampMock: c.ReqTimestamp,
+			ResTimestampMock: c.ResTimestamp,
+		},
+	}, nil
+}
+
+// MatchHttp2Call returns the HTTP mock recorded for the call, the same way the HTTP/1.x calls are matched.
+func MatchHttp2Call(c *Http2Call, h *hooks.Hook, logger *zap.Logger) (*models.Mock, error) {
+	req, err := c.Request()
+	if err != nil {
+		return nil, err
+	}
+	isMatched, stub, err := match(req, c.Body, req.URL, isJSON(c.Body), h, logger, nil, nil, c.Body, h.Recover)
+	if err != nil || !isMatched {
+		return nil, err
+	}
+	return stub, nil
+}
+
+// Http2Response returns the header fields and the body that answer the call with the response of the mock.
+func Http2Response(mock *models.Mock, logger *zap.Logger) ([]hpack.HeaderField, []byte, error) {
+	resp := mock.Spec.HttpResp
+	body := []byte(resp.Body)
+	header := pkg.ToHttpHeader(resp.Header)
+	if header["Content-Encoding"] != nil && header["Content-Encoding"][0] == "gzip" {
+		var compressedBuffer bytes.Buffer
+		gw := gzip.NewWriter(&compressedBuffer)
+		if _, err := gw.Write(body); err != nil {
+			logger.Error("failed to compress the response body", zap.Error(err))
+			return nil, nil, err
+		}
+		if err := gw.Close(); err != nil {
+			logger.Error("failed to close the gzip writer", zap.Error(err))
+			return nil, nil, err
+		}
+		body = compressedBuffer.Bytes()
+	}
+
+	fields := []hpack.HeaderField{{Name: ":status", Value: strconv.Itoa(resp.StatusCode)}}
+	for key, value := range resp.Header {
+		name := strings.ToLower(key)
+		if connectionHeaders[name] {
+			continue
+		}
+		if name == "content-length" {
+			value = strconv.Itoa(len(body))
+		}
+		fields = append(fields, hpack.HeaderField{Name: name, Value: value})
+	}
+	return fields, body, nil
+}
+
+// StripH2cUpgrade removes the request to upgrade an HTTP/1.1 connection to h2c from the first request of the
+// connection. The calls then go on over HTTP/1.1, which the server must accept.
+func StripH2cUpgrade(request []byte) []byte {
+	headerEnd := bytes.Index(request, []byte("\r\n\r\n"))
+	if headerEnd == -1 {
+		return request
+	}
+	lines := strings.Split(string(request[:headerEnd]), "\r\n")
+	upgrade := false
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "upgrade") && strings.EqualFold(strings.TrimSpace(value), "h2c") {
+			upgrade = true
+		}
+	}
+	if !upgrade {
+		return request
+	}
+
+	kept := []string{lines[0]}
+	for _, line := range lines[1:] {
+		name, value, _ := strings.Cut(line, ":")
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "upgrade", "http2-settings":
+			continue
+		case "connection":
+			var tokens []string
+			for _, token := range strings.Split(value, ",") {
+				token = strings.TrimSpace(token)
+				if !strings.EqualFold(token, "upgrade") && !strings.EqualFold(token, "http2-settings") {
+					tokens = append(tokens, token)
+				}
+			}
+			if len(tokens) == 0 {
+				continue
+			}
+			line = name + ": " + strings.Join(tokens, ", ")
+		}
+		kept = append(kept, line)
+	}
+	stripped := []byte(strings.Join(kept, "\r\n"))
+	return append(stripped, request[headerEnd:]...)
+}
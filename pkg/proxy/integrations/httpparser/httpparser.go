@@ -55,7 +55,8 @@ func NewHttpParser(logger *zap.Logger, h *hooks.Hook) *HttpParser {
 }
 
 // IsOutgoingHTTP function determines if the outgoing network call is HTTP by comparing the
-// message format with that of an HTTP text message.
+// message format with that of an HTTP text message. The HTTP/2 connections are handled by the
+// grpc parser, which stores the calls that do not carry gRPC as HTTP mocks.
 func (h *HttpParser) OutgoingType(buffer []byte) bool {
 	return bytes.HasPrefix(buffer[:], []byte("HTTP/")) ||
 		bytes.HasPrefix(buffer[:], []byte("GET ")) ||
@@ -314,6 +315,12 @@ func checkIfGzipped(check io.ReadCloser) (bool, *bufio.Reader) {
 
 // Decodes the mocks in test mode so that they can be sent to the user application.
 func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) {
+	// the mocks of the h2c upgrades are recorded without the upgrade
+	requestBuffer = StripH2cUpgrade(requestBuffer)
+	if isWebSocketHandshake(requestBuffer) {
+		decodeOutgoingWebSocket(requestBuffer, clientConn, destConn, h, logger)
+		return
+	}
 	//Matching algorithmm
 	//Get the mocks
 	for {
@@ -374,6 +381,7 @@ func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *
 			}
 			if !passthroughHost {
 				logger.Error("Didn't match any prexisting http mock")
+				h.RecordUnmatchedCall(models.HTTP, req.Method+" "+req.URL.String())
 			}
 			util.Passthrough(clientConn, destConn, [][]byte{requestBuffer}, h.Recover, logger)
 			return
@@ -446,6 +454,10 @@ func encodeOutgoingHttp(request []byte, clientConn, destConn net.Conn, logger *z
 	var finalReq []byte
 	var err error
 	defer destConn.Close()
+	request = StripH2cUpgrade(request)
+	if isWebSocketHandshake(request) {
+		return encodeOutgoingWebSocket(request, clientConn, destConn, logger, h, ctx)
+	}
 	//Writing the request to the server.
 	_, err = destConn.Write(request)
 	if err != nil {
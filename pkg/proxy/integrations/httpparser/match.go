@@ -7,11 +7,8 @@ import (
 	"net/url"
 	"unicode"
 
-	"github.com/agnivade/levenshtein"
-	"github.com/cloudflare/cfssl/log"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
-	"go.keploy.io/server/pkg/proxy/util"
 	"go.uber.org/zap"
 )
 
@@ -70,7 +67,7 @@ func match(req *http.Request, reqBody []byte, reqURL *url.URL, isReqBodyJSON boo
 			return false, nil, nil
 		}
 
-		isMatched, bestMatch := Fuzzymatch(eligibleMock, requestBuffer, h)
+		isMatched, bestMatch := scoreMatch(eligibleMock, req, reqBody, logger)
 		if isMatched {
 			isDeleted, err := h.DeleteTcsMock(bestMatch)
 			if err != nil {
@@ -85,27 +82,6 @@ func match(req *http.Request, reqBody []byte, reqURL *url.URL, isReqBodyJSON boo
 
 }
 
-func findStringMatch(req string, mockString []string) int {
-	minDist := int(^uint(0) >> 1) // Initialize with max int value
-	bestMatch := -1
-	for idx, req := range mockString {
-		if !IsAsciiPrintable(mockString[idx]) {
-			continue
-		}
-
-		dist := levenshtein.ComputeDistance(req, mockString[idx])
-		if dist == 0 {
-			return 0
-		}
-
-		if dist < minDist {
-			minDist = dist
-			bestMatch = idx
-		}
-	}
-	return bestMatch
-}
-
 func HttpDecoder(encoded string) ([]byte, error) {
 	// decode the string to a buffer.
 
@@ -113,28 +89,6 @@ func HttpDecoder(encoded string) ([]byte, error) {
 	return data, nil
 }
 
-func findBinaryMatch(configMocks []*models.Mock, reqBuff []byte, h *hooks.Hook) int {
-
-	mxSim := -1.0
-	mxIdx := -1
-	// find the fuzzy hash of the mocks
-	for idx, mock := range configMocks {
-		encoded, _ := HttpDecoder(mock.Spec.HttpReq.Body)
-		k := util.AdaptiveK(len(reqBuff), 3, 8, 5)
-		shingles1 := util.CreateShingles(encoded, k)
-		shingles2 := util.CreateShingles(reqBuff, k)
-		similarity := util.JaccardSimilarity(shingles1, shingles2)
-
-		log.Debugf("Jaccard Similarity:%f\n", similarity)
-
-		if mxSim < similarity {
-			mxSim = similarity
-			mxIdx = idx
-		}
-	}
-	return mxIdx
-}
-
 func IsAsciiPrintable(s string) bool {
 	for _, r := range s {
 		if r > unicode.MaxASCII || !unicode.IsPrint(r) {
@@ -149,29 +103,3 @@ func HttpEncoder(buffer []byte) string {
 	encoded := string(buffer)
 	return encoded
 }
-func Fuzzymatch(tcsMocks []*models.Mock, reqBuff []byte, h *hooks.Hook) (bool, *models.Mock) {
-	com := HttpEncoder(reqBuff)
-	for _, mock := range tcsMocks {
-		encoded, _ := HttpDecoder(mock.Spec.HttpReq.Body)
-		if string(encoded) == string(reqBuff) || mock.Spec.HttpReq.Body == com {
-			return true, mock
-		}
-	}
-	// convert all the configmocks to string array
-	mockString := make([]string, len(tcsMocks))
-	for i := 0; i < len(tcsMocks); i++ {
-		mockString[i] = string(tcsMocks[i].Spec.HttpReq.Body)
-	}
-	// find the closest match
-	if IsAsciiPrintable(string(reqBuff)) {
-		idx := findStringMatch(string(reqBuff), mockString)
-		if idx != -1 {
-			return true, tcsMocks[idx]
-		}
-	}
-	idx := findBinaryMatch(tcsMocks, reqBuff, h)
-	if idx != -1 {
-		return true, tcsMocks[idx]
-	}
-	return false, &models.Mock{}
-}
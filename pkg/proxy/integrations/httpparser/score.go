@@ -0,0 +1,235 @@
+package httpparser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/agnivade/levenshtein"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// the weights of the parts of a call in the score of a mock
+const (
+	queryWeight  = 1.0
+	headerWeight = 1.0
+	bodyWeight   = 2.0
+)
+
+// maxReportedCandidates is the number of mocks reported with their scores when none of them is good enough.
+const maxReportedCandidates = 3
+
+var (
+	matchConfig   models.MockMatching
+	matchConfigMu sync.RWMutex
+)
+
+// SetMatchConfig sets how the HTTP mocks are scored against the outgoing calls of the application.
+func SetMatchConfig(config models.MockMatching) {
+	matchConfigMu.Lock()
+	defer matchConfigMu.Unlock()
+	matchConfig = config
+}
+
+func getMatchConfig() models.MockMatching {
+	matchConfigMu.RLock()
+	defer matchConfigMu.RUnlock()
+	return matchConfig
+}
+
+// mockScore is how close a mock is to a call. Each part is between 0 and 1, the parts the call and the mock
+// have nothing to compare in are left out of the total.
+type mockScore struct {
+	mock   *models.Mock
+	query  *float64
+	header *float64
+	body   float64
+	total  float64
+}
+
+func (s mockScore) String() string {
+	parts := []string{"body=" + strconv.FormatFloat(s.body, 'f', 2, 64)}
+	if s.query != nil {
+		parts = append(parts, "query="+strconv.FormatFloat(*s.query, 'f', 2, 64))
+	}
+	if s.header != nil {
+		parts = append(parts, "header="+strconv.FormatFloat(*s.header, 'f', 2, 64))
+	}
+	return fmt.Sprintf("%s %s recorded at %v: total=%.2f (%s)", s.mock.Spec.HttpReq.Method, s.mock.Spec.HttpReq.URL, s.mock.Spec.ReqTimestampMock, s.total, strings.Join(parts, " "))
+}
+
+// scoreMatch returns the mock with the best score for the call. When it scores below the threshold of the config
+// no mock is served, and the best candidates are logged with the parts of their score.
+func scoreMatch(mocks []*models.Mock, req *http.Request, reqBody []byte, logger *zap.Logger) (bool, *models.Mock) {
+	config := getMatchConfig()
+	scores := scoreMocks(mocks, req, reqBody, config)
+	if scores[0].total >= config.Threshold {
+		logger.Debug("scored the http mocks", zap.String("best match", scores[0].String()))
+		return true, scores[0].mock
+	}
+	candidates := scores
+	if len(candidates) > maxReportedCandidates {
+		candidates = candidates[:maxReportedCandidates]
+	}
+	descriptions := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		descriptions[i] = candidate.String()
+	}
+	logger.Warn("no http mock scored above the threshold for the call", zap.String("method", req.Method), zap.String("url", req.URL.String()), zap.Float64("threshold", config.Threshold), zap.Strings("candidates", descriptions))
+	return false, nil
+}
+
+// scoreMocks scores the mocks against the call and returns them from the best to the worst. The mocks with the
+// same score keep their recorded order, so the earliest of the equal mocks is served first.
+func scoreMocks(mocks []*models.Mock, req *http.Request, reqBody []byte, config models.MockMatching) []mockScore {
+	noise := map[string]bool{}
+	for _, path := range config.Noise {
+		noise[strings.ToLower(path)] = true
+	}
+	var reqFields map[string]string
+	if isJSON(reqBody) {
+		reqFields = flattenJSON(reqBody, noise)
+	}
+
+	scores := make([]mockScore, 0, len(mocks))
+	for _, mock := range mocks {
+		score := mockScore{mock: mock}
+		weights, total := 0.0, 0.0
+
+		if query := scoreQuery(mock.Spec.HttpReq.URLParams, req); query != nil {
+			score.query = query
+			weights += queryWeight
+			total += queryWeight * *query
+		}
+		if header := scoreHeaders(mock.Spec.HttpReq.Header, req.Header, config.Headers); header != nil {
+			score.header = header
+			weights += headerWeight
+			total += headerWeight * *header
+		}
+		if reqFields != nil {
+			score.body = scoreFields(flattenJSON([]byte(mock.Spec.HttpReq.Body), noise), reqFields)
+		} else {
+			score.body = scoreRaw([]byte(mock.Spec.HttpReq.Body), reqBody)
+		}
+		weights += bodyWeight
+		total += bodyWeight * score.body
+
+		score.total = total / weights
+		scores = append(scores, score)
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].total > scores[j].total
+	})
+	return scores
+}
+
+// scoreQuery returns the share of the query parameters with the recorded value, nil when there are none.
+func scoreQuery(recorded map[string]string, req *http.Request) *float64 {
+	if len(recorded) == 0 {
+		return nil
+	}
+	query := req.URL.Query()
+	same := 0
+	for key, value := range recorded {
+		if query.Get(key) == value {
+			same++
+		}
+	}
+	score := float64(same) / float64(len(recorded))
+	return &score
+}
+
+// scoreHeaders returns the share of the selected headers with the recorded value, nil when none of them was
+// recorded.
+func scoreHeaders(recorded map[string]string, header http.Header, selected []string) *float64 {
+	compared, same := 0, 0
+	for _, name := range selected {
+		for key, value := range recorded {
+			if !strings.EqualFold(key, name) {
+				continue
+			}
+			compared++
+			if header.Get(name) == value {
+				same++
+			}
+		}
+	}
+	if compared == 0 {
+		return nil
+	}
+	score := float64(same) / float64(compared)
+	return &score
+}
+
+// scoreFields returns the share of the fields of both bodies with the same value.
+func scoreFields(recorded, actual map[string]string) float64 {
+	if len(recorded) == 0 && len(actual) == 0 {
+		return 1
+	}
+	keys := map[string]bool{}
+	same := 0
+	for key, value := range recorded {
+		keys[key] = true
+		if actualValue, ok := actual[key]; ok && actualValue == value {
+			same++
+		}
+	}
+	for key := range actual {
+		keys[key] = true
+	}
+	return float64(same) / float64(len(keys))
+}
+
+// scoreRaw returns how similar two bodies which are not JSON are: by their edit distance when they are text and
+// by their shingles otherwise.
+func scoreRaw(recorded, actual []byte) float64 {
+	if string(recorded) == string(actual) {
+		return 1
+	}
+	if IsAsciiPrintable(string(recorded)) && IsAsciiPrintable(string(actual)) {
+		longest := len(recorded)
+		if len(actual) > longest {
+			longest = len(actual)
+		}
+		return 1 - float64(levenshtein.ComputeDistance(string(recorded), string(actual)))/float64(longest)
+	}
+	k := util.AdaptiveK(len(actual), 3, 8, 5)
+	return util.JaccardSimilarity(util.CreateShingles(recorded, k), util.CreateShingles(actual, k))
+}
+
+// flattenJSON returns the leaves of the JSON body keyed by their path, written like the noise keys e.g.
+// "body.items.0.id". The noisy paths and everything under them are left out.
+func flattenJSON(body []byte, noise map[string]bool) map[string]string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return map[string]string{}
+	}
+	fields := map[string]string{}
+	flattenValue("body", value, noise, fields)
+	return fields
+}
+
+func flattenValue(path string, value interface{}, noise map[string]bool, fields map[string]string) {
+	if noise[strings.ToLower(path)] {
+		return
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenValue(path+"."+key, child, noise, fields)
+		}
+	case []interface{}:
+		for i, child := range v {
+			flattenValue(path+"."+strconv.Itoa(i), child, noise, fields)
+		}
+	default:
+		encoded, _ := json.Marshal(v)
+		fields[path] = string(encoded)
+	}
+}
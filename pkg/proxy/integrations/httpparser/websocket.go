@@ -0,0 +1,342 @@
+package httpparser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+// isWebSocketHandshake reports whether the request opens a WebSocket session.
+func isWebSocketHandshake(request []byte) bool {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request)))
+	if err != nil {
+		return false
+	}
+	return pkg.IsWebSocketUpgrade(req.Header)
+}
+
+// encodeOutgoingWebSocket forwards the handshake of a WebSocket session and relays its frames in both directions.
+// The session is stored as a single mock, with its frames in the order they were sent, once either side closes
+// the connection.
+func encodeOutgoingWebSocket(request []byte, clientConn, destConn net.Conn, logger *zap.Logger, h *hooks.Hook, ctx context.Context) error {
+	defer destConn.Close()
+	reqTimestampMock := time.Now()
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(request)))
+	if err != nil {
+		logger.Error("failed to parse the websocket handshake", zap.Error(err))
+		return err
+	}
+	response, frames, err := forwardWebSocket(request, clientConn, destConn, h, logger)
+	if err != nil {
+		return err
+	}
+	resTimestampMock := time.Now()
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response)), req)
+	if err != nil {
+		logger.Error("failed to parse the websocket handshake response", zap.Error(err))
+		return err
+	}
+	var respBody []byte
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		respBody, err = io.ReadAll(resp.Body)
+		if err != nil {
+			logger.Debug("failed to read the whole body of the refused websocket handshake", zap.Error(err))
+		}
+	}
+
+	for _, host := range models.PassThroughHosts {
+		if req.Host == host {
+			return nil
+		}
+	}
+	h.AppendMocks(&models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.WebSocket,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{
+				"name":      "WebSocket",
+				"type":      models.HttpClient,
+				"operation": req.Method,
+			},
+			HttpReq: &models.HttpReq{
+				Method:     models.Method(req.Method),
+				ProtoMajor: req.ProtoMajor,
+				ProtoMinor: req.ProtoMinor,
+				URL:        req.URL.String(),
+				Header:     pkg.ToYamlHttpHeader(req.Header),
+				URLParams:  pkg.UrlParams(req),
+				Host:       req.Host,
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode: resp.StatusCode,
+				Header:     pkg.ToYamlHttpHeader(resp.Header),
+				Body:       string(respBody),
+			},
+			WebSocketFrames:  frames,
+			Created:          time.Now().Unix(),
+			ReqTimestampMock: reqTimestampMock,
+			ResTimestampMock: resTimestampMock,
+		},
+	}, ctx)
+	return nil
+}
+
+// forwardWebSocket sends the handshake to the server and relays the session until either side closes the
+// connection. It returns the handshake response and the frames of the session.
+func forwardWebSocket(request []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) ([]byte, []models.WebSocketFrame, error) {
+	if destConn == nil {
+		return nil, nil, fmt.Errorf("failed to pass the websocket session to the destination server")
+	}
+	_, err := destConn.Write(request)
+	if err != nil {
+		logger.Error("failed to write the websocket handshake to the destination server", zap.Error(err))
+		return nil, nil, err
+	}
+	var response []byte
+	for !bytes.Contains(response, []byte("\r\n\r\n")) {
+		buffer, err := util.ReadBytes(destConn)
+		response = append(response, buffer...)
+		if err != nil {
+			logger.Error("failed to read the websocket handshake response from the destination server", zap.Error(err))
+			return nil, nil, err
+		}
+	}
+	_, err = clientConn.Write(response)
+	if err != nil {
+		logger.Error("failed to write the websocket handshake response to the user application", zap.Error(err))
+		return nil, nil, err
+	}
+	if !bytes.HasPrefix(response, []byte("HTTP/1.1 101")) {
+		return response, nil, nil
+	}
+
+	clientDecoder := pkg.NewWebSocketDecoder(models.FromClient)
+	serverDecoder := pkg.NewWebSocketDecoder(models.FromServer)
+	// the server may send its first frames along with the handshake response
+	frames := serverDecoder.Decode(pkg.SkipHttpHeaders(response))
+
+	type relayed struct {
+		frames []models.WebSocketFrame
+		err    error
+	}
+	relays := make(chan relayed)
+	relay := func(src, dst net.Conn, decoder *pkg.WebSocketDecoder) {
+		// Recover from panic and gracefully shutdown
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		buffer := make([]byte, 32*1024)
+		for {
+			n, err := src.Read(buffer)
+			if n > 0 {
+				// the frames are stored before the other side gets them, to keep the order of the session
+				relays <- relayed{frames: decoder.Decode(buffer[:n])}
+				if _, writeErr := dst.Write(buffer[:n]); writeErr != nil && err == nil {
+					err = writeErr
+				}
+			}
+			if err != nil {
+				relays <- relayed{err: err}
+				return
+			}
+		}
+	}
+	// the session has no deadline, it lasts as long as the application keeps it open
+	_ = clientConn.SetReadDeadline(time.Time{})
+	go relay(clientConn, destConn, clientDecoder)
+	go relay(destConn, clientConn, serverDecoder)
+
+	for running := 2; running > 0; {
+		r := <-relays
+		frames = append(frames, r.frames...)
+		if r.err != nil {
+			running--
+			// the session ends with either side, the reader of the other one is stopped
+			clientConn.Close()
+			destConn.Close()
+		}
+	}
+	logger.Debug("the websocket session ended", zap.Int("frames", len(frames)))
+	return response, frames, nil
+}
+
+// decodeOutgoingWebSocket answers the handshake of a WebSocket session with the recorded one and plays the server
+// side of the session.
+func decodeOutgoingWebSocket(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(requestBuffer)))
+	if err != nil {
+		logger.Error("failed to parse the websocket handshake", zap.Error(err))
+		return
+	}
+	stub, err := matchWebSocket(req, h, logger)
+	if err != nil {
+		logger.Error("error while matching websocket mocks", zap.Error(err))
+	}
+	if stub == nil {
+		passthroughHost := false
+		for _, host := range models.PassThroughHosts {
+			if req.Host == host {
+				passthroughHost = true
+			}
+		}
+		if !passthroughHost {
+			logger.Error("Didn't match any prexisting websocket mock")
+			h.RecordUnmatchedCall(models.WebSocket, req.Method+" "+req.URL.String())
+		}
+		_, _, err = forwardWebSocket(requestBuffer, clientConn, destConn, h, logger)
+		if err != nil {
+			logger.Debug("failed to pass the websocket session through", zap.Error(err))
+		}
+		return
+	}
+
+	header := pkg.ToHttpHeader(stub.Spec.HttpResp.Header)
+	// the accept key is derived from the key the client sent this time
+	if key := req.Header.Get("Sec-WebSocket-Key"); key != "" && header.Get("Sec-WebSocket-Accept") != "" {
+		header.Set("Sec-WebSocket-Accept", pkg.WebSocketAccept(key))
+	}
+	resp := &http.Response{
+		StatusCode:    stub.Spec.HttpResp.StatusCode,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		ContentLength: int64(len(stub.Spec.HttpResp.Body)),
+		Body:          io.NopCloser(strings.NewReader(stub.Spec.HttpResp.Body)),
+	}
+	if err := resp.Write(clientConn); err != nil {
+		logger.Error("failed to write the websocket handshake response to the user application", zap.Error(err))
+		return
+	}
+	if stub.Spec.HttpResp.StatusCode != http.StatusSwitchingProtocols {
+		return
+	}
+	replayWebSocket(clientConn, stub.Spec.WebSocketFrames, h, logger)
+}
+
+// matchWebSocket returns the oldest WebSocket mock recorded for the path and the query parameters of the
+// handshake, and consumes it.
+func matchWebSocket(req *http.Request, h *hooks.Hook, logger *zap.Logger) (*models.Mock, error) {
+	for {
+		tcsMocks, err := h.GetTcsMocks()
+		if err != nil {
+			return nil, fmt.Errorf("error while getting tcs mocks %v", err)
+		}
+		var stub *models.Mock
+		for _, mock := range tcsMocks {
+			if mock.Kind != models.WebSocket {
+				continue
+			}
+			parsedURL, err := url.Parse(mock.Spec.HttpReq.URL)
+			if err != nil {
+				logger.Error("failed to parse mock url", zap.Error(err))
+				continue
+			}
+			if parsedURL.Path != req.URL.Path || !mapsHaveSameKeys(mock.Spec.HttpReq.URLParams, req.URL.Query()) {
+				continue
+			}
+			stub = mock
+			break
+		}
+		if stub == nil {
+			return nil, nil
+		}
+		isDeleted, err := h.DeleteTcsMock(stub)
+		if err != nil {
+			return nil, fmt.Errorf("error while deleting tcs mocks: %v", err)
+		}
+		if isDeleted {
+			return stub, nil
+		}
+	}
+}
+
+// replayWebSocket plays the server side of a recorded session. The server frames are sent once the client frames
+// recorded before them are received. A client frame that is not the next recorded one is looked for further in
+// the session, the pings and the close frames that match no recorded frame are answered as a server would.
+func replayWebSocket(clientConn net.Conn, frames []models.WebSocketFrame, h *hooks.Hook, logger *zap.Logger) {
+	next := 0
+	// sendServerFrames sends the recorded server frames up to the next client frame
+	sendServerFrames := func() error {
+		for ; next < len(frames) && frames[next].Origin == models.FromServer; next++ {
+			if err := writeWebSocketFrame(clientConn, frames[next]); err != nil {
+				logger.Error("failed to write the websocket frame to the user application", zap.Error(err))
+				return err
+			}
+		}
+		return nil
+	}
+	if sendServerFrames() != nil {
+		return
+	}
+
+	decoder := pkg.NewWebSocketDecoder(models.FromClient)
+	_ = clientConn.SetReadDeadline(time.Time{})
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := clientConn.Read(buffer)
+		for _, frame := range decoder.Decode(buffer[:n]) {
+			if matched := findClientFrame(frames, next, frame); matched != -1 {
+				next = matched + 1
+				if sendServerFrames() != nil || frame.Type == models.WebSocketClose {
+					return
+				}
+				continue
+			}
+			switch frame.Type {
+			case models.WebSocketPing:
+				if writeWebSocketFrame(clientConn, models.WebSocketFrame{Type: models.WebSocketPong, Data: frame.Data}) != nil {
+					return
+				}
+			case models.WebSocketPong:
+			case models.WebSocketClose:
+				_ = writeWebSocketFrame(clientConn, models.WebSocketFrame{Type: models.WebSocketClose, CloseCode: frame.CloseCode})
+				return
+			default:
+				logger.Error("the websocket frame of the user application matched no recorded frame", zap.String("type", frame.Type), zap.String("data", frame.Data))
+				h.RecordUnmatchedCall(models.WebSocket, frame.Type+" "+frame.Data)
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("stopped reading the websocket frames of the user application", zap.Error(err))
+			}
+			return
+		}
+	}
+}
+
+// findClientFrame returns the index of the first recorded client frame, from the given one on, that carries the
+// same message as the frame, or -1.
+func findClientFrame(frames []models.WebSocketFrame, from int, frame models.WebSocketFrame) int {
+	for i := from; i < len(frames); i++ {
+		recorded := frames[i]
+		if recorded.Origin == models.FromClient && recorded.Type == frame.Type && recorded.Data == frame.Data && recorded.CloseCode == frame.CloseCode {
+			return i
+		}
+	}
+	return -1
+}
+
+func writeWebSocketFrame(conn net.Conn, frame models.WebSocketFrame) error {
+	data, err := pkg.EncodeWebSocketFrame(frame, false)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(data)
+	return err
+}
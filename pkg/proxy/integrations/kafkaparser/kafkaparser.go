@@ -0,0 +1,252 @@
+package kafkaparser
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+type KafkaParser struct {
+	logger *zap.Logger
+	hooks  *hooks.Hook
+}
+
+func NewKafkaParser(logger *zap.Logger, h *hooks.Hook) *KafkaParser {
+	return &KafkaParser{
+		logger: logger,
+		hooks:  h,
+	}
+}
+
+// postgresCodes are the codes following the length of the first message of a postgres connection: the
+// protocol version of a StartupMessage, a CancelRequest, an SSLRequest and a GSSENCRequest. A StartupMessage reads
+// as a Metadata v0 request.
+var postgresCodes = map[uint32]bool{
+	0x00030000: true,
+	80877102:   true,
+	80877103:   true,
+	80877104:   true,
+}
+
+// maxFirstCorrelationId bounds the correlation id of the first request of a connection. The clients number their
+// requests from 0 or 1, per connection or per client, while the text of other protocols reads as a huge id.
+const maxFirstCorrelationId = 1 << 24
+
+// OutgoingType function determines if the outgoing network call is Kafka by checking that the
+// buffer starts with a big endian frame length followed by a known api key and version.
+func (k *KafkaParser) OutgoingType(buffer []byte) bool {
+	if len(buffer) < 14 {
+		return false
+	}
+	size := int(int32(binary.BigEndian.Uint32(buffer[:4])))
+	if size < 10 || size > maxFrameSize || size+4 < len(buffer) {
+		return false
+	}
+	if postgresCodes[binary.BigEndian.Uint32(buffer[4:8])] {
+		return false
+	}
+	if correlationId := int32(binary.BigEndian.Uint32(buffer[8:12])); correlationId < 0 || correlationId > maxFirstCorrelationId {
+		return false
+	}
+	apiKey := int16(binary.BigEndian.Uint16(buffer[4:6]))
+	apiVersion := int16(binary.BigEndian.Uint16(buffer[6:8]))
+	if _, ok := apiNames[apiKey]; !ok || apiVersion < 0 || apiVersion > 20 {
+		return false
+	}
+	clientIdLen := int(int16(binary.BigEndian.Uint16(buffer[12:14])))
+	return clientIdLen >= -1 && clientIdLen <= size-10
+}
+
+func (k *KafkaParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, ctx context.Context) {
+	switch models.GetMode() {
+	case models.MODE_RECORD:
+		err := encodeOutgoingKafka(requestBuffer, clientConn, destConn, k.hooks, k.logger, ctx)
+		if err != nil {
+			k.logger.Error("failed to encode the kafka message into the yaml", zap.Error(err))
+			return
+		}
+	case models.MODE_TEST:
+		err := decodeOutgoingKafka(requestBuffer, clientConn, k.hooks, k.logger)
+		if err != nil {
+			k.logger.Error("failed to decode the kafka message from the yaml", zap.Error(err))
+			return
+		}
+	default:
+		k.logger.Info("Invalid mode detected while intercepting outgoing kafka call", zap.Any("mode", models.GetMode()))
+	}
+}
+
+// configApis are called while setting up a connection or refreshing cluster metadata. They are
+// recorded as config mocks so that every connection opened during a test run can be served from them.
+var configApis = map[int16]struct{}{
+	3:  {}, // Metadata
+	10: {}, // FindCoordinator
+	17: {}, // SaslHandshake
+	18: {}, // ApiVersions
+	36: {}, // SaslAuthenticate
+}
+
+type pendingRequest struct {
+	request          models.KafkaRequest
+	reqTimestampMock time.Time
+}
+
+// encodeOutgoingKafka forwards the requests of the client to the broker and records every request
+// along with its response as a separate mock. Clients keep several requests in flight on a single
+// connection, so responses are paired with their requests by correlation id.
+func encodeOutgoingKafka(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+	defer destConn.Close()
+	var (
+		mu      sync.Mutex
+		pending = map[int32]pendingRequest{}
+	)
+
+	go func() {
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		reader := &frameReader{conn: destConn}
+		for {
+			frame, err := reader.next()
+			if err != nil {
+				if err != io.EOF {
+					logger.Debug("failed to read the kafka response from the broker", zap.Error(err))
+				}
+				clientConn.Close()
+				return
+			}
+			resTimestampMock := time.Now()
+			_, err = clientConn.Write(frame)
+			if err != nil {
+				logger.Error("failed to write the kafka response to the client", zap.Error(err))
+				return
+			}
+			resp, err := decodeResponse(frame)
+			if err != nil {
+				logger.Error("failed to decode the kafka response", zap.Error(err))
+				continue
+			}
+			mu.Lock()
+			req, ok := pending[resp.CorrelationId]
+			delete(pending, resp.CorrelationId)
+			mu.Unlock()
+			if !ok {
+				logger.Debug("received a kafka response for an unknown correlation id", zap.Int32("correlation id", resp.CorrelationId))
+				continue
+			}
+
+			meta := map[string]string{
+				"name":      "Kafka",
+				"type":      models.NoSqlDB,
+				"operation": req.request.Header.ApiName,
+			}
+			if _, ok := configApis[req.request.Header.ApiKey]; ok {
+				meta["type"] = "config"
+			}
+			err = h.AppendMocks(&models.Mock{
+				Version: models.GetVersion(),
+				Name:    "mocks",
+				Kind:    models.Kafka,
+				Spec: models.MockSpec{
+					Metadata:         meta,
+					KafkaRequest:     &req.request,
+					KafkaResponse:    &resp,
+					Created:          time.Now().Unix(),
+					ReqTimestampMock: req.reqTimestampMock,
+					ResTimestampMock: resTimestampMock,
+				},
+			}, ctx)
+			if err != nil {
+				logger.Error("failed to store the kafka mock", zap.Error(err))
+			}
+		}
+	}()
+
+	reader := &frameReader{conn: clientConn, buf: requestBuffer}
+	for {
+		frame, err := reader.next()
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("failed to read the kafka request from the client", zap.Error(err))
+			}
+			return nil
+		}
+		req, err := decodeRequest(frame)
+		if err != nil {
+			logger.Error("failed to decode the kafka request", zap.Error(err))
+			return err
+		}
+		if expectsResponse(req) {
+			mu.Lock()
+			pending[req.Header.CorrelationId] = pendingRequest{request: req, reqTimestampMock: time.Now()}
+			mu.Unlock()
+		}
+		_, err = destConn.Write(frame)
+		if err != nil {
+			logger.Error("failed to write the kafka request to the broker", zap.Error(err))
+			return err
+		}
+	}
+}
+
+// decodeOutgoingKafka answers the requests of the client from the recorded kafka mocks, rewriting
+// the correlation id of every response to the one sent by the client.
+func decodeOutgoingKafka(requestBuffer []byte, clientConn net.Conn, h *hooks.Hook, logger *zap.Logger) error {
+	reader := &frameReader{conn: clientConn, buf: requestBuffer}
+	for {
+		frame, err := reader.next()
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("failed to read the kafka request from the client", zap.Error(err))
+			}
+			return nil
+		}
+		req, err := decodeRequest(frame)
+		if err != nil {
+			logger.Error("failed to decode the kafka request", zap.Error(err))
+			return err
+		}
+		if !expectsResponse(req) {
+			continue
+		}
+		resp, ok, err := match(h, req)
+		if err != nil {
+			logger.Error("error while matching kafka mocks", zap.Error(err))
+		}
+		if !ok {
+			logger.Error("Didn't match any prexisting kafka mock", zap.String("api", req.Header.ApiName), zap.Int16("api key", req.Header.ApiKey), zap.Int16("api version", req.Header.ApiVersion))
+			h.RecordUnmatchedCall(models.Kafka, fmt.Sprintf("%s v%d", req.Header.ApiName, req.Header.ApiVersion))
+			out, ok := errorResponse(req)
+			if !ok {
+				// the client would wait for the response forever, closing the connection fails its request
+				clientConn.Close()
+				return fmt.Errorf("no kafka mock for the %s v%d request", req.Header.ApiName, req.Header.ApiVersion)
+			}
+			_, err = clientConn.Write(out)
+			if err != nil {
+				logger.Error("failed to write the kafka error response to the client", zap.Error(err))
+				return err
+			}
+			continue
+		}
+		out, err := encodeResponse(*resp, req.Header.CorrelationId)
+		if err != nil {
+			logger.Error("failed to encode the kafka response from the mock", zap.Error(err))
+			return err
+		}
+		_, err = clientConn.Write(out)
+		if err != nil {
+			logger.Error("failed to write the kafka response to the client", zap.Error(err))
+			return err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package kafkaparser
+
+import (
+	"fmt"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+)
+
+// match finds the response for req. Testcase mocks are consumed on use, while config mocks such as
+// ApiVersions or Metadata stay available for every new connection.
+func match(h *hooks.Hook, req models.KafkaRequest) (*models.KafkaResponse, bool, error) {
+	for {
+		tcsMocks, err := h.GetTcsMocks()
+		if err != nil {
+			return nil, false, fmt.Errorf("error while getting tcs mocks %v", err)
+		}
+		idx := findMatch(tcsMocks, req)
+		if idx == -1 {
+			break
+		}
+		mock := tcsMocks[idx]
+		isDeleted, err := h.DeleteTcsMock(mock)
+		if err != nil {
+			return nil, false, fmt.Errorf("error while deleting tcs mock: %v", err)
+		}
+		if !isDeleted {
+			continue
+		}
+		return mock.Spec.KafkaResponse, true, nil
+	}
+
+	configMocks, err := h.GetConfigMocks()
+	if err != nil {
+		return nil, false, fmt.Errorf("error while getting config mocks %v", err)
+	}
+	idx := findMatch(configMocks, req)
+	if idx == -1 {
+		return nil, false, nil
+	}
+	h.MarkMockUsed(configMocks[idx])
+	return configMocks[idx].Spec.KafkaResponse, true, nil
+}
+
+// findMatch returns the index of the mock recorded for req. A mock with the same api key, version
+// and body wins; otherwise the first mock in recorded order with the same api key and version is
+// used, since produced record batches carry timestamps and fetch offsets move between runs.
+func findMatch(mocks []*models.Mock, req models.KafkaRequest) int {
+	fallback := -1
+	for idx, mock := range mocks {
+		if mock.Kind != models.Kafka || mock.Spec.KafkaRequest == nil || mock.Spec.KafkaResponse == nil {
+			continue
+		}
+		recorded := mock.Spec.KafkaRequest
+		if recorded.Header.ApiKey != req.Header.ApiKey || recorded.Header.ApiVersion != req.Header.ApiVersion {
+			continue
+		}
+		if recorded.Body == req.Body {
+			return idx
+		}
+		if fallback == -1 {
+			fallback = idx
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,225 @@
+package kafkaparser
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+)
+
+// maxFrameSize guards against treating random bytes as a huge kafka frame.
+const maxFrameSize = 100 * 1024 * 1024
+
+var errShortFrame = errors.New("kafka frame is too short")
+
+// apiNames holds the api keys keploy understands. Requests with other keys are still recorded
+// and replayed, but are not accepted when sniffing the first bytes of a connection.
+var apiNames = map[int16]string{
+	0:  "Produce",
+	1:  "Fetch",
+	2:  "ListOffsets",
+	3:  "Metadata",
+	8:  "OffsetCommit",
+	9:  "OffsetFetch",
+	10: "FindCoordinator",
+	11: "JoinGroup",
+	12: "Heartbeat",
+	13: "LeaveGroup",
+	14: "SyncGroup",
+	15: "DescribeGroups",
+	16: "ListGroups",
+	17: "SaslHandshake",
+	18: "ApiVersions",
+	19: "CreateTopics",
+	20: "DeleteTopics",
+	22: "InitProducerId",
+	23: "OffsetForLeaderEpoch",
+	24: "AddPartitionsToTxn",
+	25: "AddOffsetsToTxn",
+	26: "EndTxn",
+	28: "TxnOffsetCommit",
+	32: "DescribeConfigs",
+	36: "SaslAuthenticate",
+	37: "CreatePartitions",
+	47: "OffsetDelete",
+}
+
+// frameReader splits the byte stream of a connection into length prefixed kafka frames.
+type frameReader struct {
+	conn net.Conn
+	buf  []byte
+}
+
+// next returns the next complete frame, including its 4 byte length prefix.
+func (r *frameReader) next() ([]byte, error) {
+	for {
+		if len(r.buf) >= 4 {
+			size := int(int32(binary.BigEndian.Uint32(r.buf[:4])))
+			if size < 0 || size > maxFrameSize {
+				return nil, fmt.Errorf("invalid kafka frame size %d", size)
+			}
+			if len(r.buf) >= size+4 {
+				frame := r.buf[:size+4]
+				r.buf = r.buf[size+4:]
+				return frame, nil
+			}
+		}
+		more, err := util.ReadBytes(r.conn)
+		if len(more) == 0 && err != nil {
+			return nil, err
+		}
+		r.buf = append(r.buf, more...)
+	}
+}
+
+// decodeRequest decodes the header of a kafka request frame. Everything after the client id,
+// including the tagged fields of flexible request headers, is kept as the body.
+func decodeRequest(frame []byte) (models.KafkaRequest, error) {
+	// size(4) api_key(2) api_version(2) correlation_id(4) client_id_length(2)
+	if len(frame) < 14 {
+		return models.KafkaRequest{}, errShortFrame
+	}
+	header := models.KafkaRequestHeader{
+		ApiKey:        int16(binary.BigEndian.Uint16(frame[4:6])),
+		ApiVersion:    int16(binary.BigEndian.Uint16(frame[6:8])),
+		CorrelationId: int32(binary.BigEndian.Uint32(frame[8:12])),
+	}
+	header.ApiName = apiNames[header.ApiKey]
+	offset := 14
+	clientIdLen := int(int16(binary.BigEndian.Uint16(frame[12:14])))
+	if clientIdLen > 0 {
+		if len(frame) < offset+clientIdLen {
+			return models.KafkaRequest{}, errShortFrame
+		}
+		header.ClientId = string(frame[offset : offset+clientIdLen])
+		offset += clientIdLen
+	}
+	return models.KafkaRequest{
+		Header: header,
+		Body:   base64.StdEncoding.EncodeToString(frame[offset:]),
+	}, nil
+}
+
+// decodeResponse decodes a kafka response frame into its correlation id and the bytes after it.
+func decodeResponse(frame []byte) (models.KafkaResponse, error) {
+	if len(frame) < 8 {
+		return models.KafkaResponse{}, errShortFrame
+	}
+	return models.KafkaResponse{
+		CorrelationId: int32(binary.BigEndian.Uint32(frame[4:8])),
+		Body:          base64.StdEncoding.EncodeToString(frame[8:]),
+	}, nil
+}
+
+// encodeResponse builds the response frame of a recorded response for the given correlation id.
+func encodeResponse(resp models.KafkaResponse, correlationId int32) ([]byte, error) {
+	body, err := base64.StdEncoding.DecodeString(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	frame := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(frame[:4], uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(correlationId))
+	return append(frame, body...), nil
+}
+
+// unknownServerError is the error code of kafka for an unexpected error of the broker.
+const unknownServerError int16 = -1
+
+// errorResponse builds a response frame failing req with unknownServerError, for the apis whose response starts with
+// an error code whatever its version. It reports false for the other apis.
+func errorResponse(req models.KafkaRequest) ([]byte, bool) {
+	if req.Header.ApiKey != 18 {
+		return nil, false
+	}
+	// ApiVersions: error_code, an empty api_keys array and from v1 throttle_time_ms. Its response header never has
+	// tagged fields, while its body is flexible from v3.
+	code := unknownServerError
+	body := make([]byte, 2, 12)
+	binary.BigEndian.PutUint16(body, uint16(code))
+	if req.Header.ApiVersion >= 3 {
+		body = append(body, 1)
+	} else {
+		body = append(body, 0, 0, 0, 0)
+	}
+	if req.Header.ApiVersion >= 1 {
+		body = append(body, 0, 0, 0, 0)
+	}
+	if req.Header.ApiVersion >= 3 {
+		body = append(body, 0)
+	}
+	frame := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(frame[:4], uint32(4+len(body)))
+	binary.BigEndian.PutUint32(frame[4:8], uint32(req.Header.CorrelationId))
+	return append(frame, body...), true
+}
+
+// expectsResponse reports whether the broker answers req. A Produce request with acks set to 0
+// is the only request the broker never responds to.
+func expectsResponse(req models.KafkaRequest) bool {
+	if req.Header.ApiKey != 0 {
+		return true
+	}
+	body, err := base64.StdEncoding.DecodeString(req.Body)
+	if err != nil {
+		return true
+	}
+	offset := 0
+	switch {
+	case req.Header.ApiVersion >= 9:
+		// flexible versions start with the header tagged fields and a compact nullable transactional id
+		offset, err = skipTaggedFields(body, offset)
+		if err != nil {
+			return true
+		}
+		length, n := binary.Uvarint(body[offset:])
+		if n <= 0 {
+			return true
+		}
+		offset += n
+		if length > 0 {
+			offset += int(length) - 1
+		}
+	case req.Header.ApiVersion >= 3:
+		if len(body) < 2 {
+			return true
+		}
+		length := int(int16(binary.BigEndian.Uint16(body[:2])))
+		offset = 2
+		if length > 0 {
+			offset += length
+		}
+	}
+	if len(body) < offset+2 {
+		return true
+	}
+	return int16(binary.BigEndian.Uint16(body[offset:offset+2])) != 0
+}
+
+func skipTaggedFields(buf []byte, offset int) (int, error) {
+	count, n := binary.Uvarint(buf[offset:])
+	if n <= 0 {
+		return 0, errShortFrame
+	}
+	offset += n
+	for i := uint64(0); i < count; i++ {
+		_, n = binary.Uvarint(buf[offset:])
+		if n <= 0 {
+			return 0, errShortFrame
+		}
+		offset += n
+		size, n := binary.Uvarint(buf[offset:])
+		if n <= 0 {
+			return 0, errShortFrame
+		}
+		offset += n + int(size)
+		if offset > len(buf) {
+			return 0, errShortFrame
+		}
+	}
+	return offset, nil
+}
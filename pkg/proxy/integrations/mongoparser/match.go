@@ -2,6 +2,7 @@ package mongoparser
 
 import (
 	"fmt"
+	"strings"
 
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
@@ -62,3 +63,17 @@ func match(h *hooks.Hook, mongoRequests []models.MongoRequest, logger *zap.Logge
 		return true, mock, nil
 	}
 }
+
+// mongoRequestSummary describes the requests of an unmatched call in the test report.
+func mongoRequestSummary(mongoRequests []models.MongoRequest) string {
+	summary := []string{}
+	for _, req := range mongoRequests {
+		switch message := req.Message.(type) {
+		case *models.MongoOpMessage:
+			summary = append(summary, message.Sections...)
+		case *models.MongoOpQuery:
+			summary = append(summary, message.FullCollectionName+" "+message.Query)
+		}
+	}
+	return strings.Join(summary, "; ")
+}
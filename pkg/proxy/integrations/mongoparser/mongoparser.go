@@ -211,6 +211,7 @@ func decodeOutgoingMongo(requestBuffer []byte, clientConn, destConn net.Conn, h
 				logger.Debug("the mongo request do not matches with any config mocks", zap.Any("request", mongoRequests))
 				continue
 			}
+			h.MarkMockUsed(configMocks[bestMatchIndex])
 			for _, mongoResponse := range configMocks[bestMatchIndex].Spec.MongoResponses {
 				switch mongoResponse.Header.Opcode {
 				case wiremessage.OpReply:
@@ -271,6 +272,7 @@ func decodeOutgoingMongo(requestBuffer []byte, clientConn, destConn net.Conn, h
 			isMatched, matchedMock, err := match(h, mongoRequests, logger)
 
 			if !isMatched {
+				h.RecordUnmatchedCall(models.Mongo, mongoRequestSummary(mongoRequests))
 				requestBuffer, err = util.Passthrough(clientConn, destConn, requestBuffers, h.Recover, logger)
 				if err != nil {
 					return
@@ -18,7 +18,9 @@ type StmtPrepareOk struct {
 	ParamDefs    []ColumnDefinition `yaml:"param_definitions"`
 }
 
-func decodeComStmtPrepareOk(data []byte) (*StmtPrepareOk, error) {
+// decodeComStmtPrepareOk decodes the response to COM_STMT_PREPARE, the definitions of its parameters and columns
+// are not followed by EOF packets when the connection agreed on CLIENT_DEPRECATE_EOF.
+func decodeComStmtPrepareOk(data []byte, deprecateEOF bool) (*StmtPrepareOk, error) {
 	if len(data) < 12 {
 		return nil, errors.New("data length is not enough for COM_STMT_PREPARE_OK")
 	}
@@ -77,7 +79,9 @@ func decodeComStmtPrepareOk(data []byte) (*StmtPrepareOk, error) {
 			offset += 2 // filler
 			response.ParamDefs = append(response.ParamDefs, columnDef)
 		}
-		offset += 9 //skip EOF packet for Parameter Definition
+		if !deprecateEOF {
+			offset += 9 //skip EOF packet for Parameter Definition
+		}
 	}
 
 	if response.NumColumns > 0 {
@@ -124,13 +128,15 @@ func decodeComStmtPrepareOk(data []byte) (*StmtPrepareOk, error) {
 			offset += 2 // filler
 			response.ColumnDefs = append(response.ColumnDefs, columnDef)
 		}
-		offset += 9 //skip EOF packet for Column Definitions
+		if !deprecateEOF {
+			offset += 9 //skip EOF packet for Column Definitions
+		}
 	}
 
 	return response, nil
 }
 
-func encodeStmtPrepareOk(packet *models.MySQLStmtPrepareOk) ([]byte, error) {
+func encodeStmtPrepareOk(packet *models.MySQLStmtPrepareOk, deprecateEOF bool) ([]byte, error) {
 	buf := &bytes.Buffer{}
 	buf.Write([]byte{0x0C, 0x00, 0x00, 0x01})
 	// Encode the Status field
@@ -167,7 +173,7 @@ func encodeStmtPrepareOk(packet *models.MySQLStmtPrepareOk) ([]byte, error) {
 			return nil, err
 		}
 	}
-	if packet.NumParams > 0 {
+	if packet.NumParams > 0 && !deprecateEOF {
 		// Write EOF marker for parameter definitions
 		buf.Write([]byte{5, 0, 0, seqNum, 0xFE, 0x00, 0x00, 0x02, 0x00})
 		seqNum++
@@ -180,7 +186,7 @@ func encodeStmtPrepareOk(packet *models.MySQLStmtPrepareOk) ([]byte, error) {
 		}
 	}
 
-	if packet.NumColumns > 0 {
+	if packet.NumColumns > 0 && !deprecateEOF {
 		// Write EOF marker for column definitions
 		buf.Write([]byte{5, 0, 0, seqNum, 0xFE, 0x00, 0x00, 0x02, 0x00})
 		seqNum++
@@ -3,10 +3,14 @@ package mysqlparser
 import (
 	"encoding/binary"
 	"fmt"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
 )
 
 type ComStmtExecute struct {
 	StatementID    uint32           `yaml:"statement_id"`
+	Query          string           `yaml:"query"`
 	Flags          byte             `yaml:"flags"`
 	IterationCount uint32           `yaml:"iteration_count"`
 	NullBitmap     []byte           `yaml:"null_bitmap"`
@@ -19,10 +23,12 @@ type BoundParameter struct {
 	Value    []byte `yaml:"value"`
 }
 
-func decodeComStmtExecute(packet []byte) (ComStmtExecute, error) {
-	// removed the print statement for cleanliness
-	if len(packet) < 14 { // the minimal size of the packet without parameters should be 14, not 13
-		return ComStmtExecute{}, fmt.Errorf("packet length less than 14 bytes")
+// decodeComStmtExecute decodes the execution of a statement prepared on the connection of the session. The
+// number of its parameters and their types, when they are not bound again, are the ones the session kept for the
+// statement. The query of the statement is added, the ids of the statements only identify them on a connection.
+func (s *session) decodeComStmtExecute(packet []byte) (ComStmtExecute, error) {
+	if len(packet) < 10 {
+		return ComStmtExecute{}, fmt.Errorf("packet length less than 10 bytes")
 	}
 
 	stmtExecute := ComStmtExecute{}
@@ -30,28 +36,85 @@ func decodeComStmtExecute(packet []byte) (ComStmtExecute, error) {
 	stmtExecute.Flags = packet[5]
 	stmtExecute.IterationCount = binary.LittleEndian.Uint32(packet[6:10])
 
-	// the next bytes are reserved for the Null-Bitmap, Parameter Bound Flag and Bound Parameters if they exist
-	// if the length of the packet is greater than 14, then there are parameters
-	if len(packet) > 14 {
-		nullBitmapLength := int((stmtExecute.ParamCount + 7) / 8)
-
-		stmtExecute.NullBitmap = packet[10 : 10+nullBitmapLength]
-		stmtExecute.ParamCount = binary.LittleEndian.Uint16(packet[10+nullBitmapLength:])
-
-		// in case new parameters are bound, the new types and values are sent
-		if packet[10+nullBitmapLength] == 1 {
-			// read the types and values of the new parameters
-			stmtExecute.Parameters = make([]BoundParameter, stmtExecute.ParamCount)
-			for i := 0; i < int(stmtExecute.ParamCount); i++ {
-				index := 10 + nullBitmapLength + 1 + 2*i
-				if index+1 >= len(packet) {
-					return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading parameters")
-				}
-				stmtExecute.Parameters[i].Type = packet[index]
-				stmtExecute.Parameters[i].Unsigned = packet[index+1]
+	statement, ok := s.statements[stmtExecute.StatementID]
+	if !ok {
+		s.logger.Debug("executing a statement which was not prepared on the connection", zap.Uint32("statement id", stmtExecute.StatementID))
+		return stmtExecute, nil
+	}
+	stmtExecute.Query = statement.query
+	stmtExecute.ParamCount = statement.paramCount
+	if statement.paramCount == 0 {
+		return stmtExecute, nil
+	}
+
+	// the null bitmap and the flag of the new bound types follow, then the types and the values of the parameters
+	offset := 10
+	nullBitmapLength := int((statement.paramCount + 7) / 8)
+	if len(packet) < offset+nullBitmapLength+1 {
+		return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading the null bitmap")
+	}
+	stmtExecute.NullBitmap = packet[offset : offset+nullBitmapLength]
+	offset += nullBitmapLength
+	newParamsBound := packet[offset]
+	offset++
+
+	stmtExecute.Parameters = make([]BoundParameter, statement.paramCount)
+	if newParamsBound == 1 {
+		statement.paramTypes = make([][2]byte, statement.paramCount)
+		for i := range stmtExecute.Parameters {
+			if offset+2 > len(packet) {
+				return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading parameters")
 			}
+			statement.paramTypes[i] = [2]byte{packet[offset], packet[offset+1]}
+			offset += 2
 		}
 	}
+	if len(statement.paramTypes) != len(stmtExecute.Parameters) {
+		// the types were bound by an execution the session did not read
+		return stmtExecute, nil
+	}
+	for i := range stmtExecute.Parameters {
+		stmtExecute.Parameters[i].Type = statement.paramTypes[i][0]
+		stmtExecute.Parameters[i].Unsigned = statement.paramTypes[i][1]
+		if stmtExecute.NullBitmap[i/8]&(1<<(i%8)) != 0 {
+			continue
+		}
+		length, err := parameterLength(stmtExecute.Parameters[i].Type, packet[offset:])
+		if err != nil {
+			return ComStmtExecute{}, err
+		}
+		stmtExecute.Parameters[i].Value = packet[offset : offset+length]
+		offset += length
+	}
 
 	return stmtExecute, nil
 }
+
+// parameterLength returns the length of the binary value of a parameter of the type at the start of data.
+func parameterLength(paramType byte, data []byte) (int, error) {
+	var length int
+	switch paramType {
+	case models.TypeNull:
+		return 0, nil
+	case models.TypeTiny:
+		length = 1
+	case models.TypeShort, models.TypeYear:
+		length = 2
+	case models.TypeLong, models.TypeInt24, models.TypeFloat:
+		length = 4
+	case models.TypeLongLong, models.TypeDouble:
+		length = 8
+	case models.TypeDate, models.TypeDateTime, models.TypeTimestamp, models.TypeTime:
+		if len(data) == 0 {
+			return 0, fmt.Errorf("packet length less than expected while reading a temporal parameter")
+		}
+		length = 1 + int(data[0])
+	default:
+		value, _, n := readLengthEncodedInteger(data)
+		length = n + int(value)
+	}
+	if length > len(data) {
+		return 0, fmt.Errorf("packet length less than expected while reading a parameter of type %#x", paramType)
+	}
+	return length, nil
+}
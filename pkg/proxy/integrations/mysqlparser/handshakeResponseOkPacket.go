@@ -13,7 +13,9 @@ type HandshakeResponseOk struct {
 	RemainingBytes  []byte        `yaml:"remaining_bytes"`
 }
 
-func decodeHandshakeResponseOk(data []byte) (*HandshakeResponseOk, error) {
+// decodeHandshakeResponseOk decodes the response of the server to the authentication of the client, isPluginData
+// tells that it carries the data of the authentication plugin of the handshake.
+func decodeHandshakeResponseOk(data []byte, isPluginData bool, pluginName string) (*HandshakeResponseOk, error) {
 	var (
 		packetIndicator string
 		authType        string
@@ -41,7 +43,7 @@ func decodeHandshakeResponseOk(data []byte) (*HandshakeResponseOk, error) {
 	if data[0] == models.AuthMoreData {
 		count := int(data[0])
 		var authData = data[1 : count+1]
-		switch handshakePluginName {
+		switch pluginName {
 		case "caching_sha2_password":
 			switch len(authData) {
 			case 1:
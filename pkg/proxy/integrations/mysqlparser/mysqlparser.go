@@ -1,11 +1,13 @@
 package mysqlparser
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"fmt"
 	"log"
 	"net"
+	"sync"
 	"time"
 
 	"go.keploy.io/server/pkg/hooks"
@@ -18,6 +20,8 @@ type MySqlParser struct {
 	logger *zap.Logger
 	hooks  *hooks.Hook
 	delay  uint64
+	// mocksMutex serializes the connections replaying the mocks, each one takes its response out of the shared mocks
+	mocksMutex *sync.Mutex
 }
 
 func NewMySqlParser(logger *zap.Logger, hooks *hooks.Hook, delay uint64) *MySqlParser {
@@ -25,44 +29,35 @@ func NewMySqlParser(logger *zap.Logger, hooks *hooks.Hook, delay uint64) *MySqlP
 		logger: logger,
 		hooks:  hooks,
 		delay:  delay,
+
+		mocksMutex: &sync.Mutex{},
 	}
 }
 
 func (sql *MySqlParser) OutgoingType(buffer []byte) bool {
-	//Returning false here because sql parser is using the ports to check if the packet is mysql or not.
+	// the server speaks first in mysql, the proxy tells its calls apart by their port or by the greeting of the server
 	return false
 }
 func (sql *MySqlParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, ctx context.Context) {
 	delay := sql.delay
+	// every connection decodes its packets with its own state, the connections of a pool don't share it
+	s := newSession(sql.logger)
 	switch models.GetMode() {
 	case models.MODE_RECORD:
-		encodeOutgoingMySql(requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx)
+		encodeOutgoingMySql(s, requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx)
 	case models.MODE_TEST:
-		decodeOutgoingMySQL(requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx, delay)
+		decodeOutgoingMySQL(s, sql.mocksMutex, requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx, delay)
 	default:
 	}
 }
 
-var (
-	isConfigRecorded = false
-)
-var (
-	isPluginData = false
-)
-var (
-	expectingAuthSwitchResponse = false
-)
-var (
-	expectingHandshakeResponse = false
-)
-
-func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) {
+func encodeOutgoingMySql(s *session, requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) {
 	var (
 		mysqlRequests  = []models.MySQLRequest{}
 		mysqlResponses = []models.MySQLResponse{}
 	)
 	for {
-		lastCommand = 0x00 //resetting last command for new loop
+		s.lastCommand = 0x00 //resetting last command for new loop
 		data, source, err := ReadFirstBuffer(clientConn, destConn)
 		if len(data) == 0 {
 			break
@@ -99,8 +94,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				logger.Error("failed to write auth switch request to client", zap.Error(err))
 				return
 			}
-			expectingHandshakeResponse = true
-			oprRequest, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseFromClient), logger, destConn)
+			s.expectingHandshakeResponse = true
+			oprRequest, requestHeader, mysqlRequest, err := s.decodePacket(bytesToMySQLPacket(handshakeResponseFromClient))
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from client", zap.Error(err))
 				return
@@ -113,8 +108,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				},
 				Message: mysqlRequest,
 			})
-			expectingHandshakeResponse = false
-			oprResponse1, responseHeader1, mysqlResp1, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseBuffer), logger, destConn)
+			s.expectingHandshakeResponse = false
+			oprResponse1, responseHeader1, mysqlResp1, err := s.decodePacket(bytesToMySQLPacket(handshakeResponseBuffer))
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from destination", zap.Error(err))
 				return
@@ -127,7 +122,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				},
 				Message: mysqlResp1,
 			})
-			oprResponse2, responseHeader2, mysqlResp2, err := DecodeMySQLPacket(bytesToMySQLPacket(okPacket1), logger, destConn)
+			oprResponse2, responseHeader2, mysqlResp2, err := s.decodePacket(bytesToMySQLPacket(okPacket1))
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from OK packet", zap.Error(err))
 				return
@@ -162,9 +157,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				expectingAuthSwitchResponse = true
+				s.expectingAuthSwitchResponse = true
 
-				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(authSwitchResponse), logger, destConn)
+				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := s.decodePacket(bytesToMySQLPacket(authSwitchResponse))
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 					return
@@ -177,11 +172,11 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					},
 					Message: mysqlRequestFinal,
 				})
-				expectingAuthSwitchResponse = false
+				s.expectingAuthSwitchResponse = false
 
-				isPluginData = true
-				oprResponse, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(ServerResponse), logger, destConn)
-				isPluginData = false
+				s.isPluginData = true
+				oprResponse, responseHeader, mysqlResp, err := s.decodePacket(bytesToMySQLPacket(ServerResponse))
+				s.isPluginData = false
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 					return
@@ -221,7 +216,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn)
+					oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := s.decodePacket(bytesToMySQLPacket(clientResponse))
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 						return
@@ -234,9 +229,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						},
 						Message: mysqlRequestFinal,
 					})
-					isPluginData = true
-					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-					isPluginData = false
+					s.isPluginData = true
+					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := s.decodePacket(bytesToMySQLPacket(finalServerResponse))
+					s.isPluginData = false
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 						return
@@ -269,7 +264,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn)
+					finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := s.decodePacket(bytesToMySQLPacket(finalServerResponse1))
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from final server response", zap.Error(err))
 						return
@@ -312,8 +307,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-					isPluginData = false
+					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := s.decodePacket(bytesToMySQLPacket(finalServerResponse))
+					s.isPluginData = false
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 						return
@@ -357,7 +352,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn)
+				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := s.decodePacket(bytesToMySQLPacket(clientResponse))
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 					return
@@ -370,9 +365,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					},
 					Message: mysqlRequestFinal,
 				})
-				isPluginData = true
-				oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-				isPluginData = false
+				s.isPluginData = true
+				oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := s.decodePacket(bytesToMySQLPacket(finalServerResponse))
+				s.isPluginData = false
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 					return
@@ -405,7 +400,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn)
+				finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := s.decodePacket(bytesToMySQLPacket(finalServerResponse1))
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from final server response", zap.Error(err))
 					return
@@ -440,33 +435,25 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 			recordMySQLMessage(h, mysqlRequests, mysqlResponses, oprRequest, oprResponse2, "config", ctx)
 			mysqlRequests = []models.MySQLRequest{}
 			mysqlResponses = []models.MySQLResponse{}
-			handleClientQueries(h, nil, clientConn, destConn, logger, ctx)
+			handleClientQueries(s, h, nil, clientConn, destConn, logger, ctx)
 		} else if source == "client" {
-			handleClientQueries(h, nil, clientConn, destConn, logger, ctx)
+			handleClientQueries(s, h, nil, clientConn, destConn, logger, ctx)
 		}
 	}
 	return
 }
 
-var (
-	mockResponseRead = 0
-)
-
-var (
-	expectingHandshakeResponseTest = false
-)
-
-func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context, delay uint64) {
+func decodeOutgoingMySQL(s *session, mocksMutex *sync.Mutex, requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context, delay uint64) {
 	firstLoop := true
 	doHandshakeAgain := true
 	prevRequest := ""
 	var requestBuffers [][]byte
 	for {
-		configMocks, _ := h.GetConfigMocks()
-		tcsMocks, _ := h.GetTcsMocks()
-		//logger.Debug("Config and TCS Mocks", zap.Any("configMocks", configMocks), zap.Any("tcsMocks", tcsMocks))
 		if firstLoop || doHandshakeAgain {
+			mocksMutex.Lock()
+			configMocks, _ := h.GetConfigMocks()
 			if len(configMocks) == 0 {
+				mocksMutex.Unlock()
 				logger.Debug("No more config mocks available")
 				return
 			}
@@ -474,25 +461,29 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 			header := configMocks[0].Spec.MySqlResponses[0].Header
 			packet := configMocks[0].Spec.MySqlResponses[0].Message
 			opr := configMocks[0].Spec.MySqlResponses[0].Header.PacketType
+			s.served(&configMocks[0].Spec.MySqlResponses[0])
 
-			binaryPacket, err := encodeToBinary(&packet, header, opr, 0)
+			binaryPacket, err := s.encodeToBinary(&packet, header, opr, 0)
 			if err != nil {
+				mocksMutex.Unlock()
 				logger.Error("Failed to encode to binary", zap.Error(err))
 				return
 			}
-
-			_, err = clientConn.Write(binaryPacket)
-			if err != nil {
-				logger.Error("Failed to write binary packet", zap.Error(err))
-				return
-			}
 			matchedIndex := 0
 			matchedReqIndex := 0
+			h.MarkMockUsed(configMocks[matchedIndex])
 			configMocks[matchedIndex].Spec.MySqlResponses = append(configMocks[matchedIndex].Spec.MySqlResponses[:matchedReqIndex], configMocks[matchedIndex].Spec.MySqlResponses[matchedReqIndex+1:]...)
 			if len(configMocks[matchedIndex].Spec.MySqlResponses) == 0 {
 				configMocks = (append(configMocks[:matchedIndex], configMocks[matchedIndex+1:]...))
 			}
 			h.SetConfigMocks(configMocks)
+			mocksMutex.Unlock()
+
+			_, err = clientConn.Write(binaryPacket)
+			if err != nil {
+				logger.Error("Failed to write binary packet", zap.Error(err))
+				return
+			}
 			firstLoop = false
 			doHandshakeAgain = false
 			logger.Debug("BINARY PACKET SENT HANDSHAKE", zap.ByteString("binaryPacketKey", binaryPacket))
@@ -528,10 +519,10 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 				return
 			}
 			if prevRequest == "MYSQLHANDSHAKE" {
-				expectingHandshakeResponseTest = true
+				s.expectingHandshakeResponse = true
 			}
 
-			oprRequest, requestHeader, decodedRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(requestBuffer), logger, destConn)
+			oprRequest, requestHeader, decodedRequest, err := s.decodePacket(bytesToMySQLPacket(requestBuffer))
 			if err != nil {
 				logger.Error("Failed to decode MySQL packet", zap.Error(err))
 				return
@@ -539,10 +530,10 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 			if oprRequest == "COM_QUIT" {
 				return
 			}
-			if expectingHandshakeResponseTest {
+			if s.expectingHandshakeResponse {
 				// configMocks = configMocks[1:]
 				// h.SetConfigMocks(configMocks)
-				expectingHandshakeResponseTest = false
+				s.expectingHandshakeResponse = false
 			}
 
 			prevRequest = ""
@@ -559,15 +550,22 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 				Message: decodedRequest,
 			}
 			if oprRequest == "COM_STMT_CLOSE" {
-				return
+				// the statement is forgotten by the session, the server does not answer
+				continue
 			}
+			mocksMutex.Lock()
+			configMocks, _ := h.GetConfigMocks()
+			tcsMocks, _ := h.GetTcsMocks()
 			matchedResponse, matchedIndex, _, err := matchRequestWithMock(mysqlRequest, configMocks, tcsMocks, h)
+			mocksMutex.Unlock()
 			if err != nil {
 				logger.Error("Failed to match request with mock", zap.Error(err))
+				h.RecordUnmatchedCall(models.SQL, mysqlRequestSummary(oprRequest, mysqlRequest))
 				return
 			}
 			if matchedIndex != -1 {
-				responseBinary, err := encodeToBinary(&matchedResponse.Message, matchedResponse.Header, matchedResponse.Header.PacketType, 1)
+				s.served(matchedResponse)
+				responseBinary, err := s.encodeToBinary(&matchedResponse.Message, matchedResponse.Header, matchedResponse.Header.PacketType, 1)
 				logger.Debug("Response binary",
 					zap.ByteString("responseBinary", responseBinary),
 					zap.String("packetType", matchedResponse.Header.PacketType))
@@ -635,6 +633,7 @@ func matchRequestWithMock(mysqlRequest models.MySQLRequest, configMocks, tcsMock
 		if matchedIndex >= len(configMocks) {
 			return nil, -1, "", fmt.Errorf("index out of range in configMocks")
 		}
+		h.MarkMockUsed(configMocks[matchedIndex])
 		configMocks[matchedIndex].Spec.MySqlRequests = append(configMocks[matchedIndex].Spec.MySqlRequests[:matchedReqIndex], configMocks[matchedIndex].Spec.MySqlRequests[matchedReqIndex+1:]...)
 		configMocks[matchedIndex].Spec.MySqlResponses = append(configMocks[matchedIndex].Spec.MySqlResponses[:matchedReqIndex], configMocks[matchedIndex].Spec.MySqlResponses[matchedReqIndex+1:]...)
 
@@ -647,6 +646,7 @@ func matchRequestWithMock(mysqlRequest models.MySQLRequest, configMocks, tcsMock
 		if realIndex < 0 || realIndex >= len(tcsMocks) {
 			return nil, -1, "", fmt.Errorf("index out of range in tcsMocks")
 		}
+		h.MarkMockUsed(tcsMocks[realIndex])
 		tcsMocks[realIndex].Spec.MySqlRequests = append(tcsMocks[realIndex].Spec.MySqlRequests[:matchedReqIndex], tcsMocks[realIndex].Spec.MySqlRequests[matchedReqIndex+1:]...)
 		tcsMocks[realIndex].Spec.MySqlResponses = append(tcsMocks[realIndex].Spec.MySqlResponses[:matchedReqIndex], tcsMocks[realIndex].Spec.MySqlResponses[matchedReqIndex+1:]...)
 
@@ -679,6 +679,20 @@ func compareMySQLRequests(req1, req2 models.MySQLRequest) int {
 			matchCount += 5
 		}
 	}
+	if req1.Header.PacketType == "COM_STMT_PREPARE" && req2.Header.PacketType == "COM_STMT_PREPARE" {
+		packet, ok := req1.Message.(*ComStmtPreparePacket)
+		mockPacket, mockOk := req2.Message.(*models.MySQLComStmtPreparePacket)
+		if ok && mockOk && packet.Query == mockPacket.Query {
+			matchCount += 5
+		}
+	}
+	if req1.Header.PacketType == "COM_STMT_EXECUTE" && req2.Header.PacketType == "COM_STMT_EXECUTE" {
+		packet, ok := req1.Message.(ComStmtExecute)
+		mockPacket, mockOk := req2.Message.(*models.MySQLComStmtExecute)
+		if ok && mockOk {
+			matchCount += compareStmtExecutes(packet, mockPacket)
+		}
+	}
 	if req1.Header.PacketLength == req2.Header.PacketLength {
 		matchCount++
 	}
@@ -690,6 +704,29 @@ func compareMySQLRequests(req1, req2 models.MySQLRequest) int {
 	}
 	return matchCount
 }
+
+// compareStmtExecutes scores the execution of a statement against a recorded one. The statements are compared by
+// their query, the mocks recorded without it by the id of the statement on their connection.
+func compareStmtExecutes(packet ComStmtExecute, mockPacket *models.MySQLComStmtExecute) int {
+	matchCount := 0
+	if packet.Query != "" && mockPacket.Query != "" {
+		if packet.Query == mockPacket.Query {
+			matchCount += 5
+		}
+	} else if packet.StatementID == mockPacket.StatementID {
+		matchCount += 2
+	}
+	if len(packet.Parameters) != len(mockPacket.Parameters) {
+		return matchCount
+	}
+	for i, param := range packet.Parameters {
+		if !bytes.Equal(param.Value, mockPacket.Parameters[i].Value) {
+			return matchCount
+		}
+	}
+	return matchCount + 3
+}
+
 func ReadFirstBuffer(clientConn, destConn net.Conn) ([]byte, string, error) {
 	// Attempt to read from destConn first
 	n, err := util.ReadBytes(destConn)
@@ -710,7 +747,7 @@ func ReadFirstBuffer(clientConn, destConn net.Conn) ([]byte, string, error) {
 	// Return any other error from reading destConn
 	return nil, "", err
 }
-func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destConn net.Conn, logger *zap.Logger, ctx context.Context) ([]*models.Mock, error) {
+func handleClientQueries(s *session, h *hooks.Hook, initialBuffer []byte, clientConn, destConn net.Conn, logger *zap.Logger, ctx context.Context) ([]*models.Mock, error) {
 	firstIteration := true
 	var (
 		mysqlRequests  []models.MySQLRequest
@@ -734,7 +771,7 @@ func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destCo
 		if len(queryBuffer) == 0 {
 			break
 		}
-		operation, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(queryBuffer), logger, destConn)
+		operation, requestHeader, mysqlRequest, err := s.decodePacket(bytesToMySQLPacket(queryBuffer))
 		mysqlRequests = append([]models.MySQLRequest{}, models.MySQLRequest{
 			Header: &models.MySQLPacketHeader{
 				PacketLength: requestHeader.PayloadLength,
@@ -764,7 +801,7 @@ func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destCo
 		if len(queryResponse) == 0 {
 			break
 		}
-		responseOperation, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(queryResponse), logger, destConn)
+		responseOperation, responseHeader, mysqlResp, err := s.decodePacket(bytesToMySQLPacket(queryResponse))
 		if err != nil {
 			logger.Error("Failed to decode the MySQL packet from the destination server", zap.Error(err))
 			continue
@@ -824,3 +861,11 @@ func bytesToMySQLPacket(buffer []byte) MySQLPacket {
 		Payload: payload,
 	}
 }
+
+// mysqlRequestSummary describes the request of an unmatched call in the test report.
+func mysqlRequestSummary(operation string, mysqlRequest models.MySQLRequest) string {
+	if packet, ok := mysqlRequest.Message.(*QueryPacket); ok {
+		return operation + " " + packet.Query
+	}
+	return operation
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"net"
 
 	"go.keploy.io/server/pkg/models"
 	"go.uber.org/zap"
@@ -56,9 +55,7 @@ type PluginDetails struct {
 
 type CapabilityFlags uint32
 
-var handshakePluginName string
-
-func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operation string, sequence int) ([]byte, error) {
+func (s *session) encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operation string, sequence int) ([]byte, error) {
 	var data []byte
 	var err error
 	var bypassHeader = false
@@ -105,7 +102,7 @@ func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operat
 		if !ok {
 			return nil, fmt.Errorf("invalid packet type for HandshakeResponse: expected *HandshakeResponse, got %T", packet)
 		}
-		data, err = encodeStmtPrepareOk(p)
+		data, err = encodeStmtPrepareOk(p, s.deprecateEOF())
 		bypassHeader = true
 	case "RESULT_SET_PACKET":
 		p, ok := packet.(*models.MySQLResultSet)
@@ -131,7 +128,10 @@ func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operat
 	}
 }
 
-func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn) (string, MySQLPacketHeader, interface{}, error) {
+// decodePacket decodes a packet of the connection of the session, the packets answering a command are told apart
+// by the command the session read last.
+func (s *session) decodePacket(packet MySQLPacket) (string, MySQLPacketHeader, interface{}, error) {
+	logger := s.logger
 	data := packet.Payload
 	header := packet.Header
 	var packetData interface{}
@@ -143,22 +143,22 @@ func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn
 	}
 
 	switch {
-	case lastCommand == 0x03:
+	case s.lastCommand == 0x03:
 		switch {
 		case data[0] == 0x00: // OK Packet
 			packetType = "MySQLOK"
 			packetData, err = decodeMySQLOK(data)
-			lastCommand = 0x00 // Reset the last command
+			s.lastCommand = 0x00 // Reset the last command
 
 		case data[0] == 0xFF: // Error Packet
 			packetType = "MySQLErr"
 			packetData, err = decodeMySQLErr(data)
-			lastCommand = 0x00 // Reset the last command
+			s.lastCommand = 0x00 // Reset the last command
 
 		case isLengthEncodedInteger(data[0]): // ResultSet Packet
 			packetType = "RESULT_SET_PACKET"
 			packetData, err = parseResultSet(data)
-			lastCommand = 0x00 // Reset the last command
+			s.lastCommand = 0x00 // Reset the last command
 
 		default:
 			packetType = "Unknown"
@@ -168,97 +168,115 @@ func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn
 	case data[0] == 0x0e: // COM_PING
 		packetType = "COM_PING"
 		packetData, err = decodeComPing(data)
-		lastCommand = 0x0e
+		s.lastCommand = 0x0e
 	case data[0] == 0x17: // COM_STMT_EXECUTE
 		packetType = "COM_STMT_EXECUTE"
-		packetData, err = decodeComStmtExecute(data)
-		lastCommand = 0x17
+		packetData, err = s.decodeComStmtExecute(data)
+		s.lastCommand = 0x17
 	case data[0] == 0x1c: // COM_STMT_FETCH
 		packetType = "COM_STMT_FETCH"
 		packetData, err = decodeComStmtFetch(data)
-		lastCommand = 0x1c
+		s.lastCommand = 0x1c
 	case data[0] == 0x16: // COM_STMT_PREPARE
 		packetType = "COM_STMT_PREPARE"
 		packetData, err = decodeComStmtPrepare(data)
-		lastCommand = 0x16
+		if prepare, ok := packetData.(*ComStmtPreparePacket); ok {
+			s.pendingQuery = prepare.Query
+		}
+		s.lastCommand = 0x16
 	case data[0] == 0x19: // COM_STMT_CLOSE
 		if len(data) > 11 {
 
 			packetType = "COM_STMT_CLOSE_WITH_PREPARE"
 			packetData, err = decodeComStmtCloseMoreData(data)
-			lastCommand = 0x16
+			if closeAndPrepare, ok := packetData.(*ComStmtCloseAndPrepare); ok {
+				delete(s.statements, closeAndPrepare.StmtClose.StatementID)
+				s.pendingQuery = closeAndPrepare.StmtPrepare.Query
+			}
+			s.lastCommand = 0x16
 		} else {
 			packetType = "COM_STMT_CLOSE"
 			packetData, err = decodeComStmtClose(data)
-			lastCommand = 0x19
+			if closePacket, ok := packetData.(*ComStmtClosePacket); ok {
+				delete(s.statements, closePacket.StatementID)
+			}
+			s.lastCommand = 0x19
 		}
 	case data[0] == 0x11: // COM_CHANGE_USER
 		packetType = "COM_CHANGE_USER"
 		packetData, err = decodeComChangeUser(data)
-		lastCommand = 0x11
+		s.lastCommand = 0x11
 
 	case data[0] == 0x04: // Result Set Packet
 		packetType = "RESULT_SET_PACKET"
 		packetData, err = parseResultSet(data)
-		lastCommand = 0x04
+		s.lastCommand = 0x04
 	case data[0] == 0x0A: // MySQLHandshakeV10
 		packetType = "MySQLHandshakeV10"
 		packetData, err = decodeMySQLHandshakeV10(data)
-		handshakePacket, _ := packetData.(*HandshakeV10Packet)
-		handshakePluginName = handshakePacket.AuthPluginName
-		lastCommand = 0x0A
+		if handshakePacket, ok := packetData.(*HandshakeV10Packet); ok {
+			s.pluginName = handshakePacket.AuthPluginName
+			s.serverCapabilities = handshakePacket.CapabilityFlags
+		}
+		s.lastCommand = 0x0A
 	case data[0] == 0x03: // MySQLQuery
 		packetType = "MySQLQuery"
 		packetData, err = decodeMySQLQuery(data)
-		lastCommand = 0x03
+		s.lastCommand = 0x03
 	case data[0] == 0x00: // MySQLOK or COM_STMT_PREPARE_OK
-		if lastCommand == 0x16 {
+		if s.lastCommand == 0x16 {
 			packetType = "COM_STMT_PREPARE_OK"
-			packetData, err = decodeComStmtPrepareOk(data)
+			packetData, err = decodeComStmtPrepareOk(data, s.deprecateEOF())
+			if prepareOk, ok := packetData.(*StmtPrepareOk); ok {
+				s.prepared(prepareOk.StatementID, prepareOk.NumParams, prepareOk.ParamDefs, prepareOk.ColumnDefs)
+			}
 		} else {
 			packetType = "MySQLOK"
 			packetData, err = decodeMySQLOK(data)
 		}
-		lastCommand = 0x00
+		s.lastCommand = 0x00
 	case data[0] == 0xFF: // MySQLErr
 		packetType = "MySQLErr"
 		packetData, err = decodeMySQLErr(data)
-		lastCommand = 0xFF
+		s.lastCommand = 0xFF
 	case data[0] == 0xFE && len(data) > 1: // Auth Switch Packet
 		packetType = "AUTH_SWITCH_REQUEST"
 		packetData, err = decodeAuthSwitchRequest(data)
-		lastCommand = 0xFE
-	case data[0] == 0xFE || expectingAuthSwitchResponse:
+		s.lastCommand = 0xFE
+	case data[0] == 0xFE || s.expectingAuthSwitchResponse:
 		packetType = "AUTH_SWITCH_RESPONSE"
 		packetData, err = decodeAuthSwitchResponse(data)
-		expectingAuthSwitchResponse = false
+		s.expectingAuthSwitchResponse = false
 	case data[0] == 0xFE: // EOF packet
 		packetType = "MySQLEOF"
 		packetData, err = decodeMYSQLEOF(data)
-		lastCommand = 0xFE
+		s.lastCommand = 0xFE
 	case data[0] == 0x02: // New packet type
 		packetType = "AUTH_MORE_DATA"
 		packetData, err = decodeAuthMoreData(data)
-		lastCommand = 0x02
+		s.lastCommand = 0x02
 	case data[0] == 0x18: // SEND_LONG_DATA Packet
 		packetType = "COM_STMT_SEND_LONG_DATA"
 		packetData, err = decodeComStmtSendLongData(data)
-		lastCommand = 0x18
+		s.lastCommand = 0x18
 	case data[0] == 0x1a: // STMT_RESET Packet
 		packetType = "COM_STMT_RESET"
 		packetData, err = decodeComStmtReset(data)
-		lastCommand = 0x1a
-	case data[0] == 0x8d || expectingHandshakeResponse || expectingHandshakeResponseTest: // Handshake Response packet
+		s.lastCommand = 0x1a
+	case data[0] == 0x8d || s.expectingHandshakeResponse: // Handshake Response packet
 		packetType = "HANDSHAKE_RESPONSE"
 		packetData, err = decodeHandshakeResponse(data)
-		lastCommand = 0x8d // This value may differ depending on the handshake response protocol version
+		if handshakeResponse, ok := packetData.(*HandshakeResponse); ok {
+			s.clientCapabilities = handshakeResponse.CapabilityFlags
+		}
+		s.lastCommand = 0x8d // This value may differ depending on the handshake response protocol version
 	case data[0] == 0x01: // Handshake Response packet
 		if len(data) == 1 {
 			packetType = "COM_QUIT"
 			packetData = nil
 		} else {
 			packetType = "HANDSHAKE_RESPONSE_OK"
-			packetData, err = decodeHandshakeResponseOk(data)
+			packetData, err = decodeHandshakeResponseOk(data, s.isPluginData, s.pluginName)
 		}
 	default:
 		packetType = "Unknown"
@@ -275,7 +293,7 @@ func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn
 			zap.ByteString("Data", data))
 	}
 	if (models.GetMode()) == "test" {
-		lastCommand = 0x00
+		s.lastCommand = 0x00
 	}
 	return packetType, header, packetData, nil
 }
@@ -304,8 +322,6 @@ func (p *MySQLPacket) Encode() ([]byte, error) {
 	return packet, nil
 }
 
-var lastCommand byte // This is global and will remember the last command
-
 func encodeLengthEncodedInteger(n uint64) []byte {
 	var buf []byte
 
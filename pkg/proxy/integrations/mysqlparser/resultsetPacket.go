@@ -73,10 +73,12 @@ func parseResultSet(b []byte) (*ResultSet, error) {
 	for len(b) > 5 {
 		// fmt.Println(b)
 		var row *Row
-		row, b, eofFinal, paddingFinal, optionalPadding, optionalEOFBytes, err = parseRow(b, columns)
+		var rowPadding bool
+		row, b, eofFinal, paddingFinal, rowPadding, optionalEOFBytes, err = parseRow(b, columns)
 		if err != nil {
 			return nil, err
 		}
+		optionalPadding = optionalPadding || rowPadding
 		if row != nil {
 			rows = append(rows, row)
 		}
@@ -141,10 +143,8 @@ func parseColumnDefinitionPacket(b []byte) (*ColumnDefinition, []byte, error) {
 	return packet, b, nil
 }
 
-var optionalPadding bool
-
 func parseRow(b []byte, columnDefinitions []*ColumnDefinition) (*Row, []byte, bool, bool, bool, []byte, error) {
-	var eofFinal, paddingFinal bool
+	var eofFinal, paddingFinal, optionalPadding bool
 	var optionalEOFBytes []byte
 
 	row := &Row{}
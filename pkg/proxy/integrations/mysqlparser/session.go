@@ -0,0 +1,99 @@
+package mysqlparser
+
+import (
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// CLIENT_DEPRECATE_EOF replaces the EOF packets ending the definitions and the rows of a response by OK packets,
+// or drops them, when both the client and the server set it.
+const CLIENT_DEPRECATE_EOF = 0x01000000
+
+// session is the state of the protocol of a connection of the application to mysql. The packets of a connection
+// are decoded with its own session, the connections of a pool are recorded and replayed side by side.
+type session struct {
+	logger *zap.Logger
+	// lastCommand is the command whose response is read next
+	lastCommand                 byte
+	expectingHandshakeResponse  bool
+	expectingAuthSwitchResponse bool
+	// isPluginData tells that the next response of the server carries the data of the authentication plugin
+	isPluginData bool
+	// pluginName is the authentication plugin of the handshake of the server
+	pluginName         string
+	serverCapabilities uint32
+	clientCapabilities uint32
+	// pendingQuery is the query of the COM_STMT_PREPARE waiting for its response
+	pendingQuery string
+	// statements are the statements prepared on the connection, by their id
+	statements map[uint32]*preparedStatement
+}
+
+// preparedStatement is a statement prepared on the connection of a session.
+type preparedStatement struct {
+	query      string
+	paramCount uint16
+	params     []ColumnDefinition
+	columns    []ColumnDefinition
+	// paramTypes are the types of the parameters bound by the last execution of the statement, the next ones
+	// only send them again when they change
+	paramTypes [][2]byte
+}
+
+func newSession(logger *zap.Logger) *session {
+	return &session{
+		logger:     logger,
+		statements: map[uint32]*preparedStatement{},
+	}
+}
+
+// deprecateEOF reports whether the client and the server of the connection agreed on CLIENT_DEPRECATE_EOF.
+func (s *session) deprecateEOF() bool {
+	return s.serverCapabilities&s.clientCapabilities&CLIENT_DEPRECATE_EOF != 0
+}
+
+// prepared keeps the statement the server prepared for the pending COM_STMT_PREPARE.
+func (s *session) prepared(id uint32, paramCount uint16, params, columns []ColumnDefinition) {
+	s.statements[id] = &preparedStatement{
+		query:      s.pendingQuery,
+		paramCount: paramCount,
+		params:     params,
+		columns:    columns,
+	}
+	s.pendingQuery = ""
+}
+
+// served updates the session with a response of the mocks sent to the application during a test, the way the
+// decoding of the response of the server does while recording.
+func (s *session) served(response *models.MySQLResponse) {
+	switch message := response.Message.(type) {
+	case *models.MySQLHandshakeV10Packet:
+		s.pluginName = message.AuthPluginName
+		s.serverCapabilities = message.CapabilityFlags
+	case *models.MySQLStmtPrepareOk:
+		s.prepared(message.StatementID, message.NumParams, columnDefinitions(message.ParamDefs), columnDefinitions(message.ColumnDefs))
+	}
+}
+
+// columnDefinitions returns the definitions of the mocks as the ones decoded from the server.
+func columnDefinitions(definitions []models.ColumnDefinition) []ColumnDefinition {
+	columns := make([]ColumnDefinition, len(definitions))
+	for i, definition := range definitions {
+		columns[i] = ColumnDefinition{
+			PacketHeader: PacketHeader{PacketLength: definition.PacketHeader.PacketLength, PacketSequenceID: definition.PacketHeader.PacketSequenceId},
+			Catalog:      definition.Catalog,
+			Schema:       definition.Schema,
+			Table:        definition.Table,
+			OrgTable:     definition.OrgTable,
+			Name:         definition.Name,
+			OrgName:      definition.OrgName,
+			NextLength:   definition.NextLength,
+			CharacterSet: definition.CharacterSet,
+			ColumnLength: definition.ColumnLength,
+			ColumnType:   definition.ColumnType,
+			Flags:        definition.Flags,
+			Decimals:     definition.Decimals,
+		}
+	}
+	return columns
+}
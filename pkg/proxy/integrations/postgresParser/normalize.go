@@ -0,0 +1,409 @@
+package postgresparser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+var (
+	ignoredParams []ignoredParam
+	matchConfigMu sync.RWMutex
+)
+
+// ignoredParam is a compiled rule of the parameters left out of the comparison of the queries.
+type ignoredParam struct {
+	query    *regexp.Regexp
+	position int
+	value    *regexp.Regexp
+}
+
+// SetMatchConfig sets how the queries of the application are matched with the postgres mocks. The rules with an
+// invalid regular expression are left out, they are checked when the config is read.
+func SetMatchConfig(config models.PostgresMatching) {
+	params := []ignoredParam{}
+	for _, rule := range config.IgnoreParams {
+		param := ignoredParam{position: rule.Position}
+		var err error
+		if rule.Query != "" {
+			if param.query, err = regexp.Compile(rule.Query); err != nil {
+				continue
+			}
+		}
+		if rule.Value != "" {
+			if param.value, err = regexp.Compile(rule.Value); err != nil {
+				continue
+			}
+		}
+		params = append(params, param)
+	}
+	matchConfigMu.Lock()
+	defer matchConfigMu.Unlock()
+	ignoredParams = params
+}
+
+func getIgnoredParams() []ignoredParam {
+	matchConfigMu.RLock()
+	defer matchConfigMu.RUnlock()
+	return ignoredParams
+}
+
+// normalizedQuery is a Parse, a Bind or a simple Query message with its sql normalized and its parameters apart.
+type normalizedQuery struct {
+	// kind is the type of the message: 'P', 'B' or 'Q'
+	kind byte
+	// statement is the name of the prepared statement of a Parse or a Bind, empty for the unnamed one
+	statement string
+	// portal is the name of the portal of a Bind, empty for the unnamed one
+	portal string
+	// sql is the normalized sql, empty for a Bind of a statement whose Parse was not seen
+	sql string
+	// params are the bound parameters of a Bind and the literals of a simple query
+	params []string
+}
+
+func (q normalizedQuery) String() string {
+	sql := q.sql
+	if sql == "" {
+		sql = fmt.Sprintf("statement %q", q.statement)
+	}
+	if len(q.params) == 0 {
+		return sql
+	}
+	return fmt.Sprintf("%s [%s]", sql, strings.Join(q.params, ", "))
+}
+
+// normalizeRequests returns the queries of every request buffer of a call. statements are the sql of the
+// statements prepared on the connection by their name, the Parse messages of the call are added to them.
+func normalizeRequests(requestBuffers [][]byte, statements map[string]string) [][]normalizedQuery {
+	queries := make([][]normalizedQuery, len(requestBuffers))
+	for i, buffer := range requestBuffers {
+		queries[i] = normalizeBuffer(buffer, statements)
+	}
+	return queries
+}
+
+// normalizeBuffer returns the queries of the messages of a request buffer, the other messages are left out.
+func normalizeBuffer(buffer []byte, statements map[string]string) []normalizedQuery {
+	queries := []normalizedQuery{}
+	for i := 0; i+5 <= len(buffer); {
+		length := int(binary.BigEndian.Uint32(buffer[i+1:]))
+		if length < 4 || i+1+length > len(buffer) {
+			// a startup or an ssl request, or a truncated message
+			break
+		}
+		body := buffer[i+5 : i+1+length]
+		switch buffer[i] {
+		case 'Q':
+			query := pgproto3.Query{}
+			if err := query.Decode(body); err == nil {
+				sql, literals := normalizeSQL(query.String, true)
+				queries = append(queries, normalizedQuery{kind: 'Q', sql: sql, params: literals})
+			}
+		case 'P':
+			parse := pgproto3.Parse{}
+			if err := parse.Decode(body); err == nil {
+				sql, _ := normalizeSQL(parse.Query, false)
+				statements[parse.Name] = sql
+				queries = append(queries, normalizedQuery{kind: 'P', statement: parse.Name, sql: sql})
+			}
+		case 'B':
+			bind := pgproto3.Bind{}
+			if err := bind.Decode(body); err == nil {
+				params := make([]string, len(bind.Parameters))
+				for j, param := range bind.Parameters {
+					params[j] = formatParam(param, paramFormat(bind.ParameterFormatCodes, j))
+				}
+				queries = append(queries, normalizedQuery{kind: 'B', statement: bind.PreparedStatement, portal: bind.DestinationPortal, sql: statements[bind.PreparedStatement], params: params})
+			}
+		}
+		i += 1 + length
+	}
+	return queries
+}
+
+// paramFormat returns the format code of the i-th parameter of a Bind, a single code applies to all of them.
+func paramFormat(codes []int16, i int) int16 {
+	switch {
+	case len(codes) == 0:
+		return 0
+	case len(codes) == 1:
+		return codes[0]
+	case i < len(codes):
+		return codes[i]
+	}
+	return 0
+}
+
+// formatParam writes a bound parameter as text, the binary values which are not printable in hex.
+func formatParam(value []byte, format int16) string {
+	if value == nil {
+		return "NULL"
+	}
+	if format == 0 || (utf8.Valid(value) && isPrintable(string(value))) {
+		return string(value)
+	}
+	return `\x` + hex.EncodeToString(value)
+}
+
+func isPrintable(s string) bool {
+	for _, r := range s {
+		if r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeSQL writes the tokens of the sql in lower case separated by a single space, the quoted strings and
+// identifiers are kept as they are and the comments are left out. With literals, the quoted strings and the
+// numbers are replaced by $1, $2... and returned apart, the way the parameters of a prepared statement are.
+func normalizeSQL(sql string, literals bool) (string, []string) {
+	tokens := []string{}
+	params := []string{}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(sql[i:], "--"):
+			for i < len(sql) && sql[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				i = len(sql)
+			} else {
+				i += end + 4
+			}
+		case c == '\'' || c == '"':
+			j := quotedEnd(sql, i)
+			token := sql[i:j]
+			if literals && c == '\'' {
+				params = append(params, strings.ReplaceAll(strings.TrimSuffix(token[1:], "'"), "''", "'"))
+				token = fmt.Sprintf("$%d", len(params))
+			}
+			tokens = append(tokens, token)
+			i = j
+		case isWordChar(c):
+			j := i
+			for j < len(sql) && (isWordChar(sql[j]) || (sql[j] == '.' && isDigit(sql[i]))) {
+				j++
+			}
+			token := strings.ToLower(sql[i:j])
+			if literals && isDigit(c) {
+				params = append(params, token)
+				token = fmt.Sprintf("$%d", len(params))
+			}
+			tokens = append(tokens, token)
+			i = j
+		case strings.IndexByte("+-*/<>=~!@#%^&|`?:", c) >= 0:
+			j := i
+			for j < len(sql) && strings.IndexByte("+-*/<>=~!@#%^&|`?:", sql[j]) >= 0 && !strings.HasPrefix(sql[j:], "--") && !strings.HasPrefix(sql[j:], "/*") {
+				j++
+			}
+			if j == i {
+				j++
+			}
+			// like postgres, a trailing + or - is the sign of the next token unless the operator has one of ~!@#%^&|`?
+			for j-i > 1 && (sql[j-1] == '+' || sql[j-1] == '-') && strings.IndexAny(sql[i:j], "~!@#%^&|`?") < 0 {
+				j--
+			}
+			tokens = append(tokens, sql[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	for len(tokens) > 0 && tokens[len(tokens)-1] == ";" {
+		tokens = tokens[:len(tokens)-1]
+	}
+
+	var normalized strings.Builder
+	for i, token := range tokens {
+		if i > 0 && !strings.Contains(",)].;(", token) && !strings.Contains("(.[", tokens[i-1]) {
+			normalized.WriteByte(' ')
+		}
+		normalized.WriteString(token)
+	}
+	return normalized.String(), params
+}
+
+// quotedEnd returns the end of the quoted string or identifier starting at i, a doubled quote is part of it.
+func quotedEnd(sql string, i int) int {
+	quote := sql[i]
+	for j := i + 1; j < len(sql); j++ {
+		if sql[j] != quote {
+			continue
+		}
+		if j+1 < len(sql) && sql[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(sql)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// hasQueries reports whether a request buffer of the call carries a query.
+func hasQueries(queries [][]normalizedQuery) bool {
+	for _, bufferQueries := range queries {
+		if len(bufferQueries) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// queryMatch is a mock matched with the queries of a call.
+type queryMatch struct {
+	mock *models.Mock
+	// recorded are the queries of the requests of the mock
+	recorded [][]normalizedQuery
+	// extraParses is the number of Parse messages of the call on top of the ones of the mock
+	extraParses int
+}
+
+// matchQueries returns the earliest recorded mock whose requests carry the queries of the call, with the same
+// sql and the same values of the parameters which are not ignored, nil when there is none. The statements of
+// the call are compared with the recorded ones by their sql, or by their names mapped by the session when the
+// sql of the recorded one is not known.
+func matchQueries(tcsMocks []*models.Mock, queries [][]normalizedQuery, s *session, logger *zap.Logger) *queryMatch {
+	ignored := getIgnoredParams()
+	for _, mock := range tcsMocks {
+		if mock == nil || len(mock.Spec.PostgresRequests) != len(queries) {
+			continue
+		}
+		match := &queryMatch{mock: mock, recorded: make([][]normalizedQuery, len(queries))}
+		// the statements prepared by the earlier requests of the mock
+		statements := map[string]string{}
+		for i, request := range mock.Spec.PostgresRequests {
+			buffer, err := mockRequestBuffer(request)
+			if err != nil {
+				logger.Debug("failed to encode the request of the postgres mock", zap.String("mock", mock.Name), zap.Error(err))
+				match = nil
+				break
+			}
+			match.recorded[i] = normalizeBuffer(buffer, statements)
+			same, extraParses := sameQueries(match.recorded[i], queries[i], s, ignored)
+			if !same {
+				match = nil
+				break
+			}
+			match.extraParses += extraParses
+		}
+		if match != nil {
+			return match
+		}
+	}
+	return nil
+}
+
+// mockRequestBuffer returns the bytes of a recorded request, from its payload when it was recorded as it is.
+func mockRequestBuffer(request models.Backend) ([]byte, error) {
+	if request.Payload != "" {
+		return PostgresDecoder(request.Payload)
+	}
+	return PostgresDecoderBackend(request)
+}
+
+// boundQueries leaves out the Parse messages of the statements bound later in the same request, the drivers send
+// them or not depending on the state of their statement cache. It returns the number of Parse messages left out.
+func boundQueries(queries []normalizedQuery) ([]normalizedQuery, int) {
+	bound := []normalizedQuery{}
+	parses := 0
+	for i, query := range queries {
+		if query.kind == 'P' && isBoundLater(queries[i+1:], query.statement) {
+			parses++
+			continue
+		}
+		bound = append(bound, query)
+	}
+	return bound, parses
+}
+
+func isBoundLater(queries []normalizedQuery, statement string) bool {
+	for _, query := range queries {
+		if query.kind == 'B' && query.statement == statement {
+			return true
+		}
+	}
+	return false
+}
+
+// sameQueries compares the queries of a request of a mock with the ones of the call, and returns the number of
+// Parse messages of the call on top of the ones of the mock.
+func sameQueries(recorded, actual []normalizedQuery, s *session, ignored []ignoredParam) (bool, int) {
+	recorded, recordedParses := boundQueries(recorded)
+	actual, actualParses := boundQueries(actual)
+	if len(recorded) != len(actual) {
+		return false, 0
+	}
+	for i := range recorded {
+		if !sameQuery(recorded[i], actual[i], s, ignored) {
+			return false, 0
+		}
+	}
+	return true, actualParses - recordedParses
+}
+
+// sameQuery compares the sql of the queries, or the names of their statements when the sql of one of them is
+// not known, and the values of their parameters which are not ignored.
+func sameQuery(recorded, actual normalizedQuery, s *session, ignored []ignoredParam) bool {
+	if recorded.kind != actual.kind || len(recorded.params) != len(actual.params) {
+		return false
+	}
+	sql := recorded.sql
+	if sql == "" {
+		// the statement was prepared by an earlier call of the recorded connection
+		sql = s.recordedSQL[recorded.statement]
+	}
+	if sql != "" && actual.sql != "" {
+		if sql != actual.sql {
+			return false
+		}
+	} else if recorded.statement != s.recordedStatement(actual.statement) {
+		return false
+	}
+	for i, value := range recorded.params {
+		if value != actual.params[i] && !isIgnored(ignored, sql, i+1, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// isIgnored reports whether a rule leaves the recorded parameter at the position of the query out of the comparison.
+func isIgnored(ignored []ignoredParam, sql string, position int, value string) bool {
+	for _, rule := range ignored {
+		if rule.query != nil && !rule.query.MatchString(sql) {
+			continue
+		}
+		if rule.position != 0 && rule.position != position {
+			continue
+		}
+		if rule.value != nil && !rule.value.MatchString(value) {
+			continue
+		}
+		return true
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package postgresparser
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/binary"
@@ -30,12 +31,15 @@ var Emoji = "\U0001F430" + " Keploy:"
 type PostgresParser struct {
 	logger *zap.Logger
 	hooks  *hooks.Hook
+	// prepares are the mocks served to the calls preparing statements, shared by the connections
+	prepares *preparedCalls
 }
 
 func NewPostgresParser(logger *zap.Logger, h *hooks.Hook) *PostgresParser {
 	return &PostgresParser{
-		logger: logger,
-		hooks:  h,
+		logger:   logger,
+		hooks:    h,
+		prepares: newPreparedCalls(),
 	}
 }
 
@@ -63,7 +67,7 @@ func (p *PostgresParser) ProcessOutgoing(requestBuffer []byte, clientConn, destC
 	case models.MODE_RECORD:
 		encodePostgresOutgoing(requestBuffer, clientConn, destConn, p.hooks, p.logger, ctx)
 	case models.MODE_TEST:
-		decodePostgresOutgoing(requestBuffer, clientConn, destConn, p.hooks, p.logger, ctx)
+		decodePostgresOutgoing(requestBuffer, clientConn, destConn, newSession(p.prepares), p.hooks, p.logger, ctx)
 	default:
 		p.logger.Info("Invalid mode detected while intercepting outgoing http call", zap.Any("mode", models.GetMode()))
 	}
@@ -147,6 +151,10 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 	}()
 
 	isPreviousChunkRequest := false
+	// continued is the length of the end of a request message sent with the next buffers of the client
+	continued := 0
+	// responseContinued is the length of the end of a response message sent with the next buffers of the server
+	responseContinued := 0
 	logger.Debug("the iteration for the pg request starts", zap.Any("pgReqs", len(pgRequests)), zap.Any("pgResps", len(pgResponses)))
 
 	reqTimestampMock := time.Now()
@@ -204,7 +212,16 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			}
 
 			bufStr := base64.StdEncoding.EncodeToString(buffer)
-			if bufStr != "" {
+			if bufStr != "" && continued > 0 {
+				// the buffer goes on with a message of the previous one, e.g. a large CopyData of a COPY FROM STDIN,
+				// it is kept as it was sent
+				pgRequests = append(pgRequests, models.Backend{
+					Identfier: "ClientRequest",
+					Length:    uint32(len(buffer)),
+					Payload:   bufStr,
+				})
+				continued = continuedLength(buffer, continued)
+			} else if bufStr != "" {
 
 				pg := NewBackend()
 				var msg pgproto3.FrontendMessage
@@ -213,11 +230,16 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					bufferCopy := buffer
 					for i := 0; i < len(bufferCopy); {
 						logger.Debug("Inside the if condition")
+						if len(buffer) < i+5 {
+							break
+						}
 						pg.BackendWrapper.MsgType = buffer[i]
 						pg.BackendWrapper.BodyLen = int(binary.BigEndian.Uint32(buffer[i+1:])) - 4
 						if len(buffer) < (i + pg.BackendWrapper.BodyLen + 5) {
-							logger.Error("failed to translate the postgres request message due to shorter network packet buffer")
-							continue
+							// the rest of the message, e.g. a large CopyData, comes with the next buffer
+							logger.Debug("the postgres request message continues in the next network packet buffer")
+							continued = i + pg.BackendWrapper.BodyLen + 5 - len(buffer)
+							break
 						}
 						msg, err = pg.TranslateToReadableBackend(buffer[i:(i + pg.BackendWrapper.BodyLen + 5)])
 						if err != nil && buffer[i] != 112 {
@@ -242,6 +264,12 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 							pg.BackendWrapper.Executes = append(pg.BackendWrapper.Executes, pg.BackendWrapper.Execute)
 						}
 
+						if pg.BackendWrapper.MsgType == 'd' {
+							// the data is decoded in place, it is copied before the buffer is reused
+							data := append([]byte{}, pg.BackendWrapper.CopyData.Data...)
+							pg.BackendWrapper.CopyDatas = append(pg.BackendWrapper.CopyDatas, pgproto3.CopyData{Data: data})
+						}
+
 						pg.BackendWrapper.PacketTypes = append(pg.BackendWrapper.PacketTypes, string(pg.BackendWrapper.MsgType))
 
 						i += (5 + pg.BackendWrapper.BodyLen)
@@ -258,6 +286,7 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						CancelRequest:       pg.BackendWrapper.CancelRequest,
 						Close:               pg.BackendWrapper.Close,
 						CopyData:            pg.BackendWrapper.CopyData,
+						CopyDatas:           pg.BackendWrapper.CopyDatas,
 						CopyDone:            pg.BackendWrapper.CopyDone,
 						CopyFail:            pg.BackendWrapper.CopyFail,
 						Describe:            pg.BackendWrapper.Describe,
@@ -278,6 +307,10 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						MsgType:             pg.BackendWrapper.MsgType,
 						AuthType:            pg.BackendWrapper.AuthType,
 					}
+					if continued > 0 {
+						// the readable messages miss the one split between the buffers
+						pg_mock.Payload = bufStr
+					}
 					pgRequests = append(pgRequests, *pg_mock)
 
 				}
@@ -306,7 +339,16 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 
 			bufStr := base64.StdEncoding.EncodeToString(buffer)
 
-			if bufStr != "" {
+			if bufStr != "" && responseContinued > 0 {
+				// the buffer goes on with a message of the previous one, e.g. a large DataRow, it is kept as it was
+				// sent
+				pgResponses = append(pgResponses, models.Frontend{
+					Identfier: "ServerResponse",
+					Length:    uint32(len(buffer)),
+					Payload:   bufStr,
+				})
+				responseContinued = continuedLength(buffer, responseContinued)
+			} else if bufStr != "" {
 				pg := NewFrontend()
 				if !isStartupPacket(buffer) && len(buffer) > 5 && bufStr != "Tg==" {
 					bufferCopy := buffer
@@ -314,10 +356,21 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					//Saving list of packets in case of multiple packets in a single buffer steam
 					ps := make([]pgproto3.ParameterStatus, 0)
 					dataRows := []pgproto3.DataRow{}
+					notifications := []pgproto3.NotificationResponse{}
+					copyDatas := []pgproto3.CopyData{}
 
 					for i := 0; i < len(bufferCopy); {
+						if len(buffer) < i+5 {
+							break
+						}
 						pg.FrontendWrapper.MsgType = buffer[i]
 						pg.FrontendWrapper.BodyLen = int(binary.BigEndian.Uint32(buffer[i+1:])) - 4
+						if len(buffer) < i+pg.FrontendWrapper.BodyLen+5 {
+							// the rest of the message comes with the next buffer, the buffer is kept as its payload
+							logger.Debug("the postgres response message continues in the next network packet buffer")
+							responseContinued = i + pg.FrontendWrapper.BodyLen + 5 - len(buffer)
+							break
+						}
 						msg, err := pg.TranslateToReadableResponse(buffer[i:(i+pg.FrontendWrapper.BodyLen+5)], logger) // arre yeh index leta hai length nhi
 						if err != nil {
 							logger.Error("failed to translate the response message to readable", zap.Error(err))
@@ -332,6 +385,14 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 							pg.FrontendWrapper.CommandComplete = *msg.(*pgproto3.CommandComplete)
 							pg.FrontendWrapper.CommandCompletes = append(pg.FrontendWrapper.CommandCompletes, pg.FrontendWrapper.CommandComplete)
 						}
+						if pg.FrontendWrapper.MsgType == 'A' {
+							// the notifications of the listened channels are replayed where the server sent them
+							notifications = append(notifications, pg.FrontendWrapper.NotificationResponse)
+						}
+						if pg.FrontendWrapper.MsgType == 'd' {
+							data := append([]byte{}, pg.FrontendWrapper.CopyData.Data...)
+							copyDatas = append(copyDatas, pgproto3.CopyData{Data: data})
+						}
 						if pg.FrontendWrapper.DataRow.RowValues != nil {
 							// Create a new slice for each DataRow
 							valuesCopy := make([]string, len(pg.FrontendWrapper.DataRow.RowValues))
@@ -351,6 +412,12 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					if len(dataRows) > 0 {
 						pg.FrontendWrapper.DataRows = dataRows
 					}
+					if len(notifications) > 0 {
+						pg.FrontendWrapper.NotificationResponses = notifications
+					}
+					if len(copyDatas) > 0 {
+						pg.FrontendWrapper.CopyDatas = copyDatas
+					}
 
 					// from here take the msg and append its readabable form to the pgResponses
 					pg_mock := &models.Frontend{
@@ -372,6 +439,7 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						CommandComplete:                 pg.FrontendWrapper.CommandComplete,
 						CommandCompletes:                pg.FrontendWrapper.CommandCompletes,
 						CopyData:                        pg.FrontendWrapper.CopyData,
+						CopyDatas:                       pg.FrontendWrapper.CopyDatas,
 						CopyDone:                        pg.FrontendWrapper.CopyDone,
 						CopyInResponse:                  pg.FrontendWrapper.CopyInResponse,
 						CopyOutResponse:                 pg.FrontendWrapper.CopyOutResponse,
@@ -383,6 +451,7 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						NoData:                          pg.FrontendWrapper.NoData,
 						NoticeResponse:                  pg.FrontendWrapper.NoticeResponse,
 						NotificationResponse:            pg.FrontendWrapper.NotificationResponse,
+						NotificationResponses:           pg.FrontendWrapper.NotificationResponses,
 						ParameterDescription:            pg.FrontendWrapper.ParameterDescription,
 						ParameterStatusCombined:         pg.FrontendWrapper.ParameterStatusCombined,
 						ParseComplete:                   pg.FrontendWrapper.ParseComplete,
@@ -394,7 +463,10 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					}
 
 					after_encoded, _ := PostgresDecoderFrontend(*pg_mock)
-					if (len(after_encoded) != len(buffer) && pg_mock.PacketTypes[0] != "R") || len(pg_mock.DataRows) > 0 {
+					// the readable messages miss the one split between the buffers, and the authentication
+					// messages are encoded again with their own length
+					authentication := len(pg_mock.PacketTypes) > 0 && pg_mock.PacketTypes[0] == "R"
+					if (len(after_encoded) != len(buffer) && !authentication) || len(pg_mock.DataRows) > 0 || responseContinued > 0 {
 						logger.Debug("the length of the encoded buffer is not equal to the length of the original buffer", zap.Any("after_encoded", len(after_encoded)), zap.Any("buffer", len(buffer)))
 						pg_mock.Payload = bufStr
 					}
@@ -442,7 +514,7 @@ func ReadBuffConn(conn net.Conn, bufferChannel chan []byte, errChannel chan erro
 }
 
 // This is the decoding function for the postgres wiremessage
-func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, s *session, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
 	pgRequests := [][]byte{requestBuffer}
 
 	for {
@@ -476,12 +548,24 @@ func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			continue
 		}
 
-		matched, pgResponses, err := matchingReadablePG(pgRequests, h)
+		if s.copyIn && !isCopyEnded(pgRequests) {
+			// the application is still streaming the data of the COPY FROM STDIN, which is answered at its end
+			continue
+		}
+
+		queries := normalizeRequests(pgRequests, s.statements)
+		matched, pgResponses, err := matchingReadablePG(pgRequests, queries, s, h, logger)
 		if err != nil {
 			return fmt.Errorf("error while matching tcs mocks %v", err)
 		}
 
 		if !matched {
+			summary := pgRequestSummary(pgRequests, queries)
+			if hasQueries(queries) {
+				logger.Warn("no postgres mock matched the queries of the application, passing them through", zap.String("queries", summary))
+			}
+			h.RecordUnmatchedCall(models.Postgres, summary)
+			s.copyIn = false
 			_, err = util.Passthrough(clientConn, destConn, pgRequests, h.Recover, logger)
 
 			if err != nil {
@@ -491,6 +575,7 @@ func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			continue
 
 		}
+		responses := make([][]byte, 0, len(pgResponses))
 		for _, pgResponse := range pgResponses {
 			encoded, err := PostgresDecoder(pgResponse.Payload)
 			if len(pgResponse.PacketTypes) > 0 && len(pgResponse.Payload) == 0 {
@@ -500,7 +585,12 @@ func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 				logger.Error("failed to decode the response message in proxy for postgres dependency", zap.Error(err))
 				return err
 			}
-			_, err = clientConn.Write([]byte(encoded))
+			responses = append(responses, encoded)
+		}
+		responses = s.rewriteResponses(responses)
+		s.copyIn = bytes.IndexByte(messageTypes(responses), 'G') >= 0
+		for _, encoded := range responses {
+			_, err = clientConn.Write(encoded)
 			if err != nil {
 				logger.Error("failed to write request message to the client application", zap.Error(err))
 				return err
@@ -0,0 +1,189 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// parseComplete is the response of the server to a Parse message.
+var parseComplete = []byte{'1', 0, 0, 0, 4}
+
+// session is the state of a connection of the application to postgres during a test. The drivers name the
+// prepared statements and the portals with counters of the connection, e.g. stmtcache_1 or S_1, so the names of
+// the application are mapped to the ones of the recorded calls they are matched with.
+type session struct {
+	// statements are the normalized sql of the statements prepared on the connection, by their name
+	statements map[string]string
+	// recordedStatements are the recorded names of the statements, by the names the application gives them
+	recordedStatements map[string]string
+	// recordedSQL are the normalized sql of the recorded statements, by their recorded name
+	recordedSQL map[string]string
+	// liveNames are the names the application gives to the recorded statements and portals, the latest one when
+	// several names were mapped to a recorded one
+	liveNames map[string]string
+	// extraParses is the number of Parse messages the application sent in the last served call on top of the ones
+	// of its mock, negative when it sent less of them
+	extraParses int
+	// copyIn tells that the last served response started a COPY FROM STDIN, the application streams its data next
+	copyIn   bool
+	prepares *preparedCalls
+}
+
+func newSession(prepares *preparedCalls) *session {
+	return &session{
+		statements:         map[string]string{},
+		recordedStatements: map[string]string{},
+		recordedSQL:        map[string]string{},
+		liveNames:          map[string]string{},
+		prepares:           prepares,
+	}
+}
+
+// recordedStatement returns the recorded name of a statement of the application, its own name when it was not
+// matched yet.
+func (s *session) recordedStatement(name string) string {
+	if recorded, ok := s.recordedStatements[name]; ok {
+		return recorded
+	}
+	return name
+}
+
+// served maps the names of the statements and the portals of a call to the ones of the mock it was served with.
+func (s *session) served(actual, recorded [][]normalizedQuery, extraParses int) {
+	for i := range actual {
+		actualQueries, _ := boundQueries(actual[i])
+		recordedQueries, _ := boundQueries(recorded[i])
+		for j, query := range actualQueries {
+			if query.kind == 'Q' {
+				continue
+			}
+			recordedQuery := recordedQueries[j]
+			s.recordedStatements[query.statement] = recordedQuery.statement
+			s.liveNames[recordedQuery.statement] = query.statement
+			if query.sql != "" {
+				s.recordedSQL[recordedQuery.statement] = query.sql
+			}
+			if query.kind == 'B' {
+				s.liveNames[recordedQuery.portal] = query.portal
+			}
+		}
+	}
+	s.extraParses = extraParses
+}
+
+// rewriteResponses adapts the recorded responses of the last served call to the connection: the ParseComplete
+// messages follow the Parse messages the application sent, and the recorded names of the statements and the
+// portals quoted by the errors and the notices are replaced by the names of the application.
+func (s *session) rewriteResponses(responses [][]byte) [][]byte {
+	if s.extraParses > 0 && len(responses) > 0 {
+		responses[0] = append(bytes.Repeat(parseComplete, s.extraParses), responses[0]...)
+	}
+	missing := -s.extraParses
+	s.extraParses = 0
+
+	renames := map[string]string{}
+	for recorded, actual := range s.liveNames {
+		if actual != recorded {
+			renames[`"`+recorded+`"`] = `"` + actual + `"`
+		}
+	}
+	if missing <= 0 && len(renames) == 0 {
+		return responses
+	}
+
+	for i, response := range responses {
+		rewritten := []byte{}
+		for j := 0; j+5 <= len(response); {
+			length := int(binary.BigEndian.Uint32(response[j+1:]))
+			if length < 4 || j+1+length > len(response) {
+				rewritten = append(rewritten, response[j:]...)
+				break
+			}
+			message := response[j : j+1+length]
+			j += 1 + length
+			switch message[0] {
+			case '1':
+				if missing > 0 {
+					missing--
+					continue
+				}
+			case 'E', 'N':
+				if len(renames) > 0 {
+					message = renameInError(message, renames)
+				}
+			}
+			rewritten = append(rewritten, message...)
+		}
+		responses[i] = rewritten
+	}
+	return responses
+}
+
+// renameInError replaces the quoted names in the texts of an ErrorResponse or a NoticeResponse.
+func renameInError(message []byte, renames map[string]string) []byte {
+	errorResponse := pgproto3.ErrorResponse{}
+	if err := errorResponse.Decode(message[5:]); err != nil {
+		return message
+	}
+	for recorded, actual := range renames {
+		errorResponse.Message = strings.ReplaceAll(errorResponse.Message, recorded, actual)
+		errorResponse.Detail = strings.ReplaceAll(errorResponse.Detail, recorded, actual)
+		errorResponse.Hint = strings.ReplaceAll(errorResponse.Hint, recorded, actual)
+	}
+	if message[0] == 'N' {
+		notice := pgproto3.NoticeResponse(errorResponse)
+		return notice.Encode(nil)
+	}
+	return errorResponse.Encode(nil)
+}
+
+// preparedCalls are the mocks served to the calls which only prepare statements, by their queries. A driver
+// prepares a statement once on every connection with a cold statement cache, so the application may prepare it on
+// more connections than the recorded one did: these calls are served again with the mock of the first of them.
+type preparedCalls struct {
+	mu    sync.Mutex
+	mocks map[string]*models.Mock
+}
+
+func newPreparedCalls() *preparedCalls {
+	return &preparedCalls{mocks: map[string]*models.Mock{}}
+}
+
+func (p *preparedCalls) store(queries [][]normalizedQuery, mock *models.Mock) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mocks[preparedKey(queries)] = mock
+}
+
+func (p *preparedCalls) load(queries [][]normalizedQuery) *models.Mock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.mocks[preparedKey(queries)]
+}
+
+func preparedKey(queries [][]normalizedQuery) string {
+	key := []string{}
+	for _, bufferQueries := range queries {
+		for _, query := range bufferQueries {
+			key = append(key, query.sql)
+		}
+	}
+	return strings.Join(key, "\n")
+}
+
+// onlyPrepares reports whether the queries of a call are all Parse messages.
+func onlyPrepares(queries [][]normalizedQuery) bool {
+	for _, bufferQueries := range queries {
+		for _, query := range bufferQueries {
+			if query.kind != 'P' {
+				return false
+			}
+		}
+	}
+	return hasQueries(queries)
+}
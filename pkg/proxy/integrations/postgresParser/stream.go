@@ -0,0 +1,106 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// The COPY FROM STDIN of a query is streamed by the application after the CopyInResponse of the server: any number
+// of CopyData messages ended by a CopyDone, or by a CopyFail to abort it. The server answers the whole stream at
+// once, so the stream is recorded as a single mock, whatever the number of buffers the application sent it in, and
+// the data of a call is matched with the data of the mocks rather than buffer by buffer.
+
+// splitMessages returns the complete messages of a buffer, with their type and length.
+func splitMessages(buffer []byte) [][]byte {
+	messages := [][]byte{}
+	for i := 0; i+5 <= len(buffer); {
+		length := int(binary.BigEndian.Uint32(buffer[i+1:]))
+		if length < 4 || i+1+length > len(buffer) {
+			break
+		}
+		messages = append(messages, buffer[i:i+1+length])
+		i += 1 + length
+	}
+	return messages
+}
+
+// messageTypes returns the types of the messages of the buffers, in their order. The buffers are a stream, a
+// message may be split between two of them.
+func messageTypes(buffers [][]byte) []byte {
+	types := []byte{}
+	for _, message := range splitMessages(bytes.Join(buffers, nil)) {
+		types = append(types, message[0])
+	}
+	return types
+}
+
+// continuedLength returns the length of the end of the last message of a buffer, sent with the next buffers. The
+// messages of the buffer start at offset, after the end of a message of the previous buffers.
+func continuedLength(buffer []byte, offset int) int {
+	i := offset
+	for i+5 <= len(buffer) {
+		length := int(binary.BigEndian.Uint32(buffer[i+1:]))
+		if length < 4 {
+			return 0
+		}
+		i += 1 + length
+	}
+	if i > len(buffer) {
+		return i - len(buffer)
+	}
+	return 0
+}
+
+// isCopyStream reports whether the buffers of a call stream the data of a COPY FROM STDIN.
+func isCopyStream(buffers [][]byte) bool {
+	types := messageTypes(buffers)
+	return len(types) > 0 && (types[0] == 'd' || types[0] == 'c' || types[0] == 'f')
+}
+
+// isCopyEnded reports whether the buffers of a call carry the CopyDone or the CopyFail ending a copy stream.
+func isCopyEnded(buffers [][]byte) bool {
+	types := messageTypes(buffers)
+	return bytes.IndexByte(types, 'c') >= 0 || bytes.IndexByte(types, 'f') >= 0
+}
+
+// copyContent returns the data of a copy stream followed by the message ending it, the boundaries of the
+// messages and of the buffers are left out.
+func copyContent(buffers [][]byte) []byte {
+	content := []byte{}
+	for _, message := range splitMessages(bytes.Join(buffers, nil)) {
+		switch message[0] {
+		case 'd':
+			content = append(content, message[5:]...)
+		case 'c', 'f':
+			content = append(content, message...)
+		}
+	}
+	return content
+}
+
+// matchCopyStream returns the earliest recorded copy stream with the data of the call, nil when there is none.
+func matchCopyStream(tcsMocks []*models.Mock, requestBuffers [][]byte, logger *zap.Logger) *models.Mock {
+	content := copyContent(requestBuffers)
+	for _, mock := range tcsMocks {
+		if mock == nil || len(mock.Spec.PostgresRequests) == 0 {
+			continue
+		}
+		recorded := make([][]byte, 0, len(mock.Spec.PostgresRequests))
+		for _, request := range mock.Spec.PostgresRequests {
+			buffer, err := mockRequestBuffer(request)
+			if err != nil {
+				logger.Debug("failed to encode the request of the postgres mock", zap.String("mock", mock.Name), zap.Error(err))
+				recorded = nil
+				break
+			}
+			recorded = append(recorded, buffer)
+		}
+		if recorded != nil && isCopyStream(recorded) && bytes.Equal(copyContent(recorded), content) {
+			return mock
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/cloudflare/cfssl/log"
 	"github.com/jackc/pgproto3/v2"
@@ -29,7 +30,7 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 	var resbuffer []byte
 	// list of packets available in the buffer
 	packets := response.PacketTypes
-	var cc, dtr, ps int = 0, 0, 0
+	var cc, dtr, ps, cd, nr int = 0, 0, 0, 0, 0
 	for _, packet := range packets {
 		var msg pgproto3.BackendMessage
 
@@ -41,10 +42,16 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 		case string('3'):
 			msg = &pgproto3.CloseComplete{}
 		case string('A'):
+			notification := response.NotificationResponse
+			// the mocks recorded before the list of notifications hold a single one
+			if nr < len(response.NotificationResponses) {
+				notification = response.NotificationResponses[nr]
+				nr++
+			}
 			msg = &pgproto3.NotificationResponse{
-				PID:     response.NotificationResponse.PID,
-				Channel: response.NotificationResponse.Channel,
-				Payload: response.NotificationResponse.Payload,
+				PID:     notification.PID,
+				Channel: notification.Channel,
+				Payload: notification.Payload,
 			}
 		case string('c'):
 			msg = &pgproto3.CopyDone{}
@@ -54,8 +61,13 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 			}
 			cc++
 		case string('d'):
+			copyData := response.CopyData
+			if cd < len(response.CopyDatas) {
+				copyData = response.CopyDatas[cd]
+				cd++
+			}
 			msg = &pgproto3.CopyData{
-				Data: response.CopyData.Data,
+				Data: copyData.Data,
 			}
 		case string('D'):
 			msg = &pgproto3.DataRow{
@@ -196,7 +208,7 @@ func PostgresDecoderBackend(request models.Backend) ([]byte, error) {
 
 	var reqbuffer []byte
 	// list of packets available in the buffer
-	var b, e, p int = 0, 0, 0
+	var b, e, p, cd int = 0, 0, 0, 0
 	packets := request.PacketTypes
 	for _, packet := range packets {
 		// isme se encode ek ek
@@ -228,26 +240,30 @@ func PostgresDecoderBackend(request models.Backend) ([]byte, error) {
 			}
 			e++
 		case string('F'):
-			// *msg.(*pgproto3.Flush) = request.Flush
-			msg = &pgproto3.Flush{}
-		case string('f'):
-			// *msg.(*pgproto3.FunctionCall) = request.FunctionCall
 			msg = &pgproto3.FunctionCall{
 				Function:         request.FunctionCall.Function,
 				Arguments:        request.FunctionCall.Arguments,
 				ArgFormatCodes:   request.FunctionCall.ArgFormatCodes,
 				ResultFormatCode: request.FunctionCall.ResultFormatCode,
 			}
+		case string('f'):
+			msg = &pgproto3.CopyFail{
+				Message: request.CopyFail.Message,
+			}
 		case string('d'):
+			copyData := request.CopyData
+			// the mocks recorded before the list of the copied data hold a single message
+			if cd < len(request.CopyDatas) {
+				copyData = request.CopyDatas[cd]
+				cd++
+			}
 			msg = &pgproto3.CopyData{
-				Data: request.CopyData.Data,
+				Data: copyData.Data,
 			}
 		case string('c'):
 			msg = &pgproto3.CopyDone{}
 		case string('H'):
-			msg = &pgproto3.CopyFail{
-				Message: request.CopyFail.Message,
-			}
+			msg = &pgproto3.Flush{}
 		case string('P'):
 			msg = &pgproto3.Parse{
 				Name:          request.Parses[p].Name,
@@ -391,12 +407,16 @@ func CheckValidEncode(tcsMocks []*models.Mock, h *hooks.Hook, log *zap.Logger) {
 	h.SetTcsMocks(tcsMocks)
 }
 
-func matchingReadablePG(requestBuffers [][]byte, h *hooks.Hook) (bool, []models.Frontend, error) {
+// matchingReadablePG returns the responses of the mock of the call. The calls carrying queries are matched by
+// their normalized queries, the other ones by their bytes. The names of the statements and the portals of the
+// calls served by their queries are mapped in the session of the connection.
+func matchingReadablePG(requestBuffers [][]byte, queries [][]normalizedQuery, s *session, h *hooks.Hook, logger *zap.Logger) (bool, []models.Frontend, error) {
 
 	for {
 
 		var isMatched bool
 		var matchedMock *models.Mock
+		var queryMatched *queryMatch
 
 		tcsMocks, err := h.GetTcsMocks()
 		if err != nil {
@@ -500,8 +520,25 @@ func matchingReadablePG(requestBuffers [][]byte, h *hooks.Hook) (bool, []models.
 			}
 		}
 
-		idx := findBinaryStreamMatch(tcsMocks, requestBuffers, h)
-		if idx != -1 {
+		if isCopyStream(requestBuffers) {
+			matchedMock = matchCopyStream(tcsMocks, requestBuffers, logger)
+			isMatched = matchedMock != nil
+		} else if hasQueries(queries) {
+			// the similarity of the bytes tells apart neither the values of the parameters nor the whitespace
+			queryMatched = matchQueries(tcsMocks, queries, s, logger)
+			isMatched = queryMatched != nil
+			if isMatched {
+				matchedMock = queryMatched.mock
+			} else if onlyPrepares(queries) {
+				// the statements were prepared on another connection with the mock of the recorded connection
+				if mock := s.prepares.load(queries); mock != nil {
+					if queryMatched = matchQueries([]*models.Mock{mock}, queries, s, logger); queryMatched != nil {
+						s.served(queries, queryMatched.recorded, queryMatched.extraParses)
+						return true, mock.Spec.PostgresResponses, nil
+					}
+				}
+			}
+		} else if idx := findBinaryStreamMatch(tcsMocks, requestBuffers, h); idx != -1 {
 			isMatched = true
 			matchedMock = tcsMocks[idx]
 		}
@@ -514,6 +551,12 @@ func matchingReadablePG(requestBuffers [][]byte, h *hooks.Hook) (bool, []models.
 			if !isDeleted {
 				continue
 			} else {
+				if queryMatched != nil {
+					s.served(queries, queryMatched.recorded, queryMatched.extraParses)
+					if onlyPrepares(queries) {
+						s.prepares.store(queries, matchedMock)
+					}
+				}
 				return true, matchedMock.Spec.PostgresResponses, nil
 			}
 		}
@@ -521,3 +564,23 @@ func matchingReadablePG(requestBuffers [][]byte, h *hooks.Hook) (bool, []models.
 		return false, nil, nil
 	}
 }
+
+// pgRequestSummary describes the requests of an unmatched call in the test report, by their normalized queries
+// with their parameters when they carry some.
+func pgRequestSummary(requestBuffers [][]byte, queries [][]normalizedQuery) string {
+	summary := []string{}
+	for i, buf := range requestBuffers {
+		if len(queries[i]) > 0 {
+			for j, query := range queries[i] {
+				// the Parse of a statement bound right after it is shown by the Bind
+				if query.kind == 'P' && j+1 < len(queries[i]) && queries[i][j+1].kind == 'B' && queries[i][j+1].statement == query.statement {
+					continue
+				}
+				summary = append(summary, query.String())
+			}
+			continue
+		}
+		summary = append(summary, fmt.Sprintf("%d bytes", len(buf)))
+	}
+	return strings.Join(summary, "; ")
+}
@@ -0,0 +1,67 @@
+package redisparser
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+)
+
+// match finds the reply for req. Testcase mocks are consumed on use, while config mocks such as
+// HELLO or AUTH stay available for every new connection.
+func match(h *hooks.Hook, req models.RedisRequest) (*models.RedisResponse, bool, error) {
+	for {
+		tcsMocks, err := h.GetTcsMocks()
+		if err != nil {
+			return nil, false, fmt.Errorf("error while getting tcs mocks %v", err)
+		}
+		idx := findMatch(tcsMocks, req)
+		if idx == -1 {
+			break
+		}
+		mock := tcsMocks[idx]
+		isDeleted, err := h.DeleteTcsMock(mock)
+		if err != nil {
+			return nil, false, fmt.Errorf("error while deleting tcs mock: %v", err)
+		}
+		if !isDeleted {
+			continue
+		}
+		return &mock.Spec.RedisResponses[0], true, nil
+	}
+
+	configMocks, err := h.GetConfigMocks()
+	if err != nil {
+		return nil, false, fmt.Errorf("error while getting config mocks %v", err)
+	}
+	idx := findMatch(configMocks, req)
+	if idx == -1 {
+		return nil, false, nil
+	}
+	h.MarkMockUsed(configMocks[idx])
+	return &configMocks[idx].Spec.RedisResponses[0], true, nil
+}
+
+// findMatch returns the index of the mock recorded for req. A mock with the same command, key and
+// arguments wins; otherwise the first mock in recorded order with the same command, key and
+// number of arguments is used, so that values such as timestamps in SET do not break the replay.
+func findMatch(mocks []*models.Mock, req models.RedisRequest) int {
+	fallback := -1
+	for idx, mock := range mocks {
+		if mock.Kind != models.Redis || len(mock.Spec.RedisRequests) == 0 || len(mock.Spec.RedisResponses) == 0 {
+			continue
+		}
+		recorded := mock.Spec.RedisRequests[0]
+		if recorded.Command != req.Command || recorded.Key != req.Key {
+			continue
+		}
+		if recorded.Encoding == req.Encoding && reflect.DeepEqual(recorded.Args, req.Args) {
+			return idx
+		}
+		if fallback == -1 && len(recorded.Args) == len(req.Args) {
+			fallback = idx
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,256 @@
+package redisparser
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+type RedisParser struct {
+	logger *zap.Logger
+	hooks  *hooks.Hook
+}
+
+func NewRedisParser(logger *zap.Logger, h *hooks.Hook) *RedisParser {
+	return &RedisParser{
+		logger: logger,
+		hooks:  h,
+	}
+}
+
+// commandPattern matches the start of a RESP command: an array whose first element is a bulk string.
+var commandPattern = regexp.MustCompile(`^\*[1-9][0-9]*\r\n\$[0-9]+\r\n`)
+
+// OutgoingType function determines if the outgoing network call is Redis by checking that the
+// client opened with a RESP array of bulk strings, which is how every redis client sends commands.
+func (r *RedisParser) OutgoingType(buffer []byte) bool {
+	return commandPattern.Match(buffer)
+}
+
+func (r *RedisParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, ctx context.Context) {
+	switch models.GetMode() {
+	case models.MODE_RECORD:
+		err := encodeOutgoingRedis(requestBuffer, clientConn, destConn, r.hooks, r.logger, ctx)
+		if err != nil {
+			r.logger.Error("failed to encode the redis message into the yaml", zap.Error(err))
+			return
+		}
+	case models.MODE_TEST:
+		err := decodeOutgoingRedis(requestBuffer, clientConn, r.hooks, r.logger)
+		if err != nil {
+			r.logger.Error("failed to decode the redis message from the yaml", zap.Error(err))
+			return
+		}
+	default:
+		r.logger.Info("Invalid mode detected while intercepting outgoing redis call", zap.Any("mode", models.GetMode()))
+	}
+}
+
+// configCommands are sent while setting up a connection. They are recorded as config mocks so that
+// every connection opened by a client pool during a test run can be served from them.
+var configCommands = map[string]struct{}{
+	"AUTH": {}, "HELLO": {}, "SELECT": {}, "PING": {}, "READONLY": {},
+	"CLIENT SETNAME": {}, "CLIENT SETINFO": {}, "CLIENT ID": {}, "CLIENT TRACKING": {},
+}
+
+// subscribeCommands switch the connection into pub/sub mode, after which the server pushes
+// messages on its own and replies can no longer be paired with commands.
+var subscribeCommands = map[string]struct{}{
+	"SUBSCRIBE": {}, "PSUBSCRIBE": {}, "SSUBSCRIBE": {}, "MONITOR": {},
+}
+
+// readValues reads from conn until buf holds the wanted number of complete RESP values.
+// When want is 0, it returns once every byte read so far has been decoded into values.
+func readValues(conn net.Conn, buf []byte, want int) ([]models.RedisValue, []byte, error) {
+	var (
+		values []models.RedisValue
+		offset int
+	)
+	for {
+		for offset < len(buf) && (want == 0 || len(values) < want) {
+			val, next, err := decodeAt(buf, offset)
+			if errors.Is(err, errIncomplete) {
+				break
+			}
+			if err != nil {
+				return nil, buf, err
+			}
+			values = append(values, val)
+			offset = next
+		}
+		if (want == 0 && len(values) > 0 && offset == len(buf)) || (want > 0 && len(values) >= want) {
+			return values, buf, nil
+		}
+		more, err := util.ReadBytes(conn)
+		if len(more) == 0 && err != nil {
+			return nil, buf, err
+		}
+		buf = append(buf, more...)
+	}
+}
+
+// encodeOutgoingRedis forwards the commands of the client to the redis server and records every
+// command along with its reply as a separate mock.
+func encodeOutgoingRedis(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+	defer destConn.Close()
+	for {
+		values, raw, err := readValues(clientConn, requestBuffer, 0)
+		if err != nil {
+			logger.Error("failed to read the redis commands from the client", zap.Error(err))
+			return err
+		}
+		reqTimestampMock := time.Now()
+		_, err = destConn.Write(raw)
+		if err != nil {
+			logger.Error("failed to write the redis commands to the destination server", zap.Error(err))
+			return err
+		}
+
+		requests := make([]models.RedisRequest, 0, len(values))
+		for _, val := range values {
+			req, err := decodeCommand(val)
+			if err != nil {
+				logger.Error("failed to decode the redis command", zap.Error(err))
+				return err
+			}
+			if _, ok := subscribeCommands[req.Command]; ok {
+				logger.Debug("redis connection switched to pub/sub mode, the rest of it will not be recorded", zap.String("command", req.Command))
+				pipe(clientConn, destConn, h, logger)
+				return nil
+			}
+			requests = append(requests, req)
+		}
+
+		replies, respRaw, err := readValues(destConn, nil, len(requests))
+		if err != nil {
+			logger.Error("failed to read the redis replies from the destination server", zap.Error(err))
+			return err
+		}
+		resTimestampMock := time.Now()
+		_, err = clientConn.Write(respRaw)
+		if err != nil {
+			logger.Error("failed to write the redis replies to the client", zap.Error(err))
+			return err
+		}
+
+		for i, req := range requests {
+			meta := map[string]string{
+				"name":      "Redis",
+				"type":      models.NoSqlDB,
+				"operation": req.Command,
+			}
+			if _, ok := configCommands[req.Command]; ok {
+				meta["type"] = "config"
+			}
+			err = h.AppendMocks(&models.Mock{
+				Version: models.GetVersion(),
+				Name:    "mocks",
+				Kind:    models.Redis,
+				Spec: models.MockSpec{
+					Metadata:         meta,
+					RedisRequests:    []models.RedisRequest{req},
+					RedisResponses:   []models.RedisResponse{{Message: replies[i]}},
+					Created:          time.Now().Unix(),
+					ReqTimestampMock: reqTimestampMock,
+					ResTimestampMock: resTimestampMock,
+				},
+			}, ctx)
+			if err != nil {
+				logger.Error("failed to store the redis mock", zap.Error(err))
+			}
+		}
+
+		requestBuffer, err = util.ReadBytes(clientConn)
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("failed to read the redis command from the client", zap.Error(err))
+			}
+			return nil
+		}
+	}
+}
+
+// decodeOutgoingRedis answers the commands of the client from the recorded redis mocks.
+func decodeOutgoingRedis(requestBuffer []byte, clientConn net.Conn, h *hooks.Hook, logger *zap.Logger) error {
+	for {
+		values, _, err := readValues(clientConn, requestBuffer, 0)
+		if err != nil {
+			logger.Error("failed to read the redis commands from the client", zap.Error(err))
+			return err
+		}
+
+		var replies []byte
+		for _, val := range values {
+			req, err := decodeCommand(val)
+			if err != nil {
+				logger.Error("failed to decode the redis command", zap.Error(err))
+				return err
+			}
+			resp, ok, err := match(h, req)
+			if err != nil {
+				logger.Error("error while matching redis mocks", zap.Error(err))
+			}
+			if !ok {
+				logger.Error("Didn't match any prexisting redis mock", zap.String("command", req.Command), zap.String("key", req.Key), zap.Strings("args", req.Args))
+				h.RecordUnmatchedCall(models.Redis, strings.TrimSpace(req.Command+" "+req.Key))
+				replies = append(replies, []byte("-ERR keploy: no mock found for "+req.Command+"\r\n")...)
+				continue
+			}
+			reply, err := encodeValue(resp.Message)
+			if err != nil {
+				logger.Error("failed to encode the redis reply from the mock", zap.Error(err))
+				return err
+			}
+			replies = append(replies, reply...)
+		}
+
+		_, err = clientConn.Write(replies)
+		if err != nil {
+			logger.Error("failed to write the redis replies to the client", zap.Error(err))
+			return err
+		}
+
+		requestBuffer, err = util.ReadBytes(clientConn)
+		if err != nil {
+			if err != io.EOF {
+				logger.Debug("failed to read the redis command from the client", zap.Error(err))
+			}
+			return nil
+		}
+	}
+}
+
+// pipe copies the traffic in both directions until either side closes the connection.
+func pipe(clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) {
+	done := make(chan struct{}, 2)
+	go func() {
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		_, err := io.Copy(clientConn, destConn)
+		if err != nil {
+			logger.Debug("stopped copying redis messages to the client", zap.Error(err))
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		_, err := io.Copy(destConn, clientConn)
+		if err != nil {
+			logger.Debug("stopped copying redis commands to the server", zap.Error(err))
+		}
+		done <- struct{}{}
+	}()
+	<-done
+}
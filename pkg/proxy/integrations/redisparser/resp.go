@@ -0,0 +1,295 @@
+package redisparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// errIncomplete is returned by decodeAt when the buffer ends in the middle of a RESP value,
+// so the caller should read more bytes from the connection and try again.
+var errIncomplete = errors.New("incomplete resp message")
+
+var crlf = []byte("\r\n")
+
+var typeByPrefix = map[byte]models.RedisDataType{
+	'+': models.RedisSimpleString,
+	'-': models.RedisError,
+	':': models.RedisInteger,
+	'$': models.RedisBulkString,
+	'*': models.RedisArray,
+	'_': models.RedisNull,
+	',': models.RedisDouble,
+	'#': models.RedisBoolean,
+	'!': models.RedisBulkError,
+	'=': models.RedisVerbatim,
+	'(': models.RedisBigNumber,
+	'%': models.RedisMap,
+	'~': models.RedisSet,
+	'>': models.RedisPush,
+}
+
+var prefixByType = func() map[models.RedisDataType]byte {
+	m := make(map[models.RedisDataType]byte, len(typeByPrefix))
+	for k, v := range typeByPrefix {
+		m[v] = k
+	}
+	return m
+}()
+
+// readLine returns the bytes up to the next CRLF and the offset just after it.
+func readLine(buf []byte, start int) ([]byte, int, error) {
+	idx := bytes.Index(buf[start:], crlf)
+	if idx == -1 {
+		return nil, 0, errIncomplete
+	}
+	return buf[start : start+idx], start + idx + 2, nil
+}
+
+// decodeAt decodes one RESP2/RESP3 value starting at buf[start] and returns it along with the
+// offset just past it. Attribute types (|) are folded into the value that follows them.
+func decodeAt(buf []byte, start int) (models.RedisValue, int, error) {
+	if start >= len(buf) {
+		return models.RedisValue{}, 0, errIncomplete
+	}
+	prefix := buf[start]
+	line, next, err := readLine(buf, start+1)
+	if err != nil {
+		return models.RedisValue{}, 0, err
+	}
+
+	if prefix == '|' {
+		count, err := strconv.Atoi(string(line))
+		if err != nil {
+			return models.RedisValue{}, 0, fmt.Errorf("invalid attribute length %q: %v", line, err)
+		}
+		attrs, next, err := decodeElements(buf, next, count*2)
+		if err != nil {
+			return models.RedisValue{}, 0, err
+		}
+		val, next, err := decodeAt(buf, next)
+		if err != nil {
+			return models.RedisValue{}, 0, err
+		}
+		val.Attributes = attrs
+		return val, next, nil
+	}
+
+	dataType, ok := typeByPrefix[prefix]
+	if !ok {
+		return models.RedisValue{}, 0, fmt.Errorf("unknown resp type prefix %q", prefix)
+	}
+	val := models.RedisValue{Type: dataType}
+
+	switch prefix {
+	case '+', '-', ':', ',', '#', '(':
+		val.Data = string(line)
+	case '_':
+		val.Null = true
+	case '$', '!', '=':
+		size, err := strconv.Atoi(string(line))
+		if err != nil {
+			return models.RedisValue{}, 0, fmt.Errorf("invalid bulk length %q: %v", line, err)
+		}
+		if size < 0 {
+			val.Null = true
+			return val, next, nil
+		}
+		if len(buf) < next+size+2 {
+			return models.RedisValue{}, 0, errIncomplete
+		}
+		val.Data, val.Encoding = encodeData(buf[next : next+size])
+		next += size + 2
+	case '*', '%', '~', '>':
+		count, err := strconv.Atoi(string(line))
+		if err != nil {
+			return models.RedisValue{}, 0, fmt.Errorf("invalid aggregate length %q: %v", line, err)
+		}
+		if count < 0 {
+			val.Null = true
+			return val, next, nil
+		}
+		if prefix == '%' {
+			count *= 2
+		}
+		elements, end, err := decodeElements(buf, next, count)
+		if err != nil {
+			return models.RedisValue{}, 0, err
+		}
+		val.Elements = elements
+		next = end
+	}
+	return val, next, nil
+}
+
+func decodeElements(buf []byte, start, count int) ([]models.RedisValue, int, error) {
+	elements := make([]models.RedisValue, 0, count)
+	next := start
+	for i := 0; i < count; i++ {
+		element, end, err := decodeAt(buf, next)
+		if err != nil {
+			return nil, 0, err
+		}
+		elements = append(elements, element)
+		next = end
+	}
+	return elements, next, nil
+}
+
+// encodeValue serialises a RedisValue back to its RESP wire format.
+func encodeValue(val models.RedisValue) ([]byte, error) {
+	var out bytes.Buffer
+	if len(val.Attributes) > 0 {
+		out.WriteString("|" + strconv.Itoa(len(val.Attributes)/2) + "\r\n")
+		for _, attr := range val.Attributes {
+			b, err := encodeValue(attr)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(b)
+		}
+	}
+
+	prefix, ok := prefixByType[val.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown redis data type %q", val.Type)
+	}
+	out.WriteByte(prefix)
+
+	switch prefix {
+	case '+', '-', ':', ',', '#', '(':
+		out.WriteString(val.Data + "\r\n")
+	case '_':
+		out.WriteString("\r\n")
+	case '$', '!', '=':
+		if val.Null {
+			out.WriteString("-1\r\n")
+			break
+		}
+		data, err := decodeData(val.Data, val.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		out.WriteString(strconv.Itoa(len(data)) + "\r\n")
+		out.Write(data)
+		out.WriteString("\r\n")
+	case '*', '%', '~', '>':
+		if val.Null {
+			out.WriteString("-1\r\n")
+			break
+		}
+		count := len(val.Elements)
+		if prefix == '%' {
+			count /= 2
+		}
+		out.WriteString(strconv.Itoa(count) + "\r\n")
+		for _, element := range val.Elements {
+			b, err := encodeValue(element)
+			if err != nil {
+				return nil, err
+			}
+			out.Write(b)
+		}
+	}
+	return out.Bytes(), nil
+}
+
+// decodeCommand converts a client RESP array of bulk strings into a RedisRequest.
+func decodeCommand(val models.RedisValue) (models.RedisRequest, error) {
+	if val.Type != models.RedisArray || len(val.Elements) == 0 {
+		return models.RedisRequest{}, fmt.Errorf("redis command is not a non-empty array, got %s", val.Type)
+	}
+	parts := make([]string, 0, len(val.Elements))
+	binary := false
+	for _, element := range val.Elements {
+		data, err := decodeData(element.Data, element.Encoding)
+		if err != nil {
+			return models.RedisRequest{}, err
+		}
+		if !isPrintable(data) {
+			binary = true
+		}
+		parts = append(parts, string(data))
+	}
+
+	req := models.RedisRequest{Command: strings.ToUpper(parts[0])}
+	args := parts[1:]
+	if _, ok := containerCommands[req.Command]; ok && len(args) > 0 {
+		req.Command += " " + strings.ToUpper(args[0])
+		args = args[1:]
+	}
+	req.Key = commandKey(req.Command, args)
+
+	if binary {
+		req.Encoding = "base64"
+		for i, arg := range args {
+			args[i] = base64.StdEncoding.EncodeToString([]byte(arg))
+		}
+	}
+	if len(args) > 0 {
+		req.Args = args
+	}
+	return req, nil
+}
+
+// containerCommands take a subcommand as their first argument, e.g. CLIENT SETNAME.
+var containerCommands = map[string]struct{}{
+	"ACL": {}, "CLIENT": {}, "CLUSTER": {}, "COMMAND": {}, "CONFIG": {}, "FUNCTION": {},
+	"MEMORY": {}, "MODULE": {}, "OBJECT": {}, "PUBSUB": {}, "SCRIPT": {}, "XGROUP": {}, "XINFO": {},
+}
+
+// keylessCommands do not operate on a key, so their first argument is not treated as one.
+var keylessCommands = map[string]struct{}{
+	"AUTH": {}, "DBSIZE": {}, "DISCARD": {}, "ECHO": {}, "EXEC": {}, "FLUSHALL": {}, "FLUSHDB": {},
+	"HELLO": {}, "INFO": {}, "MULTI": {}, "PING": {}, "PUBLISH": {}, "QUIT": {}, "RANDOMKEY": {},
+	"READONLY": {}, "RESET": {}, "SCAN": {}, "SELECT": {}, "SUBSCRIBE": {}, "PSUBSCRIBE": {},
+	"SSUBSCRIBE": {}, "UNSUBSCRIBE": {}, "PUNSUBSCRIBE": {}, "SUNSUBSCRIBE": {}, "TIME": {},
+	"UNWATCH": {}, "WAIT": {},
+}
+
+func commandKey(command string, args []string) string {
+	if len(args) == 0 || strings.Contains(command, " ") {
+		return ""
+	}
+	if _, ok := keylessCommands[command]; ok {
+		return ""
+	}
+	switch command {
+	case "EVAL", "EVALSHA", "EVAL_RO", "EVALSHA_RO", "FCALL", "FCALL_RO":
+		// EVAL script numkeys key [key ...] arg [arg ...]
+		if len(args) > 2 && args[1] != "0" {
+			return args[2]
+		}
+		return ""
+	}
+	return args[0]
+}
+
+func encodeData(data []byte) (string, string) {
+	if isPrintable(data) {
+		return string(data), ""
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+func decodeData(data, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(data)
+	}
+	return []byte(data), nil
+}
+
+func isPrintable(data []byte) bool {
+	for _, r := range string(data) {
+		if r == unicode.ReplacementChar || (!unicode.IsPrint(r) && !unicode.IsSpace(r)) {
+			return false
+		}
+	}
+	return true
+}
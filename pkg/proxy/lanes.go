@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+)
+
+// lane is one instance of the user application replaying a test-set. Its outgoing calls are handled
+// by parsers bound to its own hook, so the instances running in parallel don't share mocks.
+type lane struct {
+	hook    *hooks.Hook
+	parsers map[string]DependencyHandler
+}
+
+// AddLane routes the outgoing calls of the application launched by h to the mocks of h.
+func (ps *ProxySet) AddLane(h *hooks.Hook) {
+	ps.lanesMutex.Lock()
+	defer ps.lanesMutex.Unlock()
+	ps.lanes = append(ps.lanes, &lane{
+		hook:    h,
+		parsers: newParsers(ps.logger, h, ps.MongoPassword, ps.delay),
+	})
+}
+
+// RemoveLane stops routing the outgoing calls to the mocks of h.
+func (ps *ProxySet) RemoveLane(h *hooks.Hook) {
+	ps.lanesMutex.Lock()
+	defer ps.lanesMutex.Unlock()
+	for i, l := range ps.lanes {
+		if l.hook == h {
+			ps.lanes = append(ps.lanes[:i], ps.lanes[i+1:]...)
+			return
+		}
+	}
+}
+
+// route returns the hook and the parsers for an outgoing call of the process with the kernel pid.
+// The calls use the hook the proxy was booted with when no lane is running. ok is false when lanes
+// are running but none of them owns the process: the mocks of another test-set must not serve it.
+func (ps *ProxySet) route(pid uint32) (*hooks.Hook, map[string]DependencyHandler, bool) {
+	ps.lanesMutex.Lock()
+	defer ps.lanesMutex.Unlock()
+	if len(ps.lanes) == 0 {
+		return ps.hook, ParsersMap, true
+	}
+	for _, l := range ps.lanes {
+		if l.hook.OwnsPid(pid) {
+			return l.hook, l.parsers, true
+		}
+	}
+	return nil, nil, false
+}
+
+// recordUnroutedCall records a call which no lane owns as unmatched in every running lane, as any
+// of their test-sets may have made it.
+func (ps *ProxySet) recordUnroutedCall(pid uint32, address string) {
+	ps.lanesMutex.Lock()
+	defer ps.lanesMutex.Unlock()
+	for _, l := range ps.lanes {
+		l.hook.RecordUnmatchedCall(models.GENERIC, fmt.Sprintf("call of the process %d to %s", pid, address))
+	}
+}
+
+// udpOwner returns the process owning the udp socket bound to the local port. The inode of the
+// socket is read from /proc/net/udp{,6}, then looked up in the file descriptors of the processes.
+func udpOwner(port int) (uint32, error) {
+	inode := ""
+	for _, table := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		data, err := os.ReadFile(table)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n")[1:] {
+			fields := strings.Fields(line)
+			if len(fields) < 10 {
+				continue
+			}
+			local := strings.Split(fields[1], ":")
+			if p, err := strconv.ParseUint(local[len(local)-1], 16, 16); err == nil && int(p) == port {
+				inode = fields[9]
+				break
+			}
+		}
+		if inode != "" {
+			break
+		}
+	}
+	if inode == "" || inode == "0" {
+		return 0, fmt.Errorf("no udp socket is bound to port %d", port)
+	}
+
+	fds, err := filepath.Glob("/proc/[0-9]*/fd/*")
+	if err != nil {
+		return 0, err
+	}
+	socket := "socket:[" + inode + "]"
+	for _, fd := range fds {
+		if target, err := os.Readlink(fd); err == nil && target == socket {
+			pid, err := strconv.Atoi(strings.Split(fd, "/")[2])
+			if err != nil {
+				return 0, err
+			}
+			return uint32(pid), nil
+		}
+	}
+	return 0, fmt.Errorf("no process owns the udp socket bound to port %d", port)
+}
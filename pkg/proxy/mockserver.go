@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+// StartMockServer starts the proxy without the eBPF hooks, for the environments where keploy can't load them.
+// The application sends its HTTP and HTTPS calls to the forward proxy listening on opt.Port, through HTTP_PROXY
+// and HTTPS_PROXY, and connects to the listeners of its other dependencies. The calls are served by the same
+// parsers and mocks as the redirected ones.
+func StartMockServer(logger *zap.Logger, opt Option, listeners []models.DependencyListener, h *hooks.Hook, ctx context.Context) (*ProxySet, error) {
+	for name, parser := range newParsers(logger, h, opt.MongoPassword, 0) {
+		Register(name, parser)
+	}
+	httpparser.SetMatchConfig(opt.MockMatching)
+	postgresparser.SetMatchConfig(opt.MockMatching.Postgres)
+	caCert, caKey, err := LoadCA()
+	if err != nil {
+		logger.Error("failed to load the CA", zap.Error(err))
+		return nil, err
+	}
+	certs, err := newCertCache(logger, caCert, caKey, DefaultCertCacheSize)
+	if err != nil {
+		logger.Error("failed to load the CA", zap.Error(err))
+		return nil, err
+	}
+
+	listener := opt.Listener
+	if listener != nil {
+		opt.Port = uint32(listener.Addr().(*net.TCPAddr).Port)
+	} else if opt.Port == 0 {
+		opt.Port = 16789
+	}
+	ps := &ProxySet{
+		Port:              opt.Port,
+		logger:            logger,
+		clientConnections: []net.Conn{},
+		connMutex:         &sync.Mutex{},
+		hook:              h,
+		MongoPassword:     opt.MongoPassword,
+		lanesMutex:        &sync.Mutex{},
+		ctx:               ctx,
+		DnsServerTimeout:  opt.DnsTimeout,
+		dnsRecorded:       map[string]bool{},
+		dnsMutex:          &sync.Mutex{},
+		certs:             certs,
+		passThrough:       compilePassThroughRules(opt.PassThrough, logger),
+		protocolPorts:     withDefaultPorts(opt.ProtocolPorts),
+	}
+	h.SetProxyPort(opt.Port)
+
+	if listener == nil {
+		listener, err = net.Listen("tcp", fmt.Sprintf(":%v", opt.Port))
+		if err != nil {
+			logger.Error("failed to start the forward proxy", zap.Error(err), zap.Any("port", opt.Port))
+			return nil, err
+		}
+	}
+	ps.Listener = listener
+	go func() {
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		ps.accept(listener, func(conn net.Conn) {
+			ps.serveForwardProxy(conn, ctx)
+		})
+	}()
+
+	for _, dependency := range listeners {
+		dependencyListener, err := net.Listen("tcp", dependency.Listen)
+		if err != nil {
+			logger.Error("failed to listen for the dependency", zap.Error(err), zap.String("listen", dependency.Listen), zap.String("target", dependency.Target))
+			ps.StopProxyServer()
+			return nil, err
+		}
+		ps.listeners = append(ps.listeners, dependencyListener)
+		dest := resolveDestination(dependency.Target)
+		go func() {
+			defer h.Recover(pkg.GenerateRandomID())
+			defer utils.HandlePanic()
+			ps.accept(dependencyListener, func(conn net.Conn) {
+				ps.forward(conn, dest, ps.Port, ps.hook, ParsersMap, ctx)
+			})
+		}()
+		logger.Info(fmt.Sprintf("Serving %v on %v", dependency.Target, dependencyListener.Addr()))
+	}
+
+	proxyURL := fmt.Sprintf("http://localhost:%v", opt.Port)
+	logger.Info(fmt.Sprintf("Proxy started at port:%v, point the application at it with HTTP_PROXY=%v HTTPS_PROXY=%v", opt.Port, proxyURL, proxyURL))
+	if caPath, err := ExtractCertToTemp(caCert); err != nil {
+		logger.Error("failed to write the CA certificate", zap.Error(err))
+	} else {
+		logger.Info(fmt.Sprintf("The HTTPS calls are signed by the keploy CA, make the application trust %v e.g. with SSL_CERT_FILE, NODE_EXTRA_CA_CERTS or REQUESTS_CA_BUNDLE", caPath))
+	}
+	return ps, nil
+}
+
+// serveForwardProxy serves a connection opened by the application to the forward proxy. A CONNECT request opens
+// a tunnel to its destination, the calls made over the tunnel are then served like the redirected ones. The
+// other requests carry the absolute URL of their destination, a client may send requests to several
+// destinations over the same connection, so every request is served on its own with its destination.
+func (ps *ProxySet) serveForwardProxy(conn net.Conn, ctx context.Context) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	requestLine, err := peekLine(reader)
+	if err != nil {
+		if err != io.EOF {
+			ps.logger.Error("failed to read the request of the forward proxy", zap.Error(err))
+		}
+		return
+	}
+	fields := strings.Fields(requestLine)
+	if len(fields) != 3 {
+		ps.logger.Error("failed to parse the request line of the forward proxy", zap.String("request line", requestLine))
+		return
+	}
+
+	if fields[0] != http.MethodConnect {
+		ps.serveProxiedRequests(conn, reader, ctx)
+		return
+	}
+	// the request of the tunnel is answered by the proxy, only the calls made over it are served
+	if _, err := http.ReadRequest(reader); err != nil {
+		ps.logger.Error("failed to read the CONNECT request", zap.Error(err))
+		return
+	}
+	if _, err := conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		ps.logger.Error("failed to accept the CONNECT request", zap.Error(err))
+		return
+	}
+	dest := resolveDestination(fields[1])
+	if dest.port == 0 {
+		ps.logger.Error("failed to read the destination of the forward proxy request", zap.String("address", fields[1]))
+		return
+	}
+	ps.forward(&CustomConn{Conn: conn, r: reader, logger: ps.logger}, dest, ps.Port, ps.hook, ParsersMap, ctx)
+}
+
+// serveProxiedRequests serves the requests with an absolute URL sent over a connection of the forward proxy, one
+// after the other.
+func (ps *ProxySet) serveProxiedRequests(conn net.Conn, reader *bufio.Reader, ctx context.Context) {
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			if err != io.EOF {
+				ps.logger.Error("failed to read the request of the forward proxy", zap.Error(err))
+			}
+			return
+		}
+		if !req.URL.IsAbs() {
+			ps.logger.Error("the forward proxy only serves requests with an absolute URL, set HTTP_PROXY in the application", zap.String("url", req.RequestURI))
+			_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+			return
+		}
+		address := req.URL.Host
+		if req.URL.Port() == "" {
+			port := "80"
+			if req.URL.Scheme == "https" {
+				port = "443"
+			}
+			address = net.JoinHostPort(req.URL.Hostname(), port)
+		}
+		dest := resolveDestination(address)
+		if dest.port == 0 {
+			ps.logger.Error("failed to read the destination of the forward proxy request", zap.String("address", address))
+			return
+		}
+		// the request is sent to the parsers in origin form, as the application would send it to the server
+		var request bytes.Buffer
+		if err := req.Write(&request); err != nil {
+			ps.logger.Error("failed to read the body of the forward proxy request", zap.Error(err))
+			return
+		}
+		ps.forward(&proxiedRequestConn{Conn: conn, request: &request, next: reader}, dest, ps.Port, ps.hook, ParsersMap, ctx)
+	}
+}
+
+// proxiedRequestConn hands a single request of a forward proxy connection to the parsers. The responses are
+// written to the connection of the application, which is kept open for its next requests.
+type proxiedRequestConn struct {
+	net.Conn
+	request io.Reader
+	next    *bufio.Reader
+}
+
+// errRequestServed ends the reads of a request of the forward proxy. It wraps io.EOF for the readers which
+// wait for more data on a plain io.EOF.
+var errRequestServed = fmt.Errorf("the request of the forward proxy is served: %w", io.EOF)
+
+// Read returns the request, then ends once the application sends its next request or closes the connection:
+// the parsers and the relays keep answering the request until then.
+func (c *proxiedRequestConn) Read(p []byte) (int, error) {
+	n, err := c.request.Read(p)
+	if err != io.EOF || n > 0 {
+		return n, err
+	}
+	if _, err := c.next.Peek(1); err != nil && err != io.EOF {
+		return 0, err
+	}
+	return 0, errRequestServed
+}
+
+func (c *proxiedRequestConn) Close() error { return nil }
+
+// peekLine returns the first line of the reader without consuming it.
+func peekLine(reader *bufio.Reader) (string, error) {
+	for {
+		// waits for more data than the reader holds, then looks at all of it
+		if _, err := reader.Peek(reader.Buffered() + 1); err != nil {
+			return "", err
+		}
+		buffered, _ := reader.Peek(reader.Buffered())
+		if i := bytes.IndexByte(buffered, '\n'); i != -1 {
+			return strings.TrimRight(string(buffered[:i]), "\r"), nil
+		}
+	}
+}
+
+// resolveDestination returns the destination of the host:port address. The port is zero when the address is
+// invalid, the ip is nil when the host can't be resolved.
+func resolveDestination(address string) destination {
+	dest := destination{address: address}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return dest
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return dest
+	}
+	dest.port = uint32(portNum)
+	if dest.ip = net.ParseIP(host); dest.ip == nil {
+		if ips, err := net.LookupIP(host); err == nil && len(ips) > 0 {
+			dest.ip = ips[0]
+		}
+	}
+	return dest
+}
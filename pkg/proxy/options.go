@@ -1,7 +1,24 @@
 package proxy
 
+import (
+	"net"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
 // Option provides a means to initiate the proxy based on user input.
 type Option struct {
 	Port          uint32
 	MongoPassword string
+	// DnsTimeout is the time given to the upstream servers to resolve a dns question, DefaultDnsTimeout is used when it is zero
+	DnsTimeout time.Duration
+	// PassThrough are the rules of the outgoing calls sent to their destination without being recorded or mocked
+	PassThrough []models.PassThroughRule
+	// MockMatching is how the HTTP mocks are scored against the outgoing calls during a test
+	MockMatching models.MockMatching
+	// ProtocolPorts are the protocols of the destination ports, 3306 is handled by mysql unless it is mapped
+	ProtocolPorts models.ProtocolPorts
+	// Listener is the listener of the forward proxy started by StartMockServer, one is opened on Port when it is nil
+	Listener net.Listener
 }
@@ -0,0 +1,240 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/hooks/structs"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+// passThroughRule is a pass-through rule of the config with its destination address parsed.
+type passThroughRule struct {
+	models.PassThroughRule
+	name    string
+	ip      net.IP
+	network *net.IPNet
+}
+
+// passThroughTarget holds what is known of an outgoing call when the rules are checked. The fields which are
+// not known yet are empty, the rules matching on them are checked again once the call has been read.
+type passThroughTarget struct {
+	ip        net.IP
+	sni       string
+	host      string
+	path      string
+	protocols map[string]bool // the parsers claiming the traffic, "generic" when none of them does
+}
+
+// compilePassThroughRules parses the pass-through rules of the config. The hosts of models.PassThroughHosts
+// are always passed through, the rules with an invalid address are skipped.
+func compilePassThroughRules(rules []models.PassThroughRule, logger *zap.Logger) []passThroughRule {
+	var compiled []passThroughRule
+	for _, host := range models.PassThroughHosts {
+		compiled = append(compiled, passThroughRule{PassThroughRule: models.PassThroughRule{Host: host}, name: host})
+	}
+	for _, rule := range rules {
+		r := passThroughRule{PassThroughRule: rule, name: rule.Name}
+		if r.name == "" {
+			r.name = describePassThroughRule(rule)
+		}
+		if rule.IP != "" {
+			if ip := net.ParseIP(rule.IP); ip != nil {
+				r.ip = ip
+			} else if _, network, err := net.ParseCIDR(rule.IP); err == nil {
+				r.network = network
+			} else {
+				logger.Error("skipping the pass-through rule with an invalid ip", zap.String("rule", r.name), zap.String("ip", rule.IP))
+				continue
+			}
+		}
+		compiled = append(compiled, r)
+	}
+	return compiled
+}
+
+// describePassThroughRule names a rule by the fields it matches on.
+func describePassThroughRule(rule models.PassThroughRule) string {
+	var fields []string
+	for _, field := range [][2]string{{"ip", rule.IP}, {"host", rule.Host}, {"path", rule.Path}, {"protocol", rule.Protocol}} {
+		if field[1] != "" {
+			fields = append(fields, field[0]+"="+field[1])
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// match reports whether all the fields set in the rule match the call.
+func (r passThroughRule) match(target passThroughTarget) bool {
+	if r.ip != nil && !r.ip.Equal(target.ip) {
+		return false
+	}
+	if r.network != nil && (target.ip == nil || !r.network.Contains(target.ip)) {
+		return false
+	}
+	if r.Host != "" && !matchHost(r.Host, target.sni) && !matchHost(r.Host, target.host) {
+		return false
+	}
+	if r.Path != "" && (target.path == "" || !strings.HasPrefix(target.path, r.Path)) {
+		return false
+	}
+	if r.Protocol != "" && !target.protocols[r.Protocol] {
+		return false
+	}
+	return true
+}
+
+// matchHost matches the host, with or without its port, with the host of a rule. A leading "*." in the
+// rule matches any subdomain.
+func matchHost(pattern, host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	if host == "" {
+		return false
+	}
+	if domain, ok := strings.CutPrefix(pattern, "*."); ok {
+		return len(host) > len(domain) && strings.HasSuffix(strings.ToLower(host), "."+strings.ToLower(domain))
+	}
+	return strings.EqualFold(pattern, host)
+}
+
+// passThroughRule returns the name of the first rule which passes the call through.
+func (ps *ProxySet) passThroughRule(target passThroughTarget) (string, bool) {
+	for _, rule := range ps.passThrough {
+		if rule.match(target) {
+			return rule.name, true
+		}
+	}
+	return "", false
+}
+
+// destinationIP returns the address of the destination of the call.
+func destinationIP(destInfo *structs.DestInfo) net.IP {
+	if destInfo.IpVersion == 6 {
+		return net.ParseIP(util.ToIPv6AddressStr(destInfo.DestIp6))
+	}
+	return net.ParseIP(util.ToIP4AddressStr(destInfo.DestIp4))
+}
+
+// readProtocols returns the parsers claiming the first buffer of the call along with the host and the path
+// of its HTTP request.
+func readProtocols(buffer []byte, parsers map[string]DependencyHandler, target *passThroughTarget) {
+	target.protocols = map[string]bool{}
+	for name, parser := range parsers {
+		if parser.OutgoingType(buffer) {
+			target.protocols[name] = true
+		}
+	}
+	if len(target.protocols) == 0 {
+		target.protocols["generic"] = true
+	}
+	if !target.protocols["http"] {
+		return
+	}
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(buffer)))
+	if err != nil {
+		return
+	}
+	target.host = req.Host
+	target.path = req.URL.Path
+}
+
+var errHelloRead = errors.New("client hello read")
+
+// tlsRecordSize is the largest plaintext TLS record, with its header. The reader of a connection is that large,
+// so that it holds the whole ClientHello when peeking at its server name.
+const tlsRecordSize = 5 + 1<<14
+
+// passThroughDialTimeout is how long the destination of a call passed through is waited for.
+const passThroughDialTimeout = 10 * time.Second
+
+// peekServerName returns the SNI of the TLS ClientHello at the start of the reader, without consuming it.
+// An empty name is returned when the hello does not fit into the buffer of the reader.
+func peekServerName(reader *bufio.Reader, logger *zap.Logger) string {
+	header, err := reader.Peek(5)
+	if err != nil {
+		return ""
+	}
+	record, err := reader.Peek(5 + (int(header[3])<<8 | int(header[4])))
+	if err != nil {
+		logger.Warn("failed to read the server name of the TLS ClientHello, the rules of the server names don't apply to the call", zap.Error(err), zap.Int("hello size", 5+(int(header[3])<<8|int(header[4]))), zap.Int("buffer size", reader.Size()))
+		return ""
+	}
+	var serverName string
+	_ = tls.Server(&helloConn{r: bytes.NewReader(record)}, &tls.Config{
+		GetConfigForClient: func(clientHello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = clientHello.ServerName
+			return nil, errHelloRead
+		},
+	}).Handshake()
+	return serverName
+}
+
+// helloConn hands a peeked ClientHello to the TLS server reading its server name, nothing is written back.
+type helloConn struct {
+	r io.Reader
+}
+
+func (c *helloConn) Read(p []byte) (int, error)         { return c.r.Read(p) }
+func (c *helloConn) Write(p []byte) (int, error)        { return 0, errHelloRead }
+func (c *helloConn) Close() error                       { return nil }
+func (c *helloConn) LocalAddr() net.Addr                { return &net.TCPAddr{} }
+func (c *helloConn) RemoteAddr() net.Addr               { return &net.TCPAddr{} }
+func (c *helloConn) SetDeadline(t time.Time) error      { return nil }
+func (c *helloConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *helloConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// passThroughCall sends the call to its destination without recording or mocking it and counts it for the
+// rule. The plain connection to the destination is dialed when dst is nil.
+func (ps *ProxySet) passThroughCall(buffer []byte, conn, dst net.Conn, actualAddress string, h *hooks.Hook, rule string, logger *zap.Logger) {
+	if dst == nil {
+		var err error
+		dst, err = net.DialTimeout("tcp", actualAddress, passThroughDialTimeout)
+		if err != nil {
+			logger.Error("failed to dial the connection to destination server", zap.Error(err), zap.Any("server address", actualAddress))
+			return
+		}
+	}
+	logger.Debug("passing the outgoing call through to its destination", zap.String("rule", rule), zap.Any("server address", actualAddress))
+	h.RecordPassThrough(rule)
+	if err := ps.relay(buffer, conn, dst, logger); err != nil {
+		logger.Error("failed to pass through the outgoing call", zap.Error(err), zap.String("rule", rule))
+	}
+}
+
+// relay sends the call to its destination untouched and copies the traffic of the connection both ways
+// until one of the sides closes it.
+func (ps *ProxySet) relay(buffer []byte, conn, dst net.Conn, logger *zap.Logger) error {
+	defer dst.Close()
+	if len(buffer) > 0 {
+		if _, err := dst.Write(buffer); err != nil {
+			logger.Error("failed to write the request message to the destination server", zap.Error(err))
+			return err
+		}
+	}
+	done := make(chan struct{}, 2)
+	go func() {
+		defer utils.HandlePanic()
+		_, _ = io.Copy(dst, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		defer utils.HandlePanic()
+		_, _ = io.Copy(conn, dst)
+		done <- struct{}{}
+	}()
+	<-done
+	return nil
+}
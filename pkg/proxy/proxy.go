@@ -4,10 +4,9 @@ import (
 	"bufio"
 	"bytes"
 	"context"
-	"crypto"
 	"crypto/tls"
-	"crypto/x509"
 	"embed"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -26,13 +25,6 @@ import (
 	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.keploy.io/server/utils"
 
-	"github.com/cloudflare/cfssl/csr"
-	cfsslLog "github.com/cloudflare/cfssl/log"
-
-	"github.com/cloudflare/cfssl/helpers"
-	"github.com/cloudflare/cfssl/signer"
-	"github.com/cloudflare/cfssl/signer/local"
-
 	"time"
 
 	"github.com/miekg/dns"
@@ -40,8 +32,10 @@ import (
 	"go.keploy.io/server/pkg/models"
 	genericparser "go.keploy.io/server/pkg/proxy/integrations/genericParser"
 	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
+	"go.keploy.io/server/pkg/proxy/integrations/kafkaparser"
 	"go.keploy.io/server/pkg/proxy/integrations/mongoparser"
 	"go.keploy.io/server/pkg/proxy/integrations/mysqlparser"
+	"go.keploy.io/server/pkg/proxy/integrations/redisparser"
 	"go.keploy.io/server/pkg/proxy/util"
 	"go.uber.org/zap"
 )
@@ -77,6 +71,16 @@ type ProxySet struct {
 	dockerAppCmd      bool
 	PassThroughPorts  []uint
 	MongoPassword     string // password to mock the mongo connection and pass the authentication requests
+	delay             uint64
+	lanes             []*lane // instances of the user application replaying test-sets in parallel
+	lanesMutex        *sync.Mutex
+	ctx               context.Context // carries the counters of the recorded mocks
+	dnsRecorded       map[string]bool // dns questions already stored as mocks
+	dnsMutex          *sync.Mutex
+	certs             *certCache           // certificates served to the application, keyed by server name
+	passThrough       []passThroughRule    // outgoing calls sent to their destination without being recorded or mocked
+	listeners         []net.Listener       // listeners of the dependencies, when the proxy runs without eBPF
+	protocolPorts     models.ProtocolPorts // parsers of the destination ports whose protocol can't be read from the calls
 }
 
 type CustomConn struct {
@@ -160,7 +164,7 @@ func isJavaInstalled() bool {
 }
 
 // to extract ca certificate to temp
-func ExtractCertToTemp() (string, error) {
+func ExtractCertToTemp(caCert []byte) (string, error) {
 	tempFile, err := ioutil.TempFile("", "ca.crt")
 	if err != nil {
 		return "", err
@@ -174,7 +178,7 @@ func ExtractCertToTemp() (string, error) {
 	}
 
 	// Write to the file
-	_, err = tempFile.Write(caCrt)
+	_, err = tempFile.Write(caCert)
 	if err != nil {
 		return "", err
 	}
@@ -196,6 +200,21 @@ func JavaCAExists(alias, storepass, cacertsPath string) bool {
 	return err == nil
 }
 
+// javaCAMatches reports whether the certificate stored under alias in the java keystore is the one of caPath.
+func javaCAMatches(alias, storepass, cacertsPath, caPath string) bool {
+	stored, err := exec.Command("keytool", "-exportcert", "-rfc", "-keystore", cacertsPath, "-storepass", storepass, "-alias", alias).Output()
+	if err != nil {
+		return false
+	}
+	current, err := os.ReadFile(caPath)
+	if err != nil {
+		return false
+	}
+	storedBlock, _ := pem.Decode(stored)
+	currentBlock, _ := pem.Decode(current)
+	return storedBlock != nil && currentBlock != nil && bytes.Equal(storedBlock.Bytes, currentBlock.Bytes)
+}
+
 // get jdk path from application pid using proc file system in case of running application via IDE's
 func getJavaHomeFromPID(pid string) (string, error) {
 	cmdlinePath := fmt.Sprintf("/proc/%s/cmdline", pid)
@@ -275,8 +294,18 @@ func InstallJavaCA(logger *zap.Logger, caPath string, pid uint32, isJavaServe bo
 		logger.Debug("", zap.Any("java_home", javaHome), zap.Any("caCertsPath", cacertsPath), zap.Any("caPath", caPath))
 
 		if JavaCAExists(alias, storePass, cacertsPath) {
-			logger.Info("Java detected and CA already exists", zap.String("path", cacertsPath))
-			return
+			if javaCAMatches(alias, storePass, cacertsPath, caPath) {
+				logger.Info("Java detected and CA already exists", zap.String("path", cacertsPath))
+				return
+			}
+			// the CA was rotated or replaced since it was imported
+			cmd := exec.Command("keytool", "-delete", "-keystore", cacertsPath, "-storepass", storePass, "-noprompt", "-alias", alias)
+			cmdOutput, err := cmd.CombinedOutput()
+			if err != nil {
+				logger.Error("Java detected but failed to remove the previous CA", zap.Error(err), zap.String("output", string(cmdOutput)))
+				return
+			}
+			logger.Debug("removed the previous CA from the java keystore", zap.String("path", cacertsPath))
 		}
 
 		cmd := exec.Command("keytool", "-import", "-trustcacerts", "-keystore", cacertsPath, "-storepass", storePass, "-noprompt", "-alias", alias, "-file", caPath)
@@ -309,14 +338,39 @@ func Register(parserName string, parser DependencyHandler) {
 	ParsersMap[parserName] = parser
 }
 
+// newParsers returns the parsers of every supported dependency, reading and writing the mocks of h.
+func newParsers(logger *zap.Logger, h *hooks.Hook, mongoPassword string, delay uint64) map[string]DependencyHandler {
+	return map[string]DependencyHandler{
+		"grpc":     grpcparser.NewGrpcParser(logger, h),
+		"postgres": postgresparser.NewPostgresParser(logger, h),
+		"mongo":    mongoparser.NewMongoParser(logger, h, mongoPassword),
+		"http":     httpparser.NewHttpParser(logger, h),
+		"mysql":    mysqlparser.NewMySqlParser(logger, h, delay),
+		"redis":    redisparser.NewRedisParser(logger, h),
+		"kafka":    kafkaparser.NewKafkaParser(logger, h),
+	}
+}
+
 // BootProxy starts proxy server on the idle local port, Default:16789
 func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid uint32, lang string, passThroughPorts []uint, h *hooks.Hook, ctx context.Context, delay uint64) *ProxySet {
 	//Register all the parsers in the map.
-	Register("grpc", grpcparser.NewGrpcParser(logger, h))
-	Register("postgres", postgresparser.NewPostgresParser(logger, h))
-	Register("mongo", mongoparser.NewMongoParser(logger, h, opt.MongoPassword))
-	Register("http", httpparser.NewHttpParser(logger, h))
-	Register("mysql", mysqlparser.NewMySqlParser(logger, h, delay))
+	for name, parser := range newParsers(logger, h, opt.MongoPassword, delay) {
+		Register(name, parser)
+	}
+	httpparser.SetMatchConfig(opt.MockMatching)
+	postgresparser.SetMatchConfig(opt.MockMatching.Postgres)
+	// the CA managed by `keploy cert`, or the bundled one
+	caCert, caKey, err := LoadCA()
+	if err != nil {
+		logger.Error("failed to load the CA", zap.Error(err))
+		return nil
+	}
+	certs, err := newCertCache(logger, caCert, caKey, DefaultCertCacheSize)
+	if err != nil {
+		logger.Error("failed to load the CA", zap.Error(err))
+		return nil
+	}
+
 	// assign default values if not provided
 	caPaths, err := getCaPaths()
 	if err != nil {
@@ -332,7 +386,7 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 			return nil
 		}
 
-		_, err = fs.Write(caCrt)
+		_, err = fs.Write(caCert)
 		if err != nil {
 			logger.Error("failed to write custom ca certificate", zap.Error(err), zap.Any("root store path", path))
 			return nil
@@ -352,21 +406,13 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 		logger.Error("Failed to update the CA store", zap.Error(err))
 	}
 
-	tempCertPath, err := ExtractCertToTemp()
+	tempCertPath, err := ExtractCertToTemp(caCert)
 	if err != nil {
 		logger.Error(Emoji+"Failed to extract certificate to tmp folder: %v", zap.Any("failed to extract certificate", err))
-	}
-
-	// for node
-	err = os.Setenv("NODE_EXTRA_CA_CERTS", tempCertPath)
-	if err != nil {
-		logger.Error(Emoji+"Failed to set environment variable NODE_EXTRA_CA_CERTS: %v", zap.Any("failed to certificate path in environment", err))
-	}
-
-	// for python
-	err = os.Setenv("REQUESTS_CA_BUNDLE", tempCertPath)
-	if err != nil {
-		logger.Error(Emoji+"Failed to set environment variable REQUESTS_CA_BUNDLE: %v", zap.Any("failed to certificate path in environment", err))
+	} else {
+		// node and python do not read the system store
+		InstallNodeCA(logger, tempCertPath)
+		InstallPythonCA(logger, tempCertPath)
 	}
 
 	if opt.Port == 0 {
@@ -420,6 +466,18 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 		PassThroughPorts:  passThroughPorts,
 		hook:              h,
 		MongoPassword:     opt.MongoPassword,
+		delay:             delay,
+		lanesMutex:        &sync.Mutex{},
+		ctx:               ctx,
+		DnsServerTimeout:  opt.DnsTimeout,
+		dnsRecorded:       map[string]bool{},
+		dnsMutex:          &sync.Mutex{},
+		certs:             certs,
+		passThrough:       compilePassThroughRules(opt.PassThrough, logger),
+		protocolPorts:     withDefaultPorts(opt.ProtocolPorts),
+	}
+	if proxySet.DnsServerTimeout <= 0 {
+		proxySet.DnsServerTimeout = DefaultDnsTimeout
 	}
 
 	//setting the proxy port field in hook
@@ -431,10 +489,12 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 			defer utils.HandlePanic()
 			proxySet.startProxy(ctx)
 		}()
-		// Resolve DNS queries only in case of test mode.
-		if models.GetMode() == models.MODE_TEST {
-			proxySet.logger.Debug("Running Dns Server in Test mode...")
-			proxySet.logger.Info("Keploy has hijacked the DNS resolution mechanism, your application may misbehave in keploy test mode if you have provided wrong domain name in your application code.")
+		// DNS answers are recorded in record mode and served from the mocks in test mode.
+		if mode := models.GetMode(); mode == models.MODE_TEST || mode == models.MODE_RECORD {
+			proxySet.logger.Debug("Running Dns Server...", zap.Any("mode", mode))
+			if mode == models.MODE_TEST {
+				proxySet.logger.Info("Keploy has hijacked the DNS resolution mechanism, your application may misbehave in keploy test mode if you have provided wrong domain name in your application code.")
+			}
 			go func() {
 				defer h.Recover(pkg.GenerateRandomID())
 				defer utils.HandlePanic()
@@ -481,63 +541,6 @@ var caStoreUpdateCmd = []string{
 	"certctl rehash",
 }
 
-type certKeyPair struct {
-	cert tls.Certificate
-	host string
-}
-
-var (
-	caPrivKey      interface{}
-	caCertParsed   *x509.Certificate
-	destinationUrl string
-)
-
-func certForClient(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
-	// Generate a new server certificate and private key for the given hostname
-	destinationUrl = clientHello.ServerName
-
-	cfsslLog.Level = cfsslLog.LevelError
-
-	serverReq := &csr.CertificateRequest{
-		//Make the name accordng to the ip of the request
-		CN: clientHello.ServerName,
-		Hosts: []string{
-			clientHello.ServerName,
-		},
-		KeyRequest: csr.NewKeyRequest(),
-	}
-
-	serverCsr, serverKey, err := csr.ParseRequest(serverReq)
-	if err != nil {
-		return nil, fmt.Errorf(Emoji+"failed to create server CSR: %v", err)
-	}
-	cryptoSigner, ok := caPrivKey.(crypto.Signer)
-	if !ok {
-		log.Printf(Emoji, "Error in typecasting the caPrivKey")
-	}
-	signerd, err := local.NewSigner(cryptoSigner, caCertParsed, signer.DefaultSigAlgo(cryptoSigner), nil)
-	if err != nil {
-		return nil, fmt.Errorf(Emoji+"failed to create signer: %v", err)
-	}
-
-	serverCert, err := signerd.Sign(signer.SignRequest{
-		Hosts:   serverReq.Hosts,
-		Request: string(serverCsr),
-		Profile: "web",
-	})
-	if err != nil {
-		return nil, fmt.Errorf(Emoji+"failed to sign server certificate: %v", err)
-	}
-
-	// Load the server certificate and private key
-	serverTlsCert, err := tls.X509KeyPair(serverCert, serverKey)
-	if err != nil {
-		return nil, fmt.Errorf(Emoji+"failed to load server certificate and key: %v", err)
-	}
-
-	return &serverTlsCert, nil
-}
-
 // startProxy function initiates a proxy on the specified port to handle redirected outgoing network calls.
 func (ps *ProxySet) startProxy(ctx context.Context) {
 
@@ -565,7 +568,13 @@ func (ps *ProxySet) startProxy(ctx context.Context) {
 	// }
 	// listener = tls.NewListener(listener, config)
 
-	// retry := 0
+	ps.accept(listener, func(conn net.Conn) {
+		ps.handleConnection(conn, port, ctx)
+	})
+}
+
+// accept serves the connections of the listener until it is closed.
+func (ps *ProxySet) accept(listener net.Listener, serve func(conn net.Conn)) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -582,152 +591,11 @@ func (ps *ProxySet) startProxy(ctx context.Context) {
 		go func() {
 			defer ps.hook.Recover(pkg.GenerateRandomID())
 			defer utils.HandlePanic()
-			ps.handleConnection(conn, port, ctx)
+			serve(conn)
 		}()
 	}
 }
 
-func (ps *ProxySet) startDnsServer() {
-
-	dnsServerAddr := fmt.Sprintf(":%v", ps.Port)
-	//TODO: Need to make it configurable
-	ps.DnsServerTimeout = 1 * time.Second
-
-	handler := ps
-	server := &dns.Server{
-		Addr:      dnsServerAddr,
-		Net:       "udp",
-		Handler:   handler,
-		UDPSize:   65535,
-		ReusePort: true,
-		// DisableBackground: true,
-	}
-
-	ps.DnsServer = server
-
-	ps.logger.Info(fmt.Sprintf("starting DNS server at addr %v", server.Addr))
-	err := server.ListenAndServe()
-	if err != nil {
-		ps.logger.Error("failed to start dns server", zap.Any("addr", server.Addr), zap.Error(err))
-	}
-}
-
-// For DNS caching
-var cache = struct {
-	sync.RWMutex
-	m map[string][]dns.RR
-}{m: make(map[string][]dns.RR)}
-
-func generateCacheKey(name string, qtype uint16) string {
-	return fmt.Sprintf("%s-%s", name, dns.TypeToString[qtype])
-}
-
-func (ps *ProxySet) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
-
-	ps.logger.Debug("", zap.Any("Source socket info", w.RemoteAddr().String()))
-	msg := new(dns.Msg)
-	msg.SetReply(r)
-	msg.Authoritative = true
-	ps.logger.Debug("Got some Dns queries")
-	for _, question := range r.Question {
-		ps.logger.Debug("", zap.Any("Record Type", question.Qtype), zap.Any("Received Query", question.Name))
-
-		key := generateCacheKey(question.Name, question.Qtype)
-
-		// Check if the answer is cached
-		cache.RLock()
-		answers, found := cache.m[key]
-		cache.RUnlock()
-
-		if !found {
-			// If not found in cache, resolve the DNS query
-			// answers = resolveDNSQuery(question.Name, ps.logger, ps.DnsServerTimeout)
-
-			if answers == nil || len(answers) == 0 {
-				// If the resolution failed, return a default A record with Proxy IP
-				if question.Qtype == dns.TypeA {
-					answers = []dns.RR{&dns.A{
-						Hdr: dns.RR_Header{Name: question.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
-						A:   net.ParseIP(util.ToIP4AddressStr(ps.IP4)),
-					}}
-					ps.logger.Debug("failed to resolve dns query hence sending proxy ip4", zap.Any("proxy Ip", util.ToIP4AddressStr(ps.IP4)))
-				} else if question.Qtype == dns.TypeAAAA {
-					if ps.dockerAppCmd {
-						ps.logger.Debug("failed to resolve dns query (in docker case) hence sending empty record")
-					} else {
-						answers = []dns.RR{&dns.AAAA{
-							Hdr:  dns.RR_Header{Name: question.Name, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 3600},
-							AAAA: net.ParseIP(util.ToIPv6AddressStr(ps.IP6)),
-						}}
-						ps.logger.Debug("failed to resolve dns query hence sending proxy ip6", zap.Any("proxy Ip", util.ToIPv6AddressStr(ps.IP6)))
-					}
-				}
-
-				ps.logger.Debug(fmt.Sprintf("Answers[when resolution failed for query:%v]:\n%v\n", question.Qtype, answers))
-			}
-
-			// Cache the answer
-			cache.Lock()
-			cache.m[key] = answers
-			cache.Unlock()
-			ps.logger.Debug(fmt.Sprintf("Answers[after caching it]:\n%v\n", answers))
-		}
-
-		ps.logger.Debug(fmt.Sprintf("Answers[before appending to msg]:\n%v\n", answers))
-		msg.Answer = append(msg.Answer, answers...)
-		ps.logger.Debug(fmt.Sprintf("Answers[After appending to msg]:\n%v\n", msg.Answer))
-	}
-
-	ps.logger.Debug(fmt.Sprintf("dns msg sending back:\n%v\n", msg))
-	ps.logger.Debug(fmt.Sprintf("dns msg RCODE sending back:\n%v\n", msg.Rcode))
-	ps.logger.Debug("Writing dns info back to the client...")
-	err := w.WriteMsg(msg)
-	if err != nil {
-		ps.logger.Error("failed to write dns info back to the client", zap.Error(err))
-	}
-}
-
-func resolveDNSQuery(domain string, logger *zap.Logger, timeout time.Duration) []dns.RR {
-	// Remove the last dot from the domain name if it exists
-	domain = strings.TrimSuffix(domain, ".")
-
-	// Create a context with a timeout
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// Use the default system resolver
-	resolver := net.DefaultResolver
-
-	// Perform the lookup with the context
-	ips, err := resolver.LookupIPAddr(ctx, domain)
-	if err != nil {
-		logger.Debug(fmt.Sprintf("failed to resolve the dns query for:%v", domain), zap.Error(err))
-		return nil
-	}
-
-	// Convert the resolved IPs to dns.RR
-	var answers []dns.RR
-	for _, ip := range ips {
-		if ipv4 := ip.IP.To4(); ipv4 != nil {
-			answers = append(answers, &dns.A{
-				Hdr: dns.RR_Header{Name: dns.Fqdn(domain), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 3600},
-				A:   ipv4,
-			})
-		} else {
-			answers = append(answers, &dns.AAAA{
-				Hdr:  dns.RR_Header{Name: dns.Fqdn(domain), Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: 3600},
-				AAAA: ip.IP,
-			})
-		}
-	}
-
-	if len(answers) > 0 {
-		logger.Debug("net.LookupIP resolved the ip address...")
-	}
-
-	return answers
-}
-
 func isTLSHandshake(data []byte) bool {
 	if len(data) < 5 {
 		return false
@@ -735,42 +603,67 @@ func isTLSHandshake(data []byte) bool {
 	return data[0] == 0x16 && data[1] == 0x03 && (data[2] == 0x00 || data[2] == 0x01 || data[2] == 0x02 || data[2] == 0x03)
 }
 
-func (ps *ProxySet) handleTLSConnection(conn net.Conn) (net.Conn, error) {
-	//Load the CA certificate and private key
-
-	var err error
-	caPrivKey, err = helpers.ParsePrivateKeyPEM(caPKey)
-	if err != nil {
-		ps.logger.Error(Emoji+"Failed to parse CA private key: ", zap.Error(err))
-		return nil, err
-	}
-	caCertParsed, err = helpers.ParseCertificatePEM(caCrt)
-	if err != nil {
-		ps.logger.Error(Emoji+"Failed to parse CA certificate: ", zap.Error(err))
-		return nil, err
-	}
-
-	// Create a TLS configuration
+// handleTLSConnection completes the handshake with the application and returns the decrypted connection. The
+// application dials the destination through dial, which returns the protocols the proxy may agree on with the
+// application, so that both connections speak the same one.
+func (ps *ProxySet) handleTLSConnection(conn net.Conn, dial func(clientHello *tls.ClientHelloInfo) []string) (net.Conn, error) {
 	config := &tls.Config{
-		GetCertificate: certForClient,
+		GetConfigForClient: func(clientHello *tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				GetCertificate: ps.certs.getCertificate,
+				NextProtos:     dial(clientHello),
+			}, nil
+		},
 	}
 
 	// Wrap the TCP connection with TLS
 	tlsConn := tls.Server(conn, config)
 	// Perform the handshake
-	err = tlsConn.Handshake()
-
+	err := tlsConn.Handshake()
 	if err != nil {
 		ps.logger.Error(Emoji+"failed to complete TLS handshake with the client with error: ", zap.Error(err))
 		return nil, err
 	}
-	// Use the tlsConn for further communication
-	// For example, you can read and write data using tlsConn.Read() and tlsConn.Write()
-
-	// Here, we simply close the connection
 	return tlsConn, nil
 }
 
+// dialTLS dials the destination of the application over TLS, offering the protocols the application offered.
+// Without the server name the certificate of the destination is verified against its ip.
+func dialTLS(clientHello *tls.ClientHelloInfo, actualAddress string, destPort uint32) (net.Conn, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: false,
+		ServerName:         clientHello.ServerName,
+		NextProtos:         clientHello.SupportedProtos,
+	}
+	tlsAddress := actualAddress
+	if clientHello.ServerName != "" {
+		tlsAddress = fmt.Sprintf("%v:%v", clientHello.ServerName, destPort)
+	}
+	dst, err := tls.Dial("tcp", tlsAddress, config)
+	if err != nil {
+		return nil, err
+	}
+	return dst, nil
+}
+
+// applicationProtos returns the protocols the proxy agrees on with the application: the one chosen by the
+// destination, or the ones keploy serves the mocks of when there is no destination.
+func applicationProtos(dst net.Conn, supportedProtos []string) []string {
+	if tlsDst, ok := dst.(*tls.Conn); ok {
+		if proto := tlsDst.ConnectionState().NegotiatedProtocol; proto != "" {
+			return []string{proto}
+		}
+		return nil
+	}
+	var protos []string
+	for _, proto := range supportedProtos {
+		if proto == "h2" || proto == "http/1.1" {
+			protos = append(protos, proto)
+		}
+	}
+	return protos
+}
+
 // handleConnection function executes the actual outgoing network call and captures/forwards the request and response messages.
 func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Context) {
 
@@ -805,29 +698,77 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 
 	// releases the occupied source port when done fetching the destination info
 	ps.hook.CleanProxyEntry(uint16(sourcePort))
-	//checking for the destination port of mysql
-	if destInfo.DestPort == 3306 {
-		var dst net.Conn
-		var actualAddress = ""
-		if destInfo.IpVersion == 4 {
-			actualAddress = fmt.Sprintf("%v:%v", util.ToIP4AddressStr(destInfo.DestIp4), destInfo.DestPort)
-		} else if destInfo.IpVersion == 6 {
-			actualAddress = fmt.Sprintf("[%v]:%v", util.ToIPv6AddressStr(destInfo.DestIp6), destInfo.DestPort)
+	var actualAddress = ""
+	if destInfo.IpVersion == 4 {
+		actualAddress = fmt.Sprintf("%v:%v", util.ToIP4AddressStr(destInfo.DestIp4), destInfo.DestPort)
+	} else if destInfo.IpVersion == 6 {
+		actualAddress = fmt.Sprintf("[%v]:%v", util.ToIPv6AddressStr(destInfo.DestIp6), destInfo.DestPort)
+	}
+	// the instance of the user application that made the call decides the mocks to use
+	hook, parsers, ok := ps.route(destInfo.KernelPid)
+	if !ok {
+		ps.logger.Error("failed to find the instance of the user application which made the call, the call is not served", zap.Any("pid", destInfo.KernelPid), zap.Any("destination", actualAddress))
+		ps.recordUnroutedCall(destInfo.KernelPid, actualAddress)
+		conn.Close()
+		return
+	}
+	// dns queries over tcp are answered like the ones over udp
+	if destInfo.DestPort == 53 && (models.GetMode() == models.MODE_TEST || models.GetMode() == models.MODE_RECORD) {
+		ps.serveDNSOverTCP(conn, hook)
+		return
+	}
+	ps.forward(conn, destination{address: actualAddress, ip: destinationIP(destInfo), port: destInfo.DestPort}, port, hook, parsers, ctx)
+
+	// Closing the user client connection
+	conn.Close()
+	duration := time.Since(start)
+	ps.logger.Debug("time taken by proxy to execute the flow", zap.Any("Duration(ms)", duration.Milliseconds()))
+}
+
+// destination is the server an outgoing call of the application is made to.
+type destination struct {
+	address string // host:port dialed to reach the server
+	ip      net.IP // nil when the address names a host which could not be resolved
+	port    uint32
+}
+
+// forward serves an outgoing call of the application: it is passed through, recorded or answered from the mocks
+// of hook by the parser of its protocol.
+func (ps *ProxySet) forward(conn net.Conn, dest destination, port uint32, hook *hooks.Hook, parsers map[string]DependencyHandler, ctx context.Context) {
+	// the server speaks first in mysql, its calls are told apart by the port or by the greeting of the server
+	protocol := ps.protocolPorts[dest.port]
+	reader := bufio.NewReaderSize(conn, tlsRecordSize)
+	// greetedConn is the connection to the destination dialed to read its greeting, it serves the call whatever
+	// the protocol of the greeting
+	var greetedConn net.Conn
+	if protocol == "" && models.GetMode() != models.MODE_TEST {
+		protocol, greetedConn = ps.sniffServerGreeting(reader, conn, dest)
+	}
+	if greetedConn != nil {
+		defer greetedConn.Close()
+	}
+	if protocol == "mysql" {
+		conn = &CustomConn{Conn: conn, r: reader, logger: ps.logger}
+		dst := greetedConn
+		var err error
+		if rule, ok := ps.passThroughRule(passThroughTarget{ip: dest.ip, protocols: map[string]bool{"mysql": true}}); ok {
+			ps.passThroughCall(nil, conn, dst, dest.address, hook, rule, ps.logger)
+			conn.Close()
+			return
 		}
-		if models.GetMode() != models.MODE_TEST {
-			dst, err = net.Dial("tcp", actualAddress)
+		if dst == nil && models.GetMode() != models.MODE_TEST {
+			dst, err = net.Dial("tcp", dest.address)
 			if err != nil {
-				ps.logger.Error(Emoji+"failed to dial the connection to destination server", zap.Error(err), zap.Any("proxy port", port), zap.Any("server address", actualAddress))
+				ps.logger.Error(Emoji+"failed to dial the connection to destination server", zap.Error(err), zap.Any("proxy port", port), zap.Any("server address", dest.address))
 				conn.Close()
 				return
 				// }
 			}
 		}
-		ParsersMap["mysql"].ProcessOutgoing([]byte{}, conn, dst, ctx)
+		parsers["mysql"].ProcessOutgoing([]byte{}, conn, dst, ctx)
 
 	} else {
 		clientConnId := getNextID()
-		reader := bufio.NewReader(conn)
 		initialData := make([]byte, 5)
 		testBuffer, err := reader.Peek(len(initialData))
 		if err != nil {
@@ -847,10 +788,31 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 			r:      multiReader,
 			logger: ps.logger,
 		}
+		// the calls which the rules pass through by their destination are relayed without decrypting them
+		target := passThroughTarget{ip: dest.ip}
+		if isTLS {
+			target.sni = peekServerName(reader, ps.logger)
+		}
+		if rule, ok := ps.passThroughRule(target); ok {
+			ps.passThroughCall(nil, conn, greetedConn, dest.address, hook, rule, ps.logger)
+			conn.Close()
+			return
+		}
+		// dst stores the connection with actual destination for the outgoing network call, the TLS one is
+		// dialed during the handshake with the application to agree on the protocol of the destination
+		var dst net.Conn
+		var dstErr error
 		if isTLS {
-			conn, err = ps.handleTLSConnection(conn)
+			conn, err = ps.handleTLSConnection(conn, func(clientHello *tls.ClientHelloInfo) []string {
+				target.sni = clientHello.ServerName
+				dst, dstErr = dialTLS(clientHello, dest.address, dest.port)
+				return applicationProtos(dst, clientHello.SupportedProtos)
+			})
 			if err != nil {
 				ps.logger.Error("failed to handle TLS connection", zap.Error(err))
+				if dst != nil {
+					dst.Close()
+				}
 				return
 			}
 		}
@@ -874,41 +836,43 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 			return
 		}
 
-		// dst stores the connection with actual destination for the outgoing network call
-		var dst net.Conn
-		var actualAddress = ""
-		if destInfo.IpVersion == 4 {
-			actualAddress = fmt.Sprintf("%v:%v", util.ToIP4AddressStr(destInfo.DestIp4), destInfo.DestPort)
-		} else if destInfo.IpVersion == 6 {
-			actualAddress = fmt.Sprintf("[%v]:%v", util.ToIPv6AddressStr(destInfo.DestIp6), destInfo.DestPort)
-		}
-
 		//Dialing for tls connection
 		destConnId := getNextID()
-		logger := ps.logger.With(zap.Any("Client IP Address", conn.RemoteAddr().String()), zap.Any("Client ConnectionID", clientConnId), zap.Any("Destination IP Address", actualAddress), zap.Any("Destination ConnectionID", destConnId))
+		logger := ps.logger.With(zap.Any("Client IP Address", conn.RemoteAddr().String()), zap.Any("Client ConnectionID", clientConnId), zap.Any("Destination IP Address", dest.address), zap.Any("Destination ConnectionID", destConnId))
 		if isTLS {
 			logger.Debug("", zap.Any("isTLS", isTLS))
-			config := &tls.Config{
-				InsecureSkipVerify: false,
-				ServerName:         destinationUrl,
-			}
-			dst, err = tls.Dial("tcp", fmt.Sprintf("%v:%v", destinationUrl, destInfo.DestPort), config)
-			if err != nil && models.GetMode() != models.MODE_TEST {
-				logger.Error("failed to dial the connection to destination server", zap.Error(err), zap.Any("proxy port", port), zap.Any("server address", actualAddress))
+			if dstErr != nil && models.GetMode() != models.MODE_TEST {
+				logger.Error("failed to dial the connection to destination server", zap.Error(dstErr), zap.Any("proxy port", port), zap.Any("server address", dest.address))
 				conn.Close()
 				return
 			}
+		} else if greetedConn != nil {
+			dst = greetedConn
 		} else {
-			dst, err = net.Dial("tcp", actualAddress)
+			dst, err = net.Dial("tcp", dest.address)
 			if err != nil && models.GetMode() != models.MODE_TEST {
-				logger.Error("failed to dial the connection to destination server", zap.Error(err), zap.Any("proxy port", port), zap.Any("server address", actualAddress))
+				logger.Error("failed to dial the connection to destination server", zap.Error(err), zap.Any("proxy port", port), zap.Any("server address", dest.address))
 				conn.Close()
 				return
 			}
 		}
 
+		readProtocols(buffer, parsers, &target)
+		if protocol != "" {
+			target.protocols = map[string]bool{protocol: true}
+		}
+		if rule, ok := ps.passThroughRule(target); ok {
+			if isTLS && dst == nil {
+				logger.Error("failed to dial the connection to destination server", zap.Error(dstErr), zap.Any("server address", dest.address))
+			} else {
+				ps.passThroughCall(buffer, conn, dst, dest.address, hook, rule, logger)
+			}
+			conn.Close()
+			return
+		}
+
 		for _, port := range ps.PassThroughPorts {
-			if port == uint(destInfo.DestPort) {
+			if port == uint(dest.port) {
 				err = ps.callNext(buffer, conn, dst, logger)
 				if err != nil {
 					logger.Error("failed to pass through the outgoing call", zap.Error(err), zap.Any("for port", port))
@@ -916,24 +880,26 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 				}
 			}
 		}
+		if parser, ok := parsers[protocol]; ok {
+			parser.ProcessOutgoing(buffer, conn, dst, ctx)
+			return
+		}
 		genericCheck := true
 		//Checking for all the parsers.
-		for _, parser := range ParsersMap {
+		for _, parser := range parsers {
 			if parser.OutgoingType(buffer) {
 				parser.ProcessOutgoing(buffer, conn, dst, ctx)
 				genericCheck = false
+				// the connection belongs to the first parser claiming it
+				break
 			}
 		}
 		if genericCheck {
 			logger.Debug("The external dependency is not supported. Hence using generic parser")
-			genericparser.ProcessGeneric(buffer, conn, dst, ps.hook, logger, ctx)
+			genericparser.ProcessGeneric(buffer, conn, dst, hook, logger, ctx)
 		}
 	}
 
-	// Closing the user client connection
-	conn.Close()
-	duration := time.Since(start)
-	ps.logger.Debug("time taken by proxy to execute the flow", zap.Any("Duration(ms)", duration.Milliseconds()))
 }
 
 func (ps *ProxySet) callNext(requestBuffer []byte, clientConn, destConn net.Conn, logger *zap.Logger) error {
@@ -1013,6 +979,11 @@ func (ps *ProxySet) StopProxyServer() {
 			ps.logger.Error("failed to stop proxy server", zap.Error(err))
 		}
 	}
+	for _, listener := range ps.listeners {
+		if err := listener.Close(); err != nil {
+			ps.logger.Error("failed to stop listening for the dependency", zap.Error(err), zap.Any("address", listener.Addr()))
+		}
+	}
 
 	// stop dns server only in case of test mode.
 	if ps.DnsServer != nil {
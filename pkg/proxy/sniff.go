@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// clientSilenceTimeout is how long the application may stay silent on a new connection before the proxy reads
+// the greeting of the server, the clients of the protocols where they speak first write their request at once.
+const clientSilenceTimeout = 200 * time.Millisecond
+
+// serverGreetingTimeout is how long the server of a connection the application is silent on is waited for. The
+// servers greet as soon as they accept the connection, a client writing slowly to a server waiting for it is only
+// held that long.
+const serverGreetingTimeout = 500 * time.Millisecond
+
+// withDefaultPorts returns the protocols of the ports of the config along with the default port of mysql.
+func withDefaultPorts(protocolPorts models.ProtocolPorts) models.ProtocolPorts {
+	ports := models.ProtocolPorts{3306: "mysql"}
+	for port, protocol := range protocolPorts {
+		ports[port] = protocol
+	}
+	return ports
+}
+
+// sniffServerGreeting returns the protocol of a connection the application does not speak first on, read from
+// the greeting of its destination. The connection dialed to the destination is returned along with the protocol,
+// its greeting is read again by the parser. An empty protocol is returned when the application speaks first, or
+// when the greeting is not known, the connection is then handled like the other ones over the dialed one.
+func (ps *ProxySet) sniffServerGreeting(reader *bufio.Reader, conn net.Conn, dest destination) (string, net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(clientSilenceTimeout)); err != nil {
+		return "", nil
+	}
+	_, err := reader.Peek(1)
+	if err := conn.SetReadDeadline(time.Time{}); err != nil {
+		ps.logger.Error("failed to reset the read deadline of the connection", zap.Error(err))
+		return "", nil
+	}
+	var netErr net.Error
+	if err == nil || !errors.As(err, &netErr) || !netErr.Timeout() {
+		return "", nil
+	}
+
+	dst, err := net.DialTimeout("tcp", dest.address, passThroughDialTimeout)
+	if err != nil {
+		ps.logger.Debug("failed to dial the destination to read its greeting", zap.Error(err), zap.String("server address", dest.address))
+		return "", nil
+	}
+	dstReader := bufio.NewReader(dst)
+	greetedConn := &CustomConn{Conn: dst, r: dstReader, logger: ps.logger}
+	if err := dst.SetReadDeadline(time.Now().Add(serverGreetingTimeout)); err != nil {
+		return "", greetedConn
+	}
+	greeting, err := peekPacket(dstReader)
+	if err := dst.SetReadDeadline(time.Time{}); err != nil {
+		ps.logger.Error("failed to reset the read deadline of the destination", zap.Error(err), zap.String("server address", dest.address))
+		dst.Close()
+		return "", nil
+	}
+	if err != nil {
+		ps.logger.Debug("the destination did not greet the silent application", zap.Error(err), zap.String("server address", dest.address))
+		return "", greetedConn
+	}
+	if !isMySQLGreeting(greeting) {
+		ps.logger.Debug("the greeting of the destination is not from a known protocol", zap.String("server address", dest.address))
+		return "", greetedConn
+	}
+	ps.logger.Debug("the destination greeted with a mysql handshake", zap.String("server address", dest.address), zap.Uint32("port", dest.port))
+	return "mysql", greetedConn
+}
+
+// peekPacket returns the first mysql packet of the reader without consuming it.
+func peekPacket(reader *bufio.Reader) ([]byte, error) {
+	header, err := reader.Peek(4)
+	if err != nil {
+		return nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	if 4+length > reader.Size() {
+		return nil, errors.New("the first packet does not fit into the buffer")
+	}
+	return reader.Peek(4 + length)
+}
+
+// isMySQLGreeting reports whether the packet is the HandshakeV10 the mysql servers, and the ones speaking their
+// protocol like MariaDB, TiDB or ProxySQL, open their connections with: the protocol version 10 followed by the
+// NUL terminated version of the server and the id of the connection.
+func isMySQLGreeting(packet []byte) bool {
+	if len(packet) < 5 || packet[3] != 0 || packet[4] != 0x0a {
+		return false
+	}
+	payload := packet[5:]
+	end := bytes.IndexByte(payload, 0)
+	if end <= 0 || len(payload) < end+1+4 {
+		return false
+	}
+	for _, c := range payload[:end] {
+		if c < 0x20 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -216,6 +216,9 @@ func ReadBytes(reader io.Reader) ([]byte, error) {
 				time.Sleep(time.Millisecond * 100) // sleep before trying again
 				continue
 			}
+			if errors.Is(err, io.EOF) {
+				return buffer, io.EOF // the reader wraps io.EOF when it has ended for good, there is nothing to wait for
+			}
 			return buffer, err
 		}
 
@@ -34,6 +34,15 @@ record:
   delay: 5
   buildDelay: 30s
   passThroughPorts: []
+  # outgoing calls sent to their destination without being recorded, a rule matches the calls
+  # matching all of its fields, e.g.
+  # - { name: "telemetry", host: "*.telemetry.example.com", path: "/v1/traces" }
+  # - { ip: "10.0.0.0/8", protocol: "redis" }
+  passThrough: []
+  # protocols of the dependencies on ports other than their default one, e.g. { 6033: "mysql" }
+  protocolPorts: {}
+  # storage backend for the testcases and mocks: yaml or sqlite
+  storage: "yaml"
   filters:
     ReqHeader: []
     urlMethods: {}
@@ -55,8 +64,39 @@ test:
   buildDelay: 30s
   apiTimeout: 5
   passThroughPorts: []
+  # outgoing calls sent to their destination instead of being mocked, same rules as in record
+  passThrough: []
+  # the parser serving the mocks of the ports whose protocol can't be told from the calls, same map as in record
+  protocolPorts: {}
   withCoverage: false
   coverageReportPath: ""
+  storage: "yaml"
+  # fail (or warn) when a replayed call is slower than the recorded one, e.g.
+  # global: { ratio: 1.5, absolute: 100ms, mode: "fail" }
+  latency:
+    global: {}
+    test-sets: {}
+  # check JSON paths of the response body against rules instead of the recorded values, e.g.
+  # global: { "body.id": { type: "string", regex: "^[0-9a-f-]{36}$" }, "body.createdAt": { timestamp: true } }
+  assertions:
+    global: {}
+    test-sets: {}
+  # score the HTTP mocks by their query values, JSON body fields and the listed headers, a mock scoring
+  # below the threshold (0 to 1) is not served, e.g. noise: ["body.requestId"]. Only read by 'keploy test',
+  # 'keploy mockTest' matches its mocks with the defaults
+  mockMatching:
+    threshold: 0
+    headers: []
+    noise: []
+    postgres:
+      ignoreParams: []
+  #
+  # Example on leaving parameters out of the matching of the postgres queries
+  # postgres:
+  #   ignoreParams:
+  #     - query: "^insert into orders"
+  #       position: 1
+  #     - value: "^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$"
   #
   # Example on using globalNoise
   # globalNoise: 
@@ -83,6 +123,11 @@ test:
   #           # we can also pass the exact value to ignore for a field
   #           "User-Agent": ["PostmanRuntime/7.34.0"]
   #         }
+mockServer:
+  # local addresses of the proxy standing for the dependencies when mockRecord and mockTest run
+  # with --no-ebpf, the HTTP calls go through HTTP_PROXY instead, e.g.
+  # - { listen: "localhost:15432", target: "postgres:5432" }
+  listeners: []
 `
 
 func (g *generatorConfig) GenerateConfig(filePath string) {
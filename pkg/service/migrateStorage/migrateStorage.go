@@ -0,0 +1,217 @@
+package migrateStorage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.keploy.io/server/pkg/platform/fs"
+	"go.keploy.io/server/pkg/platform/sqlite"
+	"go.keploy.io/server/pkg/platform/telemetry"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.uber.org/zap"
+)
+
+type migrator struct {
+	logger *zap.Logger
+}
+
+func NewMigrator(logger *zap.Logger) Migrator {
+	return &migrator{
+		logger: logger,
+	}
+}
+
+// backend gives access to the test-sets and test reports of one storage backend under the keploy directory.
+type backend struct {
+	testSets   []string
+	store      func(testSet string) platform.TestCaseDB
+	reports    platform.TestReportDB
+	reportPath string
+	// reportNames lists the stored test reports
+	reportNames func() ([]string, error)
+	close       func()
+}
+
+func (m *migrator) open(storage, path string, tele *telemetry.Telemetry) (*backend, error) {
+	reportPath := filepath.Join(path, "testReports")
+	switch storage {
+	case models.YamlStorage:
+		testSets, err := yaml.ReadSessionIndices(path, m.logger)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{
+			testSets: testSets,
+			store: func(testSet string) platform.TestCaseDB {
+				return yaml.NewYamlStore(filepath.Join(path, testSet, "tests"), filepath.Join(path, testSet), "", "", m.logger, tele)
+			},
+			reports:    yaml.NewTestReportFS(m.logger),
+			reportPath: reportPath,
+			reportNames: func() ([]string, error) {
+				entries, err := os.ReadDir(reportPath)
+				if os.IsNotExist(err) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+				names := []string{}
+				for _, entry := range entries {
+					if !entry.IsDir() && filepath.Ext(entry.Name()) == ".yaml" {
+						names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+					}
+				}
+				return names, nil
+			},
+			close: func() {},
+		}, nil
+	case models.SqliteStorage:
+		dbPath := filepath.Join(path, models.SqliteDbName)
+		sqliteStore, err := sqlite.NewSqliteStore(dbPath, "", "", m.logger, tele)
+		if err != nil {
+			return nil, err
+		}
+		reports, err := sqlite.NewTestReportDB(dbPath, m.logger)
+		if err != nil {
+			sqliteStore.Close()
+			return nil, err
+		}
+		testSets, err := sqliteStore.ReadSessionIndices()
+		if err != nil {
+			sqliteStore.Close()
+			return nil, err
+		}
+		return &backend{
+			testSets: testSets,
+			store: func(testSet string) platform.TestCaseDB {
+				sqliteStore.TcsPath = filepath.Join(path, testSet, "tests")
+				sqliteStore.MockPath = filepath.Join(path, testSet)
+				return sqliteStore
+			},
+			reports:     reports,
+			reportPath:  reportPath,
+			reportNames: reports.Names,
+			close: func() {
+				sqliteStore.Close()
+			},
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid storage backend %q, should be one of %s or %s", storage, models.YamlStorage, models.SqliteStorage)
+}
+
+// Migrate copies the test-sets and test reports stored under path from one storage backend into the
+// other. Test-sets and reports which already exist in the destination are skipped.
+func (m *migrator) Migrate(path, from, to string) error {
+	if from == to {
+		return fmt.Errorf("the source and destination storage backends are both %s", from)
+	}
+	tele := telemetry.NewTelemetry(false, false, fs.NewTeleFS(m.logger), m.logger, "", nil)
+
+	src, err := m.open(from, path, tele)
+	if err != nil {
+		m.logger.Error("failed to open the source storage", zap.Error(err), zap.String("storage", from))
+		return err
+	}
+	defer src.close()
+	dst, err := m.open(to, path, tele)
+	if err != nil {
+		m.logger.Error("failed to open the destination storage", zap.Error(err), zap.String("storage", to))
+		return err
+	}
+	defer dst.close()
+
+	existing := map[string]bool{}
+	for _, testSet := range dst.testSets {
+		existing[testSet] = true
+	}
+
+	mocksTotal := make(map[string]int)
+	testsTotal := 0
+	ctx := context.WithValue(context.Background(), "mocksTotal", &mocksTotal)
+	ctx = context.WithValue(ctx, "testsTotal", &testsTotal)
+
+	for _, testSet := range src.testSets {
+		if existing[testSet] {
+			m.logger.Warn("skipping the test-set as it already exists in the destination storage", zap.String("test-set", testSet), zap.String("storage", to))
+			continue
+		}
+		err := m.migrateTestSet(ctx, src.store(testSet), dst.store(testSet))
+		if err != nil {
+			m.logger.Error("failed to migrate the test-set", zap.Error(err), zap.String("test-set", testSet))
+			return err
+		}
+		m.logger.Info("migrated the test-set", zap.String("test-set", testSet), zap.String("from", from), zap.String("to", to))
+	}
+
+	err = m.migrateReports(src, dst)
+	if err != nil {
+		m.logger.Error("failed to migrate the test reports", zap.Error(err))
+		return err
+	}
+	m.logger.Info("storage migration completed", zap.Int("testcases", testsTotal), zap.Any("mocks", mocksTotal))
+	return nil
+}
+
+func (m *migrator) migrateTestSet(ctx context.Context, src, dst platform.TestCaseDB) error {
+	tcs, err := src.ReadTestcase("", nil, nil)
+	if err != nil {
+		return err
+	}
+	for _, tc := range tcs {
+		err := dst.WriteTestcase(tc, ctx, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	configMocks, err := src.ReadConfigMocks("")
+	if err != nil {
+		return err
+	}
+	tcsMocks, err := src.ReadTcsMocks(nil, "")
+	if err != nil {
+		return err
+	}
+	for _, mock := range append(configMocks, tcsMocks...) {
+		err := dst.WriteMock(mock, ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *migrator) migrateReports(src, dst *backend) error {
+	names, err := src.reportNames()
+	if err != nil {
+		return err
+	}
+	existingNames, err := dst.reportNames()
+	if err != nil {
+		return err
+	}
+	existing := map[string]bool{}
+	for _, name := range existingNames {
+		existing[name] = true
+	}
+	for _, name := range names {
+		if existing[name] {
+			m.logger.Warn("skipping the test report as it already exists in the destination storage", zap.String("report", name))
+			continue
+		}
+		report, err := src.reports.Read(context.Background(), src.reportPath, name)
+		if err != nil {
+			return err
+		}
+		err = dst.reports.Write(context.Background(), dst.reportPath, report)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,5 @@
+package migrateStorage
+
+type Migrator interface {
+	Migrate(path, from, to string) error
+}
@@ -32,7 +32,7 @@ func NewMockRecorder(logger *zap.Logger) MockRecorder {
 	}
 }
 
-func (s *mockRecorder) MockRecord(path string, proxyPort uint32, pid uint32, mockName string, enableTele bool) {
+func (s *mockRecorder) MockRecord(path string, proxyPort uint32, pid uint32, mockName string, server *models.MockServer, enableTele bool) {
 
 	models.SetMode(models.MODE_RECORD)
 	teleFS := fs.NewTeleFS(s.logger)
@@ -51,17 +51,26 @@ func (s *mockRecorder) MockRecord(path string, proxyPort uint32, pid uint32, moc
 		s.logger.Error("error while creating hooks", zap.Error(err))
 		return
 	}
-	
-	if err := loadedHooks.LoadHooks("", "", pid, ctx, nil); err != nil {
-		return
-	}
-	// start the proxy
-	ps := proxy.BootProxy(s.logger, proxy.Option{Port: proxyPort}, "", "", pid, "", []uint{}, loadedHooks, ctx, 0)
 
-	// proxy update its state in the ProxyPorts map
-	// Sending Proxy Ip & Port to the ebpf program
-	if err := loadedHooks.SendProxyInfo(ps.IP4, ps.Port, ps.IP6); err != nil {
-		return
+	var ps *proxy.ProxySet
+	if server != nil {
+		// the application reaches the proxy by itself, nothing is loaded into the kernel
+		ps, err = proxy.StartMockServer(s.logger, proxy.Option{Port: proxyPort}, server.Listeners, loadedHooks, ctx)
+		if err != nil {
+			return
+		}
+	} else {
+		if err := loadedHooks.LoadHooks("", "", pid, ctx, nil); err != nil {
+			return
+		}
+		// start the proxy
+		ps = proxy.BootProxy(s.logger, proxy.Option{Port: proxyPort}, "", "", pid, "", []uint{}, loadedHooks, ctx, 0)
+
+		// proxy update its state in the ProxyPorts map
+		// Sending Proxy Ip & Port to the ebpf program
+		if err := loadedHooks.SendProxyInfo(ps.IP4, ps.Port, ps.IP6); err != nil {
+			return
+		}
 	}
 
 	// Listen for the interrupt signal
@@ -77,6 +86,8 @@ func (s *mockRecorder) MockRecord(path string, proxyPort uint32, pid uint32, moc
 	}
 
 	// Shutdown other resources
-	loadedHooks.Stop(true)
+	if server == nil {
+		loadedHooks.Stop(true)
+	}
 	ps.StopProxyServer()
 }
@@ -1,5 +1,7 @@
 package mockrecord
 
+import "go.keploy.io/server/pkg/models"
+
 type MockRecorder interface {
-	MockRecord(path string, proxyPort uint32, pid uint32, dir string, enableTele bool)
+	MockRecord(path string, proxyPort uint32, pid uint32, dir string, server *models.MockServer, enableTele bool)
 }
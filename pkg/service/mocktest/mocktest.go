@@ -32,7 +32,7 @@ func NewMockTester(logger *zap.Logger) MockTester {
 	}
 }
 
-func (s *mockTester) MockTest(path string, proxyPort, pid uint32, mockName string, enableTele bool) {
+func (s *mockTester) MockTest(path string, proxyPort, pid uint32, mockName string, server *models.MockServer, enableTele bool) {
 
 	models.SetMode(models.MODE_TEST)
 	teleFS := fs.NewTeleFS(s.logger)
@@ -50,23 +50,31 @@ func (s *mockTester) MockTest(path string, proxyPort, pid uint32, mockName strin
 		return
 	}
 
-	if err := loadedHooks.LoadHooks("", "", pid, ctx, nil); err != nil {
-		return
-	}
+	var ps *proxy.ProxySet
+	if server != nil {
+		// the application reaches the proxy by itself, nothing is loaded into the kernel
+		ps, err = proxy.StartMockServer(s.logger, proxy.Option{Port: proxyPort}, server.Listeners, loadedHooks, ctx)
+		if err != nil {
+			return
+		}
+	} else {
+		if err := loadedHooks.LoadHooks("", "", pid, ctx, nil); err != nil {
+			return
+		}
 
-	// start the proxy
-	ps := proxy.BootProxy(s.logger, proxy.Option{Port: proxyPort}, "", "", pid, "", []uint{}, loadedHooks, ctx, 0)
+		// start the proxy
+		ps = proxy.BootProxy(s.logger, proxy.Option{Port: proxyPort}, "", "", pid, "", []uint{}, loadedHooks, ctx, 0)
 
-	// proxy update its state in the ProxyPorts map
-	// Sending Proxy Ip & Port to the ebpf program
-	if err := loadedHooks.SendProxyInfo(ps.IP4, ps.Port, ps.IP6); err != nil {
-		return
+		// proxy update its state in the ProxyPorts map
+		// Sending Proxy Ip & Port to the ebpf program
+		if err := loadedHooks.SendProxyInfo(ps.IP4, ps.Port, ps.IP6); err != nil {
+			return
+		}
 	}
 
 	tcsMocks, err := ys.ReadTcsMocks(&models.TestCase{}, "")
 	if err != nil {
-		loadedHooks.Stop(true)
-		ps.StopProxyServer()
+		s.stop(loadedHooks, ps, server)
 		return
 	}
 	readTcsMocks := []*models.Mock{}
@@ -81,8 +89,7 @@ func (s *mockTester) MockTest(path string, proxyPort, pid uint32, mockName strin
 	configMocks, err := ys.ReadConfigMocks("")
 
 	if err != nil {
-		loadedHooks.Stop(true)
-		ps.StopProxyServer()
+		s.stop(loadedHooks, ps, server)
 		return
 	}
 
@@ -123,6 +130,13 @@ func (s *mockTester) MockTest(path string, proxyPort, pid uint32, mockName strin
 		tele.MockTestRun(usedMocks)
 	}
 	// Shutdown other resources
-	loadedHooks.Stop(true)
+	s.stop(loadedHooks, ps, server)
+}
+
+// stop stops the proxy, and the eBPF hooks when they were loaded.
+func (s *mockTester) stop(loadedHooks *hooks.Hook, ps *proxy.ProxySet, server *models.MockServer) {
+	if server == nil {
+		loadedHooks.Stop(true)
+	}
 	ps.StopProxyServer()
 }
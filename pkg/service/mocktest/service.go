@@ -1,5 +1,7 @@
 package mocktest
 
+import "go.keploy.io/server/pkg/models"
+
 type MockTester interface {
-	MockTest(path string, proxyPort uint32, pid uint32, dir string, enableTele bool)
+	MockTest(path string, proxyPort uint32, pid uint32, dir string, server *models.MockServer, enableTele bool)
 }
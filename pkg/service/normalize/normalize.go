@@ -0,0 +1,134 @@
+package normalize
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform/fs"
+	"go.keploy.io/server/pkg/platform/telemetry"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.keploy.io/server/pkg/service/test"
+	"go.uber.org/zap"
+)
+
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+type normalizer struct {
+	logger *zap.Logger
+}
+
+func NewNormalizer(logger *zap.Logger) Normalizer {
+	return &normalizer{
+		logger: logger,
+	}
+}
+
+func (n *normalizer) Normalize(path string, testSets []string, write bool) error {
+	if len(testSets) == 0 {
+		var err error
+		testSets, err = yaml.ReadSessionIndices(path, n.logger)
+		if err != nil {
+			n.logger.Error("failed to read the recorded test-sets", zap.Error(err))
+			return err
+		}
+	}
+
+	tele := telemetry.NewTelemetry(false, false, fs.NewTeleFS(n.logger), n.logger, "", nil)
+	for _, testSet := range testSets {
+		ys := yaml.NewYamlStore(filepath.Join(path, testSet, "tests"), filepath.Join(path, testSet), "", "", n.logger, tele)
+		tcsRead, err := ys.ReadTestcase("", nil, nil)
+		if err != nil {
+			n.logger.Error("failed to read the testcases", zap.Error(err), zap.Any("test-set", testSet))
+			return err
+		}
+		for _, tcRead := range tcsRead {
+			tc, ok := tcRead.(*models.TestCase)
+			if !ok || tc.Kind != models.HTTP {
+				continue
+			}
+			rules := n.Infer(tc)
+			if len(rules) == 0 {
+				continue
+			}
+			n.logger.Info("inferred assertion rules", zap.Any("test-set", testSet), zap.Any("testcase", tc.Name), zap.Any("rules", rules))
+			if !write {
+				continue
+			}
+			if err := ys.SetRules("", tc.Name, rules); err != nil {
+				return err
+			}
+		}
+	}
+	if !write {
+		n.logger.Info("run with --write to save the inferred rules into the testcases")
+	}
+	return nil
+}
+
+// Infer builds a starting rule set from the recorded JSON body. Noisy fields keep only their type,
+// ISO timestamps and UUIDs get a format rule. The rules already in the testcase are kept as they are.
+func (n *normalizer) Infer(tc *models.TestCase) models.AssertionRules {
+	var body interface{}
+	if err := json.Unmarshal([]byte(tc.HttpResp.Body), &body); err != nil {
+		return nil
+	}
+
+	noisy := map[string]bool{}
+	for field := range tc.Noise {
+		if strings.HasPrefix(field, "body.") {
+			noisy[field] = true
+		}
+	}
+
+	inferred := models.AssertionRules{}
+	conflicts := map[string]bool{}
+	walk(body, "body", func(path string, value interface{}) {
+		if conflicts[path] {
+			return
+		}
+		rule, ok := ruleFor(value, noisy[path])
+		// elements of an array share one path, keep the rule only when all of them agree
+		if previous, seen := inferred[path]; !ok || (seen && !reflect.DeepEqual(previous, rule)) {
+			delete(inferred, path)
+			conflicts[path] = true
+			return
+		}
+		inferred[path] = rule
+	})
+	return test.MergeRules(inferred, tc.Rules)
+}
+
+// walk calls visit for every scalar value of the body along with its path.
+func walk(v interface{}, path string, visit func(path string, value interface{})) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			walk(child, path+"."+key, visit)
+		}
+	case []interface{}:
+		for _, element := range val {
+			walk(element, path, visit)
+		}
+	default:
+		visit(path, v)
+	}
+}
+
+func ruleFor(value interface{}, noisy bool) (models.AssertionRule, bool) {
+	if str, ok := value.(string); ok {
+		if test.IsISOTimestamp(str) {
+			return models.AssertionRule{Type: "string", Timestamp: true}, true
+		}
+		if uuidRegex.MatchString(str) {
+			return models.AssertionRule{Type: "string", Regex: uuidRegex.String()}, true
+		}
+	}
+	if noisy && value != nil {
+		return models.AssertionRule{Type: test.JsonType(value)}, true
+	}
+	return models.AssertionRule{}, false
+}
@@ -0,0 +1,10 @@
+package normalize
+
+import "go.keploy.io/server/pkg/models"
+
+type Normalizer interface {
+	// Normalize infers the assertion rules of the testcases in the test-sets, all test-sets when
+	// none are given, and writes them into the testcase files when write is set.
+	Normalize(path string, testSets []string, write bool) error
+	Infer(tc *models.TestCase) models.AssertionRules
+}
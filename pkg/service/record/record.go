@@ -4,13 +4,16 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
 	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
 	"go.keploy.io/server/pkg/platform/fs"
+	"go.keploy.io/server/pkg/platform/sqlite"
 	"go.keploy.io/server/pkg/platform/telemetry"
 	"go.keploy.io/server/pkg/platform/yaml"
 	"go.keploy.io/server/pkg/proxy"
@@ -29,24 +32,49 @@ func NewRecorder(logger *zap.Logger) Recorder {
 	}
 }
 
-func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, Delay uint64, buildDelay time.Duration, ports []uint, filters *models.Filters, enableTele bool) {
+func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, Delay uint64, buildDelay, dnsTimeout time.Duration, ports []uint, passThrough []models.PassThroughRule, protocolPorts models.ProtocolPorts, filters *models.Filters, storage string, grpcSchema models.GrpcSchema, enableTele bool) {
 
 	var ps *proxy.ProxySet
 	stopper := make(chan os.Signal, 1)
 	signal.Notify(stopper, os.Interrupt, os.Kill, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGKILL)
 
 	models.SetMode(models.MODE_RECORD)
+	if err := pkg.LoadGrpcSchema(path, grpcSchema, r.Logger); err != nil {
+		return
+	}
 	teleFS := fs.NewTeleFS(r.Logger)
 	tele := telemetry.NewTelemetry(enableTele, false, teleFS, r.Logger, "", nil)
 	tele.Ping(false)
 
-	dirName, err := yaml.NewSessionIndex(path, r.Logger)
-	if err != nil {
-		r.Logger.Error("Failed to create the session index file", zap.Error(err))
-		return
+	var (
+		dirName string
+		ys      platform.TestCaseDB
+	)
+	switch storage {
+	case models.SqliteStorage:
+		sqliteStore, err := sqlite.NewSqliteStore(filepath.Join(path, models.SqliteDbName), "", "", r.Logger, tele)
+		if err != nil {
+			r.Logger.Error("failed to open the sqlite store", zap.Error(err))
+			return
+		}
+		defer sqliteStore.Close()
+		dirName, err = sqliteStore.NewSessionIndex()
+		if err != nil {
+			r.Logger.Error("Failed to create the session index", zap.Error(err))
+			return
+		}
+		sqliteStore.TcsPath = path + "/" + dirName + "/tests"
+		sqliteStore.MockPath = path + "/" + dirName
+		ys = sqliteStore
+	default:
+		var err error
+		dirName, err = yaml.NewSessionIndex(path, r.Logger)
+		if err != nil {
+			r.Logger.Error("Failed to create the session index file", zap.Error(err))
+			return
+		}
+		ys = yaml.NewYamlStore(path+"/"+dirName+"/tests", path+"/"+dirName, "", "", r.Logger, tele)
 	}
-
-	ys := yaml.NewYamlStore(path+"/"+dirName+"/tests", path+"/"+dirName, "", "", r.Logger, tele)
 	routineId := pkg.GenerateRandomID()
 	// Initiate the hooks and update the vaccant ProxyPorts map
 	loadedHooks, err := hooks.NewHook(ys, routineId, r.Logger)
@@ -79,7 +107,7 @@ func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appCont
 		return
 	default:
 		// start the BootProxy
-		ps = proxy.BootProxy(r.Logger, proxy.Option{Port: proxyPort}, appCmd, appContainer, 0, "", ports, loadedHooks, ctx, 0)
+		ps = proxy.BootProxy(r.Logger, proxy.Option{Port: proxyPort, DnsTimeout: dnsTimeout, PassThrough: passThrough, ProtocolPorts: protocolPorts}, appCmd, appContainer, 0, "", ports, loadedHooks, ctx, 0)
 	}
 
 	//proxy fetches the destIp and destPort from the redirect proxy map
@@ -122,6 +150,7 @@ func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appCont
 				loadedHooks.Stop(!stopApplication)
 				//stop listening for proxy server
 				ps.StopProxyServer()
+				r.reportPassThrough(loadedHooks)
 				exitCmd <- true
 			} else {
 				return
@@ -137,6 +166,7 @@ func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appCont
 			tele.RecordedTestSuite(dirName, testsTotal, mocksTotal)
 		}
 		ps.StopProxyServer()
+		r.reportPassThrough(loadedHooks)
 		return
 	case <-abortStopHooksInterrupt:
 		if testsTotal != 0 {
@@ -147,3 +177,11 @@ func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appCont
 
 	<-exitCmd
 }
+
+// reportPassThrough logs the outgoing calls which the pass-through rules sent to their destination without
+// recording them.
+func (r *recorder) reportPassThrough(h *hooks.Hook) {
+	for rule, calls := range h.PassThroughUsage() {
+		r.Logger.Info("outgoing calls passed through without being recorded", zap.String("rule", rule), zap.Int("calls", calls))
+	}
+}
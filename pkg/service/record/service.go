@@ -7,5 +7,5 @@ import (
 )
 
 type Recorder interface {
-	CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, networkName string, Delay uint64, buildDelay time.Duration, ports []uint, filters *models.Filters, enableTele bool)
+	CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, networkName string, Delay uint64, buildDelay, dnsTimeout time.Duration, ports []uint, passThrough []models.PassThroughRule, protocolPorts models.ProtocolPorts, filters *models.Filters, storage string, grpcSchema models.GrpcSchema, enableTele bool)
 }
@@ -14,6 +14,7 @@ import (
 	"go.keploy.io/server/pkg/platform/telemetry"
 	"go.keploy.io/server/pkg/platform/yaml"
 	"go.keploy.io/server/pkg/service/serve/graph/model"
+	"go.keploy.io/server/pkg/service/test"
 	"go.keploy.io/server/utils"
 	"go.uber.org/zap"
 )
@@ -63,7 +64,21 @@ func (r *mutationResolver) RunTestSet(ctx context.Context, testSet string) (*mod
 	go func() {
 		defer utils.HandlePanic()
 		r.Logger.Debug("starting testrun...", zap.Any("testSet", testSet))
-		tester.RunTestSet(testSet, testCasePath, testReportPath, "", "", "", delay, 30*time.Second, pid, ys, loadedHooks, testReportFS, testRunChan, r.ApiTimeout, ctx, nil, nil, serveTest)
+		tester.RunTestSet(&test.RunTestSetConfig{
+			TestSet:        testSet,
+			Path:           testCasePath,
+			TestReportPath: testReportPath,
+			Delay:          delay,
+			BuildDelay:     30 * time.Second,
+			Pid:            pid,
+			YamlStore:      ys,
+			LoadedHooks:    loadedHooks,
+			TestReportFS:   testReportFS,
+			TestRunChan:    testRunChan,
+			ApiTimeout:     r.ApiTimeout,
+			Ctx:            ctx,
+			ServeTest:      serveTest,
+		})
 	}()
 
 	testRunID := <-testRunChan
@@ -0,0 +1,190 @@
+package test
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/pp/v3"
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// testcaseURL returns the url on which the application serves the testcase. The gRPC calls are
+// served over h2c on the authority of the call.
+func testcaseURL(tc *models.TestCase) string {
+	if tc.Kind == models.GRPC_EXPORT {
+		headers := tc.GrpcReq.Headers.PseudoHeaders
+		return fmt.Sprintf("http://%s%s", headers[":authority"], headers[":path"])
+	}
+	return tc.HttpReq.URL
+}
+
+// simulateGrpc replays the gRPC call of the testcase and stores the result of its comparison
+// with the recorded response.
+func (t *tester) simulateGrpc(cfg *SimulateRequestConfig) {
+	started := time.Now().UTC()
+	t.logger.Debug("Before simulating the grpc call", zap.Any("Test case", cfg.Tc))
+
+	target := testcaseURL(cfg.Tc)
+	ok, _ := cfg.LoadedHooks.IsDockerRelatedCmd(cfg.AppCmd)
+	if ok || cfg.DockerID {
+		var err error
+		target, err = replaceHostToIP(target, cfg.UserIP)
+		if err != nil {
+			t.logger.Error("failed to replace host to docker container's IP", zap.Error(err))
+		}
+	}
+	if cfg.PortOffset > 0 {
+		var err error
+		target, err = shiftPort(target, cfg.PortOffset)
+		if err != nil {
+			t.logger.Error("failed to move the testcase to the port of its application instance", zap.Error(err))
+		}
+	}
+	if parsedURL, err := url.Parse(target); err == nil {
+		cfg.Tc.GrpcReq.Headers.PseudoHeaders[":authority"] = parsedURL.Host
+	}
+	t.logger.Debug(fmt.Sprintf("the authority of the testcase: %v", cfg.Tc.GrpcReq.Headers.PseudoHeaders[":authority"]))
+
+	simulated := time.Now()
+	resp, err := pkg.SimulateGrpc(*cfg.Tc, cfg.TestSet, t.logger, cfg.ApiTimeout)
+	latency := time.Since(simulated)
+	mockUsage := cfg.Coverage.collect(cfg.LoadedHooks, cfg.TcsMocks)
+	t.logger.Debug("After simulating the grpc call", zap.Any("test case id", cfg.Tc.Name))
+
+	if err != nil && resp == nil {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString("false")))
+		return
+	}
+	testPass, testResult := t.testGrpc(*cfg.Tc, resp, CompareLatency(cfg.Tc, latency, cfg.LatencyBudget))
+
+	if !testPass {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString(testPass)))
+	} else {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightPassingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightPassingString(cfg.TestSet)), zap.Any("passed", models.HighlightPassingString(testPass)))
+	}
+
+	testStatus := models.TestStatusPending
+	if testPass {
+		testStatus = models.TestStatusPassed
+		*cfg.Success++
+	} else {
+		testStatus = models.TestStatusFailed
+		*cfg.Failure++
+		*cfg.Status = models.TestRunStatusFailed
+	}
+
+	cfg.TestReportFS.SetResult(cfg.TestReport.Name, &models.TestResult{
+		Kind:         models.GRPC_EXPORT,
+		Name:         cfg.TestReport.Name,
+		Status:       testStatus,
+		Started:      started.Unix(),
+		Completed:    time.Now().UTC().Unix(),
+		TestCaseID:   cfg.Tc.Name,
+		GrpcReq:      cfg.Tc.GrpcReq,
+		GrpcRes:      cfg.Tc.GrpcResp,
+		TestCasePath: cfg.Path + "/" + cfg.TestSet,
+		Noise:        cfg.Tc.Noise,
+		Result:       *testResult,
+		Mocks:        mockUsage,
+	})
+}
+
+// testGrpc compares the status, the trailers and the decoded message of the response with the
+// recorded ones. The trailers are left out of the comparison by the "trailer.<name>" noise, the
+// fields of the message by the "body.<field path>" noise and the whole message by the "body" noise.
+func (t *tester) testGrpc(tc models.TestCase, actualResponse *models.GrpcResp, latency *models.LatencyResult) (bool, *models.Result) {
+	res := &models.Result{
+		StatusCode: models.IntResult{
+			Expected: grpcStatus(tc.GrpcResp),
+			Actual:   grpcStatus(*actualResponse),
+		},
+		BodyResult: []models.BodyResult{{
+			Type:     models.BodyTypePlain,
+			Expected: tc.GrpcResp.Body.DecodedData,
+			Actual:   actualResponse.Body.DecodedData,
+		}},
+		Latency: latency,
+	}
+	pass := true
+
+	trailerNoise, bodyNoise := map[string][]string{}, map[string][]string{}
+	for field, regexArr := range tc.Noise {
+		if name, ok := strings.CutPrefix(field, "trailer."); ok {
+			trailerNoise[name] = regexArr
+		} else if path, ok := strings.CutPrefix(field, "body."); ok {
+			bodyNoise[path] = regexArr
+		}
+	}
+	hRes := &[]models.HeaderResult{}
+	if !CompareHeaders(pkg.ToHttpHeader(tc.GrpcResp.Trailers.OrdinaryHeaders), pkg.ToHttpHeader(actualResponse.Trailers.OrdinaryHeaders), hRes, trailerNoise) {
+		pass = false
+	}
+	res.HeadersResult = *hRes
+
+	res.StatusCode.Normal = res.StatusCode.Expected == res.StatusCode.Actual
+	if !res.StatusCode.Normal {
+		pass = false
+	}
+
+	_, bodyNoisy := tc.Noise["body"]
+	res.BodyResult[0].Normal = bodyNoisy || pkg.MatchGrpcMessages(tc.GrpcResp.Body, actualResponse.Body, bodyNoise)
+	if !res.BodyResult[0].Normal {
+		pass = false
+	}
+
+	if latency != nil && !latency.Normal {
+		if latency.Mode == models.LatencyModeWarn {
+			t.logger.Warn("response time exceeded the latency budget", zap.String("testcase id", tc.Name), zap.Int64("recorded(ms)", latency.Expected), zap.Int64("budget(ms)", latency.Budget), zap.Int64("actual(ms)", latency.Actual))
+		} else {
+			pass = false
+		}
+	}
+
+	logger := pp.New()
+	logger.WithLineInfo = false
+	if pass {
+		logger.SetColorScheme(models.PassingColorScheme)
+		t.mutex.Lock()
+		logger.Printf(logger.Sprintf("Testrun passed for testcase with id: %s\n\n--------------------------------------------------------------------\n\n", tc.Name))
+		t.mutex.Unlock()
+		return pass, res
+	}
+
+	logDiffs := NewDiffsPrinter(tc.Name)
+	logger.SetColorScheme(models.FailingColorScheme)
+	logs := logger.Sprintf("Testrun failed for testcase with id: %s\n\n--------------------------------------------------------------------\n\n", tc.Name)
+	if latency != nil && !latency.Normal && latency.Mode != models.LatencyModeWarn {
+		logs = logs + logger.Sprintf("Response time regressed: recorded %dms, budget %dms, actual %dms\n\n", latency.Expected, latency.Budget, latency.Actual)
+	}
+	if !res.StatusCode.Normal {
+		logDiffs.PushStatusDiff(fmt.Sprint(res.StatusCode.Expected), fmt.Sprint(res.StatusCode.Actual))
+	}
+	for _, header := range res.HeadersResult {
+		if !header.Normal {
+			logDiffs.PushHeaderDiff(fmt.Sprint(header.Expected.Value), fmt.Sprint(header.Actual.Value), header.Expected.Key, trailerNoise)
+		}
+	}
+	if !res.BodyResult[0].Normal {
+		logDiffs.PushBodyDiff(res.BodyResult[0].Expected, res.BodyResult[0].Actual, bodyNoise)
+	}
+	t.mutex.Lock()
+	logger.Printf(logs)
+	logDiffs.Render()
+	t.mutex.Unlock()
+	return pass, res
+}
+
+// grpcStatus returns the status code of the call from the grpc-status trailer. A call without a
+// valid status is reported with -1.
+func grpcStatus(resp models.GrpcResp) int {
+	status, err := strconv.Atoi(resp.Trailers.OrdinaryHeaders["grpc-status"])
+	if err != nil {
+		return -1
+	}
+	return status
+}
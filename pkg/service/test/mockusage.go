@@ -0,0 +1,170 @@
+package test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockCoverage follows the consumption of the mocks of a test-set during its run. The mocks are
+// read again for every testcase, so the copies are told apart by their content: a mock recorded
+// once but consumed by the replay of several testcases is over-consumed.
+type mockCoverage struct {
+	keys          map[*models.Mock]string
+	refs          map[string]*models.MockRef
+	order         []string
+	consumed      map[string]int
+	unmatched     int
+	passedThrough map[string]int
+}
+
+// newMockCoverage starts following the mocks recorded for the test-set.
+func newMockCoverage(configMocks, tcsMocks []*models.Mock) *mockCoverage {
+	c := &mockCoverage{
+		keys:          map[*models.Mock]string{},
+		refs:          map[string]*models.MockRef{},
+		consumed:      map[string]int{},
+		passedThrough: map[string]int{},
+	}
+	for _, mock := range append(append([]*models.Mock{}, configMocks...), tcsMocks...) {
+		key := mockKey(mock)
+		if key == "" {
+			continue
+		}
+		c.keys[mock] = key
+		ref, ok := c.refs[key]
+		if !ok {
+			ref = newMockRef(mock)
+			c.refs[key] = ref
+			c.order = append(c.order, key)
+		}
+		ref.Recorded++
+	}
+	return c
+}
+
+// track registers the copies of the mocks read for a testcase. It has to be called before the
+// mocks are handed to the hooks, since the parsers may change them while they are consumed.
+func (c *mockCoverage) track(mocks []*models.Mock) {
+	for _, mock := range mocks {
+		if _, ok := c.keys[mock]; ok {
+			continue
+		}
+		if key := mockKey(mock); key != "" {
+			c.keys[mock] = key
+		}
+	}
+}
+
+// collect takes the usage counted by the hooks since the previous call and returns the mocks of
+// tcsMocks which were not consumed along with the outgoing calls which no mock matched.
+func (c *mockCoverage) collect(h *hooks.Hook, tcsMocks []*models.Mock) models.MockUsage {
+	consumed, unmatched := h.MockUsage()
+	for rule, calls := range h.PassThroughUsage() {
+		c.passedThrough[rule] += calls
+	}
+	for mock := range consumed {
+		if key, ok := c.keys[mock]; ok {
+			c.consumed[key]++
+		}
+	}
+	c.unmatched += len(unmatched)
+
+	usage := models.MockUsage{Unmatched: unmatched}
+	for _, mock := range tcsMocks {
+		if consumed[mock] == 0 {
+			usage.Unused = append(usage.Unused, *newMockRef(mock))
+		}
+	}
+	return usage
+}
+
+// report returns the consumption of the mocks of the test-set over the run.
+func (c *mockCoverage) report() *models.MockCoverage {
+	coverage := &models.MockCoverage{Unmatched: c.unmatched}
+	if len(c.passedThrough) > 0 {
+		coverage.PassedThrough = c.passedThrough
+	}
+	for _, key := range c.order {
+		ref := *c.refs[key]
+		ref.Consumed = c.consumed[key]
+		coverage.Total += ref.Recorded
+		switch {
+		case ref.Consumed == 0:
+			coverage.Unused = append(coverage.Unused, ref)
+		case ref.Consumed > ref.Recorded:
+			coverage.Used += ref.Recorded
+			// config mocks are shared by the connections and expected to be consumed many times
+			if ref.Type != "config" {
+				coverage.OverConsumed = append(coverage.OverConsumed, ref)
+			}
+		default:
+			coverage.Used += ref.Consumed
+		}
+	}
+	return coverage
+}
+
+// unused reports whether the mock was recorded for the test-set and never consumed.
+func (c *mockCoverage) unused(mockRead platform.KindSpecifier) bool {
+	mock, ok := mockRead.(*models.Mock)
+	if !ok {
+		return false
+	}
+	key := mockKey(mock)
+	if _, recorded := c.refs[key]; !recorded {
+		return false
+	}
+	return c.consumed[key] == 0
+}
+
+// pruneUnusedMocks removes the mocks of the test-set which its run never consumed. Only complete
+// and passing runs are pruned, the mocks of skipped or failing testcases would be lost otherwise.
+func (t *tester) pruneUnusedMocks(store platform.TestCaseDB, path, testSet string, status models.TestRunStatus, filtered bool, coverage *mockCoverage, ctx context.Context) {
+	switch {
+	case filtered:
+		t.logger.Warn("the unused mocks are not pruned when only some testcases of the test-set are run", zap.Any("test-set", testSet))
+		return
+	case status != models.TestRunStatusPassed:
+		t.logger.Warn("the unused mocks are only pruned when all the testcases of the test-set pass", zap.Any("test-set", testSet), zap.Any("status", status))
+		return
+	}
+	pruner, ok := store.(platform.MockPruner)
+	if !ok {
+		t.logger.Warn("the storage does not support pruning the mocks", zap.Any("test-set", testSet))
+		return
+	}
+	removed, err := pruner.DeleteMocks(path, coverage.unused, ctx)
+	if err != nil {
+		t.logger.Error("failed to prune the unused mocks", zap.Error(err), zap.Any("test-set", testSet))
+		return
+	}
+	t.logger.Info("pruned the unused mocks", zap.Any("test-set", testSet), zap.Int("removed", removed))
+}
+
+func newMockRef(mock *models.Mock) *models.MockRef {
+	return &models.MockRef{
+		Kind:      mock.Kind,
+		Type:      mock.Spec.Metadata["type"],
+		Timestamp: mock.Spec.ReqTimestampMock,
+	}
+}
+
+// mockKey identifies a mock by its content, the id set by the hooks is left out. An empty key is
+// returned for the mocks which can't be encoded, they are left out of the coverage.
+func mockKey(mock *models.Mock) string {
+	keyed := *mock
+	keyed.Id = ""
+	data, err := json.Marshal(keyed)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package test
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.uber.org/zap"
+)
+
+// noiseStore is implemented by the storage backends that can rewrite the noise of a recorded testcase.
+type noiseStore interface {
+	SetNoise(path, name string, noise map[string][]string) error
+}
+
+// NoisyFields returns the flattened header and body fields that are not the same in every response.
+func NoisyFields(responses []*models.HttpResp) []string {
+	flattened := make([]map[string][]string, 0, len(responses))
+	fields := map[string][]string{}
+	for _, resp := range responses {
+		m, _ := FlattenHttpResponse(pkg.ToHttpHeader(resp.Header), resp.Body)
+		flattened = append(flattened, m)
+		for k, v := range m {
+			fields[k] = v
+		}
+	}
+	noisy := yaml.FindNoisyFields(fields, func(k string, _ []string) bool {
+		for _, m := range flattened[1:] {
+			if !reflect.DeepEqual(flattened[0][k], m[k]) {
+				return true
+			}
+		}
+		return false
+	})
+	sort.Strings(noisy)
+	return noisy
+}
+
+// detectNoise replays the testcase against the same mocks until there are NoiseRuns responses and
+// writes the fields that changed between them into the noise of the testcase.
+func (t *tester) detectNoise(cfg *SimulateRequestConfig, resp *models.HttpResp) {
+	responses := []*models.HttpResp{resp}
+	for i := 1; i < cfg.NoiseRuns; i++ {
+		if err := cfg.LoadedHooks.SetTcsMocks(cfg.TcsMocks); err != nil {
+			t.logger.Error("failed to reload the mocks of the testcase to detect noise", zap.Error(err), zap.Any("testcase id", cfg.Tc.Name))
+			return
+		}
+		replayed, err := pkg.SimulateHttp(*cfg.Tc, cfg.TestSet, t.logger, cfg.ApiTimeout)
+		if err != nil {
+			t.logger.Warn("failed to replay the testcase to detect noise", zap.Error(err), zap.Any("testcase id", cfg.Tc.Name))
+			return
+		}
+		responses = append(responses, replayed)
+	}
+
+	noise := map[string][]string{}
+	for field, regexArr := range cfg.Tc.Noise {
+		noise[field] = regexArr
+	}
+	added := []string{}
+	for _, field := range NoisyFields(responses) {
+		if _, ok := noise[field]; ok {
+			continue
+		}
+		noise[field] = []string{}
+		added = append(added, field)
+	}
+	if len(added) == 0 {
+		return
+	}
+	cfg.Tc.Noise = noise
+
+	store, ok := cfg.YamlStore.(noiseStore)
+	if !ok {
+		t.logger.Warn("the storage backend can't update the noise of the testcase", zap.Any("testcase id", cfg.Tc.Name), zap.Strings("noisy fields", added))
+		return
+	}
+	if err := store.SetNoise(filepath.Join(cfg.Path, cfg.TestSet, "tests"), cfg.Tc.Name, noise); err != nil {
+		t.logger.Error("failed to write the detected noise into the testcase", zap.Error(err), zap.Any("testcase id", cfg.Tc.Name))
+		return
+	}
+	t.mutex.Lock()
+	cfg.DetectedNoise[cfg.Tc.Name] = added
+	t.mutex.Unlock()
+	t.logger.Info("added the noisy fields to the testcase", zap.Any("testcase id", cfg.Tc.Name), zap.Strings("noisy fields", added))
+}
@@ -0,0 +1,98 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// AppPortPlaceholder in the application command is replaced by the port an instance has to listen
+// on, the same port is exported to the instance as KEPLOY_APP_PORT.
+const AppPortPlaceholder = "{port}"
+
+// runParallel replays the test-sets on the given number of instances of the user application and
+// returns the status of every test-set. A failure of the application stops the test-sets that
+// haven't started yet.
+func (t *tester) runParallel(lanes int, testSets []string, run func(testSet string, lane int) models.TestRunStatus) map[string]models.TestRunStatus {
+	var (
+		statuses = map[string]models.TestRunStatus{}
+		mu       sync.Mutex
+		halted   bool
+		wg       sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for i := 0; i < lanes; i++ {
+		wg.Add(1)
+		go func(lane int) {
+			defer wg.Done()
+			for testSet := range jobs {
+				status := run(testSet, lane)
+				mu.Lock()
+				statuses[testSet] = status
+				switch status {
+				case models.TestRunStatusAppHalted, models.TestRunStatusFaultUserApp, models.TestRunStatusUserAbort:
+					halted = true
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	for _, testSet := range testSets {
+		mu.Lock()
+		stop := halted
+		mu.Unlock()
+		if stop {
+			t.logger.Warn("skipping the test-set as the user application halted", zap.Any("test-set", testSet))
+			continue
+		}
+		jobs <- testSet
+	}
+	close(jobs)
+	wg.Wait()
+	return statuses
+}
+
+// lanePort returns the port the instance of the lane listens on for the recorded testcase url.
+func lanePort(rawURL string, offset int) (string, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	port := parsedURL.Port()
+	if port == "" {
+		port = "80"
+		if parsedURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(p + offset), nil
+}
+
+// shiftPort moves the testcase url to the port of the instance of the lane.
+func shiftPort(rawURL string, offset int) (string, error) {
+	port, err := lanePort(rawURL, offset)
+	if err != nil {
+		return rawURL, err
+	}
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL, err
+	}
+	parsedURL.Host = net.JoinHostPort(parsedURL.Hostname(), port)
+	return parsedURL.String(), nil
+}
+
+// laneAppCmd returns the application command and environment of the instance listening on port.
+func laneAppCmd(appCmd, port string) (string, []string) {
+	return strings.ReplaceAll(appCmd, AppPortPlaceholder, port), []string{fmt.Sprintf("KEPLOY_APP_PORT=%s", port)}
+}
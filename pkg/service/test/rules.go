@@ -0,0 +1,224 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// isoLayouts are the ISO 8601 layouts accepted by the timestamp rule.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02",
+}
+
+// IsISOTimestamp reports whether s is an ISO 8601 date or date-time.
+func IsISOTimestamp(s string) bool {
+	for _, layout := range isoLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// RulesFor returns the assertion rules of the test-set, its rules override the global ones for the same path.
+func RulesFor(assertions models.AssertionConfig, testSet string) models.AssertionRules {
+	return MergeRules(assertions.Global, assertions.Testsets[testSet])
+}
+
+// MergeRules returns the union of the rule sets, a later set overrides an earlier one for the same path.
+func MergeRules(ruleSets ...models.AssertionRules) models.AssertionRules {
+	merged := models.AssertionRules{}
+	for _, rules := range ruleSets {
+		for path, rule := range rules {
+			merged[path] = rule
+		}
+	}
+	return merged
+}
+
+// ApplyRules checks the actual JSON body against the assertion rules and returns a result per path
+// along with whether all of them passed. A path that is missing from the body fails its rule.
+func ApplyRules(actualBody string, rules models.AssertionRules, log *zap.Logger) ([]models.AssertionResult, bool) {
+	var actual interface{}
+	if err := json.Unmarshal([]byte(actualBody), &actual); err != nil {
+		log.Debug("skipping the assertion rules as the response body is not json", zap.Error(err))
+		return nil, true
+	}
+
+	paths := make([]string, 0, len(rules))
+	for path := range rules {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	pass := true
+	results := make([]models.AssertionResult, 0, len(paths))
+	for _, path := range paths {
+		result := models.AssertionResult{Normal: true, Path: path}
+		values, found := valuesAt(actual, rulePath(path))
+		if !found {
+			result.Normal = false
+			result.Message = "path not found in the response body"
+		}
+		for _, value := range values {
+			if msg := checkRule(rules[path], value); msg != "" {
+				result.Normal = false
+				result.Message = msg
+				break
+			}
+		}
+		pass = pass && result.Normal
+		results = append(results, result)
+	}
+	return results, pass
+}
+
+// rulePath splits a rule path such as "body.data.id" into the keys below the body.
+func rulePath(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "body"), ".")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+// noiseKey returns the body noise key that ignores the recorded value of the rule path.
+func noiseKey(path string) string {
+	return strings.Join(rulePath(path), ".")
+}
+
+// valuesAt collects the values at keys. Arrays on the way are walked element by element.
+func valuesAt(v interface{}, keys []string) ([]interface{}, bool) {
+	if len(keys) == 0 {
+		return []interface{}{v}, true
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		child, ok := val[keys[0]]
+		if !ok {
+			return nil, false
+		}
+		return valuesAt(child, keys[1:])
+	case []interface{}:
+		values := []interface{}{}
+		for _, element := range val {
+			elementValues, ok := valuesAt(element, keys)
+			if !ok {
+				return nil, false
+			}
+			values = append(values, elementValues...)
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+// JsonType returns the JSON type name of a value decoded by encoding/json.
+func JsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return reflect.TypeOf(v).String()
+}
+
+// checkRule returns why value breaks the rule, or an empty string when it passes.
+func checkRule(rule models.AssertionRule, value interface{}) string {
+	// a rule about the elements of an array is applied to each of them
+	if arr, ok := value.([]interface{}); ok && rule.Type != "array" && rule.MinLength == nil && rule.MaxLength == nil {
+		for _, element := range arr {
+			if msg := checkRule(rule, element); msg != "" {
+				return msg
+			}
+		}
+		return ""
+	}
+
+	if rule.Type != "" && JsonType(value) != rule.Type {
+		return fmt.Sprintf("expected type %s, got %s", rule.Type, JsonType(value))
+	}
+	if rule.Regex != "" {
+		re, err := regexp.Compile(rule.Regex)
+		if err != nil {
+			return fmt.Sprintf("invalid regex %q: %v", rule.Regex, err)
+		}
+		if value == nil || !re.MatchString(InterfaceToString(value)) {
+			return fmt.Sprintf("value %v does not match %q", value, rule.Regex)
+		}
+	}
+	if rule.Min != nil || rule.Max != nil {
+		num, ok := value.(float64)
+		if !ok {
+			return fmt.Sprintf("expected a number for the range check, got %s", JsonType(value))
+		}
+		if rule.Min != nil && num < *rule.Min {
+			return fmt.Sprintf("value %v is less than %v", num, *rule.Min)
+		}
+		if rule.Max != nil && num > *rule.Max {
+			return fmt.Sprintf("value %v is greater than %v", num, *rule.Max)
+		}
+	}
+	if rule.MinLength != nil || rule.MaxLength != nil {
+		arr, ok := value.([]interface{})
+		if !ok {
+			return fmt.Sprintf("expected an array for the length check, got %s", JsonType(value))
+		}
+		if rule.MinLength != nil && len(arr) < *rule.MinLength {
+			return fmt.Sprintf("array length %d is less than %d", len(arr), *rule.MinLength)
+		}
+		if rule.MaxLength != nil && len(arr) > *rule.MaxLength {
+			return fmt.Sprintf("array length %d is greater than %d", len(arr), *rule.MaxLength)
+		}
+	}
+	if len(rule.OneOf) > 0 && !oneOf(rule.OneOf, value) {
+		return fmt.Sprintf("value %v is not one of %v", value, rule.OneOf)
+	}
+	if rule.Timestamp {
+		str, ok := value.(string)
+		if !ok || !IsISOTimestamp(str) {
+			return fmt.Sprintf("value %v is not an ISO 8601 timestamp", value)
+		}
+	}
+	return ""
+}
+
+// oneOf compares value with the allowed values after converting them to their json form, so
+// that an integer read from yaml equals the float64 decoded from the body.
+func oneOf(allowed []interface{}, value interface{}) bool {
+	for _, option := range allowed {
+		data, err := json.Marshal(option)
+		if err != nil {
+			continue
+		}
+		var normalised interface{}
+		if err := json.Unmarshal(data, &normalised); err != nil {
+			continue
+		}
+		if reflect.DeepEqual(normalised, value) {
+			return true
+		}
+	}
+	return false
+}
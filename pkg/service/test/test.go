@@ -9,6 +9,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
@@ -21,8 +22,9 @@ import (
 	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
-	"go.keploy.io/server/pkg/platform"
+	"go.keploy.io/server/pkg/platform/export"
 	"go.keploy.io/server/pkg/platform/fs"
+	"go.keploy.io/server/pkg/platform/sqlite"
 	"go.keploy.io/server/pkg/platform/telemetry"
 	"go.keploy.io/server/pkg/platform/yaml"
 	"go.keploy.io/server/pkg/proxy"
@@ -40,6 +42,7 @@ type TestOptions struct {
 	Delay              uint64
 	BuildDelay         time.Duration
 	PassThroughPorts   []uint
+	PassThrough        []models.PassThroughRule
 	ApiTimeout         uint64
 	Tests              map[string][]string
 	AppContainer       string
@@ -49,6 +52,19 @@ type TestOptions struct {
 	TestsetNoise       models.TestsetNoise
 	WithCoverage       bool
 	CoverageReportPath string
+	Storage            string
+	ReportFormats      []string
+	ReportDir          string
+	Latency            models.LatencyConfig
+	Assertions         models.AssertionConfig
+	MockMatching       models.MockMatching
+	ProtocolPorts      models.ProtocolPorts
+	NoiseRuns          int
+	Update             *UpdateOptions
+	Parallel           int
+	PruneUnusedMocks   bool
+	DnsTimeout         time.Duration
+	GrpcSchema         models.GrpcSchema
 }
 
 func NewTester(logger *zap.Logger) Tester {
@@ -110,14 +126,42 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (InitialiseTestReturn, error) {
 	signal.Notify(stopper, os.Interrupt, os.Kill, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGKILL)
 
 	models.SetMode(models.MODE_TEST)
+	if err := pkg.LoadGrpcSchema(cfg.Path, cfg.GrpcSchema, t.logger); err != nil {
+		return returnVal, err
+	}
 
 	teleFS := fs.NewTeleFS(t.logger)
 	tele := telemetry.NewTelemetry(cfg.EnableTele, false, teleFS, t.logger, "", nil)
 
-	returnVal.TestReportFS = yaml.NewTestReportFS(t.logger)
-	// fetch the recorded testcases with their mocks
-	yamlStore := yaml.NewYamlStore(cfg.Path+"/tests", cfg.Path, "", "", t.logger, tele)
-	returnVal.YamlStore = yamlStore
+	switch cfg.Storage {
+	case models.SqliteStorage:
+		dbPath := filepath.Join(cfg.Path, models.SqliteDbName)
+		returnVal.TestReportFS, err = sqlite.NewTestReportDB(dbPath, t.logger)
+		if err != nil {
+			return returnVal, fmt.Errorf("error while opening the sqlite test report store %v", err)
+		}
+		// fetch the recorded testcases with their mocks
+		sqliteStore, err := sqlite.NewSqliteStore(dbPath, cfg.Path+"/tests", cfg.Path, t.logger, tele)
+		if err != nil {
+			return returnVal, fmt.Errorf("error while opening the sqlite store %v", err)
+		}
+		returnVal.YamlStore = sqliteStore
+		returnVal.Sessions, err = sqliteStore.ReadSessionIndices()
+		if err != nil {
+			t.logger.Debug("failed to read the recorded sessions", zap.Error(err))
+			return returnVal, err
+		}
+	default:
+		returnVal.TestReportFS = yaml.NewTestReportFS(t.logger)
+		// fetch the recorded testcases with their mocks
+		yamlStore := yaml.NewYamlStore(cfg.Path+"/tests", cfg.Path, "", "", t.logger, tele)
+		returnVal.YamlStore = yamlStore
+		returnVal.Sessions, err = yaml.ReadSessionIndices(cfg.Path, t.logger)
+		if err != nil {
+			t.logger.Debug("failed to read the recorded sessions", zap.Error(err))
+			return returnVal, err
+		}
+	}
 	routineId := pkg.GenerateRandomID()
 	// Initiate the hooks
 	returnVal.LoadedHooks, err = hooks.NewHook(returnVal.YamlStore, routineId, t.logger)
@@ -141,7 +185,7 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (InitialiseTestReturn, error) {
 		return returnVal, errors.New("Keploy was interupted by stopper")
 	default:
 		// start the proxy
-		returnVal.ProxySet = proxy.BootProxy(t.logger, proxy.Option{Port: cfg.Proxyport, MongoPassword: cfg.MongoPassword}, cfg.AppCmd, cfg.AppContainer, 0, "", cfg.PassThroughPorts, returnVal.LoadedHooks, context.Background(), cfg.Delay)
+		returnVal.ProxySet = proxy.BootProxy(t.logger, proxy.Option{Port: cfg.Proxyport, MongoPassword: cfg.MongoPassword, DnsTimeout: cfg.DnsTimeout, PassThrough: cfg.PassThrough, MockMatching: cfg.MockMatching, ProtocolPorts: cfg.ProtocolPorts}, cfg.AppCmd, cfg.AppContainer, 0, "", cfg.PassThroughPorts, returnVal.LoadedHooks, context.Background(), cfg.Delay)
 	}
 
 	// proxy update its state in the ProxyPorts map
@@ -155,13 +199,7 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (InitialiseTestReturn, error) {
 		return returnVal, err
 	}
 
-	sessions, err := yaml.ReadSessionIndices(cfg.Path, t.logger)
-	if err != nil {
-		t.logger.Debug("failed to read the recorded sessions", zap.Error(err))
-		return returnVal, err
-	}
-	t.logger.Debug(fmt.Sprintf("the session indices are:%v", sessions))
-	returnVal.Sessions = sessions
+	t.logger.Debug(fmt.Sprintf("the session indices are:%v", returnVal.Sessions))
 
 	// Channels to communicate between different types of closing keploy
 	returnVal.AbortStopHooksInterrupt = make(chan bool) // channel to stop closing of keploy via interrupt
@@ -169,6 +207,8 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (InitialiseTestReturn, error) {
 	returnVal.ExitCmd = make(chan bool)                 // channel to exit this command
 	resultForTele := []int{0, 0}
 	returnVal.Ctx = context.WithValue(context.Background(), "resultForTele", &resultForTele)
+	testReports := []*models.TestReport{}
+	returnVal.Ctx = context.WithValue(returnVal.Ctx, "testReports", &testReports)
 
 	go func() {
 		select {
@@ -209,11 +249,17 @@ func (t *tester) Test(path string, testReportPath string, appCmd string, options
 		Delay:              options.Delay,
 		BuildDelay:         options.BuildDelay,
 		PassThroughPorts:   options.PassThroughPorts,
+		PassThrough:        options.PassThrough,
 		ApiTimeout:         options.ApiTimeout,
 		MongoPassword:      options.MongoPassword,
 		WithCoverage:       options.WithCoverage,
 		CoverageReportPath: options.CoverageReportPath,
 		EnableTele:         enableTele,
+		Storage:            options.Storage,
+		DnsTimeout:         options.DnsTimeout,
+		GrpcSchema:         options.GrpcSchema,
+		MockMatching:       options.MockMatching,
+		ProtocolPorts:      options.ProtocolPorts,
 	}
 	initialisedValues, err := t.InitialiseTest(cfg)
 	// Recover from panic and gracfully shutdown
@@ -222,39 +268,115 @@ func (t *tester) Test(path string, testReportPath string, appCmd string, options
 		t.logger.Error("failed to initialise the test", zap.Error(err))
 		return false
 	}
-	for _, sessionIndex := range initialisedValues.Sessions {
-		// checking whether the provided testset match with a recorded testset.
+	// runTestSet replays a test-set on the instance of the user application launched by the hook
+	runTestSet := func(sessionIndex string, loadedHooks *hooks.Hook, portOffset int) models.TestRunStatus {
 		testcases := ArrayToMap(options.Tests[sessionIndex])
-		if _, ok := options.Tests[sessionIndex]; !ok && len(options.Tests) != 0 {
-			continue
-		}
 		noiseConfig := options.GlobalNoise
 		if tsNoise, ok := options.TestsetNoise[sessionIndex]; ok {
 			noiseConfig = LeftJoinNoise(options.GlobalNoise, tsNoise)
 		}
+		return t.RunTestSet(&RunTestSetConfig{
+			TestSet:        sessionIndex,
+			Path:           path,
+			TestReportPath: testReportPath,
+			AppCmd:         appCmd,
+			AppContainer:   options.AppContainer,
+			AppNetwork:     options.AppNetwork,
+			Delay:          options.Delay,
+			BuildDelay:     options.BuildDelay,
+			YamlStore:      initialisedValues.YamlStore,
+			LoadedHooks:    loadedHooks,
+			TestReportFS:   initialisedValues.TestReportFS,
+			ApiTimeout:     options.ApiTimeout,
+			Ctx:            initialisedValues.Ctx,
+			Testcases:      testcases,
+			NoiseConfig:    noiseConfig,
+			LatencyBudget:  LatencyBudgetFor(options.Latency, sessionIndex),
+			Rules:          RulesFor(options.Assertions, sessionIndex),
+			NoiseRuns:      options.NoiseRuns,
+			Update:         options.Update,
+			PruneMocks:     options.PruneUnusedMocks,
+			PortOffset:     portOffset,
+		})
+	}
 
-		testRunStatus := t.RunTestSet(sessionIndex, path, testReportPath, appCmd, options.AppContainer, options.AppNetwork, options.Delay, options.BuildDelay, 0, initialisedValues.YamlStore, initialisedValues.LoadedHooks, initialisedValues.TestReportFS, nil, options.ApiTimeout, initialisedValues.Ctx, testcases, noiseConfig, false)
+	testSets := []string{}
+	for _, sessionIndex := range initialisedValues.Sessions {
+		// checking whether the provided testset match with a recorded testset.
+		if _, ok := options.Tests[sessionIndex]; !ok && len(options.Tests) != 0 {
+			continue
+		}
+		testSets = append(testSets, sessionIndex)
+	}
 
-		switch testRunStatus {
-		case models.TestRunStatusAppHalted:
-			testRes = false
-			exitLoop = true
-		case models.TestRunStatusFaultUserApp:
-			testRes = false
-			exitLoop = true
-		case models.TestRunStatusUserAbort:
-			return false
-		case models.TestRunStatusFailed:
-			testRes = false
-		case models.TestRunStatusPassed:
-			testRes = true
+	if isDockerCmd, _ := initialisedValues.LoadedHooks.IsDockerRelatedCmd(appCmd); options.Parallel > 1 && (appCmd == "" || isDockerCmd) {
+		t.logger.Warn("parallel test runs need a native command to launch the user application, running the test-sets one after another")
+		options.Parallel = 0
+	}
+
+	if options.Parallel > 1 {
+		statuses := t.runParallel(options.Parallel, testSets, func(testSet string, lane int) models.TestRunStatus {
+			laneHooks, err := initialisedValues.LoadedHooks.NewLane()
+			if err != nil {
+				t.logger.Error("failed to create the hooks for a parallel instance of the user application", zap.Error(err))
+				return models.TestRunStatusFailed
+			}
+			defer initialisedValues.LoadedHooks.ReleaseLane(laneHooks)
+			initialisedValues.ProxySet.AddLane(laneHooks)
+			defer initialisedValues.ProxySet.RemoveLane(laneHooks)
+			return runTestSet(testSet, laneHooks, lane)
+		})
+		for _, testSet := range testSets {
+			status, ok := statuses[testSet]
+			if !ok {
+				result = false
+				continue
+			}
+			if status == models.TestRunStatusUserAbort {
+				return false
+			}
+			result = result && status == models.TestRunStatusPassed
 		}
-		result = result && testRes
-		if exitLoop {
-			break
+	} else {
+		for _, sessionIndex := range testSets {
+			testRunStatus := runTestSet(sessionIndex, initialisedValues.LoadedHooks, 0)
+
+			switch testRunStatus {
+			case models.TestRunStatusAppHalted:
+				testRes = false
+				exitLoop = true
+			case models.TestRunStatusFaultUserApp:
+				testRes = false
+				exitLoop = true
+			case models.TestRunStatusUserAbort:
+				return false
+			case models.TestRunStatusFailed:
+				testRes = false
+			case models.TestRunStatusPassed:
+				testRes = true
+			}
+			result = result && testRes
+			if exitLoop {
+				break
+			}
 		}
 	}
 	t.logger.Info("test run completed", zap.Bool("passed overall", result))
+	// export the reports of the test-sets in the formats understood by the CI systems
+	if testReports, ok := initialisedValues.Ctx.Value("testReports").(*[]*models.TestReport); ok && len(options.ReportFormats) > 0 {
+		reportDir := options.ReportDir
+		if reportDir == "" {
+			reportDir = testReportPath
+		}
+		// parallel test-sets complete in any order
+		sort.Slice(*testReports, func(i, j int) bool {
+			return (*testReports)[i].TestSet < (*testReports)[j].TestSet
+		})
+		err := export.ExportAll(*testReports, options.ReportFormats, reportDir, t.logger)
+		if err != nil {
+			t.logger.Error("failed to export the test reports", zap.Error(err))
+		}
+	}
 	// log the overall code coverage for the test run of go binaries
 	if options.WithCoverage {
 		t.logger.Info("there is a opportunity to get the coverage here")
@@ -339,6 +461,7 @@ func (t *tester) InitialiseRunTestSet(cfg *RunTestSetConfig) InitialiseRunTestSe
 		return returnVal
 	}
 	t.logger.Debug(fmt.Sprintf("the config mocks for %s are: %v\nthe testcase mocks are: %v", cfg.TestSet, configMocks, returnVal.TcsMocks))
+	returnVal.Coverage = newMockCoverage(readConfigMocks, readTcsMocks)
 	cfg.LoadedHooks.SetConfigMocks(readConfigMocks)
 	cfg.LoadedHooks.SetTcsMocks(readTcsMocks)
 	returnVal.ErrChan = make(chan error, 1)
@@ -348,10 +471,23 @@ func (t *tester) InitialiseRunTestSet(cfg *RunTestSetConfig) InitialiseRunTestSe
 		t.logger.Debug("running keploy tests along with other unit tests")
 	} else {
 		t.logger.Info("running user application for", zap.Any("test-set", models.HighlightString(cfg.TestSet)))
+		appCmd := cfg.AppCmd
+		// every instance of a parallel run listens on its own port
+		if cfg.PortOffset > 0 || strings.Contains(appCmd, AppPortPlaceholder) {
+			port, err := lanePort(testcaseURL(returnVal.Tcs[0]), cfg.PortOffset)
+			if err != nil {
+				t.logger.Error("failed to find the port of the user application", zap.Error(err))
+				returnVal.InitialStatus = models.TestRunStatusFailed
+				return returnVal
+			}
+			var appEnv []string
+			appCmd, appEnv = laneAppCmd(appCmd, port)
+			cfg.LoadedHooks.SetAppEnv(appEnv...)
+		}
 		// start user application
 		if !cfg.ServeTest {
 			go func() {
-				if err := cfg.LoadedHooks.LaunchUserApplication(cfg.AppCmd, cfg.AppContainer, cfg.AppNetwork, cfg.Delay, cfg.BuildDelay, false); err != nil {
+				if err := cfg.LoadedHooks.LaunchUserApplication(appCmd, cfg.AppContainer, cfg.AppNetwork, cfg.Delay, cfg.BuildDelay, false); err != nil {
 					switch err {
 					case hooks.ErrInterrupted:
 						t.logger.Info("keploy terminated user application")
@@ -413,6 +549,7 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 		started := time.Now().UTC()
 		t.logger.Debug("Before simulating the request", zap.Any("Test case", cfg.Tc))
 
+		recordedURL := cfg.Tc.HttpReq.URL
 		ok, _ := cfg.LoadedHooks.IsDockerRelatedCmd(cfg.AppCmd)
 		if ok || cfg.DockerID {
 			var err error
@@ -422,8 +559,18 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			}
 			t.logger.Debug("", zap.Any("replaced URL in case of docker env", cfg.Tc.HttpReq.URL))
 		}
+		if cfg.PortOffset > 0 {
+			var err error
+			cfg.Tc.HttpReq.URL, err = shiftPort(cfg.Tc.HttpReq.URL, cfg.PortOffset)
+			if err != nil {
+				t.logger.Error("failed to move the testcase to the port of its application instance", zap.Error(err))
+			}
+		}
 		t.logger.Debug(fmt.Sprintf("the url of the testcase: %v", cfg.Tc.HttpReq.URL))
+		simulated := time.Now()
 		resp, err := pkg.SimulateHttp(*cfg.Tc, cfg.TestSet, t.logger, cfg.ApiTimeout)
+		latency := time.Since(simulated)
+		mockUsage := cfg.Coverage.collect(cfg.LoadedHooks, cfg.TcsMocks)
 		t.logger.Debug("After simulating the request", zap.Any("test case id", cfg.Tc.Name))
 		t.logger.Debug("After GetResp of the request", zap.Any("test case id", cfg.Tc.Name))
 
@@ -431,7 +578,12 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString("false")))
 			return
 		}
-		testPass, testResult := t.testHttp(*cfg.Tc, resp, cfg.NoiseConfig)
+		if cfg.NoiseRuns > 1 {
+			t.detectNoise(cfg, resp)
+			// the mocks consumed again by the noise detection runs are not counted
+			cfg.LoadedHooks.MockUsage()
+		}
+		testPass, testResult := t.testHttp(*cfg.Tc, resp, cfg.NoiseConfig, CompareLatency(cfg.Tc, latency, cfg.LatencyBudget), cfg.Rules)
 
 		if !testPass {
 			t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString(testPass)))
@@ -439,6 +591,10 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			t.logger.Info("result", zap.Any("testcase id", models.HighlightPassingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightPassingString(cfg.TestSet)), zap.Any("passed", models.HighlightPassingString(testPass)))
 		}
 
+		if !testPass && cfg.Update != nil {
+			t.updateTest(cfg, resp, recordedURL)
+		}
+
 		testStatus := models.TestStatusPending
 		if testPass {
 			testStatus = models.TestStatusPassed
@@ -480,8 +636,13 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			// Noise:        httpSpec.Assertions["noise"],
 			Noise:  cfg.Tc.Noise,
 			Result: *testResult,
+			Mocks:  mockUsage,
 		})
 
+	case models.GRPC_EXPORT:
+		t.simulateGrpc(cfg)
+	case models.WebSocket:
+		t.simulateWebSocket(cfg)
 	}
 }
 
@@ -511,9 +672,16 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 	if !ok {
 		t.logger.Debug("resultForTele is not of type *[]int")
 	}
+	t.mutex.Lock()
 	(*resultForTele)[0] += *cfg.Success
 	(*resultForTele)[1] += *cfg.Failure
 
+	testReports, ok := cfg.Ctx.Value("testReports").(*[]*models.TestReport)
+	if ok {
+		*testReports = append(*testReports, cfg.TestReport)
+	}
+	t.mutex.Unlock()
+
 	err = cfg.TestReportFS.Write(context.Background(), cfg.TestReportPath, cfg.TestReport)
 
 	t.logger.Info("test report for "+cfg.TestSet+": ", zap.Any("name: ", cfg.TestReport.Name), zap.Any("path: ", cfg.Path+"/"+cfg.TestReport.Name))
@@ -524,7 +692,19 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 		pp.SetColorScheme(models.PassingColorScheme)
 	}
 
-	pp.Printf("\n <=========================================> \n  TESTRUN SUMMARY. For testrun with id: %s\n"+"\tTotal tests: %s\n"+"\tTotal test passed: %s\n"+"\tTotal test failed: %s\n <=========================================> \n\n", cfg.TestReport.TestSet, cfg.TestReport.Total, cfg.TestReport.Success, cfg.TestReport.Failure)
+	passedThrough := 0
+	if cfg.TestReport.Mocks != nil {
+		for _, calls := range cfg.TestReport.Mocks.PassedThrough {
+			passedThrough += calls
+		}
+	}
+	summary := "\n <=========================================> \n  TESTRUN SUMMARY. For testrun with id: %s\n" + "\tTotal tests: %s\n" + "\tTotal test passed: %s\n" + "\tTotal test failed: %s\n"
+	summaryArgs := []interface{}{cfg.TestReport.TestSet, cfg.TestReport.Total, cfg.TestReport.Success, cfg.TestReport.Failure}
+	if passedThrough > 0 {
+		summary += "\tTotal calls passed through: %s\n"
+		summaryArgs = append(summaryArgs, passedThrough)
+	}
+	pp.Printf(summary+" <=========================================> \n\n", summaryArgs...)
 
 	if err != nil {
 		t.logger.Error(err.Error())
@@ -536,26 +716,9 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 	return *cfg.Status
 }
 
-// testSet, path, testReportPath, appCmd, appContainer, appNetwork, delay, pid, ys, loadedHooks, testReportFS, testRunChan, apiTimeout, ctx
-func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer, appNetwork string, delay uint64, buildDelay time.Duration, pid uint32, ys platform.TestCaseDB, loadedHooks *hooks.Hook, testReportFS platform.TestReportDB, testRunChan chan string, apiTimeout uint64, ctx context.Context, testcases map[string]bool, noiseConfig models.GlobalNoise, serveTest bool) models.TestRunStatus {
-	cfg := &RunTestSetConfig{
-		TestSet:        testSet,
-		Path:           path,
-		TestReportPath: testReportPath,
-		AppCmd:         appCmd,
-		AppContainer:   appContainer,
-		AppNetwork:     appNetwork,
-		Delay:          delay,
-		BuildDelay:     buildDelay,
-		Pid:            pid,
-		YamlStore:      ys,
-		LoadedHooks:    loadedHooks,
-		TestReportFS:   testReportFS,
-		TestRunChan:    testRunChan,
-		ApiTimeout:     apiTimeout,
-		Ctx:            ctx,
-		ServeTest:      serveTest,
-	}
+// RunTestSet replays the testcases of the test-set of cfg against the user application and returns the status of
+// its test run.
+func (t *tester) RunTestSet(cfg *RunTestSetConfig) models.TestRunStatus {
 	initialisedValues := t.InitialiseRunTestSet(cfg)
 	if initialisedValues.InitialStatus != "" {
 		return initialisedValues.InitialStatus
@@ -563,14 +726,14 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 
 	isApplicationStopped := false
 	// Recover from panic and gracfully shutdown
-	defer loadedHooks.Recover(pkg.GenerateRandomID())
+	defer cfg.LoadedHooks.Recover(pkg.GenerateRandomID())
 	defer func() {
-		if len(appCmd) == 0 && pid != 0 {
+		if len(cfg.AppCmd) == 0 && cfg.Pid != 0 {
 			t.logger.Debug("no need to stop the user application when running keploy tests along with unit tests")
 		} else {
 			// stop the user application
-			if !isApplicationStopped && !serveTest {
-				loadedHooks.StopUserApplication()
+			if !isApplicationStopped && !cfg.ServeTest {
+				cfg.LoadedHooks.StopUserApplication()
 			}
 		}
 	}()
@@ -587,8 +750,10 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 	t.logger.Debug("the userip of the user docker container", zap.Any("", userIp))
 
 	var entTcs, nonKeployTcs []string
+	// the noisy fields added to each testcase when noise detection is enabled
+	detectedNoise := map[string][]string{}
 	for _, tc := range initialisedValues.Tcs {
-		if _, ok := testcases[tc.Name]; !ok && len(testcases) != 0 {
+		if _, ok := cfg.Testcases[tc.Name]; !ok && len(cfg.Testcases) != 0 {
 			continue
 		}
 		// Filter the TCS Mocks based on the test case's request and response timestamp such that mock's timestamps lies between the test's timestamp and then, set the TCS Mocks.
@@ -602,7 +767,11 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 			readTcsMocks = append(readTcsMocks, tcsmock)
 		}
 		readTcsMocks = FilterTcsMocks(tc, readTcsMocks, t.logger)
-		loadedHooks.SetTcsMocks(readTcsMocks)
+		// the mocks consumed before the testcase, like the ones while the application starts, only
+		// count for the test-set
+		initialisedValues.Coverage.collect(cfg.LoadedHooks, nil)
+		initialisedValues.Coverage.track(readTcsMocks)
+		cfg.LoadedHooks.SetTcsMocks(readTcsMocks)
 		if tc.Version == "api.keploy-enterprise.io/v1beta1" {
 			entTcs = append(entTcs, tc.Name)
 		} else if tc.Version != "api.keploy.io/v1beta1" && tc.Version != "api.keploy.io/v1beta2" {
@@ -621,11 +790,11 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 			case hooks.ErrUnExpected:
 				exitLoop = true
 				status = models.TestRunStatusAppHalted
-				t.logger.Warn("stopping testrun for the test set:", zap.Any("test-set", testSet))
+				t.logger.Warn("stopping testrun for the test set:", zap.Any("test-set", cfg.TestSet))
 			default:
 				exitLoop = true
 				status = models.TestRunStatusAppHalted
-				t.logger.Error("stopping testrun for the test set:", zap.Any("test-set", testSet))
+				t.logger.Error("stopping testrun for the test set:", zap.Any("test-set", cfg.TestSet))
 			}
 		default:
 		}
@@ -634,23 +803,46 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 			break
 		}
 
-		cfg := &SimulateRequestConfig{
-			Tc:           tc,
-			LoadedHooks:  loadedHooks,
-			AppCmd:       appCmd,
-			UserIP:       userIp,
-			TestSet:      testSet,
-			ApiTimeout:   apiTimeout,
-			Success:      &success,
-			Failure:      &failure,
-			Status:       &status,
-			TestReportFS: testReportFS,
-			TestReport:   initialisedValues.TestReport,
-			Path:         path,
-			DockerID:     initialisedValues.DockerID,
-			NoiseConfig:  noiseConfig,
-		}
-		t.SimulateRequest(cfg)
+		requestCfg := &SimulateRequestConfig{
+			Tc:            tc,
+			LoadedHooks:   cfg.LoadedHooks,
+			AppCmd:        cfg.AppCmd,
+			UserIP:        userIp,
+			TestSet:       cfg.TestSet,
+			ApiTimeout:    cfg.ApiTimeout,
+			Success:       &success,
+			Failure:       &failure,
+			Status:        &status,
+			TestReportFS:  cfg.TestReportFS,
+			TestReport:    initialisedValues.TestReport,
+			Path:          cfg.Path,
+			DockerID:      initialisedValues.DockerID,
+			NoiseConfig:   cfg.NoiseConfig,
+			LatencyBudget: cfg.LatencyBudget,
+			Rules:         cfg.Rules,
+			YamlStore:     cfg.YamlStore,
+			TcsMocks:      readTcsMocks,
+			NoiseRuns:     cfg.NoiseRuns,
+			DetectedNoise: detectedNoise,
+			Update:        cfg.Update,
+			PortOffset:    cfg.PortOffset,
+			Coverage:      initialisedValues.Coverage,
+		}
+		t.SimulateRequest(requestCfg)
+	}
+	if cfg.NoiseRuns > 1 {
+		if len(detectedNoise) == 0 {
+			t.logger.Info("no new noisy fields were detected", zap.Any("test-set", cfg.TestSet))
+		}
+		for name, fields := range detectedNoise {
+			t.logger.Info("noise detection summary", zap.Any("test-set", cfg.TestSet), zap.Any("testcase id", name), zap.Int("added", len(fields)), zap.Strings("noisy fields", fields))
+		}
+	}
+	initialisedValues.Coverage.collect(cfg.LoadedHooks, nil)
+	mockCoverage := initialisedValues.Coverage.report()
+	initialisedValues.TestReport.Mocks = mockCoverage
+	if len(mockCoverage.Unused) > 0 || len(mockCoverage.OverConsumed) > 0 || mockCoverage.Unmatched > 0 {
+		t.logger.Warn("some mocks of the test-set were not consumed as recorded, see the test report for details", zap.Any("test-set", cfg.TestSet), zap.Int("total", mockCoverage.Total), zap.Int("used", mockCoverage.Used), zap.Int("unused", len(mockCoverage.Unused)), zap.Int("over-consumed", len(mockCoverage.OverConsumed)), zap.Int("unmatched calls", mockCoverage.Unmatched))
 	}
 	if len(entTcs) > 0 {
 		t.logger.Warn("These testcases have been recorded with Keploy Enterprise, may not work properly with the open-source version", zap.Strings("enterprise mocks:", entTcs))
@@ -659,21 +851,24 @@ func (t *tester) RunTestSet(testSet, path, testReportPath, appCmd, appContainer,
 		t.logger.Warn("These testcases have not been recorded by Keploy, may not work properly with Keploy.", zap.Strings("non-keploy mocks:", nonKeployTcs))
 	}
 	resultsCfg := &FetchTestResultsConfig{
-		TestReportFS:   testReportFS,
+		TestReportFS:   cfg.TestReportFS,
 		TestReport:     initialisedValues.TestReport,
 		Status:         &status,
-		TestSet:        testSet,
+		TestSet:        cfg.TestSet,
 		Success:        &success,
 		Failure:        &failure,
-		Ctx:            ctx,
-		TestReportPath: testReportPath,
-		Path:           path,
+		Ctx:            cfg.Ctx,
+		TestReportPath: cfg.TestReportPath,
+		Path:           cfg.Path,
 	}
 	status = t.FetchTestResults(resultsCfg)
+	if cfg.PruneMocks {
+		t.pruneUnusedMocks(cfg.YamlStore, filepath.Join(cfg.Path, cfg.TestSet), cfg.TestSet, status, len(cfg.Testcases) != 0, initialisedValues.Coverage, cfg.Ctx)
+	}
 	return status
 }
 
-func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, noiseConfig models.GlobalNoise) (bool, *models.Result) {
+func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, noiseConfig models.GlobalNoise, latency *models.LatencyResult, testSetRules models.AssertionRules) (bool, *models.Result) {
 
 	bodyType := models.BodyTypePlain
 	if json.Valid([]byte(actualResponse.Body)) {
@@ -719,6 +914,20 @@ func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, n
 		}
 	}
 
+	// the fields covered by an assertion rule are checked against the rule instead of the recorded value
+	rulesPass := true
+	if rules := MergeRules(testSetRules, tc.Rules); len(rules) > 0 && bodyType == models.BodyTypeJSON {
+		res.RuleResult, rulesPass = ApplyRules(actualResponse.Body, rules, t.logger)
+		ruleNoise := map[string][]string{}
+		for field, regexArr := range bodyNoise {
+			ruleNoise[field] = regexArr
+		}
+		for path := range rules {
+			ruleNoise[noiseKey(path)] = []string{}
+		}
+		bodyNoise = ruleNoise
+	}
+
 	// stores the json body after removing the noise
 	cleanExp, cleanAct := "", ""
 	var err error
@@ -727,6 +936,7 @@ func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, n
 		if err != nil {
 			return false, res
 		}
+		pass = pass && rulesPass
 		// debug log for cleanExp and cleanAct
 		t.logger.Debug("cleanExp", zap.Any("", cleanExp))
 		t.logger.Debug("cleanAct", zap.Any("", cleanAct))
@@ -751,6 +961,15 @@ func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, n
 		pass = false
 	}
 
+	res.Latency = latency
+	if latency != nil && !latency.Normal {
+		if latency.Mode == models.LatencyModeWarn {
+			t.logger.Warn("response time exceeded the latency budget", zap.String("testcase id", tc.Name), zap.Int64("recorded(ms)", latency.Expected), zap.Int64("budget(ms)", latency.Budget), zap.Int64("actual(ms)", latency.Actual))
+		} else {
+			pass = false
+		}
+	}
+
 	if !pass {
 		logDiffs := NewDiffsPrinter(tc.Name)
 
@@ -760,6 +979,14 @@ func (t *tester) testHttp(tc models.TestCase, actualResponse *models.HttpResp, n
 		var logs = ""
 
 		logs = logs + logger.Sprintf("Testrun failed for testcase with id: %s\n\n--------------------------------------------------------------------\n\n", tc.Name)
+		if latency != nil && !latency.Normal && latency.Mode != models.LatencyModeWarn {
+			logs = logs + logger.Sprintf("Response time regressed: recorded %dms, budget %dms, actual %dms\n\n", latency.Expected, latency.Budget, latency.Actual)
+		}
+		for _, rule := range res.RuleResult {
+			if !rule.Normal {
+				logs = logs + logger.Sprintf("Assertion failed for %s: %s\n\n", rule.Path, rule.Message)
+			}
+		}
 
 		// ------------ DIFFS RELATED CODE -----------
 		if !res.StatusCode.Normal {
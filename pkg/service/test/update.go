@@ -0,0 +1,85 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// UpdateOptions turns a test run into a re-baseline, the expected response of the failing testcases
+// is replaced by the actual one.
+type UpdateOptions struct {
+	// Yes updates every matching testcase without asking
+	Yes bool
+	// Filter selects the testcases to update by a regex over their name or request URL
+	Filter *regexp.Regexp
+
+	stdin *bufio.Reader
+}
+
+// Matches reports whether the testcase is selected by the filter.
+func (u *UpdateOptions) Matches(tc *models.TestCase) bool {
+	return u.Filter == nil || u.Filter.MatchString(tc.Name) || u.Filter.MatchString(tc.HttpReq.URL)
+}
+
+// confirm asks whether to update the testcase, answering "a" updates all the remaining ones.
+func (u *UpdateOptions) confirm(tc *models.TestCase) bool {
+	if u.Yes {
+		return true
+	}
+	if u.stdin == nil {
+		u.stdin = bufio.NewReader(os.Stdin)
+	}
+	fmt.Printf("%s update the expected response of testcase %s (%s %s)? [y/N/a]: ", Emoji, tc.Name, tc.HttpReq.Method, tc.HttpReq.URL)
+	answer, err := u.stdin.ReadString('\n')
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	case "a", "all":
+		u.Yes = true
+		return true
+	}
+	return false
+}
+
+// updateTest rewrites the expected response of a failing testcase with the actual response.
+func (t *tester) updateTest(cfg *SimulateRequestConfig, resp *models.HttpResp, recordedURL string) bool {
+	if !cfg.Update.Matches(cfg.Tc) {
+		return false
+	}
+	t.mutex.Lock()
+	confirmed := cfg.Update.confirm(cfg.Tc)
+	t.mutex.Unlock()
+	if !confirmed {
+		return false
+	}
+
+	store, ok := cfg.YamlStore.(platform.TestCaseUpdater)
+	if !ok {
+		t.logger.Error("the storage backend can't update the testcases")
+		return false
+	}
+	updated := *cfg.Tc
+	// the url may point to the docker container of this run, so the recorded request is written back
+	updated.HttpReq.URL = recordedURL
+	updated.HttpResp = *resp
+	// the timestamps select the mocks of the testcase, so they stay as recorded
+	updated.HttpResp.Timestamp = cfg.Tc.HttpResp.Timestamp
+	if err := store.UpdateTest(filepath.Join(cfg.Path, cfg.TestSet, "tests"), &updated, context.Background()); err != nil {
+		t.logger.Error("failed to update the testcase", zap.Error(err), zap.Any("testcase id", cfg.Tc.Name))
+		return false
+	}
+	t.logger.Info("updated the expected response of the testcase", zap.Any("testcase id", cfg.Tc.Name), zap.Any("testset id", cfg.TestSet))
+	return true
+}
@@ -16,7 +16,6 @@ import (
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
 	"go.keploy.io/server/pkg/platform"
-	"go.keploy.io/server/pkg/platform/yaml"
 	"go.keploy.io/server/pkg/proxy"
 	"go.uber.org/zap"
 )
@@ -29,11 +28,12 @@ type InitialiseRunTestSetReturn struct {
 	UserIP        string
 	InitialStatus models.TestRunStatus
 	TcsMocks      []*models.Mock
+	Coverage      *mockCoverage
 }
 
 type InitialiseTestReturn struct {
 	Sessions                 []string
-	TestReportFS             *yaml.TestReport
+	TestReportFS             platform.TestReportDB
 	Ctx                      context.Context
 	AbortStopHooksForcefully bool
 	ProxySet                 *proxy.ProxySet
@@ -54,10 +54,16 @@ type TestConfig struct {
 	Delay              uint64
 	BuildDelay         time.Duration
 	PassThroughPorts   []uint
+	PassThrough        []models.PassThroughRule
 	ApiTimeout         uint64
 	WithCoverage       bool
 	CoverageReportPath string
 	EnableTele         bool
+	Storage            string
+	DnsTimeout         time.Duration
+	GrpcSchema         models.GrpcSchema
+	MockMatching       models.MockMatching
+	ProtocolPorts      models.ProtocolPorts
 }
 
 type RunTestSetConfig struct {
@@ -77,23 +83,40 @@ type RunTestSetConfig struct {
 	ApiTimeout     uint64
 	Ctx            context.Context
 	ServeTest      bool
+	Testcases      map[string]bool // the testcases to run, all of them when empty
+	NoiseConfig    models.GlobalNoise
+	LatencyBudget  *models.LatencyBudget // the response time budget of the testcases, nil when there is none
+	Rules          models.AssertionRules
+	NoiseRuns      int            // the runs of each testcase to detect its noisy fields, no detection when it is 1 or less
+	Update         *UpdateOptions // re-baselines the failing testcases when it is set
+	PruneMocks     bool           // removes the mocks which no testcase consumed once the test-set passed
+	PortOffset     int
 }
 
 type SimulateRequestConfig struct {
-	Tc           *models.TestCase
-	LoadedHooks  *hooks.Hook
-	AppCmd       string
-	UserIP       string
-	TestSet      string
-	ApiTimeout   uint64
-	Success      *int
-	Failure      *int
-	Status       *models.TestRunStatus
-	TestReportFS platform.TestReportDB
-	TestReport   *models.TestReport
-	Path         string
-	DockerID     bool
-	NoiseConfig  models.GlobalNoise
+	Tc            *models.TestCase
+	LoadedHooks   *hooks.Hook
+	AppCmd        string
+	UserIP        string
+	TestSet       string
+	ApiTimeout    uint64
+	Success       *int
+	Failure       *int
+	Status        *models.TestRunStatus
+	TestReportFS  platform.TestReportDB
+	TestReport    *models.TestReport
+	Path          string
+	DockerID      bool
+	NoiseConfig   models.GlobalNoise
+	LatencyBudget *models.LatencyBudget
+	Rules         models.AssertionRules
+	YamlStore     platform.TestCaseDB
+	TcsMocks      []*models.Mock
+	NoiseRuns     int
+	DetectedNoise map[string][]string
+	Update        *UpdateOptions
+	PortOffset    int
+	Coverage      *mockCoverage
 }
 
 type FetchTestResultsConfig struct {
@@ -207,6 +230,57 @@ func LeftJoinNoise(globalNoise models.GlobalNoise, tsNoise models.GlobalNoise) m
 	return noise
 }
 
+// LatencyBudgetFor returns the latency budget of the test-set, or nil when none is configured.
+func LatencyBudgetFor(latency models.LatencyConfig, testSet string) *models.LatencyBudget {
+	budget := latency.Global
+	if tsBudget, ok := latency.Testsets[testSet]; ok {
+		if tsBudget.Ratio != 0 {
+			budget.Ratio = tsBudget.Ratio
+		}
+		if tsBudget.Absolute != 0 {
+			budget.Absolute = tsBudget.Absolute
+		}
+		if tsBudget.Mode != "" {
+			budget.Mode = tsBudget.Mode
+		}
+	}
+	if budget.Ratio <= 0 && budget.Absolute <= 0 {
+		return nil
+	}
+	if budget.Mode == "" {
+		budget.Mode = models.LatencyModeFail
+	}
+	return &budget
+}
+
+// CompareLatency compares the response time of the replayed call with the latency recorded in
+// the testcase. It returns nil when there is no budget or the testcase has no recorded timestamps.
+func CompareLatency(tc *models.TestCase, actual time.Duration, budget *models.LatencyBudget) *models.LatencyResult {
+	reqTimestamp, resTimestamp := tc.Timestamps()
+	if budget == nil || reqTimestamp.IsZero() || resTimestamp.IsZero() {
+		return nil
+	}
+	expected := resTimestamp.Sub(reqTimestamp)
+	if expected < 0 {
+		return nil
+	}
+	// the call is a regression only when it exceeds every configured budget
+	var allowed time.Duration
+	if budget.Ratio > 0 {
+		allowed = time.Duration(float64(expected) * budget.Ratio)
+	}
+	if budget.Absolute > 0 && expected+budget.Absolute > allowed {
+		allowed = expected + budget.Absolute
+	}
+	return &models.LatencyResult{
+		Normal:   actual <= allowed,
+		Expected: expected.Milliseconds(),
+		Actual:   actual.Milliseconds(),
+		Budget:   allowed.Milliseconds(),
+		Mode:     budget.Mode,
+	}
+}
+
 func MatchesAnyRegex(str string, regexArray []string) (bool, string) {
 	for _, pattern := range regexArray {
 		re := regexp.MustCompile(pattern)
@@ -382,13 +456,14 @@ func Contains(elems []string, v string) bool {
 // Filter the mocks based on req and res timestamp of test
 func FilterTcsMocks(tc *models.TestCase, m []*models.Mock, logger *zap.Logger) []*models.Mock {
 	filteredMocks := make([]*models.Mock, 0)
+	reqTimestamp, resTimestamp := tc.Timestamps()
 
-	if tc.HttpReq.Timestamp == (time.Time{}) {
+	if reqTimestamp == (time.Time{}) {
 		logger.Warn("request timestamp is missing for " + tc.Name)
 		return m
 	}
 
-	if tc.HttpResp.Timestamp == (time.Time{}) {
+	if resTimestamp == (time.Time{}) {
 		logger.Warn("response timestamp is missing for " + tc.Name)
 		return m
 	}
@@ -401,7 +476,7 @@ func FilterTcsMocks(tc *models.TestCase, m []*models.Mock, logger *zap.Logger) [
 		}
 
 		// Checking if the mock's request and response timestamps lie between the test's request and response timestamp
-		if mock.Spec.ReqTimestampMock.After(tc.HttpReq.Timestamp) && mock.Spec.ResTimestampMock.Before(tc.HttpResp.Timestamp) {
+		if mock.Spec.ReqTimestampMock.After(reqTimestamp) && mock.Spec.ResTimestampMock.Before(resTimestamp) {
 			filteredMocks = append(filteredMocks, mock)
 		}
 	}
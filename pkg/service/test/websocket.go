@@ -0,0 +1,197 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/k0kubun/pp/v3"
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// simulateWebSocket replays the WebSocket session of the testcase and stores the result of the comparison of
+// the frames sent by the application with the recorded ones.
+func (t *tester) simulateWebSocket(cfg *SimulateRequestConfig) {
+	started := time.Now().UTC()
+	t.logger.Debug("Before simulating the websocket session", zap.Any("Test case", cfg.Tc))
+
+	ok, _ := cfg.LoadedHooks.IsDockerRelatedCmd(cfg.AppCmd)
+	if ok || cfg.DockerID {
+		var err error
+		cfg.Tc.HttpReq.URL, err = replaceHostToIP(cfg.Tc.HttpReq.URL, cfg.UserIP)
+		if err != nil {
+			t.logger.Error("failed to replace host to docker container's IP", zap.Error(err))
+		}
+	}
+	if cfg.PortOffset > 0 {
+		var err error
+		cfg.Tc.HttpReq.URL, err = shiftPort(cfg.Tc.HttpReq.URL, cfg.PortOffset)
+		if err != nil {
+			t.logger.Error("failed to move the testcase to the port of its application instance", zap.Error(err))
+		}
+	}
+	t.logger.Debug(fmt.Sprintf("the url of the testcase: %v", cfg.Tc.HttpReq.URL))
+
+	simulated := time.Now()
+	resp, frames, err := pkg.SimulateWebSocket(*cfg.Tc, cfg.TestSet, t.logger, cfg.ApiTimeout)
+	latency := time.Since(simulated)
+	mockUsage := cfg.Coverage.collect(cfg.LoadedHooks, cfg.TcsMocks)
+	t.logger.Debug("After simulating the websocket session", zap.Any("test case id", cfg.Tc.Name))
+
+	if err != nil && resp == nil {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString("false")))
+		return
+	}
+	testPass, testResult := t.testWebSocket(*cfg.Tc, resp, frames, CompareLatency(cfg.Tc, latency, cfg.LatencyBudget))
+
+	if !testPass {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightFailingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightFailingString(cfg.TestSet)), zap.Any("passed", models.HighlightFailingString(testPass)))
+	} else {
+		t.logger.Info("result", zap.Any("testcase id", models.HighlightPassingString(cfg.Tc.Name)), zap.Any("testset id", models.HighlightPassingString(cfg.TestSet)), zap.Any("passed", models.HighlightPassingString(testPass)))
+	}
+
+	testStatus := models.TestStatusPending
+	if testPass {
+		testStatus = models.TestStatusPassed
+		*cfg.Success++
+	} else {
+		testStatus = models.TestStatusFailed
+		*cfg.Failure++
+		*cfg.Status = models.TestRunStatusFailed
+	}
+
+	cfg.TestReportFS.SetResult(cfg.TestReport.Name, &models.TestResult{
+		Kind:            models.WebSocket,
+		Name:            cfg.TestReport.Name,
+		Status:          testStatus,
+		Started:         started.Unix(),
+		Completed:       time.Now().UTC().Unix(),
+		TestCaseID:      cfg.Tc.Name,
+		Req:             cfg.Tc.HttpReq,
+		Res:             cfg.Tc.HttpResp,
+		TestCasePath:    cfg.Path + "/" + cfg.TestSet,
+		Noise:           cfg.Tc.Noise,
+		Result:          *testResult,
+		Mocks:           mockUsage,
+		WebSocketFrames: frames,
+	})
+}
+
+// testWebSocket compares the handshake response and each frame sent by the application with the recorded ones.
+// The headers of the handshake are left out of the comparison by the "header.<name>" noise, a whole frame by the
+// "frames.<index>" noise and the data of all the frames by the "body" noise. The text frames carrying JSON are
+// compared field by field, the fields are left out by the "body.<field path>" noise.
+func (t *tester) testWebSocket(tc models.TestCase, actualResponse *models.HttpResp, actualFrames []models.WebSocketFrame, latency *models.LatencyResult) (bool, *models.Result) {
+	res := &models.Result{
+		StatusCode: models.IntResult{
+			Expected: tc.HttpResp.StatusCode,
+			Actual:   actualResponse.StatusCode,
+		},
+		Latency: latency,
+	}
+	pass := true
+
+	headerNoise, bodyNoise := map[string][]string{}, map[string][]string{}
+	for field, regexArr := range tc.Noise {
+		if name, ok := strings.CutPrefix(field, "header."); ok {
+			headerNoise[name] = regexArr
+		} else if path, ok := strings.CutPrefix(field, "body."); ok {
+			bodyNoise[path] = regexArr
+		}
+	}
+	hRes := &[]models.HeaderResult{}
+	if !CompareHeaders(pkg.ToHttpHeader(tc.HttpResp.Header), pkg.ToHttpHeader(actualResponse.Header), hRes, headerNoise) {
+		pass = false
+	}
+	res.HeadersResult = *hRes
+
+	res.StatusCode.Normal = res.StatusCode.Expected == res.StatusCode.Actual
+	if !res.StatusCode.Normal {
+		pass = false
+	}
+
+	_, bodyNoisy := tc.Noise["body"]
+	index := 0
+	for _, expected := range tc.WebSocketFrames {
+		if expected.Origin != models.FromServer {
+			continue
+		}
+		var actual models.WebSocketFrame
+		if index < len(actualFrames) {
+			actual = actualFrames[index]
+		}
+		result := models.BodyResult{
+			Type:     models.BodyTypePlain,
+			Expected: expected.Type + ": " + expected.Data,
+			Actual:   actual.Type + ": " + actual.Data,
+		}
+		_, frameNoisy := tc.Noise["frames."+strconv.Itoa(index)]
+		result.Normal = frameNoisy || (expected.Type == actual.Type && expected.CloseCode == actual.CloseCode &&
+			(bodyNoisy || t.sameFrameData(expected, actual, bodyNoise)))
+		if !result.Normal {
+			pass = false
+		}
+		res.BodyResult = append(res.BodyResult, result)
+		index++
+	}
+
+	if latency != nil && !latency.Normal {
+		if latency.Mode == models.LatencyModeWarn {
+			t.logger.Warn("response time exceeded the latency budget", zap.String("testcase id", tc.Name), zap.Int64("recorded(ms)", latency.Expected), zap.Int64("budget(ms)", latency.Budget), zap.Int64("actual(ms)", latency.Actual))
+		} else {
+			pass = false
+		}
+	}
+
+	logger := pp.New()
+	logger.WithLineInfo = false
+	if pass {
+		logger.SetColorScheme(models.PassingColorScheme)
+		t.mutex.Lock()
+		logger.Printf(logger.Sprintf("Testrun passed for testcase with id: %s\n\n--------------------------------------------------------------------\n\n", tc.Name))
+		t.mutex.Unlock()
+		return pass, res
+	}
+
+	logDiffs := NewDiffsPrinter(tc.Name)
+	logger.SetColorScheme(models.FailingColorScheme)
+	logs := logger.Sprintf("Testrun failed for testcase with id: %s\n\n--------------------------------------------------------------------\n\n", tc.Name)
+	if latency != nil && !latency.Normal && latency.Mode != models.LatencyModeWarn {
+		logs = logs + logger.Sprintf("Response time regressed: recorded %dms, budget %dms, actual %dms\n\n", latency.Expected, latency.Budget, latency.Actual)
+	}
+	if !res.StatusCode.Normal {
+		logDiffs.PushStatusDiff(fmt.Sprint(res.StatusCode.Expected), fmt.Sprint(res.StatusCode.Actual))
+	}
+	for _, header := range res.HeadersResult {
+		if !header.Normal {
+			logDiffs.PushHeaderDiff(fmt.Sprint(header.Expected.Value), fmt.Sprint(header.Actual.Value), header.Expected.Key, headerNoise)
+		}
+	}
+	// the diff of the first frame that differs is shown
+	for i, frame := range res.BodyResult {
+		if !frame.Normal {
+			logs = logs + logger.Sprintf("Frame %d sent by the application differs from the recorded one\n\n", i)
+			logDiffs.PushBodyDiff(frame.Expected, frame.Actual, bodyNoise)
+			break
+		}
+	}
+	t.mutex.Lock()
+	logger.Printf(logs)
+	logDiffs.Render()
+	t.mutex.Unlock()
+	return pass, res
+}
+
+// sameFrameData compares the data of two frames of the same type. The text frames carrying JSON are compared
+// without the noisy fields.
+func (t *tester) sameFrameData(expected, actual models.WebSocketFrame, bodyNoise map[string][]string) bool {
+	if expected.Type == models.WebSocketText && json.Valid([]byte(expected.Data)) && json.Valid([]byte(actual.Data)) {
+		_, _, match, err := Match(expected.Data, actual.Data, bodyNoise, t.logger)
+		return err == nil && match
+	}
+	return expected.Data == actual.Data
+}
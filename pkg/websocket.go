@@ -0,0 +1,469 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// webSocketGUID is appended to the key of the handshake to compute the accept key of the server (RFC 6455).
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// deflateWindow is the size of the window of the permessage-deflate extension, the compressed messages may refer
+// to that much of the data inflated before them.
+const deflateWindow = 32 * 1024
+
+// deflateTail is removed from the end of the compressed messages by the permessage-deflate extension (RFC 7692).
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// opcodes of the WebSocket frames
+const (
+	wsContinuation byte = 0x0
+	wsText         byte = 0x1
+	wsBinary       byte = 0x2
+	wsClose        byte = 0x8
+	wsPing         byte = 0x9
+	wsPong         byte = 0xa
+)
+
+// IsWebSocketUpgrade reports whether the headers of an HTTP/1.1 request ask to open a WebSocket session.
+func IsWebSocketUpgrade(header http.Header) bool {
+	if !strings.EqualFold(strings.TrimSpace(header.Get("Upgrade")), "websocket") {
+		return false
+	}
+	for _, value := range header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WebSocketAccept returns the Sec-WebSocket-Accept header of the handshake response to the given Sec-WebSocket-Key.
+func WebSocketAccept(key string) string {
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SkipHttpHeaders returns the bytes that follow the headers of an HTTP/1.x message, which are the first frames of
+// the session when the message is a WebSocket handshake.
+func SkipHttpHeaders(buffer []byte) []byte {
+	headerEnd := bytes.Index(buffer, []byte("\r\n\r\n"))
+	if headerEnd == -1 {
+		return nil
+	}
+	return buffer[headerEnd+4:]
+}
+
+// WebSocketDecoder decodes the frames sent by one side of a WebSocket session. A frame may be split over several
+// reads of the connection, the bytes are kept until the frame is complete.
+type WebSocketDecoder struct {
+	origin     models.OriginType
+	buffer     []byte
+	opcode     byte   // opcode of the fragmented message being joined
+	message    []byte // payload of the fragments received so far
+	compressed bool   // the message being joined is compressed by the permessage-deflate extension
+	window     []byte // end of the data inflated so far, the dictionary of the next compressed messages
+}
+
+func NewWebSocketDecoder(origin models.OriginType) *WebSocketDecoder {
+	return &WebSocketDecoder{origin: origin}
+}
+
+// Decode adds the bytes read from the connection and returns the messages they complete. The fragments of a
+// message are joined, the control frames sent between them are returned on their own. The messages compressed by
+// the permessage-deflate extension are inflated.
+func (d *WebSocketDecoder) Decode(data []byte) []models.WebSocketFrame {
+	d.buffer = append(d.buffer, data...)
+	var frames []models.WebSocketFrame
+	for {
+		fin, opcode, payload, size := parseWebSocketFrame(d.buffer)
+		if size == 0 {
+			break
+		}
+		// RSV1 of the first frame of a message tells that the message is compressed
+		rsv1 := d.buffer[0]&0x40 != 0
+		d.buffer = d.buffer[size:]
+		switch {
+		case opcode >= wsClose:
+			frames = append(frames, d.frame(opcode, payload))
+			continue
+		case opcode != wsContinuation:
+			d.opcode = opcode
+			d.message = nil
+			d.compressed = rsv1
+		}
+		d.message = append(d.message, payload...)
+		if fin {
+			message := d.message
+			if d.compressed {
+				if inflated, err := d.inflate(message); err == nil {
+					message = inflated
+				}
+			}
+			frames = append(frames, d.frame(d.opcode, message))
+			d.message = nil
+		}
+	}
+	if len(d.buffer) == 0 {
+		d.buffer = nil
+	}
+	return frames
+}
+
+// inflate decompresses a message of the permessage-deflate extension. The compressor of the other side may refer
+// to the previous messages, so the end of the inflated data is kept as the dictionary of the next ones.
+func (d *WebSocketDecoder) inflate(payload []byte) ([]byte, error) {
+	reader := flate.NewReaderDict(io.MultiReader(bytes.NewReader(payload), bytes.NewReader(deflateTail)), d.window)
+	defer reader.Close()
+	// the message ends with a sync flush rather than the final block, the reader runs out of input after it
+	data, err := io.ReadAll(reader)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	d.window = append(d.window, data...)
+	if len(d.window) > deflateWindow {
+		d.window = append([]byte(nil), d.window[len(d.window)-deflateWindow:]...)
+	}
+	return data, nil
+}
+
+func (d *WebSocketDecoder) frame(opcode byte, payload []byte) models.WebSocketFrame {
+	frame := models.WebSocketFrame{
+		Origin:    d.origin,
+		Timestamp: time.Now(),
+	}
+	switch opcode {
+	case wsText:
+		frame.Type = models.WebSocketText
+		frame.Data = string(payload)
+	case wsClose:
+		frame.Type = models.WebSocketClose
+		if len(payload) >= 2 {
+			frame.CloseCode = int(binary.BigEndian.Uint16(payload[:2]))
+			frame.Data = string(payload[2:])
+		}
+	case wsPing:
+		frame.Type = models.WebSocketPing
+		frame.Data = base64.StdEncoding.EncodeToString(payload)
+	case wsPong:
+		frame.Type = models.WebSocketPong
+		frame.Data = base64.StdEncoding.EncodeToString(payload)
+	default:
+		frame.Type = models.WebSocketBinary
+		frame.Data = base64.StdEncoding.EncodeToString(payload)
+	}
+	return frame
+}
+
+// parseWebSocketFrame reads the frame at the start of the buffer and returns its unmasked payload along with the
+// number of bytes it takes. The size is 0 when the buffer does not hold a whole frame yet.
+func parseWebSocketFrame(buffer []byte) (bool, byte, []byte, int) {
+	if len(buffer) < 2 {
+		return false, 0, nil, 0
+	}
+	fin := buffer[0]&0x80 != 0
+	opcode := buffer[0] & 0x0f
+	masked := buffer[1]&0x80 != 0
+	length := uint64(buffer[1] & 0x7f)
+	offset := 2
+	switch length {
+	case 126:
+		if len(buffer) < 4 {
+			return false, 0, nil, 0
+		}
+		length = uint64(binary.BigEndian.Uint16(buffer[2:4]))
+		offset = 4
+	case 127:
+		if len(buffer) < 10 {
+			return false, 0, nil, 0
+		}
+		length = binary.BigEndian.Uint64(buffer[2:10])
+		offset = 10
+	}
+	var mask []byte
+	if masked {
+		if len(buffer) < offset+4 {
+			return false, 0, nil, 0
+		}
+		mask = buffer[offset : offset+4]
+		offset += 4
+	}
+	if uint64(len(buffer)-offset) < length {
+		return false, 0, nil, 0
+	}
+	payload := make([]byte, length)
+	copy(payload, buffer[offset:])
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, offset + int(length)
+}
+
+// EncodeWebSocketFrame returns the frame carrying the whole message. The frames sent by the clients are masked.
+func EncodeWebSocketFrame(frame models.WebSocketFrame, masked bool) ([]byte, error) {
+	var opcode byte
+	var payload []byte
+	var err error
+	switch frame.Type {
+	case models.WebSocketText:
+		opcode, payload = wsText, []byte(frame.Data)
+	case models.WebSocketClose:
+		opcode = wsClose
+		if frame.CloseCode != 0 {
+			payload = binary.BigEndian.AppendUint16(nil, uint16(frame.CloseCode))
+			payload = append(payload, frame.Data...)
+		}
+	case models.WebSocketBinary:
+		opcode = wsBinary
+	case models.WebSocketPing:
+		opcode = wsPing
+	case models.WebSocketPong:
+		opcode = wsPong
+	default:
+		return nil, fmt.Errorf("unknown type of websocket frame: %q", frame.Type)
+	}
+	if opcode == wsBinary || opcode == wsPing || opcode == wsPong {
+		payload, err = base64.StdEncoding.DecodeString(frame.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the data of the %s frame: %v", frame.Type, err)
+		}
+	}
+
+	encoded := []byte{0x80 | opcode}
+	var maskBit byte
+	if masked {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		encoded = append(encoded, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		encoded = append(encoded, maskBit|126)
+		encoded = binary.BigEndian.AppendUint16(encoded, uint16(len(payload)))
+	default:
+		encoded = append(encoded, maskBit|127)
+		encoded = binary.BigEndian.AppendUint64(encoded, uint64(len(payload)))
+	}
+	if !masked {
+		return append(encoded, payload...), nil
+	}
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return nil, fmt.Errorf("failed to generate the mask of the websocket frame: %v", err)
+	}
+	encoded = append(encoded, mask...)
+	for i, b := range payload {
+		encoded = append(encoded, b^mask[i%4])
+	}
+	return encoded, nil
+}
+
+// WebSocketCapture collects a WebSocket session served by the application. The frames are decoded from the
+// request and response buffer pairs of the connection that follow the handshake.
+type WebSocketCapture struct {
+	testcase     models.TestCase
+	client       *WebSocketDecoder
+	server       *WebSocketDecoder
+	clientClosed bool
+	serverClosed bool
+}
+
+func NewWebSocketCapture(req *http.Request, resp *http.Response, reqTimestamp, resTimestamp time.Time) *WebSocketCapture {
+	return &WebSocketCapture{
+		testcase: models.TestCase{
+			Version: models.GetVersion(),
+			Name:    ToYamlHttpHeader(req.Header)["Keploy-Test-Name"],
+			Kind:    models.WebSocket,
+			Created: time.Now().Unix(),
+			HttpReq: models.HttpReq{
+				Method:     models.Method(req.Method),
+				ProtoMajor: req.ProtoMajor,
+				ProtoMinor: req.ProtoMinor,
+				URL:        fmt.Sprintf("http://%s%s", req.Host, req.URL.RequestURI()),
+				Header:     ToYamlHttpHeader(req.Header),
+				URLParams:  UrlParams(req),
+				Timestamp:  reqTimestamp,
+			},
+			HttpResp: models.HttpResp{
+				StatusCode: resp.StatusCode,
+				Header:     ToYamlHttpHeader(resp.Header),
+				Timestamp:  resTimestamp,
+			},
+			Noise: map[string][]string{},
+		},
+		client: NewWebSocketDecoder(models.FromClient),
+		server: NewWebSocketDecoder(models.FromServer),
+	}
+}
+
+// Capture decodes the frames of a request and response buffer pair, the frames take the times at which the
+// buffers were captured. The frames of the client come first, the application sends its own in answer to them.
+// It reports whether the pair closed the session, the pairs that follow are ignored.
+func (c *WebSocketCapture) Capture(requestBuf, responseBuf []byte, reqTimestamp, resTimestamp time.Time) bool {
+	if c.Closed() {
+		return false
+	}
+	for _, frame := range c.client.Decode(requestBuf) {
+		c.clientClosed = c.clientClosed || frame.Type == models.WebSocketClose
+		frame.Timestamp = reqTimestamp
+		c.testcase.WebSocketFrames = append(c.testcase.WebSocketFrames, frame)
+	}
+	for _, frame := range c.server.Decode(responseBuf) {
+		c.serverClosed = c.serverClosed || frame.Type == models.WebSocketClose
+		frame.Timestamp = resTimestamp
+		c.testcase.WebSocketFrames = append(c.testcase.WebSocketFrames, frame)
+	}
+	return c.Closed()
+}
+
+// Closed reports whether both sides sent the close frame of the session.
+func (c *WebSocketCapture) Closed() bool {
+	return c.clientClosed && c.serverClosed
+}
+
+// TestCase returns the testcase of the session captured so far.
+func (c *WebSocketCapture) TestCase() *models.TestCase {
+	return &c.testcase
+}
+
+// SimulateWebSocket opens the WebSocket session of the testcase on the application and sends the frames of the
+// client in the recorded order. It returns the handshake response of the application and the frames it sent,
+// waiting at most apiTimeout seconds for each of the recorded ones.
+func SimulateWebSocket(tc models.TestCase, testSet string, logger *zap.Logger, apiTimeout uint64) (*models.HttpResp, []models.WebSocketFrame, error) {
+	logger.Info("starting test for of", zap.Any("test case", models.HighlightString(tc.Name)), zap.Any("test set", models.HighlightString(testSet)))
+	timeout := time.Second * time.Duration(apiTimeout)
+
+	req, err := http.NewRequest(http.MethodGet, tc.HttpReq.URL, nil)
+	if err != nil {
+		logger.Error("failed to create the websocket handshake from the yaml document", zap.Error(err))
+		return nil, nil, err
+	}
+	// the recorded key is sent again, the accept key of the response then matches the recorded one
+	req.Header = ToHttpHeader(tc.HttpReq.Header)
+	req.Header.Set("KEPLOY-TEST-ID", tc.Name)
+
+	conn, err := net.DialTimeout("tcp", hostWithPort(req.URL), timeout)
+	if err != nil {
+		logger.Error("failed to connect to the application", zap.Error(err))
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		logger.Error("failed to set the deadline of the websocket handshake", zap.Error(err))
+		return nil, nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		logger.Error("failed to send the websocket handshake", zap.Error(err))
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	httpResp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		logger.Error("failed to read the websocket handshake response", zap.Error(err))
+		return nil, nil, err
+	}
+	resp := &models.HttpResp{
+		StatusCode: httpResp.StatusCode,
+		Header:     ToYamlHttpHeader(httpResp.Header),
+		ProtoMajor: httpResp.ProtoMajor,
+		ProtoMinor: httpResp.ProtoMinor,
+	}
+	if httpResp.StatusCode != http.StatusSwitchingProtocols {
+		body, err := io.ReadAll(httpResp.Body)
+		if err != nil {
+			logger.Error("failed reading the body of the refused websocket handshake", zap.Error(err))
+			return nil, nil, err
+		}
+		resp.Body = string(body)
+		return resp, nil, nil
+	}
+
+	decoder := NewWebSocketDecoder(models.FromServer)
+	var received, frames []models.WebSocketFrame
+	// next returns the next frame of the application, read from the connection when none is left
+	next := func() (models.WebSocketFrame, error) {
+		buf := make([]byte, 4096)
+		for len(received) == 0 {
+			if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+				return models.WebSocketFrame{}, err
+			}
+			n, err := reader.Read(buf)
+			received = append(received, decoder.Decode(buf[:n])...)
+			if err != nil && len(received) == 0 {
+				return models.WebSocketFrame{}, err
+			}
+		}
+		frame := received[0]
+		received = received[1:]
+		return frame, nil
+	}
+
+	closed := false
+	for _, frame := range tc.WebSocketFrames {
+		if frame.Origin == models.FromClient {
+			if closed {
+				break
+			}
+			data, err := EncodeWebSocketFrame(frame, true)
+			if err != nil {
+				logger.Error("failed to encode the websocket frame of the client", zap.Error(err))
+				return resp, frames, err
+			}
+			if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+				return resp, frames, err
+			}
+			if _, err := conn.Write(data); err != nil {
+				logger.Error("failed to send the websocket frame to the application", zap.Error(err))
+				return resp, frames, err
+			}
+			closed = frame.Type == models.WebSocketClose
+			continue
+		}
+		actual, err := next()
+		if err != nil {
+			logger.Debug("the application sent no more websocket frames", zap.Error(err))
+			break
+		}
+		frames = append(frames, actual)
+	}
+
+	if !closed {
+		data, err := EncodeWebSocketFrame(models.WebSocketFrame{Type: models.WebSocketClose, CloseCode: 1000}, true)
+		if err == nil {
+			_, _ = conn.Write(data)
+		}
+	}
+	return resp, frames, nil
+}
+
+// hostWithPort returns the address of the url, with the default port of its scheme when it has none.
+func hostWithPort(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		return net.JoinHostPort(u.Hostname(), "443")
+	}
+	return net.JoinHostPort(u.Hostname(), "80")
+}